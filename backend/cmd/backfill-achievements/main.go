@@ -0,0 +1,65 @@
+// Команда backfill-achievements открывает достижения по накопленной истории
+// детей. Нужна после добавления встроенных достижений миграцией: обычная
+// проверка срабатывает только на новые события.
+//
+//	go run ./cmd/backfill-achievements -all
+//	go run ./cmd/backfill-achievements -achievement first_week
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/config"
+	"github.com/soulfeelings/parents-children-contracts/backend/database"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+)
+
+func main() {
+	achievementFlag := flag.String("achievement", "", "ID или код достижения")
+	all := flag.Bool("all", false, "пересчитать все достижения")
+	flag.Parse()
+
+	if (*achievementFlag == "") == !*all {
+		log.Fatal("Укажите -achievement или -all")
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Ошибка загрузки конфигурации:", err)
+	}
+
+	// Подключаемся к базе данных
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatal("Ошибка подключения к базе данных:", err)
+	}
+
+	var achievements []models.Achievement
+	query := db.Order("family_id NULLS FIRST, metric, threshold")
+	if !*all {
+		query = query.Where("id::text = ? OR code = ?", *achievementFlag, *achievementFlag)
+	}
+	if err := query.Find(&achievements).Error; err != nil {
+		log.Fatal("Ошибка получения достижений:", err)
+	}
+	if len(achievements) == 0 {
+		log.Fatalf("Достижение %q не найдено", *achievementFlag)
+	}
+
+	now := time.Now()
+	var total int64
+	for i := range achievements {
+		awarded, err := services.BackfillAchievement(db, &achievements[i], now)
+		total += awarded
+		if err != nil {
+			log.Fatalf("Ошибка пересчета достижения %s: %v", achievements[i].Title, err)
+		}
+		log.Printf("%s: открыто %d", achievements[i].Title, awarded)
+	}
+
+	log.Printf("Пересчитано достижений: %d, открыто: %d", len(achievements), total)
+}
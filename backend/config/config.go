@@ -4,23 +4,32 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/soulfeelings/parents-children-contracts/backend/storage"
 )
 
 type Config struct {
-	DBHost         string
-	DBPort         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	DBSSLMode      string
-	JWTSecret      string
-	JWTExpiration  time.Duration
-	ServerPort     string
-	Environment    string
-	MigrationPath  string
+	DBHost            string
+	DBPort            string
+	DBUser            string
+	DBPassword        string
+	DBName            string
+	DBSSLMode         string
+	JWTSecret         string
+	JWTExpiration     time.Duration
+	RefreshExpiration time.Duration
+	ServerPort        string
+	Environment       string
+	MigrationPath     string
+	FrontendURL       string
+	SchedulerEnabled  bool
+	SchedulerInterval time.Duration
+	TaskGracePeriod   time.Duration
+	Storage           storage.Config
+	AttachmentMaxSize int64
 }
 
 func LoadConfig() (*Config, error) {
@@ -51,25 +60,71 @@ func LoadConfig() (*Config, error) {
 	// Парсим время жизни JWT токена
 	jwtExpiration := os.Getenv("JWT_EXPIRATION")
 	if jwtExpiration == "" {
-		jwtExpiration = "24h"
+		jwtExpiration = "15m"
 	}
 	expiration, err := time.ParseDuration(jwtExpiration)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка парсинга JWT_EXPIRATION: %v", err)
 	}
 
+	// Парсим время жизни refresh-токена (сессии)
+	refreshExpiration := os.Getenv("REFRESH_TOKEN_EXPIRATION")
+	if refreshExpiration == "" {
+		refreshExpiration = "720h"
+	}
+	refreshTTL, err := time.ParseDuration(refreshExpiration)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка парсинга REFRESH_TOKEN_EXPIRATION: %v", err)
+	}
+
+	// Параметры фонового планировщика
+	schedulerInterval, err := durationEnv("SCHEDULER_INTERVAL", "1m")
+	if err != nil {
+		return nil, err
+	}
+	taskGracePeriod, err := durationEnv("TASK_GRACE_PERIOD", "1h")
+	if err != nil {
+		return nil, err
+	}
+
+	// Максимальный размер вложения, по умолчанию 10 МБ
+	attachmentMaxSize := int64(10 << 20)
+	if value := os.Getenv("ATTACHMENT_MAX_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("ошибка парсинга ATTACHMENT_MAX_SIZE: %s", value)
+		}
+		attachmentMaxSize = size
+	}
+
 	config := &Config{
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		DBUser:        os.Getenv("DB_USER"),
-		DBPassword:    os.Getenv("DB_PASSWORD"),
-		DBName:        os.Getenv("DB_NAME"),
-		DBSSLMode:     os.Getenv("DB_SSL_MODE"),
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		JWTExpiration: expiration,
-		ServerPort:    os.Getenv("PORT"),
-		Environment:   env,
-		MigrationPath: migrationPath,
+		DBHost:            os.Getenv("DB_HOST"),
+		DBPort:            os.Getenv("DB_PORT"),
+		DBUser:            os.Getenv("DB_USER"),
+		DBPassword:        os.Getenv("DB_PASSWORD"),
+		DBName:            os.Getenv("DB_NAME"),
+		DBSSLMode:         os.Getenv("DB_SSL_MODE"),
+		JWTSecret:         os.Getenv("JWT_SECRET"),
+		JWTExpiration:     expiration,
+		RefreshExpiration: refreshTTL,
+		ServerPort:        os.Getenv("PORT"),
+		Environment:       env,
+		MigrationPath:     migrationPath,
+		FrontendURL:       os.Getenv("FRONTEND_URL"),
+		SchedulerEnabled:  os.Getenv("SCHEDULER_ENABLED") != "false",
+		SchedulerInterval: schedulerInterval,
+		TaskGracePeriod:   taskGracePeriod,
+		Storage: storage.Config{
+			Driver:      os.Getenv("STORAGE_DRIVER"),
+			LocalPath:   os.Getenv("STORAGE_LOCAL_PATH"),
+			S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+			S3Region:    os.Getenv("S3_REGION"),
+			S3Bucket:    os.Getenv("S3_BUCKET"),
+			S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+			S3PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		},
+		AttachmentMaxSize: attachmentMaxSize,
 	}
 
 	// Проверяем обязательные параметры
@@ -89,11 +144,36 @@ func LoadConfig() (*Config, error) {
 		config.DBSSLMode = "disable"
 	}
 
+	if config.Storage.Driver == "" {
+		config.Storage.Driver = "local"
+	}
+
+	if config.Storage.LocalPath == "" {
+		config.Storage.LocalPath = "uploads"
+	}
+
+	if config.FrontendURL == "" {
+		config.FrontendURL = "http://localhost:5173"
+	}
+
 	return config, nil
 }
 
+// durationEnv читает длительность из переменной окружения со значением по умолчанию
+func durationEnv(name, fallback string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		value = fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка парсинга %s: %v", name, err)
+	}
+	return duration, nil
+}
+
 // GetDSN возвращает строку подключения к базе данных
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
 		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName, c.DBSSLMode)
-} 
\ No newline at end of file
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateAchievementRequest struct {
+	FamilyID    string `json:"family_id" binding:"required"`
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description"`
+	Icon        string `json:"icon" binding:"max=50"`
+	Metric      string `json:"metric" binding:"required"`
+	Threshold   int    `json:"threshold" binding:"required"`
+}
+
+type UpdateAchievementRequest struct {
+	Title       string  `json:"title" binding:"max=255"`
+	Description *string `json:"description"`
+	Icon        *string `json:"icon" binding:"omitempty,max=50"`
+	Metric      string  `json:"metric"`
+	Threshold   *int    `json:"threshold"`
+}
+
+type AchievementResponse struct {
+	Achievement models.Achievement `json:"achievement"`
+	// Сколько детей семьи сразу открыли достижение по своей истории
+	Awarded int64 `json:"awarded,omitempty"`
+}
+
+type AchievementsResponse struct {
+	Achievements []models.Achievement `json:"achievements"`
+	Total        int64                `json:"total"`
+}
+
+type ChildAchievementsResponse struct {
+	ChildID      string                         `json:"child_id"`
+	Achievements []services.AchievementProgress `json:"achievements"`
+	Unlocked     int                            `json:"unlocked"`
+}
+
+func NewAchievementHandlers(db *gorm.DB) *AchievementHandlers {
+	return &AchievementHandlers{db: db}
+}
+
+type AchievementHandlers struct {
+	db *gorm.DB
+}
+
+// Достижения видны всем участникам семьи вместе со встроенными
+func (h *AchievementHandlers) visible(userID interface{}) *gorm.DB {
+	return h.db.Model(&models.Achievement{}).
+		Where("family_id IS NULL OR family_id IN (?)", services.UserFamilies(h.db, userID))
+}
+
+// Поиск семейного достижения, которое пользователь может изменять.
+// Встроенные достижения не изменяются.
+func (h *AchievementHandlers) findEditable(c *gin.Context) (models.Achievement, bool) {
+	userID, _ := c.Get("user_id")
+
+	var achievement models.Achievement
+	if err := h.visible(userID).Where("id = ?", c.Param("id")).First(&achievement).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Достижение не найдено"})
+		return achievement, false
+	}
+	if achievement.BuiltIn() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Встроенные достижения изменять нельзя"})
+		return achievement, false
+	}
+	if !services.IsFamilyParent(h.db, *achievement.FamilyID, userID.(string)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Изменять достижения могут только родители семьи"})
+		return achievement, false
+	}
+	return achievement, true
+}
+
+// backfill открывает достижение детям семьи, которые уже выполнили его
+// условие. Ошибка пересчета не отменяет сохранение достижения: его
+// можно повторить командой backfill-achievements.
+func (h *AchievementHandlers) backfill(achievement *models.Achievement) int64 {
+	awarded, err := services.BackfillAchievement(h.db, achievement, time.Now())
+	if err != nil {
+		log.Printf("Ошибка пересчета достижения %s: %v", achievement.ID, err)
+	}
+	return awarded
+}
+
+// Получение списка достижений: встроенных и семейных
+func (h *AchievementHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.visible(userID)
+	if familyID := c.Query("family_id"); familyID != "" {
+		query = query.Where("family_id = ?", familyID)
+	}
+	if c.Query("builtin") == "true" {
+		query = query.Where("family_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var achievements []models.Achievement
+	if err := query.Order("family_id NULLS FIRST, metric, threshold").Find(&achievements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении достижений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AchievementsResponse{Achievements: achievements, Total: total})
+}
+
+// Получение достижения по ID
+func (h *AchievementHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var achievement models.Achievement
+	if err := h.visible(userID).Where("id = ?", c.Param("id")).First(&achievement).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Достижение не найдено"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AchievementResponse{Achievement: achievement})
+}
+
+// Создание семейного достижения. Дети, которые уже выполнили условие,
+// получают его сразу.
+func (h *AchievementHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateAchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !services.IsFamilyParent(h.db, req.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+
+	now := time.Now()
+	createdBy := userID.(string)
+	achievement := models.Achievement{
+		FamilyID:    &req.FamilyID,
+		Title:       req.Title,
+		Description: req.Description,
+		Icon:        req.Icon,
+		Metric:      req.Metric,
+		Threshold:   req.Threshold,
+		CreatedBy:   &createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if !respondAchievementError(c, services.ValidateAchievement(&achievement)) {
+		return
+	}
+
+	if err := h.db.Create(&achievement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании достижения"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, AchievementResponse{Achievement: achievement, Awarded: h.backfill(&achievement)})
+}
+
+// Изменение семейного достижения. Открытые достижения остаются у детей,
+// при снижении порога его сразу получают те, кто уже выполнил условие.
+func (h *AchievementHandlers) Update(c *gin.Context) {
+	achievement, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title != "" {
+		achievement.Title = req.Title
+	}
+	if req.Description != nil {
+		achievement.Description = *req.Description
+	}
+	if req.Icon != nil {
+		achievement.Icon = *req.Icon
+	}
+	if req.Metric != "" {
+		achievement.Metric = req.Metric
+	}
+	if req.Threshold != nil {
+		achievement.Threshold = *req.Threshold
+	}
+	if !respondAchievementError(c, services.ValidateAchievement(&achievement)) {
+		return
+	}
+
+	achievement.UpdatedAt = time.Now()
+	if err := h.db.Model(&achievement).Updates(map[string]interface{}{
+		"title":       achievement.Title,
+		"description": achievement.Description,
+		"icon":        achievement.Icon,
+		"metric":      achievement.Metric,
+		"threshold":   achievement.Threshold,
+		"updated_at":  achievement.UpdatedAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении достижения"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AchievementResponse{Achievement: achievement, Awarded: h.backfill(&achievement)})
+}
+
+// Удаление семейного достижения; открытые достижения больше не показываются
+func (h *AchievementHandlers) Delete(c *gin.Context) {
+	achievement, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(&achievement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении достижения"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Достижение успешно удалено"})
+}
+
+// Получение достижений ребенка: открытых и тех, к которым он идет
+func (h *AchievementHandlers) Child(c *gin.Context) {
+	childID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	// Ребенок видит только свои достижения, родитель - достижения своих детей
+	if role == "parent" {
+		if !services.IsGuardian(h.db, userID.(string), childID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ребенок не найден"})
+			return
+		}
+	} else if childID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	achievements, err := services.LoadChildAchievements(h.db, childID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении достижений"})
+		return
+	}
+
+	unlocked := 0
+	for _, achievement := range achievements {
+		if achievement.Unlocked {
+			unlocked++
+		}
+	}
+	c.JSON(http.StatusOK, ChildAchievementsResponse{ChildID: childID, Achievements: achievements, Unlocked: unlocked})
+}
+
+// respondAchievementError отвечает на ошибку проверки достижения и сообщает,
+// можно ли продолжать обработку
+func respondAchievementError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrInvalidAchievement) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректное достижение: проверьте показатель и порог"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке достижения"})
+		return false
+	}
+	return true
+}
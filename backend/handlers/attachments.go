@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/soulfeelings/parents-children-contracts/backend/storage"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
+)
+
+// Время действия ссылки на скачивание вложения
+const attachmentURLTTL = 15 * time.Minute
+
+// Разрешенные типы вложений и расширения файлов для них.
+// Тип определяется по содержимому файла, а не по заголовкам запроса.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+type AttachmentsResponse struct {
+	Attachments []models.Attachment `json:"attachments"`
+}
+
+type AttachmentResponse struct {
+	Attachment models.Attachment `json:"attachment"`
+}
+
+func NewAttachmentHandlers(db *gorm.DB, store storage.Storage, maxSize int64) *AttachmentHandlers {
+	return &AttachmentHandlers{db: db, store: store, maxSize: maxSize}
+}
+
+type AttachmentHandlers struct {
+	db      *gorm.DB
+	store   storage.Storage
+	maxSize int64
+}
+
+// Загрузка вложения к задаче
+func (h *AttachmentHandlers) UploadToTask(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, err := h.findTask(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	// Ребенок прикладывает подтверждения только к задачам, которые еще не проверены
+	if role != "parent" && task.Status != "pending" && task.Status != "submitted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "К проверенной задаче нельзя приложить файл"})
+		return
+	}
+
+	h.upload(c, task, nil, userID.(string))
+}
+
+// Загрузка вложения к отправке задачи на проверку
+func (h *AttachmentHandlers) UploadToSubmission(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, err := h.findTask(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	var submission models.TaskSubmission
+	if err := h.db.Where("id = ? AND task_id = ?", c.Param("submissionId"), task.ID).
+		First(&submission).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Отправка на проверку не найдена"})
+		return
+	}
+
+	if submission.ChildID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Приложить файл может только отправивший задачу"})
+		return
+	}
+	if submission.Status != "submitted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Отправка уже проверена"})
+		return
+	}
+
+	h.upload(c, task, &submission.ID, userID.(string))
+}
+
+// upload проверяет размер и тип файла, сохраняет его в хранилище и
+// создает запись о вложении
+func (h *AttachmentHandlers) upload(c *gin.Context, task models.Task, submissionID *string, userID string) {
+	data, contentType, header, ok := readUpload(c, h.maxSize, attachmentTypes, "Недопустимый тип файла: разрешены изображения, PDF и текст")
+	if !ok {
+		return
+	}
+	ext := attachmentTypes[contentType]
+
+	fileName := strings.TrimSpace(filepath.Base(header.Filename))
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		fileName = "file" + ext
+	}
+	if len(fileName) > 255 {
+		fileName = fileName[len(fileName)-255:]
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := models.Attachment{
+		TaskID:       task.ID,
+		SubmissionID: submissionID,
+		UploadedBy:   userID,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		StorageKey:   "tasks/" + task.ID + "/" + uuid.NewString() + ext,
+		Checksum:     hex.EncodeToString(sum[:]),
+		CreatedAt:    time.Now(),
+	}
+
+	if err := h.store.Put(c.Request.Context(), attachment.StorageKey, data, contentType); err != nil {
+		log.Printf("Ошибка сохранения вложения %s: %v", attachment.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении файла"})
+		return
+	}
+
+	if err := h.db.Create(&attachment).Error; err != nil {
+		// Запись не создана - файл в хранилище больше не нужен
+		if err := h.store.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+			log.Printf("Ошибка удаления вложения %s: %v", attachment.StorageKey, err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении файла"})
+		return
+	}
+
+	attachment.DownloadURL = downloadURL(attachment.ID, userID)
+	c.JSON(http.StatusCreated, AttachmentResponse{Attachment: attachment})
+}
+
+// readUpload читает файл из поля file multipart-запроса и проверяет его
+// размер и тип. Тип определяется по содержимому и должен быть в types.
+// При ошибке ответ уже отправлен и ok равен false.
+func readUpload(c *gin.Context, maxSize int64, types map[string]string, unsupported string) (data []byte, contentType string, header *multipart.FileHeader, ok bool) {
+	// Запас сверх лимита на служебные части multipart-запроса
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLargeMessage(maxSize)})
+			return nil, "", nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не передан"})
+		return nil, "", nil, false
+	}
+	defer file.Close()
+
+	data, err = io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка при чтении файла"})
+		return nil, "", nil, false
+	}
+	if int64(len(data)) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLargeMessage(maxSize)})
+		return nil, "", nil, false
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл пустой"})
+		return nil, "", nil, false
+	}
+
+	contentType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	if _, ok := types[contentType]; !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": unsupported})
+		return nil, "", nil, false
+	}
+	return data, contentType, header, true
+}
+
+func tooLargeMessage(maxSize int64) string {
+	return fmt.Sprintf("Размер файла превышает %d МБ", maxSize>>20)
+}
+
+// Список вложений задачи, включая приложенные к отправкам на проверку
+func (h *AttachmentHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, err := h.findTask(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := h.db.Where("task_id = ?", task.ID).
+		Order("created_at").
+		Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении вложений"})
+		return
+	}
+
+	withDownloadURLs(attachments, userID.(string))
+	c.JSON(http.StatusOK, AttachmentsResponse{Attachments: attachments})
+}
+
+// Скачивание вложения по токену авторизации
+func (h *AttachmentHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	attachment, err := h.findAttachment(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вложение не найдено"})
+		return
+	}
+
+	h.serve(c, attachment)
+}
+
+// Скачивание вложения по подписанной ссылке. Ссылку можно открыть без
+// заголовка авторизации (например, в теге img), но доступ пользователя,
+// для которого она выпущена, проверяется заново.
+func (h *AttachmentHandlers) Download(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Query("user")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyDownload(id, userID, expires, c.Query("signature"), time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна или устарела"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна или устарела"})
+		return
+	}
+
+	attachment, err := h.findAttachment(id, user.ID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вложение не найдено"})
+		return
+	}
+
+	h.serve(c, attachment)
+}
+
+// serve отдает содержимое вложения из хранилища
+func (h *AttachmentHandlers) serve(c *gin.Context, attachment models.Attachment) {
+	reader, err := h.store.Get(c.Request.Context(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл вложения не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения вложения %s: %v", attachment.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении файла"})
+		return
+	}
+	defer reader.Close()
+
+	// Изображения и PDF открываются в браузере, остальное скачивается
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") || attachment.ContentType == "application/pdf" {
+		disposition = "inline"
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=300")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, nil)
+}
+
+// Удаление вложения. Ребенок может удалить только свой файл до проверки задачи.
+func (h *AttachmentHandlers) Delete(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	attachment, err := h.findAttachment(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Вложение не найдено"})
+		return
+	}
+
+	if role != "parent" {
+		if attachment.UploadedBy != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Можно удалить только свое вложение"})
+			return
+		}
+		var task models.Task
+		if err := h.db.First(&task, "id = ?", attachment.TaskID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+			return
+		}
+		if task.Status != "pending" && task.Status != "submitted" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Вложение проверенной задачи нельзя удалить"})
+			return
+		}
+	}
+
+	if err := h.db.Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении вложения"})
+		return
+	}
+
+	if err := h.store.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+		log.Printf("Ошибка удаления вложения %s: %v", attachment.StorageKey, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Вложение успешно удалено"})
+}
+
+// Поиск задачи с учетом прав доступа пользователя
+func (h *AttachmentHandlers) findTask(id string, userID, role interface{}) (models.Task, error) {
+	var task models.Task
+	query := h.db.Joins("Contract").Where("tasks.id = ?", id)
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+	err := query.First(&task).Error
+	return task, err
+}
+
+// Поиск вложения с учетом прав доступа к задаче, к которой оно приложено
+func (h *AttachmentHandlers) findAttachment(id string, userID, role interface{}) (models.Attachment, error) {
+	var attachment models.Attachment
+	if err := h.db.First(&attachment, "id = ?", id).Error; err != nil {
+		return attachment, err
+	}
+	_, err := h.findTask(attachment.TaskID, userID, role)
+	return attachment, err
+}
+
+// downloadURL выпускает подписанную ссылку на скачивание вложения
+func downloadURL(attachmentID, userID string) string {
+	expires := time.Now().Add(attachmentURLTTL)
+	query := url.Values{}
+	query.Set("user", userID)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", utils.SignDownload(attachmentID, userID, expires))
+	return "/api/attachments/" + attachmentID + "/download?" + query.Encode()
+}
+
+// withDownloadURLs заполняет ссылки на скачивание для списка вложений
+func withDownloadURLs(attachments []models.Attachment, userID string) {
+	for i := range attachments {
+		attachments[i].DownloadURL = downloadURL(attachments[i].ID, userID)
+	}
+}
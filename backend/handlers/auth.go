@@ -1,31 +1,81 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"github.com/soulfeelings/parents-children-contracts/backend/utils"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=parent child"`
+	Username   string `json:"username" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	Role       string `json:"role" binding:"required,oneof=parent child"`
+	DeviceName string `json:"device_name"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+type PinLoginRequest struct {
+	FamilyCode string `json:"family_code" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Pin        string `json:"pin" binding:"required,numeric,min=4,max=6"`
+	DeviceName string `json:"device_name"`
+}
+
+const (
+	maxPinAttempts  = 5
+	pinLockDuration = 15 * time.Minute
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int64       `json:"expires_in"`
+	User         models.User `json:"user"`
+}
+
+type FamilyMembership struct {
+	FamilyID string `json:"family_id"`
+	Name     string `json:"name"`
+	Code     string `json:"code,omitempty"`
+	Role     string `json:"role"`
+	IsOwner  bool   `json:"is_owner"`
+}
+
+type MeResponse struct {
+	User         models.User        `json:"user"`
+	Families     []FamilyMembership `json:"families"`
+	Capabilities []string           `json:"capabilities"`
+	Scope        string             `json:"scope"`
+	SessionID    string             `json:"session_id"`
+}
+
+type SessionInfo struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+type SessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+	Total    int64         `json:"total"`
 }
 
 func NewAuthHandlers(db *gorm.DB) *AuthHandlers {
@@ -60,28 +110,41 @@ func (h *AuthHandlers) Register(c *gin.Context) {
 	// Создаем нового пользователя
 	user := models.User{
 		Username:  req.Username,
-		Email:     req.Email,
+		Email:     &req.Email,
 		Password:  string(hashedPassword),
 		Role:      req.Role,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if result := h.db.Create(&user); result.Error != nil {
+	// Родитель сразу получает собственную семью
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if user.Role == "parent" {
+			_, err := services.CreateFamily(tx, "Семья "+user.Username, user.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании пользователя"})
 		return
 	}
 
-	// Генерируем JWT токен
-	token, err := utils.GenerateToken(user.ID, user.Role)
+	// Открываем сессию и генерируем пару токенов
+	tokens, err := services.IssueSession(h.db, &user, "", sessionMeta(c, req.DeviceName))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
 	})
 }
 
@@ -105,15 +168,254 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
-	// Генерируем JWT токен
-	token, err := utils.GenerateToken(user.ID, user.Role)
+	// Открываем сессию и генерируем пару токенов
+	tokens, err := services.IssueSession(h.db, &user, "", sessionMeta(c, req.DeviceName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	})
+}
+
+// Вход ребенка по коду семьи, имени пользователя и PIN-коду
+func (h *AuthHandlers) PinLogin(c *gin.Context) {
+	var req PinLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var family models.Family
+	if err := h.db.Where("code = ?", strings.ToUpper(strings.TrimSpace(req.FamilyCode))).First(&family).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код семьи, имя пользователя или PIN-код"})
+		return
+	}
+
+	var user models.User
+	var locked bool
+	var valid bool
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Блокируем строку пользователя, чтобы счетчик попыток обновлялся атомарно
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("username = ? AND role = ? AND pin_hash <> ''", req.Username, "child").
+			Where("id IN (?)", tx.Model(&models.FamilyMember{}).Select("user_id").Where("family_id = ?", family.ID)).
+			First(&user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if user.PinLockedUntil != nil && now.Before(*user.PinLockedUntil) {
+			locked = true
+			return nil
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(req.Pin)) == nil {
+			valid = true
+			return tx.Model(&user).Updates(map[string]interface{}{
+				"failed_pin_attempts": 0,
+				"pin_locked_until":    nil,
+			}).Error
+		}
+
+		// После нескольких неудачных попыток вход по PIN-коду временно блокируется
+		attempts := user.FailedPinAttempts + 1
+		updates := map[string]interface{}{"failed_pin_attempts": attempts}
+		if attempts >= maxPinAttempts {
+			updates["failed_pin_attempts"] = 0
+			updates["pin_locked_until"] = now.Add(pinLockDuration)
+			locked = true
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код семьи, имя пользователя или PIN-код"})
+		return
+	}
+	if locked {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много неудачных попыток, попробуйте позже"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код семьи, имя пользователя или PIN-код"})
+		return
+	}
+
+	// Ребенок, вошедший по PIN-коду, получает токен с ограниченной областью доступа
+	tokens, err := services.IssueSession(h.db, &user, utils.ScopeChild, sessionMeta(c, req.DeviceName))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
 		return
 	}
 
 	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	})
+}
+
+// Сведения об устройстве для новой сессии
+func sessionMeta(c *gin.Context, deviceName string) services.SessionMeta {
+	return services.SessionMeta{
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		DeviceName: deviceName,
+	}
+}
+
+// Обновление пары токенов по refresh-токену
+func (h *AuthHandlers) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, user, err := services.RefreshSession(h.db, req.RefreshToken, sessionMeta(c, ""))
+	if errors.Is(err, services.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh-токен уже использован, сессия завершена"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Недействительный refresh-токен"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	})
+}
+
+// Выход: завершение текущей сессии
+func (h *AuthHandlers) Logout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	if _, err := services.RevokeSession(h.db, userID.(string), sessionID.(string), services.RevokeLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
+// Выход на всех устройствах
+func (h *AuthHandlers) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := services.RevokeUserSessions(h.db, userID.(string), "", services.RevokeLogoutAll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессий"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Все сессии завершены"})
+}
+
+// Получение текущего пользователя с его семьями и возможностями
+func (h *AuthHandlers) Me(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+	scope, _ := c.Get("scope")
+	sessionID, _ := c.Get("session_id")
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	// Семьи загружаются одним запросом вместе с ролью пользователя в них
+	var members []struct {
+		FamilyID string
+		Name     string
+		Code     string
+		OwnerID  string
+		Role     string
+	}
+	if err := h.db.Table("family_members").
+		Select("families.id AS family_id, families.name, families.code, families.owner_id, family_members.role").
+		Joins("JOIN families ON families.id = family_members.family_id AND families.deleted_at IS NULL").
+		Where("family_members.user_id = ?", user.ID).
+		Order("family_members.created_at asc").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении семей"})
+		return
+	}
+
+	families := make([]FamilyMembership, 0, len(members))
+	for _, member := range members {
+		membership := FamilyMembership{
+			FamilyID: member.FamilyID,
+			Name:     member.Name,
+			Role:     member.Role,
+			IsOwner:  member.OwnerID == user.ID,
+		}
+		// Код семьи для входа детей по PIN-коду показываем только родителям
+		if member.Role == "parent" {
+			membership.Code = member.Code
+		}
+		families = append(families, membership)
+	}
+
+	c.JSON(http.StatusOK, MeResponse{
+		User:         user,
+		Families:     families,
+		Capabilities: services.Capabilities(role.(string), scope.(string)),
+		Scope:        scope.(string),
+		SessionID:    sessionID.(string),
+	})
+}
+
+// Получение списка активных сессий пользователя
+func (h *AuthHandlers) Sessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	sessions, err := services.ActiveSessions(h.db, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении сессий"})
+		return
+	}
+
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionInfo{Session: session, Current: session.ID == sessionID})
+	}
+
+	c.JSON(http.StatusOK, SessionsResponse{
+		Sessions: result,
+		Total:    int64(len(result)),
 	})
-} 
\ No newline at end of file
+}
+
+// Завершение одной из сессий пользователя (например, на общем планшете)
+func (h *AuthHandlers) RevokeSession(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	revoked, err := services.RevokeSession(h.db, userID.(string), id, services.RevokeByUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессии"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Сессия не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
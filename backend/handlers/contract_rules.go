@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateContractRuleRequest struct {
+	ContractID string `json:"contract_id" binding:"required"`
+	Name       string `json:"name" binding:"required,max=255"`
+	Trigger    string `json:"trigger" binding:"required"`
+	// По умолчанию task, для итогов периода - contract
+	Scope      string                   `json:"scope"`
+	Conditions []services.RuleCondition `json:"conditions" binding:"dive"`
+	Action     string                   `json:"action" binding:"required"`
+	Points     int                      `json:"points" binding:"min=0"`
+	RewardID   *string                  `json:"reward_id"`
+	Timezone   string                   `json:"timezone"`
+	Enabled    *bool                    `json:"enabled"`
+}
+
+type UpdateContractRuleRequest struct {
+	Name       string                    `json:"name" binding:"max=255"`
+	Trigger    string                    `json:"trigger"`
+	Scope      string                    `json:"scope"`
+	Conditions *[]services.RuleCondition `json:"conditions" binding:"omitempty,dive"`
+	Action     string                    `json:"action"`
+	Points     *int                      `json:"points" binding:"omitempty,min=0"`
+	RewardID   *string                   `json:"reward_id"`
+	Timezone   string                    `json:"timezone"`
+	Enabled    *bool                     `json:"enabled"`
+}
+
+type ContractRuleResponse struct {
+	Rule models.ContractRule `json:"rule"`
+}
+
+type ContractRulesResponse struct {
+	Rules []models.ContractRule `json:"rules"`
+	Total int64                 `json:"total"`
+}
+
+type RuleFiringsResponse struct {
+	Firings []models.RuleFiring `json:"firings"`
+	Total   int64               `json:"total"`
+}
+
+func NewContractRuleHandlers(db *gorm.DB) *ContractRuleHandlers {
+	return &ContractRuleHandlers{db: db}
+}
+
+type ContractRuleHandlers struct {
+	db *gorm.DB
+}
+
+// Поиск правила с учетом прав доступа пользователя
+func (h *ContractRuleHandlers) findRule(id string, userID, role interface{}) (models.ContractRule, error) {
+	var rule models.ContractRule
+	query := h.db.Preload("Contract").
+		Joins("Contract").
+		Where("contract_rules.id = ?", id)
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	err := query.First(&rule).Error
+	return rule, err
+}
+
+// buildRule собирает правило из запроса и проверяет его. Награда, которую
+// открывает правило, должна быть в том же контракте.
+func (h *ContractRuleHandlers) buildRule(req *CreateContractRuleRequest, contract *models.Contract) (*models.ContractRule, error) {
+	rule := &models.ContractRule{
+		ContractID: contract.ID,
+		Name:       req.Name,
+		Trigger:    req.Trigger,
+		Scope:      req.Scope,
+		Action:     req.Action,
+		Points:     req.Points,
+		RewardID:   req.RewardID,
+		Timezone:   req.Timezone,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if rule.Scope == "" {
+		rule.Scope = models.RuleScopeTask
+		if rule.Trigger == models.RuleSchedule {
+			rule.Scope = models.RuleScopeContract
+		}
+	}
+	if err := h.setConditions(rule, req.Conditions); err != nil {
+		return nil, err
+	}
+	if err := h.checkReward(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (h *ContractRuleHandlers) setConditions(rule *models.ContractRule, conditions []services.RuleCondition) error {
+	if conditions == nil {
+		conditions = []services.RuleCondition{}
+	}
+	if err := services.ValidateRule(rule, conditions); err != nil {
+		return err
+	}
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return err
+	}
+	rule.Conditions = models.JSON(data)
+	return nil
+}
+
+func (h *ContractRuleHandlers) checkReward(rule *models.ContractRule) error {
+	if rule.RewardID == nil {
+		return nil
+	}
+	var count int64
+	h.db.Model(&models.Reward{}).Where("id = ? AND contract_id = ?", *rule.RewardID, rule.ContractID).Count(&count)
+	if count == 0 {
+		return services.ErrInvalidContractRule
+	}
+	return nil
+}
+
+// respondRuleError отвечает на ошибку проверки правила и сообщает,
+// можно ли продолжать обработку
+func respondRuleError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrInvalidContractRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректное правило: проверьте событие, область, условия, действие и награду"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке правила"})
+		return false
+	}
+	return true
+}
+
+// Создание правила контракта
+func (h *ContractRuleHandlers) Create(c *gin.Context) {
+	var req CreateContractRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять правила в контракт в текущем статусе"})
+		return
+	}
+
+	rule, err := h.buildRule(&req, &contract)
+	if !respondRuleError(c, err) {
+		return
+	}
+
+	now := time.Now()
+	createdBy := userID.(string)
+	rule.CreatedBy = &createdBy
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Contract").Create(rule).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, createdBy, c.ClientIP(), "Добавлено правило «"+rule.Name+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании правила"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ContractRuleResponse{Rule: *rule})
+}
+
+// Получение списка правил
+func (h *ContractRuleHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.db.Model(&models.ContractRule{}).
+		Joins("Contract").
+		Where("Contract.deleted_at IS NULL")
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("contract_rules.contract_id = ?", contractID)
+	}
+	if trigger := c.Query("trigger"); trigger != "" {
+		query = query.Where("contract_rules.trigger = ?", trigger)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rules []models.ContractRule
+	if err := query.Order("contract_rules.created_at").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении правил"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractRulesResponse{Rules: rules, Total: total})
+}
+
+// Получение правила по ID
+func (h *ContractRuleHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	rule, err := h.findRule(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractRuleResponse{Rule: rule})
+}
+
+// Изменение правила. Новые условия действуют только на будущие события,
+// уже начисленные бонусы и штрафы остаются.
+func (h *ContractRuleHandlers) Update(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	rule, err := h.findRule(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	var req UpdateContractRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contract := rule.Contract
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять правила в контракте в текущем статусе"})
+		return
+	}
+
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Trigger != "" {
+		rule.Trigger = req.Trigger
+	}
+	if req.Scope != "" {
+		rule.Scope = req.Scope
+	}
+	if req.Action != "" {
+		rule.Action = req.Action
+		// Баллы и награда относятся к разным действиям
+		if req.Action == models.RuleUnlockReward {
+			rule.Points = 0
+		} else {
+			rule.RewardID = nil
+		}
+	}
+	if req.Points != nil {
+		rule.Points = *req.Points
+	}
+	if req.RewardID != nil {
+		rule.RewardID = req.RewardID
+	}
+	if req.Timezone != "" {
+		rule.Timezone = req.Timezone
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	conditions, err := services.ParseConditions(rule.Conditions)
+	if req.Conditions != nil {
+		conditions, err = *req.Conditions, nil
+	}
+	if err == nil {
+		err = h.setConditions(&rule, conditions)
+	}
+	if err == nil {
+		err = h.checkReward(&rule)
+	}
+	if !respondRuleError(c, err) {
+		return
+	}
+
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rule).Omit("Contract").Updates(map[string]interface{}{
+			"name":       rule.Name,
+			"trigger":    rule.Trigger,
+			"scope":      rule.Scope,
+			"conditions": rule.Conditions,
+			"action":     rule.Action,
+			"points":     rule.Points,
+			"reward_id":  rule.RewardID,
+			"timezone":   rule.Timezone,
+			"enabled":    rule.Enabled,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Изменено правило «"+rule.Name+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении правила"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractRuleResponse{Rule: rule})
+}
+
+// Удаление правила; начисленные по нему баллы остаются
+func (h *ContractRuleHandlers) Delete(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	rule, err := h.findRule(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	if !rule.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять правила из контракта в текущем статусе"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, rule.ContractID, userID.(string), c.ClientIP(), "Удалено правило «"+rule.Name+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении правила"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Правило успешно удалено"})
+}
+
+// Получение срабатываний правила
+func (h *ContractRuleHandlers) Firings(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	rule, err := h.findRule(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	var firings []models.RuleFiring
+	if err := h.db.Where("rule_id = ?", rule.ID).Order("fired_at DESC").Find(&firings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении срабатываний правила"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RuleFiringsResponse{Firings: firings, Total: int64(len(firings))})
+}
+
+// Пробный прогон сохраненного правила по истории его контракта
+func (h *ContractRuleHandlers) DryRun(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	rule, err := h.findRule(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	h.dryRun(c, &rule, &rule.Contract)
+}
+
+// Пробный прогон нового правила по истории контракта без сохранения
+func (h *ContractRuleHandlers) DryRunDraft(c *gin.Context) {
+	var req CreateContractRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	rule, err := h.buildRule(&req, &contract)
+	if !respondRuleError(c, err) {
+		return
+	}
+	rule.CreatedAt = time.Now()
+
+	h.dryRun(c, rule, &contract)
+}
+
+func (h *ContractRuleHandlers) dryRun(c *gin.Context, rule *models.ContractRule, contract *models.Contract) {
+	history, err := services.LoadTaskHistory(h.db, contract.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при загрузке истории контракта"})
+		return
+	}
+
+	result, err := services.DryRunRule(rule, contract, history, time.Now())
+	if !respondRuleError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
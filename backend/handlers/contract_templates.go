@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateTemplateRequest struct {
+	Title        string                     `json:"title" binding:"required,max=255"`
+	Description  string                     `json:"description"`
+	DurationDays int                        `json:"duration_days" binding:"required,min=1"`
+	Tasks        []services.TaskBlueprint   `json:"tasks" binding:"max=100,dive"`
+	Rewards      []services.RewardBlueprint `json:"rewards" binding:"max=100,dive"`
+}
+
+type UpdateTemplateRequest struct {
+	Title        string                      `json:"title" binding:"max=255"`
+	Description  *string                     `json:"description"`
+	DurationDays int                         `json:"duration_days" binding:"omitempty,min=1"`
+	Tasks        *[]services.TaskBlueprint   `json:"tasks"`
+	Rewards      *[]services.RewardBlueprint `json:"rewards"`
+}
+
+type SaveTemplateRequest struct {
+	ContractID  string `json:"contract_id" binding:"required"`
+	Title       string `json:"title" binding:"max=255"`
+	Description string `json:"description"`
+}
+
+type InstantiateTemplateRequest struct {
+	ChildIDs []string `json:"child_ids" binding:"required,min=1,max=20,dive,required"`
+	// Дата начала контрактов, по умолчанию сегодня
+	StartDate time.Time `json:"start_date"`
+	// Название контрактов, по умолчанию название шаблона
+	Title string `json:"title" binding:"max=255"`
+}
+
+type TemplateResponse struct {
+	Template models.ContractTemplate `json:"template"`
+}
+
+type TemplatesResponse struct {
+	Templates []models.ContractTemplate `json:"templates"`
+	Total     int64                     `json:"total"`
+}
+
+var errChildNotGuarded = errors.New("родитель не является опекуном ребенка")
+
+func NewContractTemplateHandlers(db *gorm.DB) *ContractTemplateHandlers {
+	return &ContractTemplateHandlers{db: db}
+}
+
+type ContractTemplateHandlers struct {
+	db *gorm.DB
+}
+
+// Шаблоны, доступные родителю: встроенные, свои и шаблоны других родителей его семей
+func (h *ContractTemplateHandlers) visible(userID interface{}) *gorm.DB {
+	return h.db.Model(&models.ContractTemplate{}).
+		Where("(is_builtin OR owner_id = ? OR owner_id IN (?))", userID, services.FamilyParents(h.db, userID))
+}
+
+// Поиск шаблона, который может изменять только его владелец
+func (h *ContractTemplateHandlers) findOwned(c *gin.Context) (models.ContractTemplate, bool) {
+	userID, _ := c.Get("user_id")
+
+	var template models.ContractTemplate
+	if err := h.visible(userID).Where("id = ?", c.Param("id")).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return template, false
+	}
+	if template.IsBuiltin || template.OwnerID == nil || *template.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Изменять шаблон может только его автор"})
+		return template, false
+	}
+	return template, true
+}
+
+// Получение библиотеки шаблонов
+func (h *ContractTemplateHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.visible(userID)
+	switch c.Query("source") {
+	case "builtin":
+		query = query.Where("is_builtin")
+	case "own":
+		query = query.Where("owner_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var templates []models.ContractTemplate
+	if err := query.Order("is_builtin DESC, title").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении шаблонов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TemplatesResponse{Templates: templates, Total: total})
+}
+
+// Получение шаблона по ID
+func (h *ContractTemplateHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var template models.ContractTemplate
+	if err := h.visible(userID).Where("id = ?", c.Param("id")).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TemplateResponse{Template: template})
+}
+
+// Создание шаблона
+func (h *ContractTemplateHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ownerID := userID.(string)
+	now := time.Now()
+	template := models.ContractTemplate{
+		OwnerID:      &ownerID,
+		Title:        req.Title,
+		Description:  req.Description,
+		DurationDays: req.DurationDays,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := services.SetBlueprints(&template, req.Tasks, req.Rewards); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные задачи или награды шаблона: проверьте сроки и правила повторения"})
+		return
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании шаблона"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TemplateResponse{Template: template})
+}
+
+// Сохранение существующего контракта как шаблона
+func (h *ContractTemplateHandlers) SaveFromContract(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).
+		First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	tasks, rewards, durationDays, err := services.BlueprintsFromContract(h.db, &contract)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при чтении условий контракта"})
+		return
+	}
+
+	ownerID := userID.(string)
+	now := time.Now()
+	template := models.ContractTemplate{
+		OwnerID:      &ownerID,
+		Title:        contract.Title,
+		Description:  contract.Description,
+		DurationDays: durationDays,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if req.Title != "" {
+		template.Title = req.Title
+	}
+	if req.Description != "" {
+		template.Description = req.Description
+	}
+	if err := services.SetBlueprints(&template, tasks, rewards); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт нельзя сохранить как шаблон: слишком долгий срок или некорректные задачи"})
+		return
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании шаблона"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TemplateResponse{Template: template})
+}
+
+// Обновление шаблона. Переданные списки задач и наград заменяют прежние целиком.
+func (h *ContractTemplateHandlers) Update(c *gin.Context) {
+	var req UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, ok := h.findOwned(c)
+	if !ok {
+		return
+	}
+
+	tasks, rewards, err := services.TemplateBlueprints(&template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при чтении шаблона"})
+		return
+	}
+
+	if req.Title != "" {
+		template.Title = req.Title
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.DurationDays > 0 {
+		template.DurationDays = req.DurationDays
+	}
+	if req.Tasks != nil {
+		tasks = *req.Tasks
+	}
+	if req.Rewards != nil {
+		rewards = *req.Rewards
+	}
+	if err := services.SetBlueprints(&template, tasks, rewards); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные задачи или награды шаблона: проверьте сроки и правила повторения"})
+		return
+	}
+
+	if err := h.db.Model(&template).Updates(map[string]interface{}{
+		"title":         template.Title,
+		"description":   template.Description,
+		"duration_days": template.DurationDays,
+		"tasks":         template.Tasks,
+		"rewards":       template.Rewards,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении шаблона"})
+		return
+	}
+
+	h.db.First(&template, "id = ?", template.ID)
+
+	c.JSON(http.StatusOK, TemplateResponse{Template: template})
+}
+
+// Удаление шаблона
+func (h *ContractTemplateHandlers) Delete(c *gin.Context) {
+	template, ok := h.findOwned(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении шаблона"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Шаблон успешно удален"})
+}
+
+// Создание по шаблону черновиков контрактов сразу для нескольких детей.
+// Контракты создаются в одной транзакции: либо все, либо ни одного.
+func (h *ContractTemplateHandlers) Instantiate(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req InstantiateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var template models.ContractTemplate
+	if err := h.visible(userID).Where("id = ?", c.Param("id")).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Шаблон не найден"})
+		return
+	}
+
+	start := req.StartDate
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	var contracts []models.Contract
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		seen := make(map[string]bool, len(req.ChildIDs))
+		for _, childID := range req.ChildIDs {
+			if seen[childID] {
+				continue
+			}
+			seen[childID] = true
+
+			if !services.IsGuardian(tx, userID.(string), childID) {
+				return errChildNotGuarded
+			}
+			contract, err := services.InstantiateTemplate(tx, &template, userID.(string), childID, req.Title, start)
+			if err != nil {
+				return err
+			}
+			contracts = append(contracts, *contract)
+		}
+		return nil
+	})
+	if errors.Is(err, errChildNotGuarded) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Вы не являетесь опекуном одного из детей"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании контрактов по шаблону"})
+		return
+	}
+
+	// Загружаем связанные данные
+	for i := range contracts {
+		h.db.Preload("Parent").Preload("Child").
+			Preload("Tasks").Preload("Rewards").
+			First(&contracts[i], "id = ?", contracts[i].ID)
+	}
+
+	c.JSON(http.StatusCreated, ContractsResponse{Contracts: contracts, Total: int64(len(contracts))})
+}
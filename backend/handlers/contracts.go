@@ -1,28 +1,86 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CreateContractRequest struct {
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description"`
-	ChildID     string    `json:"child_id" binding:"required"`
-	StartDate   time.Time `json:"start_date" binding:"required"`
-	EndDate     time.Time `json:"end_date" binding:"required"`
+	Title           string    `json:"title" binding:"required"`
+	Description     string    `json:"description"`
+	ChildID         string    `json:"child_id" binding:"required"`
+	StartDate       time.Time `json:"start_date" binding:"required"`
+	EndDate         time.Time `json:"end_date" binding:"required"`
+	AutoRenew       bool      `json:"auto_renew"`
+	CarryOverPoints bool      `json:"carry_over_points"`
 }
 
 type UpdateContractRequest struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
-	Status      string    `json:"status" binding:"omitempty,oneof=active completed terminated"`
+	// Устаревший способ смены статуса, предпочтительнее /transitions
+	Status      string    `json:"status" binding:"omitempty,oneof=completed terminated"`
 	StartDate   time.Time `json:"start_date"`
 	EndDate     time.Time `json:"end_date"`
+	// Настройки продления в условия контракта не входят
+	AutoRenew       *bool `json:"auto_renew"`
+	CarryOverPoints *bool `json:"carry_over_points"`
+}
+
+type RenewContractRequest struct {
+	// Перенести непотраченные баллы; по умолчанию - настройка контракта
+	CarryOver *bool `json:"carry_over"`
+	// Сразу предложить новый контракт на подпись
+	Propose bool `json:"propose"`
+	// Начало нового периода; по умолчанию - окончание текущего контракта
+	StartDate time.Time `json:"start_date"`
+}
+
+type ProposeContractRequest struct {
+	CoSignerID string `json:"co_signer_id"`
+}
+
+type SignContractRequest struct {
+	// Хеш условий, которые видел подписант; если условия успели измениться,
+	// подпись не принимается
+	TermsHash string `json:"terms_hash"`
+}
+
+type SignaturesResponse struct {
+	Status         string                     `json:"status"`
+	TermsHash      string                     `json:"terms_hash"`
+	Signatures     []models.ContractSignature `json:"signatures"`
+	MissingSigners []string                   `json:"missing_signers"`
+}
+
+var (
+	errContractNotFound = errors.New("контракт не найден")
+	errContractStatus   = errors.New("недопустимый статус контракта")
+	errTermsChanged     = errors.New("условия контракта изменились")
+	errNotSigner        = errors.New("пользователь не является стороной контракта")
+)
+
+type ContractVersionsResponse struct {
+	Versions []models.ContractVersion `json:"versions"`
+	Total    int64                    `json:"total"`
+}
+
+type ContractVersionResponse struct {
+	Version models.ContractVersion `json:"version"`
+}
+
+type ContractDiffResponse struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes []services.TermsChange `json:"changes"`
 }
 
 type ContractResponse struct {
@@ -64,16 +122,24 @@ func (h *ContractHandlers) Create(c *gin.Context) {
 		return
 	}
 
+	// Контракт можно заключить только с ребенком, опекуном которого является родитель
+	if !services.IsGuardian(h.db, parentID.(string), child.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Вы не являетесь опекуном этого ребенка"})
+		return
+	}
+
 	contract := models.Contract{
-		Title:       req.Title,
-		Description: req.Description,
-		ParentID:    parentID.(string),
-		ChildID:     req.ChildID,
-		Status:      "active",
-		StartDate:   req.StartDate,
-		EndDate:     req.EndDate,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Title:           req.Title,
+		Description:     req.Description,
+		ParentID:        parentID.(string),
+		ChildID:         req.ChildID,
+		Status:          models.ContractDraft,
+		StartDate:       req.StartDate,
+		EndDate:         req.EndDate,
+		AutoRenew:       req.AutoRenew,
+		CarryOverPoints: req.CarryOverPoints,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	if result := h.db.Create(&contract); result.Error != nil {
@@ -96,9 +162,10 @@ func (h *ContractHandlers) List(c *gin.Context) {
 
 	// Фильтруем контракты в зависимости от роли пользователя
 	if role == "parent" {
-		query = query.Where("parent_id = ?", userID)
+		query = query.Where("child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
-		query = query.Where("child_id = ?", userID)
+		// Черновики ребенку не показываем, пока родитель не предложит их на подпись
+		query = query.Where("child_id = ? AND status <> ?", userID, models.ContractDraft)
 	}
 
 	var total int64
@@ -132,9 +199,9 @@ func (h *ContractHandlers) Get(c *gin.Context) {
 		Preload("Tasks").Preload("Rewards")
 
 	if role == "parent" {
-		query = query.Where("id = ? AND parent_id = ?", id, userID)
+		query = query.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(h.db, userID))
 	} else {
-		query = query.Where("id = ? AND child_id = ?", id, userID)
+		query = query.Where("id = ? AND child_id = ? AND status <> ?", id, userID, models.ContractDraft)
 	}
 
 	if err := query.First(&contract).Error; err != nil {
@@ -155,7 +222,7 @@ func (h *ContractHandlers) Update(c *gin.Context) {
 	query := h.db
 
 	if role == "parent" {
-		query = query.Where("id = ? AND parent_id = ?", id, userID)
+		query = query.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("id = ? AND child_id = ?", id, userID)
 	}
@@ -173,7 +240,13 @@ func (h *ContractHandlers) Update(c *gin.Context) {
 
 	// Обновляем только разрешенные поля в зависимости от роли
 	updates := make(map[string]interface{})
+	termsChanged := false
 	if role == "parent" {
+		termsChanged = req.Title != "" || req.Description != "" || !req.StartDate.IsZero() || !req.EndDate.IsZero()
+		if termsChanged && !contract.TermsEditable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Условия завершенного контракта изменить нельзя"})
+			return
+		}
 		if req.Title != "" {
 			updates["title"] = req.Title
 		}
@@ -186,16 +259,48 @@ func (h *ContractHandlers) Update(c *gin.Context) {
 		if !req.EndDate.IsZero() {
 			updates["end_date"] = req.EndDate
 		}
+		if req.AutoRenew != nil {
+			updates["auto_renew"] = *req.AutoRenew
+		}
+		if req.CarryOverPoints != nil {
+			updates["carry_over_points"] = *req.CarryOverPoints
+		}
 	}
-	
-	// Статус могут менять оба (и родитель, и ребенок)
+
+	// Смена статуса выполняется по правилам жизненного цикла контракта,
+	// как и через /transitions
+	var transition *services.Transition
 	if req.Status != "" {
-		updates["status"] = req.Status
+		var err error
+		transition, err = services.ContractMachine.ActionTo(contract.Status, req.Status, role.(string))
+		if !respondTransitionError(c, err, "Недопустимая смена статуса контракта") {
+			return
+		}
 	}
 
 	updates["updated_at"] = time.Now()
 
-	if err := h.db.Model(&contract).Updates(updates).Error; err != nil {
+	// Изменение условий предложенного или подписанного контракта
+	// фиксируется новой версией и требует повторного подписания
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&contract).Updates(updates).Error; err != nil {
+			return err
+		}
+		if termsChanged {
+			if err := services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Изменены условия контракта"); err != nil {
+				return err
+			}
+		}
+		if transition != nil {
+			_, err := services.TransitionContract(tx, contract.ID, transition.Action, role.(string), userID.(string), c.ClientIP(), "")
+			return err
+		}
+		return nil
+	})
+	if !respondTransitionError(c, err, "Недопустимая смена статуса контракта") {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении контракта"})
 		return
 	}
@@ -220,16 +325,400 @@ func (h *ContractHandlers) Delete(c *gin.Context) {
 	}
 
 	var contract models.Contract
-	if err := h.db.Where("id = ? AND parent_id = ?", id, userID).First(&contract).Error; err != nil {
+	if err := h.db.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
 		return
 	}
 
-	// Используем soft delete (благодаря gorm.DeletedAt в модели)
-	if err := h.db.Delete(&contract).Error; err != nil {
+	// Используем soft delete (благодаря gorm.DeletedAt в модели).
+	// Удаленное продление можно создать заново.
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&contract).Error; err != nil {
+			return err
+		}
+		if contract.PredecessorID == nil {
+			return nil
+		}
+		return tx.Model(&models.Contract{}).
+			Where("id = ? AND successor_id = ?", *contract.PredecessorID, contract.ID).
+			Update("successor_id", nil).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении контракта"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Контракт успешно удален"})
-} 
\ No newline at end of file
+}
+
+// Продление контракта на следующий период
+func (h *ContractHandlers) Renew(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req RenewContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	if !req.StartDate.IsZero() && req.StartDate.Before(contract.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Новый период должен начинаться после начала текущего"})
+		return
+	}
+
+	opts := services.RenewOptions{
+		CarryOver: contract.CarryOverPoints,
+		Propose:   req.Propose,
+		StartDate: req.StartDate,
+	}
+	if req.CarryOver != nil {
+		opts.CarryOver = *req.CarryOver
+	}
+
+	var successor *models.Contract
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		successor, err = services.RenewContract(tx, contract.ID, userID.(string), c.ClientIP(), opts)
+		return err
+	})
+	switch {
+	case errors.Is(err, services.ErrAlreadyRenewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Контракт уже продлен"})
+		return
+	case errors.Is(err, services.ErrNotRenewable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Продлить можно только действующий или завершенный контракт"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при продлении контракта"})
+		return
+	}
+
+	h.db.Preload("Parent").Preload("Child").
+		Preload("Tasks").Preload("Rewards").Preload("Signatures").
+		First(successor, "id = ?", successor.ID)
+
+	c.JSON(http.StatusCreated, ContractResponse{Contract: *successor})
+}
+
+// Предложение контракта на подпись: родитель фиксирует условия и подписывает их первым
+func (h *ContractHandlers) Propose(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var req ProposeContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var contract models.Contract
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(tx, userID)).
+			First(&contract).Error; err != nil {
+			return errContractNotFound
+		}
+		if contract.Status != models.ContractDraft {
+			return errContractStatus
+		}
+
+		// Второй родитель должен быть опекуном того же ребенка
+		if req.CoSignerID != "" {
+			if req.CoSignerID == userID.(string) || !services.IsGuardian(tx, req.CoSignerID, contract.ChildID) {
+				return errNotSigner
+			}
+			contract.CoSignerID = &req.CoSignerID
+		} else {
+			contract.CoSignerID = nil
+		}
+
+		return services.ProposeContract(tx, &contract, userID.(string), c.ClientIP())
+	})
+
+	switch {
+	case errors.Is(err, errContractNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	case errors.Is(err, errContractStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Предложить на подпись можно только черновик контракта"})
+		return
+	case errors.Is(err, errNotSigner):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Второй подписант должен быть другим опекуном ребенка"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отправке контракта на подпись"})
+		return
+	}
+
+	h.db.Preload("Parent").Preload("Child").
+		Preload("Tasks").Preload("Rewards").Preload("Signatures").
+		First(&contract, "id = ?", contract.ID)
+
+	c.JSON(http.StatusOK, ContractResponse{Contract: contract})
+}
+
+// Подписание контракта одной из его сторон: ребенком, родителем-автором
+// или вторым родителем
+func (h *ContractHandlers) Sign(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var req SignContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var contract models.Contract
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND (child_id = ? OR co_signer_id = ? OR child_id IN (?))",
+				id, userID, userID, services.GuardedChildren(tx, userID)).
+			First(&contract).Error; err != nil {
+			return errContractNotFound
+		}
+		if contract.Status != models.ContractPendingSignature {
+			return errContractStatus
+		}
+
+		// Подписывается ровно то, что было предложено
+		terms, err := services.LoadTerms(tx, &contract)
+		if err != nil {
+			return err
+		}
+		if terms.Hash() != contract.TermsHash || (req.TermsHash != "" && req.TermsHash != contract.TermsHash) {
+			return errTermsChanged
+		}
+
+		signerRole, err := services.SignerRole(&contract, userID.(string))
+		if err != nil {
+			return errNotSigner
+		}
+		return services.SignContract(tx, &contract, userID.(string), signerRole, c.ClientIP())
+	})
+
+	switch {
+	case errors.Is(err, errContractNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	case errors.Is(err, errContractStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт не ожидает подписи"})
+		return
+	case errors.Is(err, errTermsChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Условия контракта изменились, ознакомьтесь с ними повторно"})
+		return
+	case errors.Is(err, errNotSigner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Подписать контракт может только его сторона"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подписании контракта"})
+		return
+	}
+
+	h.db.Preload("Parent").Preload("Child").
+		Preload("Tasks").Preload("Rewards").Preload("Signatures").
+		First(&contract, "id = ?", contract.ID)
+
+	c.JSON(http.StatusOK, ContractResponse{Contract: contract})
+}
+
+// Отзыв предложения: контракт возвращается в черновик для правок.
+// Изменения уже подписанного контракта отозвать нельзя, их можно только
+// скорректировать новой версией.
+func (h *ContractHandlers) Withdraw(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var contract models.Contract
+		if err := tx.Select("id").
+			Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(tx, userID)).
+			First(&contract).Error; err != nil {
+			return errContractNotFound
+		}
+		_, err := services.TransitionContract(tx, contract.ID, services.ContractWithdraw, services.ActorParent, userID.(string), c.ClientIP(), "")
+		return err
+	})
+	switch {
+	case errors.Is(err, errContractNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Отозвать можно только новый контракт, ожидающий подписи"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отзыве контракта"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Контракт возвращен в черновик"})
+}
+
+// Получение подписей контракта
+func (h *ContractHandlers) Signatures(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	contract, err := h.findContract(id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	var signatures []models.ContractSignature
+	if err := h.db.Preload("Signer").
+		Where("contract_id = ?", contract.ID).
+		Order("signed_at asc").
+		Find(&signatures).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении подписей"})
+		return
+	}
+
+	missing := []string{}
+	if contract.Status == models.ContractPendingSignature {
+		if missing, err = services.MissingSigners(h.db, &contract); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении подписей"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, SignaturesResponse{
+		Status:         contract.Status,
+		TermsHash:      contract.TermsHash,
+		Signatures:     signatures,
+		MissingSigners: missing,
+	})
+}
+
+// Поиск контракта для просмотра с учетом прав доступа пользователя.
+// Черновики ребенку не показываются.
+func (h *ContractHandlers) findContract(id string, userID, role interface{}) (models.Contract, error) {
+	var contract models.Contract
+	query := h.db
+	if role == "parent" {
+		query = query.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("id = ? AND child_id = ? AND status <> ?", id, userID, models.ContractDraft)
+	}
+	err := query.First(&contract).Error
+	return contract, err
+}
+
+// Получение истории версий контракта
+func (h *ContractHandlers) Versions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	query := h.db.Model(&models.ContractVersion{}).Where("contract_id = ?", contract.ID)
+
+	var total int64
+	query.Count(&total)
+
+	// Снимки условий в списке не отдаем, они доступны по номеру версии
+	var versions []models.ContractVersion
+	if err := query.Omit("terms").Order("version desc").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении версий контракта"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractVersionsResponse{
+		Versions: versions,
+		Total:    total,
+	})
+}
+
+// Получение версии контракта по номеру
+func (h *ContractHandlers) Version(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер версии"})
+		return
+	}
+
+	var version models.ContractVersion
+	if err := h.db.Where("contract_id = ? AND version = ?", contract.ID, number).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractVersionResponse{Version: version})
+}
+
+// Сравнение двух версий контракта. По умолчанию текущая версия
+// сравнивается с предыдущей.
+func (h *ContractHandlers) Diff(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	to, err := strconv.Atoi(c.DefaultQuery("to", strconv.Itoa(contract.CurrentVersion)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер версии"})
+		return
+	}
+	from, err := strconv.Atoi(c.DefaultQuery("from", strconv.Itoa(to-1)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный номер версии"})
+		return
+	}
+
+	var versions []models.ContractVersion
+	if err := h.db.Where("contract_id = ? AND version IN ?", contract.ID, []int{from, to}).
+		Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении версий контракта"})
+		return
+	}
+
+	terms := make(map[int]*services.ContractTerms, 2)
+	for i := range versions {
+		t, err := services.VersionTerms(&versions[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при чтении версии контракта"})
+			return
+		}
+		terms[versions[i].Version] = t
+	}
+
+	// Первая версия сравнивается с пустыми условиями
+	if from == 0 {
+		terms[0] = &services.ContractTerms{}
+	}
+	if terms[from] == nil || terms[to] == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContractDiffResponse{
+		From:    from,
+		To:      to,
+		Changes: services.DiffTerms(terms[from], terms[to]),
+	})
+}
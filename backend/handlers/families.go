@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type CreateFamilyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateFamilyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type CreateGuardianshipRequest struct {
+	ParentID string `json:"parent_id" binding:"required"`
+	ChildID  string `json:"child_id" binding:"required"`
+}
+
+type CreateChildAccountRequest struct {
+	Username    string `json:"username" binding:"required"`
+	DisplayName string `json:"display_name" binding:"required"`
+	Pin         string `json:"pin" binding:"required,numeric,min=4,max=6"`
+}
+
+type ResetChildPinRequest struct {
+	Pin string `json:"pin" binding:"required,numeric,min=4,max=6"`
+}
+
+type ChildAccountResponse struct {
+	Child      models.User `json:"child"`
+	FamilyCode string      `json:"family_code"`
+}
+
+type FamilyResponse struct {
+	Family        models.Family         `json:"family"`
+	Guardianships []models.Guardianship `json:"guardianships"`
+}
+
+type FamiliesResponse struct {
+	Families []models.Family `json:"families"`
+	Total    int64           `json:"total"`
+}
+
+type GuardianshipResponse struct {
+	Guardianship models.Guardianship `json:"guardianship"`
+}
+
+type ChildInfo struct {
+	Child     models.User   `json:"child"`
+	Guardians []models.User `json:"guardians"`
+}
+
+type ChildrenResponse struct {
+	Children []ChildInfo `json:"children"`
+	Total    int64       `json:"total"`
+}
+
+func NewFamilyHandlers(db *gorm.DB) *FamilyHandlers {
+	return &FamilyHandlers{db: db}
+}
+
+type FamilyHandlers struct {
+	db *gorm.DB
+}
+
+// Создание новой семьи
+func (h *FamilyHandlers) Create(c *gin.Context) {
+	var req CreateFamilyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var family *models.Family
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		family, err = services.CreateFamily(tx, req.Name, userID.(string))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании семьи"})
+		return
+	}
+
+	h.db.Preload("Owner").Preload("Members.User").First(family, "id = ?", family.ID)
+
+	c.JSON(http.StatusCreated, FamilyResponse{Family: *family})
+}
+
+// Получение списка семей пользователя
+func (h *FamilyHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.db.Model(&models.Family{}).
+		Where("id IN (?)", h.db.Model(&models.FamilyMember{}).Select("family_id").Where("user_id = ?", userID))
+
+	var total int64
+	query.Count(&total)
+
+	var families []models.Family
+	result := query.Preload("Owner").Preload("Members.User").
+		Order("created_at asc").
+		Find(&families)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении семей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, FamiliesResponse{
+		Families: families,
+		Total:    total,
+	})
+}
+
+// Получение семьи по ID
+func (h *FamilyHandlers) Get(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	if !services.IsFamilyMember(h.db, id, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	var family models.Family
+	if err := h.db.Preload("Owner").Preload("Members.User").First(&family, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	var guardianships []models.Guardianship
+	h.db.Preload("Parent").Preload("Child").
+		Where("family_id = ?", family.ID).
+		Find(&guardianships)
+
+	c.JSON(http.StatusOK, FamilyResponse{Family: family, Guardianships: guardianships})
+}
+
+// Обновление семьи
+func (h *FamilyHandlers) Update(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var family models.Family
+	if err := h.db.Where("id = ? AND owner_id = ?", id, userID).First(&family).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+
+	var req UpdateFamilyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Model(&family).Updates(map[string]interface{}{
+		"name":       req.Name,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении семьи"})
+		return
+	}
+
+	h.db.Preload("Owner").Preload("Members.User").First(&family, "id = ?", family.ID)
+
+	c.JSON(http.StatusOK, FamilyResponse{Family: family})
+}
+
+// Исключение участника из семьи. Владелец может исключить любого участника,
+// остальные участники могут только покинуть семью сами.
+func (h *FamilyHandlers) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+	memberID := c.Param("userId")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var family models.Family
+	if err := h.db.First(&family, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	if family.OwnerID != userID && memberID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Только владелец может исключать участников семьи"})
+		return
+	}
+
+	// Ребенок не может сам выйти из-под опеки
+	if memberID == userID && role == "child" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ребенок не может сам покинуть семью"})
+		return
+	}
+
+	if memberID == family.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Владелец не может покинуть свою семью"})
+		return
+	}
+
+	if !services.IsFamilyMember(h.db, family.ID, memberID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Участник не найден"})
+		return
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		return services.RemoveFamilyMember(tx, family.ID, memberID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при исключении участника"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Участник исключен из семьи"})
+}
+
+// Назначение родителя опекуном ребенка внутри семьи
+func (h *FamilyHandlers) CreateGuardianship(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var family models.Family
+	if err := h.db.Where("id = ? AND owner_id = ?", id, userID).First(&family).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+
+	var req CreateGuardianshipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var parentCount, childCount int64
+	h.db.Model(&models.FamilyMember{}).
+		Where("family_id = ? AND user_id = ? AND role = ?", family.ID, req.ParentID, "parent").
+		Count(&parentCount)
+	h.db.Model(&models.FamilyMember{}).
+		Where("family_id = ? AND user_id = ? AND role = ?", family.ID, req.ChildID, "child").
+		Count(&childCount)
+	if parentCount == 0 || childCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Родитель и ребенок должны состоять в семье"})
+		return
+	}
+
+	if services.IsGuardian(h.db, req.ParentID, req.ChildID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Опекунство уже существует"})
+		return
+	}
+
+	guardianship := models.Guardianship{
+		FamilyID:  family.ID,
+		ParentID:  req.ParentID,
+		ChildID:   req.ChildID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.db.Create(&guardianship).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании опекунства"})
+		return
+	}
+
+	h.db.Preload("Parent").Preload("Child").First(&guardianship, "id = ?", guardianship.ID)
+
+	c.JSON(http.StatusCreated, GuardianshipResponse{Guardianship: guardianship})
+}
+
+// Удаление опекунства
+func (h *FamilyHandlers) DeleteGuardianship(c *gin.Context) {
+	id := c.Param("id")
+	guardianshipID := c.Param("guardianshipId")
+	userID, _ := c.Get("user_id")
+
+	var family models.Family
+	if err := h.db.Where("id = ? AND owner_id = ?", id, userID).First(&family).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+
+	result := h.db.Where("id = ? AND family_id = ?", guardianshipID, family.ID).Delete(&models.Guardianship{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении опекунства"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Опекунство не найдено"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Опекунство успешно удалено"})
+}
+
+// Получение списка детей, опекуном которых является родитель
+func (h *FamilyHandlers) Children(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var children []models.User
+	if err := h.db.Where("id IN (?)", services.GuardedChildren(h.db, userID)).
+		Order("username asc").
+		Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении списка детей"})
+		return
+	}
+
+	result := make([]ChildInfo, 0, len(children))
+	for _, child := range children {
+		var guardians []models.User
+		h.db.Where("id IN (?)", h.db.Model(&models.Guardianship{}).Select("parent_id").Where("child_id = ?", child.ID)).
+			Order("username asc").
+			Find(&guardians)
+		result = append(result, ChildInfo{Child: child, Guardians: guardians})
+	}
+
+	c.JSON(http.StatusOK, ChildrenResponse{
+		Children: result,
+		Total:    int64(len(result)),
+	})
+}
+
+// Создание детского аккаунта родителем: без email, вход по PIN-коду
+func (h *FamilyHandlers) CreateChildAccount(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var family models.Family
+	if err := h.db.First(&family, "id = ?", id).Error; err != nil ||
+		!services.IsFamilyMember(h.db, family.ID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	var req CreateChildAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.User{}).Where("username = ?", req.Username).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Пользователь с таким username уже существует"})
+		return
+	}
+
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании PIN-кода"})
+		return
+	}
+
+	// Пароль для входа по email детскому аккаунту не нужен, сохраняем случайный
+	secret, err := utils.RandomCode(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании аккаунта"})
+		return
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании пароля"})
+		return
+	}
+
+	parentID := userID.(string)
+	child := models.User{
+		Username:    req.Username,
+		DisplayName: req.DisplayName,
+		Password:    string(passwordHash),
+		PinHash:     string(pinHash),
+		Role:        "child",
+		ManagedBy:   &parentID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&child).Error; err != nil {
+			return err
+		}
+		return services.AddFamilyMember(tx, family.ID, child.ID, "child")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании аккаунта ребенка"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ChildAccountResponse{Child: child, FamilyCode: family.Code})
+}
+
+// Смена PIN-кода ребенка опекуном, заодно снимает блокировку входа
+func (h *FamilyHandlers) ResetChildPin(c *gin.Context) {
+	id := c.Param("id")
+	childID := c.Param("childId")
+	userID, _ := c.Get("user_id")
+
+	if !services.IsFamilyMember(h.db, id, childID) || !services.IsGuardian(h.db, userID.(string), childID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ребенок не найден"})
+		return
+	}
+
+	var req ResetChildPinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании PIN-кода"})
+		return
+	}
+
+	if err := h.db.Model(&models.User{}).Where("id = ?", childID).Updates(map[string]interface{}{
+		"pin_hash":            string(pinHash),
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+		"updated_at":          time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении PIN-кода"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN-код успешно обновлен"})
+}
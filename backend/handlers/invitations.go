@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	invitationCodeLength = 10
+	defaultInvitationTTL = 72 * time.Hour
+	maxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	errInvitationNotFound = errors.New("приглашение не найдено")
+	errInvitationInactive = errors.New("приглашение недействительно")
+	errInvitationRole     = errors.New("приглашение предназначено для другой роли")
+	errInvitationEmail    = errors.New("приглашение предназначено для другого email")
+	errAlreadyMember      = errors.New("пользователь уже состоит в семье")
+)
+
+type CreateInvitationRequest struct {
+	Role     string `json:"role" binding:"required,oneof=parent child"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	TTLHours int    `json:"ttl_hours" binding:"omitempty,min=1"`
+}
+
+type AcceptInvitationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type InvitationResponse struct {
+	Invitation models.FamilyInvitation `json:"invitation"`
+	Link       string                  `json:"link,omitempty"`
+}
+
+type InvitationsResponse struct {
+	Invitations []models.FamilyInvitation `json:"invitations"`
+	Total       int64                     `json:"total"`
+}
+
+func NewInvitationHandlers(db *gorm.DB, frontendURL string) *InvitationHandlers {
+	return &InvitationHandlers{db: db, frontendURL: strings.TrimRight(frontendURL, "/")}
+}
+
+type InvitationHandlers struct {
+	db          *gorm.DB
+	frontendURL string
+}
+
+func (h *InvitationHandlers) link(code string) string {
+	return h.frontendURL + "/invite/" + code
+}
+
+// Создание приглашения в семью
+func (h *InvitationHandlers) Create(c *gin.Context) {
+	familyID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	if !services.IsFamilyMember(h.db, familyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := defaultInvitationTTL
+	if req.TTLHours > 0 {
+		ttl = time.Duration(req.TTLHours) * time.Hour
+	}
+	if ttl > maxInvitationTTL {
+		ttl = maxInvitationTTL
+	}
+
+	code, err := utils.RandomCode(invitationCodeLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации кода приглашения"})
+		return
+	}
+
+	now := time.Now()
+	invitation := models.FamilyInvitation{
+		FamilyID:  familyID,
+		Code:      code,
+		Role:      req.Role,
+		Email:     strings.ToLower(req.Email),
+		InvitedBy: userID.(string),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	if err := h.db.Omit("Family").Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании приглашения"})
+		return
+	}
+
+	h.db.Preload("Family").First(&invitation, "id = ?", invitation.ID)
+	invitation.Status = invitation.State(now)
+
+	c.JSON(http.StatusCreated, InvitationResponse{
+		Invitation: invitation,
+		Link:       h.link(invitation.Code),
+	})
+}
+
+// Получение списка приглашений семьи
+func (h *InvitationHandlers) List(c *gin.Context) {
+	familyID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	if !services.IsFamilyMember(h.db, familyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена"})
+		return
+	}
+
+	query := h.db.Model(&models.FamilyInvitation{}).Where("family_id = ?", familyID)
+
+	var total int64
+	query.Count(&total)
+
+	var invitations []models.FamilyInvitation
+	if err := query.Order("created_at desc").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении приглашений"})
+		return
+	}
+
+	// Фильтруем по состоянию, если указано
+	status := c.Query("status")
+	now := time.Now()
+	filtered := make([]models.FamilyInvitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		invitation.Status = invitation.State(now)
+		if status == "" || invitation.Status == status {
+			filtered = append(filtered, invitation)
+		}
+	}
+	if status != "" {
+		total = int64(len(filtered))
+	}
+
+	c.JSON(http.StatusOK, InvitationsResponse{
+		Invitations: filtered,
+		Total:       total,
+	})
+}
+
+// Просмотр приглашения по коду перед принятием
+func (h *InvitationHandlers) Preview(c *gin.Context) {
+	code := strings.ToUpper(c.Param("code"))
+
+	var invitation models.FamilyInvitation
+	if err := h.db.Preload("Family").Where("code = ?", code).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Приглашение не найдено"})
+		return
+	}
+	invitation.Status = invitation.State(time.Now())
+
+	c.JSON(http.StatusOK, InvitationResponse{Invitation: invitation})
+}
+
+// Отзыв приглашения
+func (h *InvitationHandlers) Revoke(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var invitation models.FamilyInvitation
+	if err := h.db.First(&invitation, "id = ?", id).Error; err != nil ||
+		!services.IsFamilyMember(h.db, invitation.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Приглашение не найдено"})
+		return
+	}
+
+	now := time.Now()
+	if invitation.State(now) != models.InvitationPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Можно отозвать только действующее приглашение"})
+		return
+	}
+
+	result := h.db.Model(&models.FamilyInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отзыве приглашения"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Можно отозвать только действующее приглашение"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Приглашение отозвано"})
+}
+
+// Принятие приглашения: пользователь вступает в семью и получает связи опекунства
+func (h *InvitationHandlers) Accept(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	var invitation models.FamilyInvitation
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Блокируем приглашение, чтобы его нельзя было принять дважды
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", strings.ToUpper(strings.TrimSpace(req.Code))).
+			First(&invitation).Error; err != nil {
+			return errInvitationNotFound
+		}
+
+		now := time.Now()
+		if invitation.State(now) != models.InvitationPending {
+			return errInvitationInactive
+		}
+		if invitation.Role != user.Role {
+			return errInvitationRole
+		}
+		if invitation.Email != "" && (user.Email == nil || !strings.EqualFold(invitation.Email, *user.Email)) {
+			return errInvitationEmail
+		}
+		if services.IsFamilyMember(tx, invitation.FamilyID, user.ID) {
+			return errAlreadyMember
+		}
+
+		if err := services.AddFamilyMember(tx, invitation.FamilyID, user.ID, user.Role); err != nil {
+			return err
+		}
+
+		invitation.AcceptedAt = &now
+		invitation.AcceptedBy = &user.ID
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"accepted_at": now,
+			"accepted_by": user.ID,
+		}).Error
+	})
+
+	switch {
+	case errors.Is(err, errInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Приглашение не найдено"})
+		return
+	case errors.Is(err, errInvitationInactive):
+		c.JSON(http.StatusGone, gin.H{"error": "Приглашение уже использовано, отозвано или истекло"})
+		return
+	case errors.Is(err, errInvitationRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Приглашение предназначено для другой роли"})
+		return
+	case errors.Is(err, errInvitationEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Приглашение предназначено для другого пользователя"})
+		return
+	case errors.Is(err, errAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": "Вы уже состоите в этой семье"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при принятии приглашения"})
+		return
+	}
+
+	var family models.Family
+	h.db.Preload("Owner").Preload("Members.User").First(&family, "id = ?", invitation.FamilyID)
+
+	c.JSON(http.StatusOK, FamilyResponse{Family: family})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateAdjustmentRequest struct {
+	ContractID  string `json:"contract_id" binding:"required"`
+	Amount      int    `json:"amount" binding:"required"`
+	Description string `json:"description" binding:"required"`
+}
+
+type BalanceResponse struct {
+	ChildID   string                     `json:"child_id"`
+	Total     int                        `json:"total"`
+	Saved     int                        `json:"saved"`
+	Contracts []services.ContractBalance `json:"contracts"`
+}
+
+type LedgerResponse struct {
+	Entries []models.LedgerEntry `json:"entries"`
+	Total   int64                `json:"total"`
+}
+
+type LedgerEntryResponse struct {
+	Entry   models.LedgerEntry `json:"entry"`
+	Balance int                `json:"balance"`
+}
+
+func NewLedgerHandlers(db *gorm.DB) *LedgerHandlers {
+	return &LedgerHandlers{db: db}
+}
+
+type LedgerHandlers struct {
+	db *gorm.DB
+}
+
+// Получение баланса ребенка
+func (h *LedgerHandlers) Balance(c *gin.Context) {
+	childID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	// Ребенок видит только свой баланс, родитель - баланс своих детей
+	if role == "parent" {
+		if !services.IsGuardian(h.db, userID.(string), childID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ребенок не найден"})
+			return
+		}
+	} else if childID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	balances, err := services.Balances(h.db, childID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении баланса"})
+		return
+	}
+
+	// Фильтруем по контракту, если указан
+	if contractID := c.Query("contract_id"); contractID != "" {
+		filtered := make([]services.ContractBalance, 0, 1)
+		for _, b := range balances {
+			if b.ContractID == contractID {
+				filtered = append(filtered, b)
+			}
+		}
+		balances = filtered
+	}
+
+	total, saved := 0, 0
+	for _, b := range balances {
+		total += b.Balance
+		saved += b.Saved
+	}
+
+	c.JSON(http.StatusOK, BalanceResponse{
+		ChildID:   childID,
+		Total:     total,
+		Saved:     saved,
+		Contracts: balances,
+	})
+}
+
+// Получение журнала баллов
+func (h *LedgerHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.db.Model(&models.LedgerEntry{}).
+		Joins("JOIN contracts ON contracts.id = points_ledger.contract_id")
+
+	// Фильтруем записи в зависимости от роли пользователя
+	if role == "parent" {
+		query = query.Where("contracts.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("points_ledger.child_id = ?", userID)
+	}
+
+	if childID := c.Query("child_id"); childID != "" {
+		query = query.Where("points_ledger.child_id = ?", childID)
+	}
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("points_ledger.contract_id = ?", contractID)
+	}
+	if entryType := c.Query("type"); entryType != "" {
+		query = query.Where("points_ledger.entry_type = ?", entryType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var entries []models.LedgerEntry
+	result := query.Select("points_ledger.*").
+		Order("points_ledger.created_at desc").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении журнала баллов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LedgerResponse{
+		Entries: entries,
+		Total:   total,
+	})
+}
+
+// Ручная корректировка баланса родителем
+func (h *LedgerHandlers) Adjust(c *gin.Context) {
+	var req CreateAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	var entry *models.LedgerEntry
+	var balance int
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = services.Adjust(tx, contract.ChildID, contract.ID, req.Amount, req.Description, userID.(string))
+		if err != nil {
+			return err
+		}
+		balance, err = services.Balance(tx, contract.ChildID, contract.ID)
+		return err
+	})
+	if errors.Is(err, services.ErrInsufficientPoints) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недостаточно баллов для списания"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при корректировке баланса"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, LedgerEntryResponse{Entry: *entry, Balance: balance})
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errProposalNotFound = errors.New("предложение не найдено")
+	errProposalClosed   = errors.New("предложение уже рассмотрено")
+	errProposalOpen     = errors.New("по контракту уже есть открытое предложение")
+	errProposalOutdated = errors.New("условия контракта изменились после предложения")
+	errNotCounterparty  = errors.New("предложение рассматривает другая сторона")
+)
+
+type CreateProposalRequest struct {
+	Message string                    `json:"message"`
+	Changes []services.ProposalChange `json:"changes" binding:"required,min=1,dive"`
+}
+
+type RespondProposalRequest struct {
+	Message string `json:"message"`
+}
+
+type ProposalResponse struct {
+	Proposal models.ContractProposal `json:"proposal"`
+}
+
+type ProposalsResponse struct {
+	Proposals []models.ContractProposal `json:"proposals"`
+	Total     int64                     `json:"total"`
+}
+
+func NewProposalHandlers(db *gorm.DB) *ProposalHandlers {
+	return &ProposalHandlers{db: db}
+}
+
+type ProposalHandlers struct {
+	db *gorm.DB
+}
+
+// Поиск контракта, по которому пользователь может вести переговоры
+func (h *ProposalHandlers) lockContract(tx *gorm.DB, id string, userID, role interface{}) (models.Contract, error) {
+	var contract models.Contract
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if role == "parent" {
+		query = query.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(tx, userID))
+	} else {
+		query = query.Where("id = ? AND child_id = ?", id, userID)
+	}
+	if err := query.First(&contract).Error; err != nil {
+		return contract, errContractNotFound
+	}
+	// Обсуждать можно только предложенный или действующий контракт
+	if !contract.Versioned() || !contract.TermsEditable() {
+		return contract, errContractStatus
+	}
+	return contract, nil
+}
+
+// Блокирует открытое предложение и проверяет, что отвечает на него другая сторона
+func (h *ProposalHandlers) lockForResponse(tx *gorm.DB, id string, userID, role interface{}) (models.ContractProposal, models.Contract, error) {
+	var proposal models.ContractProposal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&proposal, "id = ?", id).Error; err != nil {
+		return proposal, models.Contract{}, errProposalNotFound
+	}
+
+	contract, err := h.lockContract(tx, proposal.ContractID, userID, role)
+	if errors.Is(err, errContractNotFound) {
+		return proposal, contract, errProposalNotFound
+	}
+	if err != nil {
+		return proposal, contract, err
+	}
+
+	if proposal.Status != models.ProposalOpen {
+		return proposal, contract, errProposalClosed
+	}
+	if proposal.AuthorRole == role {
+		return proposal, contract, errNotCounterparty
+	}
+	return proposal, contract, nil
+}
+
+func (h *ProposalHandlers) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errContractNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+	case errors.Is(err, errContractStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Обсуждать можно только предложенный или действующий контракт"})
+	case errors.Is(err, errProposalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Предложение не найдено"})
+	case errors.Is(err, errProposalClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Предложение уже рассмотрено"})
+	case errors.Is(err, errProposalOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": "По контракту уже есть открытое предложение"})
+	case errors.Is(err, errProposalOutdated):
+		c.JSON(http.StatusConflict, gin.H{"error": "Условия контракта изменились, сделайте встречное предложение"})
+	case errors.Is(err, errNotCounterparty):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Предложение должна рассмотреть другая сторона"})
+	case errors.Is(err, services.ErrInvalidProposal):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Предложение не соответствует условиям контракта"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// Создает открытое предложение по заблокированному контракту
+func (h *ProposalHandlers) create(tx *gorm.DB, contract *models.Contract, req *CreateProposalRequest, authorID, authorRole string, parentProposalID *string) (*models.ContractProposal, error) {
+	var open int64
+	tx.Model(&models.ContractProposal{}).
+		Where("contract_id = ? AND status = ?", contract.ID, models.ProposalOpen).
+		Count(&open)
+	if open > 0 {
+		return nil, errProposalOpen
+	}
+
+	if err := services.ValidateProposal(tx, contract.ID, req.Changes); err != nil {
+		return nil, err
+	}
+	changes, err := json.Marshal(req.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	proposal := &models.ContractProposal{
+		ContractID:       contract.ID,
+		ParentProposalID: parentProposalID,
+		AuthorID:         authorID,
+		AuthorRole:       authorRole,
+		Status:           models.ProposalOpen,
+		Message:          req.Message,
+		Changes:          models.JSON(changes),
+		BaseVersion:      contract.CurrentVersion,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := tx.Omit("Author").Create(proposal).Error; err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// Закрывает предложение с указанным итогом
+func (h *ProposalHandlers) resolve(tx *gorm.DB, proposal *models.ContractProposal, status, userID, message string) error {
+	now := time.Now()
+	proposal.Status = status
+	proposal.ResolvedBy = &userID
+	proposal.ResolvedAt = &now
+	proposal.ResponseMessage = message
+	return tx.Model(proposal).Updates(map[string]interface{}{
+		"status":           status,
+		"resolved_by":      userID,
+		"resolved_at":      now,
+		"response_message": message,
+		"updated_at":       now,
+	}).Error
+}
+
+func (h *ProposalHandlers) reply(c *gin.Context, status int, proposal *models.ContractProposal) {
+	h.db.Preload("Author").First(proposal, "id = ?", proposal.ID)
+	c.JSON(status, ProposalResponse{Proposal: *proposal})
+}
+
+// Получение всей переписки по условиям контракта
+func (h *ProposalHandlers) List(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var contract models.Contract
+	query := h.db
+	if role == "parent" {
+		query = query.Where("id = ? AND child_id IN (?)", id, services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("id = ? AND child_id = ? AND status <> ?", id, userID, models.ContractDraft)
+	}
+	if err := query.First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	query = h.db.Model(&models.ContractProposal{}).Where("contract_id = ?", contract.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var proposals []models.ContractProposal
+	if err := query.Preload("Author").Order("created_at asc").Find(&proposals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении предложений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProposalsResponse{
+		Proposals: proposals,
+		Total:     total,
+	})
+}
+
+// Создание предложения об изменении условий контракта
+func (h *ProposalHandlers) Create(c *gin.Context) {
+	var req CreateProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var proposal *models.ContractProposal
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		contract, err := h.lockContract(tx, c.Param("id"), userID, role)
+		if err != nil {
+			return err
+		}
+		proposal, err = h.create(tx, &contract, &req, userID.(string), role.(string), nil)
+		return err
+	})
+	if err != nil {
+		h.respondError(c, err, "Ошибка при создании предложения")
+		return
+	}
+
+	h.reply(c, http.StatusCreated, proposal)
+}
+
+// Принятие предложения: изменения применяются к контракту новой версией
+func (h *ProposalHandlers) Accept(c *gin.Context) {
+	var req RespondProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var proposal models.ContractProposal
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var contract models.Contract
+		var err error
+		proposal, contract, err = h.lockForResponse(tx, c.Param("id"), userID, role)
+		if err != nil {
+			return err
+		}
+
+		// Предложение составлено к конкретной версии условий
+		if proposal.BaseVersion != contract.CurrentVersion {
+			return errProposalOutdated
+		}
+
+		changes, err := services.ProposalChanges(&proposal)
+		if err != nil {
+			return err
+		}
+		if err := services.ValidateProposal(tx, contract.ID, changes); err != nil {
+			return err
+		}
+		if err := services.ApplyProposal(tx, contract.ID, changes); err != nil {
+			return err
+		}
+
+		// Принявшая сторона подписывает новую версию условий
+		if err := services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Принято предложение об изменении условий"); err != nil {
+			return err
+		}
+
+		// Родитель, предложивший эти условия, согласен с ними и подписывает их тоже,
+		// если он является стороной контракта
+		if err := tx.First(&contract, "id = ?", contract.ID).Error; err != nil {
+			return err
+		}
+		if proposal.AuthorRole == "parent" && contract.Status == models.ContractPendingSignature {
+			if signerRole, err := services.SignerRole(&contract, proposal.AuthorID); err == nil {
+				if err := services.SignContract(tx, &contract, proposal.AuthorID, signerRole, ""); err != nil {
+					return err
+				}
+			}
+		}
+
+		proposal.AppliedVersion = &contract.CurrentVersion
+		if err := tx.Model(&proposal).Update("applied_version", contract.CurrentVersion).Error; err != nil {
+			return err
+		}
+		return h.resolve(tx, &proposal, models.ProposalAccepted, userID.(string), req.Message)
+	})
+	if err != nil {
+		h.respondError(c, err, "Ошибка при принятии предложения")
+		return
+	}
+
+	h.reply(c, http.StatusOK, &proposal)
+}
+
+// Отклонение предложения
+func (h *ProposalHandlers) Reject(c *gin.Context) {
+	var req RespondProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var proposal models.ContractProposal
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		proposal, _, err = h.lockForResponse(tx, c.Param("id"), userID, role)
+		if err != nil {
+			return err
+		}
+		return h.resolve(tx, &proposal, models.ProposalRejected, userID.(string), req.Message)
+	})
+	if err != nil {
+		h.respondError(c, err, "Ошибка при отклонении предложения")
+		return
+	}
+
+	h.reply(c, http.StatusOK, &proposal)
+}
+
+// Встречное предложение: исходное закрывается, открывается новое от другой стороны
+func (h *ProposalHandlers) Counter(c *gin.Context) {
+	var req CreateProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var counter *models.ContractProposal
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		proposal, contract, err := h.lockForResponse(tx, c.Param("id"), userID, role)
+		if err != nil {
+			return err
+		}
+		if err := h.resolve(tx, &proposal, models.ProposalCountered, userID.(string), req.Message); err != nil {
+			return err
+		}
+		counter, err = h.create(tx, &contract, &req, userID.(string), role.(string), &proposal.ID)
+		return err
+	})
+	if err != nil {
+		h.respondError(c, err, "Ошибка при создании встречного предложения")
+		return
+	}
+
+	h.reply(c, http.StatusCreated, counter)
+}
+
+// Отзыв собственного предложения автором
+func (h *ProposalHandlers) Withdraw(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := h.db.Model(&models.ContractProposal{}).
+		Where("id = ? AND author_id = ? AND status = ?", c.Param("id"), userID, models.ProposalOpen).
+		Updates(map[string]interface{}{
+			"status":      models.ProposalWithdrawn,
+			"resolved_by": userID,
+			"resolved_at": time.Now(),
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отзыве предложения"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Открытое предложение не найдено"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Предложение отозвано"})
+}
@@ -0,0 +1,649 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/soulfeelings/parents-children-contracts/backend/storage"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
+)
+
+// Разрешенные типы изображений наград каталога
+var catalogImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type CreateCategoryRequest struct {
+	FamilyID string `json:"family_id" binding:"required"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Position int    `json:"position" binding:"min=0"`
+}
+
+type UpdateCategoryRequest struct {
+	Name     string `json:"name" binding:"max=100"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+}
+
+type CreateCatalogRewardRequest struct {
+	FamilyID    string  `json:"family_id" binding:"required"`
+	CategoryID  *string `json:"category_id"`
+	Title       string  `json:"title" binding:"required,max=255"`
+	Description string  `json:"description"`
+	Points      int     `json:"points" binding:"min=0"`
+}
+
+type UpdateCatalogRewardRequest struct {
+	// Пустая строка убирает награду из категории
+	CategoryID  *string `json:"category_id"`
+	Title       string  `json:"title" binding:"max=255"`
+	Description *string `json:"description"`
+	Points      *int    `json:"points" binding:"omitempty,min=0"`
+}
+
+type AssignCatalogRewardRequest struct {
+	ContractIDs []string `json:"contract_ids" binding:"required,min=1,max=20,dive,required"`
+	// Стоимость в этих контрактах, по умолчанию каталожная
+	Points *int `json:"points" binding:"omitempty,min=0"`
+}
+
+type CategoryResponse struct {
+	Category models.RewardCategory `json:"category"`
+}
+
+type CategoriesResponse struct {
+	Categories []models.RewardCategory `json:"categories"`
+	Total      int64                   `json:"total"`
+}
+
+type CatalogRewardResponse struct {
+	CatalogReward models.CatalogReward `json:"catalog_reward"`
+}
+
+type CatalogRewardsResponse struct {
+	CatalogRewards []models.CatalogReward `json:"catalog_rewards"`
+	Total          int64                  `json:"total"`
+}
+
+var errCategoryNotFound = errors.New("категория не найдена")
+
+func NewRewardCatalogHandlers(db *gorm.DB, store storage.Storage, maxSize int64) *RewardCatalogHandlers {
+	return &RewardCatalogHandlers{db: db, store: store, maxSize: maxSize}
+}
+
+type RewardCatalogHandlers struct {
+	db      *gorm.DB
+	store   storage.Storage
+	maxSize int64
+}
+
+// Каталог доступен всем участникам семьи, изменять его могут только родители
+func (h *RewardCatalogHandlers) visible(model interface{}, userID interface{}) *gorm.DB {
+	return h.db.Model(model).Where("family_id IN (?)", services.UserFamilies(h.db, userID))
+}
+
+// Поиск записи каталога, которую пользователь может изменять
+func (h *RewardCatalogHandlers) findEditable(c *gin.Context) (models.CatalogReward, bool) {
+	userID, _ := c.Get("user_id")
+
+	var item models.CatalogReward
+	if err := h.visible(&models.CatalogReward{}, userID).Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда каталога не найдена"})
+		return item, false
+	}
+	if !services.IsFamilyParent(h.db, item.FamilyID, userID.(string)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Изменять каталог могут только родители семьи"})
+		return item, false
+	}
+	return item, true
+}
+
+// checkCategory проверяет, что категория существует и принадлежит семье
+func (h *RewardCatalogHandlers) checkCategory(familyID string, categoryID *string) error {
+	if categoryID == nil {
+		return nil
+	}
+	var count int64
+	h.db.Model(&models.RewardCategory{}).
+		Where("id = ? AND family_id = ?", *categoryID, familyID).
+		Count(&count)
+	if count == 0 {
+		return errCategoryNotFound
+	}
+	return nil
+}
+
+// categoryNameTaken проверяет, есть ли в семье другая категория с таким названием
+func (h *RewardCatalogHandlers) categoryNameTaken(familyID, name, exceptID string) bool {
+	var count int64
+	h.db.Model(&models.RewardCategory{}).
+		Where("family_id = ? AND LOWER(name) = LOWER(?) AND id::text <> ?", familyID, name, exceptID).
+		Count(&count)
+	return count > 0
+}
+
+// Получение категорий каталога
+func (h *RewardCatalogHandlers) ListCategories(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.visible(&models.RewardCategory{}, userID)
+	if familyID := c.Query("family_id"); familyID != "" {
+		query = query.Where("family_id = ?", familyID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var categories []models.RewardCategory
+	if err := query.Order("position, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении категорий"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CategoriesResponse{Categories: categories, Total: total})
+}
+
+// Создание категории каталога
+func (h *RewardCatalogHandlers) CreateCategory(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !services.IsFamilyParent(h.db, req.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+
+	if h.categoryNameTaken(req.FamilyID, req.Name, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Категория с таким названием уже есть"})
+		return
+	}
+
+	now := time.Now()
+	category := models.RewardCategory{
+		FamilyID:  req.FamilyID,
+		Name:      req.Name,
+		Position:  req.Position,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.db.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании категории"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CategoryResponse{Category: category})
+}
+
+// Обновление категории каталога
+func (h *RewardCatalogHandlers) UpdateCategory(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var category models.RewardCategory
+	if err := h.visible(&models.RewardCategory{}, userID).Where("id = ?", c.Param("id")).First(&category).Error; err != nil ||
+		!services.IsFamilyParent(h.db, category.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Категория не найдена или недостаточно прав"})
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if req.Name != "" {
+		if h.categoryNameTaken(category.FamilyID, req.Name, category.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Категория с таким названием уже есть"})
+			return
+		}
+		updates["name"] = req.Name
+	}
+	if req.Position != nil {
+		updates["position"] = *req.Position
+	}
+	if err := h.db.Model(&category).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении категории"})
+		return
+	}
+
+	h.db.First(&category, "id = ?", category.ID)
+
+	c.JSON(http.StatusOK, CategoryResponse{Category: category})
+}
+
+// Удаление категории; награды из нее остаются в каталоге без категории
+func (h *RewardCatalogHandlers) DeleteCategory(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var category models.RewardCategory
+	if err := h.visible(&models.RewardCategory{}, userID).Where("id = ?", c.Param("id")).First(&category).Error; err != nil ||
+		!services.IsFamilyParent(h.db, category.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Категория не найдена или недостаточно прав"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CatalogReward{}).
+			Where("category_id = ?", category.ID).
+			Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении категории"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Категория успешно удалена"})
+}
+
+// Получение каталога наград
+func (h *RewardCatalogHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := h.visible(&models.CatalogReward{}, userID)
+	if familyID := c.Query("family_id"); familyID != "" {
+		query = query.Where("family_id = ?", familyID)
+	}
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		query = query.Where("category_id = ?", categoryID)
+	}
+	if search := c.Query("q"); search != "" {
+		query = query.Where("title ILIKE ?", "%"+search+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.CatalogReward
+	if err := query.Preload("Category").Order("title").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении каталога наград"})
+		return
+	}
+
+	for i := range items {
+		withImageURL(&items[i], userID.(string))
+	}
+	c.JSON(http.StatusOK, CatalogRewardsResponse{CatalogRewards: items, Total: total})
+}
+
+// Получение награды каталога по ID
+func (h *RewardCatalogHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var item models.CatalogReward
+	if err := h.visible(&models.CatalogReward{}, userID).Preload("Category").
+		Where("id = ?", c.Param("id")).
+		First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда каталога не найдена"})
+		return
+	}
+
+	withImageURL(&item, userID.(string))
+	c.JSON(http.StatusOK, CatalogRewardResponse{CatalogReward: item})
+}
+
+// Добавление награды в каталог семьи
+func (h *RewardCatalogHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateCatalogRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !services.IsFamilyParent(h.db, req.FamilyID, userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Семья не найдена или недостаточно прав"})
+		return
+	}
+	if err := h.checkCategory(req.FamilyID, req.CategoryID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Категория не найдена в каталоге семьи"})
+		return
+	}
+
+	now := time.Now()
+	item := models.CatalogReward{
+		FamilyID:    req.FamilyID,
+		CategoryID:  req.CategoryID,
+		Title:       req.Title,
+		Description: req.Description,
+		Points:      req.Points,
+		CreatedBy:   userID.(string),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.db.Omit("Category").Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при добавлении награды в каталог"})
+		return
+	}
+
+	h.db.Preload("Category").First(&item, "id = ?", item.ID)
+
+	c.JSON(http.StatusCreated, CatalogRewardResponse{CatalogReward: item})
+}
+
+// Обновление награды каталога. Изменения переносятся в награды черновиков
+// контрактов; подписанные условия не меняются.
+func (h *RewardCatalogHandlers) Update(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	item, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCatalogRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if req.CategoryID != nil {
+		if *req.CategoryID == "" {
+			updates["category_id"] = nil
+		} else {
+			if err := h.checkCategory(item.FamilyID, req.CategoryID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Категория не найдена в каталоге семьи"})
+				return
+			}
+			updates["category_id"] = *req.CategoryID
+		}
+	}
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Points != nil {
+		updates["points"] = *req.Points
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&item, "id = ?", item.ID).Error; err != nil {
+			return err
+		}
+		return services.SyncCatalogReward(tx, &item)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении награды каталога"})
+		return
+	}
+
+	h.db.Preload("Category").First(&item, "id = ?", item.ID)
+	withImageURL(&item, userID.(string))
+
+	c.JSON(http.StatusOK, CatalogRewardResponse{CatalogReward: item})
+}
+
+// Удаление награды из каталога. Награды контрактов, созданные по ней,
+// сохраняются со своими условиями.
+func (h *RewardCatalogHandlers) Delete(c *gin.Context) {
+	item, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&item).Updates(map[string]interface{}{
+			"image_key":          nil,
+			"image_content_type": nil,
+			"image_size":         nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении награды каталога"})
+		return
+	}
+
+	h.deleteImage(c, item.ImageKey)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Награда удалена из каталога"})
+}
+
+// Добавление награды каталога в контракты
+func (h *RewardCatalogHandlers) Assign(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	item, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	var req AssignCatalogRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var contracts []models.Contract
+	if err := h.db.Where("id IN ? AND child_id IN (?)", req.ContractIDs, services.GuardedChildren(h.db, userID)).
+		Find(&contracts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении контрактов"})
+		return
+	}
+	if len(contracts) != len(req.ContractIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+	for _, contract := range contracts {
+		if !contract.TermsEditable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять награды в контракт в текущем статусе"})
+			return
+		}
+		// Каталог общий только для детей этой семьи
+		if !services.IsFamilyMember(h.db, item.FamilyID, contract.ChildID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ребенок по контракту не состоит в семье каталога"})
+			return
+		}
+	}
+
+	now := time.Now()
+	rewards := make([]models.Reward, 0, len(contracts))
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for _, contract := range contracts {
+			reward := models.Reward{
+				ContractID: contract.ID,
+				Status:     models.RewardAvailable,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			services.LinkCatalogReward(&reward, &item, req.Points)
+			if err := tx.Omit("Contract").Create(&reward).Error; err != nil {
+				return err
+			}
+			if err := services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Добавлена награда «"+reward.Title+"»"); err != nil {
+				return err
+			}
+			rewards = append(rewards, reward)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при добавлении награды в контракты"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, RewardsResponse{Rewards: rewards, Total: int64(len(rewards))})
+}
+
+// Загрузка изображения награды каталога; прежнее изображение заменяется
+func (h *RewardCatalogHandlers) UploadImage(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	item, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+
+	data, contentType, _, ok := readUpload(c, h.maxSize, catalogImageTypes, "Недопустимый тип файла: разрешены только изображения")
+	if !ok {
+		return
+	}
+
+	key := "catalog/" + item.FamilyID + "/" + item.ID + "/" + uuid.NewString() + catalogImageTypes[contentType]
+	if err := h.store.Put(c.Request.Context(), key, data, contentType); err != nil {
+		log.Printf("Ошибка сохранения изображения %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении файла"})
+		return
+	}
+
+	previous := item.ImageKey
+	size := int64(len(data))
+	if err := h.db.Model(&item).Updates(map[string]interface{}{
+		"image_key":          key,
+		"image_content_type": contentType,
+		"image_size":         size,
+		"updated_at":         time.Now(),
+	}).Error; err != nil {
+		// Запись не обновлена - новый файл в хранилище больше не нужен
+		h.deleteImage(c, &key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении файла"})
+		return
+	}
+	h.deleteImage(c, previous)
+
+	h.db.Preload("Category").First(&item, "id = ?", item.ID)
+	withImageURL(&item, userID.(string))
+
+	c.JSON(http.StatusOK, CatalogRewardResponse{CatalogReward: item})
+}
+
+// Удаление изображения награды каталога
+func (h *RewardCatalogHandlers) DeleteImage(c *gin.Context) {
+	item, ok := h.findEditable(c)
+	if !ok {
+		return
+	}
+	if item.ImageKey == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "У награды нет изображения"})
+		return
+	}
+
+	if err := h.db.Model(&item).Updates(map[string]interface{}{
+		"image_key":          nil,
+		"image_content_type": nil,
+		"image_size":         nil,
+		"updated_at":         time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении изображения"})
+		return
+	}
+	h.deleteImage(c, item.ImageKey)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Изображение успешно удалено"})
+}
+
+// Получение изображения по токену авторизации
+func (h *RewardCatalogHandlers) Image(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var item models.CatalogReward
+	if err := h.visible(&models.CatalogReward{}, userID).Where("id = ?", c.Param("id")).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда каталога не найдена"})
+		return
+	}
+
+	h.serveImage(c, item)
+}
+
+// Получение изображения по подписанной ссылке, например в теге img.
+// Доступ пользователя, для которого выпущена ссылка, проверяется заново.
+func (h *RewardCatalogHandlers) DownloadImage(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Query("user")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyDownload(catalogImageFile(id), userID, expires, c.Query("signature"), time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна или устарела"})
+		return
+	}
+
+	var item models.CatalogReward
+	if err := h.visible(&models.CatalogReward{}, userID).Where("id = ?", id).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда каталога не найдена"})
+		return
+	}
+
+	h.serveImage(c, item)
+}
+
+// serveImage отдает изображение награды из хранилища
+func (h *RewardCatalogHandlers) serveImage(c *gin.Context, item models.CatalogReward) {
+	if item.ImageKey == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "У награды нет изображения"})
+		return
+	}
+
+	reader, err := h.store.Get(c.Request.Context(), *item.ImageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл изображения не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения изображения %s: %v", *item.ImageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении файла"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": item.ID + catalogImageTypes[*item.ImageContentType]}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=300")
+	c.DataFromReader(http.StatusOK, *item.ImageSize, *item.ImageContentType, reader, nil)
+}
+
+// deleteImage удаляет файл изображения; ошибка хранилища только записывается в лог
+func (h *RewardCatalogHandlers) deleteImage(c *gin.Context, key *string) {
+	if key == nil {
+		return
+	}
+	if err := h.store.Delete(c.Request.Context(), *key); err != nil {
+		log.Printf("Ошибка удаления изображения %s: %v", *key, err)
+	}
+}
+
+// catalogImageFile - идентификатор изображения в подписи ссылки. Отличается
+// от ID вложений, поэтому ссылку на изображение нельзя выдать за ссылку на вложение.
+func catalogImageFile(id string) string {
+	return "catalog/" + id
+}
+
+// withImageURL выпускает подписанную ссылку на изображение награды каталога
+func withImageURL(item *models.CatalogReward, userID string) {
+	if item.ImageKey == nil {
+		return
+	}
+	expires := time.Now().Add(attachmentURLTTL)
+	query := url.Values{}
+	query.Set("user", userID)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", utils.SignDownload(catalogImageFile(item.ID), userID, expires))
+	item.ImageURL = "/api/reward-catalog/" + item.ID + "/image/download?" + query.Encode()
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type ClaimRewardRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+type RejectClaimRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+type FulfilClaimRequest struct {
+	// Как и когда награда была вручена
+	Note string `json:"note" binding:"max=1000"`
+}
+
+type RewardClaimResponse struct {
+	Claim models.RewardClaim `json:"claim"`
+}
+
+type RewardClaimsResponse struct {
+	Claims []models.RewardClaim `json:"claims"`
+	Total  int64                `json:"total"`
+}
+
+func NewRewardClaimHandlers(db *gorm.DB) *RewardClaimHandlers {
+	return &RewardClaimHandlers{db: db}
+}
+
+type RewardClaimHandlers struct {
+	db *gorm.DB
+}
+
+// Запросы, доступные пользователю: родителю - запросы его детей, ребенку - свои
+func (h *RewardClaimHandlers) visible(db *gorm.DB, userID, role interface{}) *gorm.DB {
+	query := db.Model(&models.RewardClaim{})
+	if role == "parent" {
+		return query.Where("reward_claims.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	}
+	return query.Where("reward_claims.child_id = ?", userID)
+}
+
+// Запрос награды ребенком
+func (h *RewardClaimHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req ClaimRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reward models.Reward
+	if err := h.db.Preload("Contract").
+		Joins("Contract").
+		Where("rewards.id = ? AND Contract.child_id = ?", c.Param("id"), userID).
+		First(&reward).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена"})
+		return
+	}
+
+	// Получать награды можно только по подписанному контракту
+	if !reward.Contract.Actionable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+
+	var claim *models.RewardClaim
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		claim, err = services.ClaimReward(tx, reward.ID, userID.(string), req.Note)
+		return err
+	})
+	if !respondClaimError(c, h.db, &reward, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при запросе награды"})
+		return
+	}
+
+	h.db.Preload("Reward").First(claim, "id = ?", claim.ID)
+
+	c.JSON(http.StatusCreated, RewardClaimResponse{Claim: *claim})
+}
+
+// Получение списка запросов наград
+func (h *RewardClaimHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.visible(h.db, userID, role)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("reward_claims.status = ?", status)
+	}
+	if rewardID := c.Query("reward_id"); rewardID != "" {
+		query = query.Where("reward_claims.reward_id = ?", rewardID)
+	}
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("reward_claims.contract_id = ?", contractID)
+	}
+	if childID := c.Query("child_id"); childID != "" {
+		query = query.Where("reward_claims.child_id = ?", childID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var claims []models.RewardClaim
+	if err := query.Preload("Reward").Order("reward_claims.created_at DESC").Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении запросов наград"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RewardClaimsResponse{Claims: claims, Total: total})
+}
+
+// Получение запроса награды по ID
+func (h *RewardClaimHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var claim models.RewardClaim
+	if err := h.visible(h.db, userID, role).Preload("Reward").
+		Where("reward_claims.id = ?", c.Param("id")).
+		First(&claim).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Запрос награды не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RewardClaimResponse{Claim: claim})
+}
+
+// Одобрение запроса родителем: награда будет выдана позже
+func (h *RewardClaimHandlers) Approve(c *gin.Context) {
+	h.transition(c, services.ClaimApprove, "")
+}
+
+// Отклонение запроса родителем с указанием причины; баллы возвращаются ребенку
+func (h *RewardClaimHandlers) Reject(c *gin.Context) {
+	var req RejectClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.transition(c, services.ClaimReject, req.Reason)
+}
+
+// Отметка о выдаче награды
+func (h *RewardClaimHandlers) Fulfil(c *gin.Context) {
+	var req FulfilClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.transition(c, services.ClaimFulfil, req.Note)
+}
+
+// Отмена ребенком своего запроса, пока родитель его не рассмотрел
+func (h *RewardClaimHandlers) Cancel(c *gin.Context) {
+	h.transition(c, services.ClaimCancel, "")
+}
+
+// Получение истории статусов запроса
+func (h *RewardClaimHandlers) Transitions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var claim models.RewardClaim
+	if err := h.visible(h.db, userID, role).
+		Where("reward_claims.id = ?", c.Param("id")).
+		First(&claim).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Запрос награды не найден"})
+		return
+	}
+
+	respondTransitions(c, h.db, services.ClaimMachine, claim.ID, claim.Status, role.(string))
+}
+
+func (h *RewardClaimHandlers) transition(c *gin.Context, action, note string) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var claim *models.RewardClaim
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var found models.RewardClaim
+		if err := h.visible(tx, userID, role).
+			Select("reward_claims.id").
+			Where("reward_claims.id = ?", c.Param("id")).
+			First(&found).Error; err != nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		claim, err = services.TransitionClaim(tx, found.ID, action, role.(string), userID.(string), note)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Запрос награды не найден"})
+		return
+	}
+	if !respondTransitionError(c, err, "Действие недоступно в текущем статусе запроса") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке запроса награды"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RewardClaimResponse{Claim: *claim})
+}
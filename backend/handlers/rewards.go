@@ -1,19 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// errRewardHasOpenClaims возвращается, если у награды есть нерассмотренные запросы
+var errRewardHasOpenClaims = errors.New("у награды есть нерассмотренные запросы")
+
 type CreateRewardRequest struct {
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
 	ContractID  string `json:"contract_id" binding:"required"`
 	Points      int    `json:"points" binding:"required,min=0"`
+	// Ограничения повторяемой награды; без них награда разовая
+	Limits *services.RewardLimits `json:"limits"`
 }
 
 type UpdateRewardRequest struct {
@@ -21,6 +29,8 @@ type UpdateRewardRequest struct {
 	Description string `json:"description"`
 	Points      int    `json:"points" binding:"omitempty,min=0"`
 	Status      string `json:"status" binding:"omitempty,oneof=available claimed completed"`
+	// Новые ограничения заменяют прежние целиком
+	Limits *services.RewardLimits `json:"limits"`
 }
 
 type RewardResponse struct {
@@ -51,14 +61,14 @@ func (h *RewardHandlers) Create(c *gin.Context) {
 	// Проверяем существование контракта и права доступа
 	var contract models.Contract
 	userID, _ := c.Get("user_id")
-	if err := h.db.Where("id = ? AND parent_id = ?", req.ContractID, userID).First(&contract).Error; err != nil {
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
 		return
 	}
 
-	// Проверяем статус контракта
-	if contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять награды в неактивный контракт"})
+	// В завершенный контракт награды добавлять нельзя
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять награды в контракт в текущем статусе"})
 		return
 	}
 
@@ -71,8 +81,21 @@ func (h *RewardHandlers) Create(c *gin.Context) {
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if req.Limits != nil {
+		if err := req.Limits.Apply(&reward); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные ограничения повторяемой награды"})
+			return
+		}
+	}
 
-	if result := h.db.Create(&reward); result.Error != nil {
+	// Новая награда в предложенном или подписанном контракте - это поправка к его условиям
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reward).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Добавлена награда «"+reward.Title+"»")
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании награды"})
 		return
 	}
@@ -95,7 +118,7 @@ func (h *RewardHandlers) List(c *gin.Context) {
 
 	// Фильтруем награды в зависимости от роли пользователя
 	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("Contract.child_id = ?", userID)
 	}
@@ -104,6 +127,9 @@ func (h *RewardHandlers) List(c *gin.Context) {
 	if status != "" {
 		query = query.Where("rewards.status = ?", status)
 	}
+	if catalogRewardID := c.Query("catalog_reward_id"); catalogRewardID != "" {
+		query = query.Where("rewards.catalog_reward_id = ?", catalogRewardID)
+	}
 
 	var total int64
 	query.Count(&total)
@@ -117,6 +143,10 @@ func (h *RewardHandlers) List(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении наград"})
 		return
 	}
+	if err := services.LoadAvailability(h.db, rewards, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении наград"})
+		return
+	}
 
 	c.JSON(http.StatusOK, RewardsResponse{
 		Rewards: rewards,
@@ -126,47 +156,44 @@ func (h *RewardHandlers) List(c *gin.Context) {
 
 // Получение награды по ID
 func (h *RewardHandlers) Get(c *gin.Context) {
-	id := c.Param("id")
 	userID, _ := c.Get("user_id")
 	role, _ := c.Get("role")
 
-	var reward models.Reward
-	query := h.db.Preload("Contract").
-		Joins("Contract").
-		Where("rewards.id = ?", id)
-
-	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
-	} else {
-		query = query.Where("Contract.child_id = ?", userID)
-	}
-
-	if err := query.First(&reward).Error; err != nil {
+	reward, err := h.findReward(c.Param("id"), userID, role)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена"})
 		return
 	}
 
+	loadAvailability(h.db, &reward)
+
 	c.JSON(http.StatusOK, RewardResponse{Reward: reward})
 }
 
-// Обновление награды
-func (h *RewardHandlers) Update(c *gin.Context) {
-	id := c.Param("id")
-	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
-
+// Поиск награды с учетом прав доступа пользователя
+func (h *RewardHandlers) findReward(id string, userID, role interface{}) (models.Reward, error) {
 	var reward models.Reward
 	query := h.db.Preload("Contract").
 		Joins("Contract").
 		Where("rewards.id = ?", id)
 
 	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("Contract.child_id = ?", userID)
 	}
 
-	if err := query.First(&reward).Error; err != nil {
+	err := query.First(&reward).Error
+	return reward, err
+}
+
+// Обновление награды
+func (h *RewardHandlers) Update(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	reward, err := h.findReward(c.Param("id"), userID, role)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена"})
 		return
 	}
@@ -177,14 +204,15 @@ func (h *RewardHandlers) Update(c *gin.Context) {
 		return
 	}
 
-	// Проверяем, что контракт активен
-	if reward.Contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять награды в неактивном контракте"})
+	// Условия награды завершенного контракта менять нельзя
+	if !reward.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять награды в контракте в текущем статусе"})
 		return
 	}
 
 	// Обновляем только разрешенные поля в зависимости от роли
 	updates := make(map[string]interface{})
+	kindChanged := false
 	if role == "parent" {
 		if req.Title != "" {
 			updates["title"] = req.Title
@@ -194,33 +222,97 @@ func (h *RewardHandlers) Update(c *gin.Context) {
 		}
 		if req.Points > 0 {
 			updates["points"] = req.Points
+			// Стоимость награды из каталога теперь задана для контракта отдельно
+			if reward.CatalogRewardID != nil && req.Points != reward.PointsCost {
+				updates["custom_points"] = true
+			}
+		}
+		if req.Limits != nil {
+			limited := reward
+			if err := req.Limits.Apply(&limited); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные ограничения повторяемой награды"})
+				return
+			}
+			kindChanged = limited.Repeatable != reward.Repeatable
+			updates["repeatable"] = limited.Repeatable
+			updates["stock"] = limited.Stock
+			updates["period_limit"] = limited.PeriodLimit
+			updates["limit_period"] = limited.LimitPeriod
+			updates["cooldown_minutes"] = limited.CooldownMinutes
+			updates["timezone"] = limited.Timezone
 		}
 	}
 
-	// Статус могут менять оба (и родитель, и ребенок)
+	// Статус меняется по правилам жизненного цикла награды: ребенок
+	// запрашивает награду, родитель выдает ее по открытому запросу
+	var transition *services.Transition
 	if req.Status != "" {
-		// Ребенок может только запрашивать награды
-		if role == "child" && req.Status != "claimed" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Ребенок может только запрашивать награды"})
+		transition, err = services.RewardMachine.ActionTo(reward.Status, req.Status, role.(string))
+		if !respondTransitionError(c, err, "Недопустимая смена статуса награды") {
 			return
 		}
-		// Родитель может только подтверждать или отклонять запросы
-		if role == "parent" && req.Status != "completed" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Родитель может только подтверждать награды"})
+		// Получать награды можно только по подписанному контракту
+		if !reward.Contract.Actionable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
 			return
 		}
-		updates["status"] = req.Status
 	}
 
 	updates["updated_at"] = time.Now()
 
-	if err := h.db.Model(&reward).Updates(updates).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Статус разовой награды следует за ее запросом, поэтому вид
+		// награды нельзя менять, пока запрос не рассмотрен. Награда
+		// блокируется, чтобы новый запрос не появился до конца изменения.
+		if kindChanged {
+			if err := lockOpenClaims(tx, reward.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&reward).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if transition != nil {
+			switch transition.Action {
+			case services.RewardClaim:
+				if _, err := services.ClaimReward(tx, reward.ID, userID.(string), ""); err != nil {
+					return err
+				}
+			case services.RewardFulfil:
+				claim, err := services.OpenClaim(tx, reward.ID)
+				if err != nil {
+					return err
+				}
+				if _, err := services.TransitionClaim(tx, claim.ID, services.ClaimFulfil, role.(string), userID.(string), ""); err != nil {
+					return err
+				}
+			}
+		}
+
+		if role == "parent" {
+			return services.AmendContract(tx, reward.ContractID, userID.(string), c.ClientIP(), "Изменена награда «"+reward.Title+"»")
+		}
+		return nil
+	})
+	if errors.Is(err, errRewardHasOpenClaims) {
+		c.JSON(http.StatusConflict, gin.H{"error": "У награды есть нерассмотренные запросы"})
+		return
+	}
+	if !respondClaimError(c, h.db, &reward, err) {
+		return
+	}
+	if !respondTransitionError(c, err, "Недопустимая смена статуса награды") {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении награды"})
 		return
 	}
 
 	// Перезагружаем данные награды
 	h.db.Preload("Contract").First(&reward, reward.ID)
+	loadAvailability(h.db, &reward)
 
 	c.JSON(http.StatusOK, RewardResponse{Reward: reward})
 }
@@ -233,23 +325,99 @@ func (h *RewardHandlers) Delete(c *gin.Context) {
 	var reward models.Reward
 	if err := h.db.Preload("Contract").
 		Joins("Contract").
-		Where("rewards.id = ? AND Contract.parent_id = ?", id, userID).
+		Where("rewards.id = ? AND Contract.child_id IN (?)", id, services.GuardedChildren(h.db, userID)).
 		First(&reward).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена"})
 		return
 	}
 
-	// Проверяем, что контракт активен
-	if reward.Contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять награды из неактивного контракта"})
+	// Из завершенного контракта награды удалять нельзя
+	if !reward.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять награды из контракта в текущем статусе"})
 		return
 	}
 
 	// Используем soft delete (благодаря gorm.DeletedAt в модели)
-	if err := h.db.Delete(&reward).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Баллы по открытым запросам зарезервированы, сначала нужно решить их судьбу
+		if err := lockOpenClaims(tx, reward.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&reward).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, reward.ContractID, userID.(string), c.ClientIP(), "Удалена награда «"+reward.Title+"»")
+	})
+	if errors.Is(err, errRewardHasOpenClaims) {
+		c.JSON(http.StatusConflict, gin.H{"error": "У награды есть нерассмотренные запросы"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении награды"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Награда успешно удалена"})
-} 
\ No newline at end of file
+} 
+
+// lockOpenClaims блокирует награду до конца транзакции, как это делает
+// запрос награды, и проверяет, что у нее нет нерассмотренных запросов
+func lockOpenClaims(tx *gorm.DB, rewardID string) error {
+	var locked models.Reward
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&locked, "id = ?", rewardID).Error; err != nil {
+		return err
+	}
+	_, err := services.OpenClaim(tx, rewardID)
+	if err == nil {
+		return errRewardHasOpenClaims
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// loadAvailability дополняет повторяемую награду остатком запросов
+// и отмечает, открыта ли награда
+func loadAvailability(db *gorm.DB, reward *models.Reward) {
+	rewards := []models.Reward{*reward}
+	if err := services.LoadAvailability(db, rewards, time.Now()); err == nil {
+		reward.Availability = rewards[0].Availability
+		reward.Locked = rewards[0].Locked
+	}
+}
+
+// respondClaimError отвечает на ошибки запроса награды и сообщает,
+// можно ли продолжать обработку. При нарушении ограничений повторяемой
+// награды в ответ добавляется ее доступность, в том числе время, когда
+// награду можно будет запросить снова.
+func respondClaimError(c *gin.Context, db *gorm.DB, reward *models.Reward, err error) bool {
+	var message string
+	switch {
+	case errors.Is(err, services.ErrRewardNotAvailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Награда недоступна для получения"})
+		return false
+	case errors.Is(err, services.ErrInsufficientPoints):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недостаточно баллов для получения награды"})
+		return false
+	case errors.Is(err, services.ErrContractNotActionable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return false
+	case errors.Is(err, services.ErrRewardLocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Награда откроется, когда сработает правило контракта"})
+		return false
+	case errors.Is(err, services.ErrRewardOutOfStock):
+		message = "Запас награды исчерпан"
+	case errors.Is(err, services.ErrRewardLimitReached):
+		message = "Лимит запросов награды за период исчерпан"
+	case errors.Is(err, services.ErrRewardCooldown):
+		message = "Награду пока нельзя запросить снова"
+	default:
+		return true
+	}
+
+	loadAvailability(db, reward)
+	c.JSON(http.StatusConflict, gin.H{"error": message, "availability": reward.Availability})
+	return false
+}
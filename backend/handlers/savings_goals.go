@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateGoalRequest struct {
+	// Ребенок, для которого родитель заводит цель; ребенок заводит цель для себя
+	ChildID    string `json:"child_id"`
+	ContractID string `json:"contract_id" binding:"required"`
+	// Название, по умолчанию название награды
+	Title string `json:"title" binding:"max=255"`
+	// Цель - награда контракта или сумма баллов
+	RewardID     *string    `json:"reward_id"`
+	TargetPoints *int       `json:"target_points" binding:"omitempty,min=1"`
+	Deadline     *time.Time `json:"deadline"`
+}
+
+type UpdateGoalRequest struct {
+	Title        string     `json:"title" binding:"max=255"`
+	TargetPoints *int       `json:"target_points" binding:"omitempty,min=1"`
+	Deadline     *time.Time `json:"deadline"`
+}
+
+type GoalAmountRequest struct {
+	Amount int `json:"amount" binding:"required,min=1"`
+}
+
+type CancelGoalRequest struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+type GoalResponse struct {
+	Goal models.SavingsGoal `json:"goal"`
+}
+
+type GoalsResponse struct {
+	Goals []models.SavingsGoal `json:"goals"`
+	Total int64                `json:"total"`
+}
+
+func NewSavingsGoalHandlers(db *gorm.DB) *SavingsGoalHandlers {
+	return &SavingsGoalHandlers{db: db}
+}
+
+type SavingsGoalHandlers struct {
+	db *gorm.DB
+}
+
+// Цели, доступные пользователю: родителю - цели его детей, ребенку - свои
+func (h *SavingsGoalHandlers) visible(db *gorm.DB, userID, role interface{}) *gorm.DB {
+	query := db.Model(&models.SavingsGoal{})
+	if role == "parent" {
+		return query.Where("savings_goals.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	}
+	return query.Where("savings_goals.child_id = ?", userID)
+}
+
+func (h *SavingsGoalHandlers) find(c *gin.Context) (models.SavingsGoal, bool) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var goal models.SavingsGoal
+	if err := h.visible(h.db, userID, role).Preload("Reward").
+		Where("savings_goals.id = ?", c.Param("id")).
+		First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Цель не найдена"})
+		return goal, false
+	}
+	return goal, true
+}
+
+// Создание цели накопления
+func (h *SavingsGoalHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req CreateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.RewardID == nil) == (req.TargetPoints == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите награду или сумму баллов для цели"})
+		return
+	}
+	if req.Deadline != nil && req.Deadline.Before(time.Now().Truncate(24*time.Hour)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Срок цели уже прошел"})
+		return
+	}
+
+	childID := userID.(string)
+	if role == "parent" {
+		childID = req.ChildID
+		if childID == "" || !services.IsGuardian(h.db, userID.(string), childID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ребенок не найден"})
+			return
+		}
+	}
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id = ?", req.ContractID, childID).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+	// Копить можно только баллы действующего контракта
+	if !contract.Actionable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+
+	title := req.Title
+	if req.RewardID != nil {
+		var reward models.Reward
+		if err := h.db.Where("id = ? AND contract_id = ?", *req.RewardID, contract.ID).First(&reward).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена в контракте"})
+			return
+		}
+		if reward.Status != models.RewardAvailable {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Награда недоступна для получения"})
+			return
+		}
+		if title == "" {
+			title = reward.Title
+		}
+	}
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите название цели"})
+		return
+	}
+
+	now := time.Now()
+	goal := models.SavingsGoal{
+		ChildID:      childID,
+		ContractID:   contract.ID,
+		Title:        title,
+		RewardID:     req.RewardID,
+		TargetPoints: req.TargetPoints,
+		Deadline:     req.Deadline,
+		Status:       models.GoalActive,
+		CreatedBy:    userID.(string),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.db.Omit("Reward").Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании цели"})
+		return
+	}
+
+	h.db.Preload("Reward").First(&goal, "id = ?", goal.ID)
+	h.respond(c, http.StatusCreated, goal)
+}
+
+// Получение списка целей
+func (h *SavingsGoalHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.visible(h.db, userID, role)
+	switch status := c.Query("status"); status {
+	case "":
+	case "open":
+		query = query.Where("savings_goals.status IN ?", []string{models.GoalActive, models.GoalReached})
+	default:
+		query = query.Where("savings_goals.status = ?", status)
+	}
+	if childID := c.Query("child_id"); childID != "" {
+		query = query.Where("savings_goals.child_id = ?", childID)
+	}
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("savings_goals.contract_id = ?", contractID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var goals []models.SavingsGoal
+	if err := query.Preload("Reward").Order("savings_goals.created_at DESC").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении целей"})
+		return
+	}
+	if err := services.LoadGoalProgress(h.db, goals, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении целей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GoalsResponse{Goals: goals, Total: total})
+}
+
+// Получение цели по ID
+func (h *SavingsGoalHandlers) Get(c *gin.Context) {
+	goal, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	h.respond(c, http.StatusOK, goal)
+}
+
+// Прогресс цели: накоплено, темп заработка и прогноз достижения
+func (h *SavingsGoalHandlers) Progress(c *gin.Context) {
+	goal, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if !goal.Open() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Цель уже закрыта"})
+		return
+	}
+
+	goals := []models.SavingsGoal{goal}
+	if err := services.LoadGoalProgress(h.db, goals, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при расчете прогресса цели"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"progress": goals[0].Progress})
+}
+
+// Изменение названия, срока или суммы цели
+func (h *SavingsGoalHandlers) Update(c *gin.Context) {
+	goal, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if goal.Status != models.GoalActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Изменять можно только активную цель"})
+		return
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if req.Title != "" {
+		updates["title"] = req.Title
+	}
+	if req.Deadline != nil {
+		updates["deadline"] = *req.Deadline
+	}
+	if req.TargetPoints != nil {
+		// Сумма цели-награды определяется стоимостью награды
+		if goal.RewardID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Сумма цели определяется стоимостью награды"})
+			return
+		}
+		saved, err := services.GoalSaved(h.db, goal.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении цели"})
+			return
+		}
+		if *req.TargetPoints <= saved {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Новая сумма уже накоплена, заберите баллы или закройте цель"})
+			return
+		}
+		updates["target_points"] = *req.TargetPoints
+	}
+
+	if err := services.SetStatus(h.db, &goal, goal.ID, goal.Status, updates); err != nil {
+		if errors.Is(err, services.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Цель изменилась, обновите страницу"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении цели"})
+		return
+	}
+
+	h.db.Preload("Reward").First(&goal, "id = ?", goal.ID)
+	h.respond(c, http.StatusOK, goal)
+}
+
+// Пополнение цели баллами из доступного баланса
+func (h *SavingsGoalHandlers) Deposit(c *gin.Context) {
+	var req GoalAmountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.change(c, func(tx *gorm.DB, goal *models.SavingsGoal, userID, _ string) (*models.SavingsGoal, error) {
+		return services.DepositGoal(tx, goal.ID, req.Amount, userID)
+	})
+}
+
+// Возврат части отложенных баллов в баланс
+func (h *SavingsGoalHandlers) Withdraw(c *gin.Context) {
+	var req GoalAmountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.change(c, func(tx *gorm.DB, goal *models.SavingsGoal, userID, _ string) (*models.SavingsGoal, error) {
+		return services.WithdrawGoal(tx, goal.ID, req.Amount, userID)
+	})
+}
+
+// Запрос награды достигнутой цели
+func (h *SavingsGoalHandlers) Claim(c *gin.Context) {
+	h.change(c, func(tx *gorm.DB, goal *models.SavingsGoal, userID, role string) (*models.SavingsGoal, error) {
+		return services.ClaimGoal(tx, goal.ID, role, userID)
+	})
+}
+
+// Закрытие цели с возвратом всех отложенных баллов
+func (h *SavingsGoalHandlers) Cancel(c *gin.Context) {
+	var req CancelGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.change(c, func(tx *gorm.DB, goal *models.SavingsGoal, userID, role string) (*models.SavingsGoal, error) {
+		return services.CancelGoal(tx, goal.ID, role, userID, req.Reason)
+	})
+}
+
+// Получение истории статусов цели
+func (h *SavingsGoalHandlers) Transitions(c *gin.Context) {
+	role, _ := c.Get("role")
+
+	goal, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	respondTransitions(c, h.db, services.GoalMachine, goal.ID, goal.Status, role.(string))
+}
+
+// change выполняет операцию над целью в транзакции и отвечает на ее ошибки
+func (h *SavingsGoalHandlers) change(c *gin.Context, op func(tx *gorm.DB, goal *models.SavingsGoal, userID, role string) (*models.SavingsGoal, error)) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	goal, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	var updated *models.SavingsGoal
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = op(tx, &goal, userID.(string), role.(string))
+		return err
+	})
+	if errors.Is(err, services.ErrInsufficientPoints) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недостаточно баллов"})
+		return
+	}
+	if errors.Is(err, services.ErrContractNotActionable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidGoal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "У цели нет награды"})
+		return
+	}
+	if goal.Reward != nil && !respondClaimError(c, h.db, goal.Reward, err) {
+		return
+	}
+	if !respondTransitionError(c, err, "Действие недоступно в текущем статусе цели") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при изменении цели"})
+		return
+	}
+
+	h.respond(c, http.StatusOK, *updated)
+}
+
+// respond отвечает целью вместе с ее прогрессом
+func (h *SavingsGoalHandlers) respond(c *gin.Context, status int, goal models.SavingsGoal) {
+	goals := []models.SavingsGoal{goal}
+	if err := services.LoadGoalProgress(h.db, goals, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении цели"})
+		return
+	}
+
+	c.JSON(status, GoalResponse{Goal: goals[0]})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -69,7 +70,7 @@ func (h *SettingsHandlers) UpdateProfile(c *gin.Context) {
 	}
 
 	// Проверяем, не занят ли email другим пользователем
-	if req.Email != "" && req.Email != user.Email {
+	if req.Email != "" && (user.Email == nil || req.Email != *user.Email) {
 		var count int64
 		h.db.Model(&models.User{}).Where("email = ? AND id != ?", req.Email, userID).Count(&count)
 		if count > 0 {
@@ -141,6 +142,13 @@ func (h *SettingsHandlers) UpdatePassword(c *gin.Context) {
 		return
 	}
 
+	// Завершаем все остальные сессии: старые токены больше не должны работать
+	sessionID, _ := c.Get("session_id")
+	if err := services.RevokeUserSessions(h.db, user.ID, sessionID.(string), services.RevokePasswordChange); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессий"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Пароль успешно обновлен"})
 }
 
@@ -194,6 +202,25 @@ func (h *SettingsHandlers) DeleteAccount(c *gin.Context) {
 		return
 	}
 
+	// Удаляем связи опекунства и членство в семьях
+	if err := tx.Where("parent_id = ? OR child_id = ?", userID, userID).Delete(&models.Guardianship{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении связей с семьей"})
+		return
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.FamilyMember{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении связей с семьей"})
+		return
+	}
+
+	// Завершаем все сессии пользователя
+	if err := services.RevokeUserSessions(tx, user.ID, "", services.RevokeAccountDeleted); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессий"})
+		return
+	}
+
 	// Мягкое удаление пользователя
 	if err := tx.Delete(&user).Error; err != nil {
 		tx.Rollback()
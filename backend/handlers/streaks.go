@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateStreakBonusRequest struct {
+	ContractID string `json:"contract_id" binding:"required"`
+	// day - дни подряд, series - повторения повторяющейся задачи подряд
+	Scope    string  `json:"scope" binding:"required"`
+	SeriesID *string `json:"series_id"`
+	Kind     string  `json:"kind" binding:"required"`
+	Length   int     `json:"length" binding:"required"`
+	// Для множителя: 150 - это x1.5
+	MultiplierPercent int    `json:"multiplier_percent" binding:"min=0"`
+	Points            int    `json:"points" binding:"min=0"`
+	Timezone          string `json:"timezone"`
+}
+
+type UpdateStreakBonusRequest struct {
+	Length            *int   `json:"length"`
+	MultiplierPercent *int   `json:"multiplier_percent" binding:"omitempty,min=0"`
+	Points            *int   `json:"points" binding:"omitempty,min=0"`
+	Timezone          string `json:"timezone"`
+}
+
+type StreakBonusResponse struct {
+	Bonus models.StreakBonus `json:"bonus"`
+}
+
+type StreakBonusesResponse struct {
+	Bonuses []models.StreakBonus `json:"bonuses"`
+	Total   int64                `json:"total"`
+}
+
+type StreakAwardsResponse struct {
+	Awards []models.StreakAward `json:"awards"`
+	Total  int64                `json:"total"`
+}
+
+type StreaksResponse struct {
+	ChildID   string                     `json:"child_id"`
+	Contracts []services.ContractStreaks `json:"contracts"`
+}
+
+func NewStreakHandlers(db *gorm.DB) *StreakHandlers {
+	return &StreakHandlers{db: db}
+}
+
+type StreakHandlers struct {
+	db *gorm.DB
+}
+
+// Поиск бонуса за серию с учетом прав доступа пользователя
+func (h *StreakHandlers) findBonus(id string, userID, role interface{}) (models.StreakBonus, error) {
+	var bonus models.StreakBonus
+	query := h.db.Preload("Contract").
+		Joins("Contract").
+		Where("streak_bonuses.id = ?", id)
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	err := query.First(&bonus).Error
+	return bonus, err
+}
+
+// checkSeries проверяет, что повторяющаяся задача бонуса из того же контракта
+func (h *StreakHandlers) checkSeries(bonus *models.StreakBonus) error {
+	if bonus.SeriesID == nil {
+		return nil
+	}
+	var count int64
+	h.db.Model(&models.TaskSeries{}).Where("id = ? AND contract_id = ?", *bonus.SeriesID, bonus.ContractID).Count(&count)
+	if count == 0 {
+		return services.ErrInvalidStreakBonus
+	}
+	return nil
+}
+
+// respondStreakBonusError отвечает на ошибку проверки бонуса за серию и
+// сообщает, можно ли продолжать обработку
+func respondStreakBonusError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrInvalidStreakBonus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный бонус: проверьте вид серии, длину, множитель или баллы и повторяющуюся задачу"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке бонуса"})
+		return false
+	}
+	return true
+}
+
+// Создание бонуса за серию
+func (h *StreakHandlers) Create(c *gin.Context) {
+	var req CreateStreakBonusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять бонусы в контракт в текущем статусе"})
+		return
+	}
+
+	now := time.Now()
+	createdBy := userID.(string)
+	bonus := models.StreakBonus{
+		ContractID:        contract.ID,
+		Scope:             req.Scope,
+		SeriesID:          req.SeriesID,
+		Kind:              req.Kind,
+		Length:            req.Length,
+		MultiplierPercent: req.MultiplierPercent,
+		Points:            req.Points,
+		Timezone:          req.Timezone,
+		CreatedBy:         &createdBy,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	err := services.ValidateStreakBonus(&bonus)
+	if err == nil {
+		err = h.checkSeries(&bonus)
+	}
+	if !respondStreakBonusError(c, err) {
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Contract").Create(&bonus).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, createdBy, c.ClientIP(), "Добавлен бонус за серию")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании бонуса"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, StreakBonusResponse{Bonus: bonus})
+}
+
+// Получение списка бонусов за серии
+func (h *StreakHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.db.Model(&models.StreakBonus{}).
+		Joins("Contract").
+		Where("Contract.deleted_at IS NULL")
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("streak_bonuses.contract_id = ?", contractID)
+	}
+	if seriesID := c.Query("series_id"); seriesID != "" {
+		query = query.Where("streak_bonuses.series_id = ?", seriesID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var bonuses []models.StreakBonus
+	if err := query.Order("streak_bonuses.scope, streak_bonuses.length").Find(&bonuses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении бонусов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreakBonusesResponse{Bonuses: bonuses, Total: total})
+}
+
+// Получение бонуса за серию по ID
+func (h *StreakHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	bonus, err := h.findBonus(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Бонус не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreakBonusResponse{Bonus: bonus})
+}
+
+// Изменение бонуса за серию. Новые значения действуют на следующие
+// подтверждения задач, начисленные бонусы остаются.
+func (h *StreakHandlers) Update(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	bonus, err := h.findBonus(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Бонус не найден"})
+		return
+	}
+
+	var req UpdateStreakBonusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contract := bonus.Contract
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять бонусы в контракте в текущем статусе"})
+		return
+	}
+
+	if req.Length != nil {
+		bonus.Length = *req.Length
+	}
+	if req.MultiplierPercent != nil {
+		bonus.MultiplierPercent = *req.MultiplierPercent
+	}
+	if req.Points != nil {
+		bonus.Points = *req.Points
+	}
+	if req.Timezone != "" {
+		bonus.Timezone = req.Timezone
+	}
+	if !respondStreakBonusError(c, services.ValidateStreakBonus(&bonus)) {
+		return
+	}
+
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&bonus).Omit("Contract").Updates(map[string]interface{}{
+			"length":             bonus.Length,
+			"multiplier_percent": bonus.MultiplierPercent,
+			"points":             bonus.Points,
+			"timezone":           bonus.Timezone,
+			"updated_at":         now,
+		}).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Изменен бонус за серию")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении бонуса"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreakBonusResponse{Bonus: bonus})
+}
+
+// Удаление бонуса за серию; начисленные по нему баллы остаются
+func (h *StreakHandlers) Delete(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	bonus, err := h.findBonus(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Бонус не найден"})
+		return
+	}
+
+	if !bonus.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять бонусы из контракта в текущем статусе"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&bonus).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, bonus.ContractID, userID.(string), c.ClientIP(), "Удален бонус за серию")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении бонуса"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Бонус успешно удален"})
+}
+
+// Получение начислений по бонусу за серию
+func (h *StreakHandlers) Awards(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	bonus, err := h.findBonus(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Бонус не найден"})
+		return
+	}
+
+	var awards []models.StreakAward
+	if err := h.db.Where("bonus_id = ?", bonus.ID).Order("awarded_at DESC").Find(&awards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении начислений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreakAwardsResponse{Awards: awards, Total: int64(len(awards))})
+}
+
+// Получение текущих серий ребенка по действующим контрактам
+// или по указанному контракту
+func (h *StreakHandlers) Streaks(c *gin.Context) {
+	childID := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	// Ребенок видит только свои серии, родитель - серии своих детей
+	if role == "parent" {
+		if !services.IsGuardian(h.db, userID.(string), childID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ребенок не найден"})
+			return
+		}
+	} else if childID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var location *time.Location
+	if timezone := c.Query("timezone"); timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный часовой пояс"})
+			return
+		}
+	}
+
+	query := h.db.Where("child_id = ? AND status <> ?", childID, models.ContractDraft)
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("id = ?", contractID)
+	} else {
+		query = query.Where("status = ?", models.ContractActive)
+	}
+
+	var contracts []models.Contract
+	if err := query.Order("title").Find(&contracts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении серий"})
+		return
+	}
+
+	now := time.Now()
+	response := StreaksResponse{ChildID: childID, Contracts: make([]services.ContractStreaks, 0, len(contracts))}
+	for i := range contracts {
+		streaks, err := services.LoadStreaks(h.db, &contracts[i], location, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении серий"})
+			return
+		}
+		response.Contracts = append(response.Contracts, *streaks)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
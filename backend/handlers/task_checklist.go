@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CreateChecklistItemRequest struct {
+	Title string `json:"title" binding:"required,max=255"`
+	// Позиция в чек-листе; по умолчанию пункт добавляется в конец
+	Position *int `json:"position" binding:"omitempty,min=0"`
+}
+
+type UpdateChecklistItemRequest struct {
+	Title     string `json:"title" binding:"max=255"`
+	Completed *bool  `json:"completed"`
+}
+
+type ReorderChecklistRequest struct {
+	ItemIDs []string `json:"item_ids" binding:"required,min=1"`
+}
+
+type ChecklistResponse struct {
+	Checklist []models.TaskChecklistItem `json:"checklist"`
+	Progress  *models.TaskProgress       `json:"progress"`
+}
+
+var (
+	errChecklistItemNotFound = errors.New("пункт чек-листа не найден")
+	errChecklistOrder        = errors.New("порядок должен содержать все пункты чек-листа")
+)
+
+// Добавление пункта в чек-лист задачи
+func (h *TaskHandlers) AddChecklistItem(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req CreateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, ok := h.checklistTask(c, userID, role)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		items, err := lockChecklist(tx, task.ID)
+		if err != nil {
+			return err
+		}
+
+		position := len(items)
+		if req.Position != nil && *req.Position < position {
+			position = *req.Position
+		}
+
+		// Сдвигаем пункты после вставленного
+		if err := tx.Model(&models.TaskChecklistItem{}).
+			Where("task_id = ? AND position >= ?", task.ID, position).
+			Update("position", gorm.Expr("position + 1")).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		item := models.TaskChecklistItem{
+			TaskID:    task.ID,
+			Title:     req.Title,
+			Position:  position,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return amendChecklist(tx, task, userID.(string), c.ClientIP())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при добавлении пункта чек-листа"})
+		return
+	}
+
+	h.respondChecklist(c, http.StatusCreated, task)
+}
+
+// Изменение пункта чек-листа. Ребенок может только отмечать выполнение,
+// родитель также может переименовать пункт.
+func (h *TaskHandlers) UpdateChecklistItem(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.findTask(h.db, id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	if req.Title != "" {
+		if role != "parent" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Изменять пункты чек-листа может только родитель"})
+			return
+		}
+		if !task.Contract.TermsEditable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять задачи в контракте в текущем статусе"})
+			return
+		}
+	}
+
+	if req.Completed != nil {
+		if !task.Contract.Actionable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+			return
+		}
+		// После отправки на проверку отметки ребенка фиксируются,
+		// родитель может поправить их до подтверждения
+		if task.Status != "pending" && (role != "parent" || task.Status != "submitted") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Отмечать пункты можно только у задачи в работе"})
+			return
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var item models.TaskChecklistItem
+		if err := tx.Where("id = ? AND task_id = ?", c.Param("itemId"), task.ID).
+			First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errChecklistItemNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"updated_at": now}
+		if req.Title != "" {
+			updates["title"] = req.Title
+		}
+		if req.Completed != nil {
+			if *req.Completed && item.CompletedAt == nil {
+				updates["completed_at"] = now
+				updates["completed_by"] = userID
+			} else if !*req.Completed {
+				updates["completed_at"] = nil
+				updates["completed_by"] = nil
+			}
+		}
+		if err := tx.Model(&item).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if req.Title != "" {
+			return amendChecklist(tx, task, userID.(string), c.ClientIP())
+		}
+		return nil
+	})
+	if errors.Is(err, errChecklistItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пункт чек-листа не найден"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении пункта чек-листа"})
+		return
+	}
+
+	h.respondChecklist(c, http.StatusOK, task)
+}
+
+// Удаление пункта чек-листа
+func (h *TaskHandlers) DeleteChecklistItem(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, ok := h.checklistTask(c, userID, role)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockChecklist(tx, task.ID); err != nil {
+			return err
+		}
+
+		var item models.TaskChecklistItem
+		if err := tx.Where("id = ? AND task_id = ?", c.Param("itemId"), task.ID).
+			First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errChecklistItemNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&item).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TaskChecklistItem{}).
+			Where("task_id = ? AND position > ?", task.ID, item.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		return amendChecklist(tx, task, userID.(string), c.ClientIP())
+	})
+	if errors.Is(err, errChecklistItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пункт чек-листа не найден"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении пункта чек-листа"})
+		return
+	}
+
+	h.respondChecklist(c, http.StatusOK, task)
+}
+
+// Изменение порядка пунктов чек-листа
+func (h *TaskHandlers) ReorderChecklist(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req ReorderChecklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, ok := h.checklistTask(c, userID, role)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		items, err := lockChecklist(tx, task.ID)
+		if err != nil {
+			return err
+		}
+
+		// Новый порядок должен перечислять каждый пункт ровно один раз
+		known := make(map[string]bool, len(items))
+		for _, item := range items {
+			known[item.ID] = true
+		}
+		if len(req.ItemIDs) != len(items) {
+			return errChecklistOrder
+		}
+		for _, itemID := range req.ItemIDs {
+			if !known[itemID] {
+				return errChecklistOrder
+			}
+			delete(known, itemID)
+		}
+
+		for position, itemID := range req.ItemIDs {
+			if err := tx.Model(&models.TaskChecklistItem{}).
+				Where("id = ?", itemID).
+				Updates(map[string]interface{}{"position": position, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return amendChecklist(tx, task, userID.(string), c.ClientIP())
+	})
+	if errors.Is(err, errChecklistOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Порядок должен содержать все пункты чек-листа"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при изменении порядка чек-листа"})
+		return
+	}
+
+	h.respondChecklist(c, http.StatusOK, task)
+}
+
+// checklistTask находит задачу, состав чек-листа которой может менять родитель
+func (h *TaskHandlers) checklistTask(c *gin.Context, userID, role interface{}) (models.Task, bool) {
+	task, err := h.findTask(h.db, c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return task, false
+	}
+	if !task.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять задачи в контракте в текущем статусе"})
+		return task, false
+	}
+	if task.Status == "completed" || task.Status == "failed" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять чек-лист завершенной задачи"})
+		return task, false
+	}
+	return task, true
+}
+
+// amendChecklist фиксирует изменение состава чек-листа как поправку к
+// условиям контракта. Повторение серии с измененным чек-листом больше не
+// обновляется вместе с серией.
+func amendChecklist(tx *gorm.DB, task models.Task, actorID, ipAddress string) error {
+	if task.SeriesID != nil && !task.IsException {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("is_exception", true).Error; err != nil {
+			return err
+		}
+	}
+	return services.AmendContract(tx, task.ContractID, actorID, ipAddress, "Изменен чек-лист задачи «"+task.Title+"»")
+}
+
+// lockChecklist блокирует задачу до конца транзакции, чтобы параллельные
+// изменения не перепутали позиции пунктов, и возвращает ее чек-лист
+func lockChecklist(tx *gorm.DB, taskID string) ([]models.TaskChecklistItem, error) {
+	var task models.Task
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&task, "id = ?", taskID).Error; err != nil {
+		return nil, err
+	}
+
+	var items []models.TaskChecklistItem
+	err := tx.Where("task_id = ?", taskID).
+		Order("position").
+		Find(&items).Error
+	return items, err
+}
+
+func (h *TaskHandlers) respondChecklist(c *gin.Context, status int, task models.Task) {
+	h.loadChecklist(&task)
+	checklist := task.Checklist
+	if checklist == nil {
+		checklist = []models.TaskChecklistItem{}
+	}
+	progress := task.Progress
+	if progress == nil {
+		progress = models.NewTaskProgress(0, 0)
+	}
+	c.JSON(status, ChecklistResponse{Checklist: checklist, Progress: progress})
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSeriesDueTime  = "20:00"
+	defaultSeriesTimezone = "UTC"
+	upcomingOccurrences   = 10
+)
+
+var errInvalidSchedule = errors.New("некорректное расписание")
+
+type CreateTaskSeriesRequest struct {
+	ContractID  string     `json:"contract_id" binding:"required"`
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Points      int        `json:"points" binding:"required,min=0"`
+	Rule        string     `json:"rule" binding:"required"`
+	DueTime     string     `json:"due_time"`
+	Timezone    string     `json:"timezone"`
+	StartsOn    time.Time  `json:"starts_on"`
+	EndsOn      *time.Time `json:"ends_on"`
+}
+
+type UpdateTaskSeriesRequest struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Points      int        `json:"points" binding:"omitempty,min=0"`
+	Rule        string     `json:"rule"`
+	DueTime     string     `json:"due_time"`
+	Timezone    string     `json:"timezone"`
+	EndsOn      *time.Time `json:"ends_on"`
+}
+
+type TaskSeriesResponse struct {
+	Series   models.TaskSeries `json:"series"`
+	Upcoming []time.Time       `json:"upcoming"`
+}
+
+type TaskSeriesListResponse struct {
+	Series []models.TaskSeries `json:"series"`
+	Total  int64               `json:"total"`
+}
+
+func NewTaskSeriesHandlers(db *gorm.DB) *TaskSeriesHandlers {
+	return &TaskSeriesHandlers{db: db}
+}
+
+type TaskSeriesHandlers struct {
+	db *gorm.DB
+}
+
+// Проверяет правило, время и часовой пояс серии и приводит правило к каноническому виду
+func validateSchedule(series *models.TaskSeries) error {
+	rule, err := services.ParseRule(series.Rule)
+	if err != nil {
+		return errInvalidSchedule
+	}
+	if _, err := services.ParseTimeOfDay(series.DueTime); err != nil {
+		return errInvalidSchedule
+	}
+	if _, err := time.LoadLocation(series.Timezone); err != nil {
+		return errInvalidSchedule
+	}
+	if series.EndsOn != nil && series.EndsOn.Before(series.StartsOn) {
+		return errInvalidSchedule
+	}
+	series.Rule = rule.String()
+	return nil
+}
+
+// Ближайшие даты повторения серии
+func upcoming(series *models.TaskSeries, contract *models.Contract) []time.Time {
+	rule, err := services.ParseRule(series.Rule)
+	if err != nil {
+		return nil
+	}
+	to := services.Date(contract.EndDate)
+	if series.EndsOn != nil && series.EndsOn.Before(to) {
+		to = services.Date(*series.EndsOn)
+	}
+	dates := rule.Occurrences(series.StartsOn, time.Now(), to)
+	if len(dates) > upcomingOccurrences {
+		dates = dates[:upcomingOccurrences]
+	}
+	return dates
+}
+
+// Поиск серии с учетом прав доступа пользователя
+func (h *TaskSeriesHandlers) findSeries(id string, userID, role interface{}) (models.TaskSeries, error) {
+	var series models.TaskSeries
+	query := h.db.Preload("Contract").
+		Joins("Contract").
+		Where("task_series.id = ?", id)
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	err := query.First(&series).Error
+	return series, err
+}
+
+// Создание повторяющейся задачи
+func (h *TaskSeriesHandlers) Create(c *gin.Context) {
+	var req CreateTaskSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять задачи в контракт в текущем статусе"})
+		return
+	}
+
+	now := time.Now()
+	createdBy := userID.(string)
+	series := models.TaskSeries{
+		ContractID:  contract.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		Points:      req.Points,
+		Rule:        req.Rule,
+		DueTime:     req.DueTime,
+		Timezone:    req.Timezone,
+		StartsOn:    services.Date(req.StartsOn),
+		EndsOn:      req.EndsOn,
+		CreatedBy:   &createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if series.DueTime == "" {
+		series.DueTime = defaultSeriesDueTime
+	}
+	if series.Timezone == "" {
+		series.Timezone = defaultSeriesTimezone
+	}
+	if req.StartsOn.IsZero() {
+		series.StartsOn = services.Date(contract.StartDate)
+	}
+	if series.EndsOn != nil {
+		endsOn := services.Date(*series.EndsOn)
+		series.EndsOn = &endsOn
+	}
+
+	if err := validateSchedule(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректное правило повторения, время или часовой пояс"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Contract").Create(&series).Error; err != nil {
+			return err
+		}
+		if _, err := services.GenerateSeries(tx, &series, &contract, now.Add(services.SeriesHorizon)); err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, createdBy, c.ClientIP(), "Добавлена повторяющаяся задача «"+series.Title+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании повторяющейся задачи"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TaskSeriesResponse{Series: series, Upcoming: upcoming(&series, &contract)})
+}
+
+// Получение списка повторяющихся задач
+func (h *TaskSeriesHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.db.Model(&models.TaskSeries{}).
+		Joins("Contract").
+		Where("Contract.deleted_at IS NULL")
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ? AND Contract.status <> ?", userID, models.ContractDraft)
+	}
+
+	if contractID := c.Query("contract_id"); contractID != "" {
+		query = query.Where("task_series.contract_id = ?", contractID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var series []models.TaskSeries
+	if err := query.Order("task_series.created_at desc").Find(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении повторяющихся задач"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TaskSeriesListResponse{
+		Series: series,
+		Total:  total,
+	})
+}
+
+// Получение повторяющейся задачи с ближайшими датами
+func (h *TaskSeriesHandlers) Get(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	series, err := h.findSeries(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Повторяющаяся задача не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TaskSeriesResponse{Series: series, Upcoming: upcoming(&series, &series.Contract)})
+}
+
+// Изменение всей серии. Будущие нетронутые повторения обновляются,
+// измененные вручную повторения остаются как есть.
+func (h *TaskSeriesHandlers) Update(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	series, err := h.findSeries(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Повторяющаяся задача не найдена"})
+		return
+	}
+
+	var req UpdateTaskSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contract := series.Contract
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять задачи в контракте в текущем статусе"})
+		return
+	}
+
+	// Изменение шаблона переносится на будущие повторения на месте,
+	// изменение расписания требует пересоздать их
+	template := make(map[string]interface{})
+	if req.Title != "" {
+		series.Title = req.Title
+		template["title"] = req.Title
+	}
+	if req.Description != "" {
+		series.Description = req.Description
+		template["description"] = req.Description
+	}
+	if req.Points > 0 {
+		series.Points = req.Points
+		template["points"] = req.Points
+	}
+
+	reschedule := false
+	if req.Rule != "" {
+		series.Rule = req.Rule
+		reschedule = true
+	}
+	if req.DueTime != "" {
+		series.DueTime = req.DueTime
+		reschedule = true
+	}
+	if req.Timezone != "" {
+		series.Timezone = req.Timezone
+		reschedule = true
+	}
+	if req.EndsOn != nil {
+		endsOn := services.Date(*req.EndsOn)
+		series.EndsOn = &endsOn
+		reschedule = true
+	}
+
+	if err := validateSchedule(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректное правило повторения, время или часовой пояс"})
+		return
+	}
+
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&series).Omit("Contract").Updates(map[string]interface{}{
+			"title":       series.Title,
+			"description": series.Description,
+			"points":      series.Points,
+			"rule":        series.Rule,
+			"due_time":    series.DueTime,
+			"timezone":    series.Timezone,
+			"ends_on":     series.EndsOn,
+			"updated_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if reschedule {
+			if err := services.ResetSeries(tx, &series); err != nil {
+				return err
+			}
+		} else if len(template) > 0 {
+			template["updated_at"] = now
+			if err := tx.Model(&models.Task{}).
+				Where("series_id = ? AND is_exception = FALSE AND status = ? AND due_date > ?", series.ID, "pending", now).
+				Updates(template).Error; err != nil {
+				return err
+			}
+		}
+
+		if _, err := services.GenerateSeries(tx, &series, &contract, now.Add(services.SeriesHorizon)); err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Изменена повторяющаяся задача «"+series.Title+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении повторяющейся задачи"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TaskSeriesResponse{Series: series, Upcoming: upcoming(&series, &contract)})
+}
+
+// Удаление серии: будущие нетронутые повторения удаляются, остальные сохраняются
+func (h *TaskSeriesHandlers) Delete(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	series, err := h.findSeries(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Повторяющаяся задача не найдена"})
+		return
+	}
+
+	if !series.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять задачи из контракта в текущем статусе"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := services.ResetSeries(tx, &series); err != nil {
+			return err
+		}
+		if err := tx.Delete(&series).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, series.ContractID, userID.(string), c.ClientIP(), "Удалена повторяющаяся задача «"+series.Title+"»")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении повторяющейся задачи"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Повторяющаяся задача успешно удалена"})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -15,14 +16,36 @@ type CreateTaskRequest struct {
 	ContractID  string    `json:"contract_id" binding:"required"`
 	Points      int       `json:"points" binding:"required,min=0"`
 	DueDate     time.Time `json:"due_date" binding:"required"`
+	// Пункты чек-листа в порядке выполнения
+	Checklist     []string `json:"checklist" binding:"omitempty,max=50,dive,required,max=255"`
+	PartialCredit bool     `json:"partial_credit"`
 }
 
 type UpdateTaskRequest struct {
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Status      string    `json:"status" binding:"omitempty,oneof=pending completed failed"`
-	Points      int       `json:"points" binding:"omitempty,min=0"`
-	DueDate     time.Time `json:"due_date"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Status        string    `json:"status" binding:"omitempty,oneof=pending failed"`
+	Points        int       `json:"points" binding:"omitempty,min=0"`
+	DueDate       time.Time `json:"due_date"`
+	PartialCredit *bool     `json:"partial_credit"`
+}
+
+type SubmitTaskRequest struct {
+	Note string `json:"note"`
+}
+
+type ApproveTaskRequest struct {
+	// Начислить баллы пропорционально выполненным пунктам чек-листа.
+	// По умолчанию используется настройка задачи.
+	PartialCredit *bool `json:"partial_credit"`
+}
+
+type RejectTaskRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type TaskSubmissionsResponse struct {
+	Submissions []models.TaskSubmission `json:"submissions"`
 }
 
 type TaskResponse struct {
@@ -53,35 +76,55 @@ func (h *TaskHandlers) Create(c *gin.Context) {
 	// Проверяем существование контракта и права доступа
 	var contract models.Contract
 	userID, _ := c.Get("user_id")
-	if err := h.db.Where("id = ? AND parent_id = ?", req.ContractID, userID).First(&contract).Error; err != nil {
+	if err := h.db.Where("id = ? AND child_id IN (?)", req.ContractID, services.GuardedChildren(h.db, userID)).First(&contract).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
 		return
 	}
 
-	// Проверяем статус контракта
-	if contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять задачи в неактивный контракт"})
+	// В завершенный контракт задачи добавлять нельзя
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять задачи в контракт в текущем статусе"})
 		return
 	}
 
 	task := models.Task{
-		Title:       req.Title,
-		Description: req.Description,
-		ContractID:  req.ContractID,
-		Points:      req.Points,
-		Status:      "pending",
-		DueDate:     req.DueDate,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Title:         req.Title,
+		Description:   req.Description,
+		ContractID:    req.ContractID,
+		Points:        req.Points,
+		Status:        "pending",
+		DueDate:       req.DueDate,
+		PartialCredit: req.PartialCredit,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
-	if result := h.db.Create(&task); result.Error != nil {
+	// Новая задача в предложенном или подписанном контракте - это поправка к его условиям
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		for i, title := range req.Checklist {
+			item := models.TaskChecklistItem{
+				TaskID:    task.ID,
+				Title:     title,
+				Position:  i,
+				CreatedAt: task.CreatedAt,
+				UpdatedAt: task.CreatedAt,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+		return services.AmendContract(tx, contract.ID, userID.(string), c.ClientIP(), "Добавлена задача «"+task.Title+"»")
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании задачи"})
 		return
 	}
 
 	// Загружаем связанные данные
-	h.db.Preload("Contract").First(&task, task.ID)
+	h.loadTask(&task)
 
 	c.JSON(http.StatusCreated, TaskResponse{Task: task})
 }
@@ -98,7 +141,7 @@ func (h *TaskHandlers) List(c *gin.Context) {
 
 	// Фильтруем задачи в зависимости от роли пользователя
 	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("Contract.child_id = ?", userID)
 	}
@@ -121,6 +164,12 @@ func (h *TaskHandlers) List(c *gin.Context) {
 		return
 	}
 
+	// Прогресс чек-листов показывается в списке как "3/5"
+	if err := services.LoadProgress(h.db, tasks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении задач"})
+		return
+	}
+
 	c.JSON(http.StatusOK, TasksResponse{
 		Tasks: tasks,
 		Total: total,
@@ -139,7 +188,7 @@ func (h *TaskHandlers) Get(c *gin.Context) {
 		Where("tasks.id = ?", id)
 
 	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("Contract.child_id = ?", userID)
 	}
@@ -148,6 +197,7 @@ func (h *TaskHandlers) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
 		return
 	}
+	h.loadChecklist(&task)
 
 	c.JSON(http.StatusOK, TaskResponse{Task: task})
 }
@@ -164,7 +214,7 @@ func (h *TaskHandlers) Update(c *gin.Context) {
 		Where("tasks.id = ?", id)
 
 	if role == "parent" {
-		query = query.Where("Contract.parent_id = ?", userID)
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(h.db, userID))
 	} else {
 		query = query.Where("Contract.child_id = ?", userID)
 	}
@@ -180,9 +230,9 @@ func (h *TaskHandlers) Update(c *gin.Context) {
 		return
 	}
 
-	// Проверяем, что контракт активен
-	if task.Contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять задачи в неактивном контракте"})
+	// Условия задачи завершенного контракта менять нельзя
+	if !task.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменять задачи в контракте в текущем статусе"})
 		return
 	}
 
@@ -201,27 +251,96 @@ func (h *TaskHandlers) Update(c *gin.Context) {
 		if !req.DueDate.IsZero() {
 			updates["due_date"] = req.DueDate
 		}
+		if req.PartialCredit != nil {
+			updates["partial_credit"] = *req.PartialCredit
+		}
+		// Измененное вручную повторение серии больше не обновляется вместе с серией
+		if task.SeriesID != nil && len(updates) > 0 {
+			updates["is_exception"] = true
+		}
 	}
 
-	// Статус могут менять оба (и родитель, и ребенок)
+	// Выполнение задачи засчитывается только через отправку на проверку
+	// и подтверждение родителем, здесь родитель может лишь вернуть
+	// задачу в работу или отметить ее как проваленную
+	var transition *services.Transition
 	if req.Status != "" {
-		// Ребенок может только отмечать задачи как выполненные
-		if role == "child" && req.Status != "completed" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Ребенок может только отмечать задачи как выполненные"})
+		if role == "child" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Для отправки задачи на проверку используйте /submit"})
+			return
+		}
+		if !task.Contract.Actionable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+			return
+		}
+		// Решение по отправленной задаче записывается в историю проверок
+		if task.Status == models.TaskSubmitted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Задача ожидает проверки, используйте /approve или /reject"})
+			return
+		}
+		var err error
+		transition, err = services.TaskMachine.ActionTo(task.Status, req.Status, role.(string))
+		if !respondTransitionError(c, err, "Недопустимая смена статуса задачи") {
 			return
 		}
-		updates["status"] = req.Status
 	}
 
-	updates["updated_at"] = time.Now()
+	now := time.Now()
+	updates["updated_at"] = now
 
-	if err := h.db.Model(&task).Updates(updates).Error; err != nil {
+	// Обновление задачи и движение баллов выполняем в одной транзакции
+	previousStatus := task.Status
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if transition != nil {
+			if _, err := services.TaskMachine.Fire(tx, task.ID, transition.Action, previousStatus, role.(string), userID.(string), ""); err != nil {
+				return err
+			}
+			statusUpdates := map[string]interface{}{"status": transition.To, "updated_at": now}
+			if transition.Action == services.TaskFail {
+				statusUpdates["failed_at"] = now
+			} else {
+				statusUpdates["failed_at"] = nil
+			}
+			if err := services.SetStatus(tx, &models.Task{}, task.ID, previousStatus, statusUpdates); err != nil {
+				return err
+			}
+			if trigger := services.TaskTrigger(transition.To); trigger != "" {
+				if err := services.ApplyTaskRules(tx, task.ID, trigger, userID.(string), now); err != nil {
+					return err
+				}
+			}
+		}
+
+		if transition != nil && previousStatus == models.TaskCompleted {
+			if err := services.RevokeTask(tx, &task, task.Contract.ChildID, userID.(string)); err != nil {
+				return err
+			}
+			if err := services.RevokeStreakBonuses(tx, task.ID); err != nil {
+				return err
+			}
+		}
+
+		// Статус задачи в условия не входит, поэтому версия создается
+		// только при изменении названия, описания, баллов или срока
+		if role == "parent" {
+			return services.AmendContract(tx, task.ContractID, userID.(string), c.ClientIP(), "Изменена задача «"+task.Title+"»")
+		}
+		return nil
+	})
+	if !respondTransitionError(c, err, "Недопустимая смена статуса задачи") {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении задачи"})
 		return
 	}
 
 	// Перезагружаем данные задачи
-	h.db.Preload("Contract").First(&task, task.ID)
+	h.loadTask(&task)
 
 	c.JSON(http.StatusOK, TaskResponse{Task: task})
 }
@@ -234,23 +353,314 @@ func (h *TaskHandlers) Delete(c *gin.Context) {
 	var task models.Task
 	if err := h.db.Preload("Contract").
 		Joins("Contract").
-		Where("tasks.id = ? AND Contract.parent_id = ?", id, userID).
+		Where("tasks.id = ? AND Contract.child_id IN (?)", id, services.GuardedChildren(h.db, userID)).
 		First(&task).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
 		return
 	}
 
-	// Проверяем, что контракт активен
-	if task.Contract.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять задачи из неактивного контракта"})
+	// Из завершенного контракта задачи удалять нельзя
+	if !task.Contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя удалять задачи из контракта в текущем статусе"})
 		return
 	}
 
 	// Используем soft delete (благодаря gorm.DeletedAt в модели)
-	if err := h.db.Delete(&task).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, task.ContractID, userID.(string), c.ClientIP(), "Удалена задача «"+task.Title+"»")
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении задачи"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Задача успешно удалена"})
-} 
\ No newline at end of file
+}
+
+// Поиск задачи с учетом прав доступа пользователя
+func (h *TaskHandlers) findTask(db *gorm.DB, id string, userID, role interface{}) (models.Task, error) {
+	var task models.Task
+	query := db.Preload("Contract").
+		Joins("Contract").
+		Where("tasks.id = ?", id)
+
+	if role == "parent" {
+		query = query.Where("Contract.child_id IN (?)", services.GuardedChildren(db, userID))
+	} else {
+		query = query.Where("Contract.child_id = ?", userID)
+	}
+
+	err := query.First(&task).Error
+	return task, err
+}
+
+// loadTask перезагружает задачу вместе с контрактом и чек-листом
+func (h *TaskHandlers) loadTask(task *models.Task) {
+	h.db.Preload("Contract").First(task, "id = ?", task.ID)
+	h.loadChecklist(task)
+}
+
+// loadChecklist загружает чек-лист задачи и считает прогресс
+func (h *TaskHandlers) loadChecklist(task *models.Task) {
+	task.Checklist = nil
+	task.Progress = nil
+	checklists, err := services.LoadChecklists(h.db, []string{task.ID})
+	if err != nil || len(checklists[task.ID]) == 0 {
+		return
+	}
+
+	task.Checklist = checklists[task.ID]
+	done := 0
+	for _, item := range task.Checklist {
+		if item.CompletedAt != nil {
+			done++
+		}
+	}
+	task.Progress = models.NewTaskProgress(done, len(task.Checklist))
+}
+
+// Отправка задачи ребенком на проверку
+func (h *TaskHandlers) Submit(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req SubmitTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.findTask(h.db, id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	// Выполнять задачи можно только по подписанному контракту
+	if !task.Contract.Actionable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+
+	now := time.Now()
+	submission := models.TaskSubmission{
+		TaskID:    task.ID,
+		ChildID:   userID.(string),
+		Note:      req.Note,
+		Status:    "submitted",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		transition, err := services.TaskMachine.Fire(tx, task.ID, services.TaskSubmit, task.Status, role.(string), userID.(string), req.Note)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&submission).Error; err != nil {
+			return err
+		}
+		if err := services.SetStatus(tx, &task, task.ID, task.Status, map[string]interface{}{
+			"status":       transition.To,
+			"submitted_at": now,
+			"updated_at":   now,
+		}); err != nil {
+			return err
+		}
+		return services.ApplyTaskRules(tx, task.ID, models.RuleTaskSubmitted, userID.(string), now)
+	})
+	if !respondTransitionError(c, err, "На проверку можно отправить только задачу в работе") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отправке задачи на проверку"})
+		return
+	}
+
+	h.loadTask(&task)
+
+	c.JSON(http.StatusOK, TaskResponse{Task: task})
+}
+
+// Подтверждение выполнения задачи родителем
+func (h *TaskHandlers) Approve(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req ApproveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.findTask(h.db, id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	// Выполнять задачи можно только по подписанному контракту
+	if !task.Contract.Actionable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+
+	partial := task.PartialCredit
+	if req.PartialCredit != nil {
+		partial = *req.PartialCredit
+	}
+
+	// Родитель может подтвердить отправленную задачу или засчитать задачу в работе сам
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		transition, err := services.TaskMachine.Fire(tx, task.ID, services.TaskApprove, task.Status, role.(string), userID.(string), "")
+		if err != nil {
+			return err
+		}
+		if err := services.SetStatus(tx, &task, task.ID, task.Status, map[string]interface{}{
+			"status":           transition.To,
+			"reviewed_at":      now,
+			"reviewed_by":      userID,
+			"rejection_reason": "",
+			"updated_at":       now,
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.TaskSubmission{}).
+			Where("task_id = ? AND status = ?", task.ID, "submitted").
+			Updates(map[string]interface{}{
+				"status":      "approved",
+				"reviewed_by": userID,
+				"reviewed_at": now,
+				"updated_at":  now,
+			}).Error; err != nil {
+			return err
+		}
+
+		award := services.AwardTask
+		if partial {
+			award = services.AwardTaskPartial
+		}
+		if err := award(tx, &task, task.Contract.ChildID, userID.(string)); err != nil {
+			return err
+		}
+		if err := services.ApplyStreakBonuses(tx, &task, task.Contract.ChildID, userID.(string), now); err != nil {
+			return err
+		}
+		if err := services.ApplyTaskRules(tx, task.ID, models.RuleTaskCompleted, userID.(string), now); err != nil {
+			return err
+		}
+		_, err = services.AwardAchievements(tx, task.Contract.ChildID, now)
+		return err
+	})
+	if !respondTransitionError(c, err, "Задача не ожидает подтверждения") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подтверждении задачи"})
+		return
+	}
+
+	h.loadTask(&task)
+
+	c.JSON(http.StatusOK, TaskResponse{Task: task})
+}
+
+// Отклонение выполнения задачи родителем
+func (h *TaskHandlers) Reject(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req RejectTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.findTask(h.db, id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	// Выполнять задачи можно только по подписанному контракту
+	if !task.Contract.Actionable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Контракт еще не подписан всеми сторонами"})
+		return
+	}
+
+	// Отклоненная задача возвращается в работу с комментарием родителя
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		transition, err := services.TaskMachine.Fire(tx, task.ID, services.TaskReject, task.Status, role.(string), userID.(string), req.Reason)
+		if err != nil {
+			return err
+		}
+		if err := services.SetStatus(tx, &task, task.ID, task.Status, map[string]interface{}{
+			"status":           transition.To,
+			"reviewed_at":      now,
+			"reviewed_by":      userID,
+			"rejection_reason": req.Reason,
+			"updated_at":       now,
+		}); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.TaskSubmission{}).
+			Where("task_id = ? AND status = ?", task.ID, "submitted").
+			Updates(map[string]interface{}{
+				"status":        "rejected",
+				"review_reason": req.Reason,
+				"reviewed_by":   userID,
+				"reviewed_at":   now,
+				"updated_at":    now,
+			}).Error
+	})
+	if !respondTransitionError(c, err, "Задача не ожидает подтверждения") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отклонении задачи"})
+		return
+	}
+
+	h.loadTask(&task)
+
+	c.JSON(http.StatusOK, TaskResponse{Task: task})
+}
+
+// Получение истории отправок задачи на проверку
+func (h *TaskHandlers) Submissions(c *gin.Context) {
+	id := c.Param("id")
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, err := h.findTask(h.db, id, userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	var submissions []models.TaskSubmission
+	if err := h.db.Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).
+		Where("task_id = ?", task.ID).
+		Order("created_at desc").
+		Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении истории проверок"})
+		return
+	}
+	for i := range submissions {
+		withDownloadURLs(submissions[i].Attachments, userID.(string))
+	}
+
+	c.JSON(http.StatusOK, TaskSubmissionsResponse{Submissions: submissions})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type ContractTransitionRequest struct {
+	Action string `json:"action" binding:"required,oneof=propose withdraw complete terminate"`
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+type TransitionsResponse struct {
+	Status string `json:"status"`
+	// Действия, доступные текущему пользователю
+	Available   []string                 `json:"available"`
+	Transitions []models.StateTransition `json:"transitions"`
+}
+
+// respondTransitionError отвечает на ошибки конечного автомата и
+// сообщает, можно ли продолжать обработку запроса
+func respondTransitionError(c *gin.Context, err error, invalid string) bool {
+	switch {
+	case errors.Is(err, services.ErrTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Это действие недоступно для вашей роли"})
+		return false
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid})
+		return false
+	}
+	return true
+}
+
+// Выполнение действия над контрактом: предложение, отзыв, завершение, расторжение
+func (h *ContractHandlers) Transition(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var req ContractTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Action == services.ContractTerminate && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите причину расторжения контракта"})
+		return
+	}
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.TransitionContract(tx, contract.ID, req.Action, role.(string), userID.(string), c.ClientIP(), req.Reason)
+		return err
+	})
+	if !respondTransitionError(c, err, "Действие недоступно в текущем статусе контракта") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при смене статуса контракта"})
+		return
+	}
+
+	h.db.Preload("Parent").Preload("Child").
+		Preload("Tasks").Preload("Rewards").Preload("Signatures").
+		First(&contract, "id = ?", contract.ID)
+
+	c.JSON(http.StatusOK, ContractResponse{Contract: contract})
+}
+
+// Получение истории статусов контракта и доступных действий
+func (h *ContractHandlers) Transitions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	contract, err := h.findContract(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден"})
+		return
+	}
+
+	respondTransitions(c, h.db, services.ContractMachine, contract.ID, contract.Status, role.(string))
+}
+
+// Получение истории статусов задачи
+func (h *TaskHandlers) Transitions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	task, err := h.findTask(h.db, c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Задача не найдена"})
+		return
+	}
+
+	respondTransitions(c, h.db, services.TaskMachine, task.ID, task.Status, role.(string))
+}
+
+// Получение истории статусов награды
+func (h *RewardHandlers) Transitions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	reward, err := h.findReward(c.Param("id"), userID, role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Награда не найдена"})
+		return
+	}
+
+	respondTransitions(c, h.db, services.RewardMachine, reward.ID, reward.Status, role.(string))
+}
+
+func respondTransitions(c *gin.Context, db *gorm.DB, machine *services.StateMachine, id, status, role string) {
+	history, err := services.TransitionHistory(db, machine.Entity, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении истории статусов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TransitionsResponse{
+		Status:      status,
+		Available:   machine.Available(status, role),
+		Transitions: history,
+	})
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+type CreateWishRequest struct {
+	Title           string `json:"title" binding:"required,max=255"`
+	Description     string `json:"description" binding:"max=2000"`
+	Link            string `json:"link" binding:"omitempty,url,max=2000"`
+	SuggestedPoints int    `json:"suggested_points" binding:"min=0"`
+	Priority        int    `json:"priority" binding:"omitempty,min=1,max=5"`
+}
+
+type UpdateWishRequest struct {
+	Title           string  `json:"title" binding:"max=255"`
+	Description     *string `json:"description" binding:"omitempty,max=2000"`
+	Link            *string `json:"link" binding:"omitempty,max=2000"`
+	SuggestedPoints *int    `json:"suggested_points" binding:"omitempty,min=0"`
+	Priority        int     `json:"priority" binding:"omitempty,min=1,max=5"`
+}
+
+type PriceWishRequest struct {
+	Points int    `json:"points" binding:"min=0"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+type AcceptWishRequest struct {
+	ContractID string `json:"contract_id" binding:"required"`
+	// Стоимость награды, по умолчанию назначенная или предложенная
+	Points *int `json:"points" binding:"omitempty,min=0"`
+}
+
+type RejectWishRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+type WishResponse struct {
+	Wish models.Wish `json:"wish"`
+}
+
+type WishesResponse struct {
+	Wishes []models.Wish `json:"wishes"`
+	Total  int64         `json:"total"`
+}
+
+// Приоритет желания по умолчанию
+const defaultWishPriority = 3
+
+func NewWishHandlers(db *gorm.DB) *WishHandlers {
+	return &WishHandlers{db: db}
+}
+
+type WishHandlers struct {
+	db *gorm.DB
+}
+
+// Желания, доступные пользователю: родителю - желания его детей, ребенку - свои
+func (h *WishHandlers) visible(db *gorm.DB, userID, role interface{}) *gorm.DB {
+	query := db.Model(&models.Wish{})
+	if role == "parent" {
+		return query.Where("wishes.child_id IN (?)", services.GuardedChildren(h.db, userID))
+	}
+	return query.Where("wishes.child_id = ?", userID)
+}
+
+func (h *WishHandlers) find(c *gin.Context) (models.Wish, bool) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	var wish models.Wish
+	if err := h.visible(h.db, userID, role).Preload("Reward").
+		Where("wishes.id = ?", c.Param("id")).
+		First(&wish).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Желание не найдено"})
+		return wish, false
+	}
+	return wish, true
+}
+
+// Добавление желания ребенком
+func (h *WishHandlers) Create(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req CreateWishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Priority == 0 {
+		req.Priority = defaultWishPriority
+	}
+
+	now := time.Now()
+	wish := models.Wish{
+		ChildID:         userID.(string),
+		Title:           req.Title,
+		Description:     req.Description,
+		Link:            req.Link,
+		SuggestedPoints: req.SuggestedPoints,
+		Priority:        req.Priority,
+		Status:          models.WishPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := h.db.Omit("Reward").Create(&wish).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при добавлении желания"})
+		return
+	}
+
+	h.respond(c, http.StatusCreated, wish)
+}
+
+// Получение списка желаний
+func (h *WishHandlers) List(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+
+	query := h.visible(h.db, userID, role)
+	switch status := c.Query("status"); status {
+	case "":
+	case "open":
+		query = query.Where("wishes.status IN ?", []string{models.WishPending, models.WishPriced})
+	default:
+		query = query.Where("wishes.status = ?", status)
+	}
+	if childID := c.Query("child_id"); childID != "" {
+		query = query.Where("wishes.child_id = ?", childID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var wishes []models.Wish
+	if err := query.Preload("Reward").
+		Order("wishes.priority DESC, wishes.created_at DESC").
+		Find(&wishes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении списка желаний"})
+		return
+	}
+	if err := services.LoadWishProgress(h.db, wishes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении списка желаний"})
+		return
+	}
+
+	c.JSON(http.StatusOK, WishesResponse{Wishes: wishes, Total: total})
+}
+
+// Получение желания по ID
+func (h *WishHandlers) Get(c *gin.Context) {
+	wish, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	h.respond(c, http.StatusOK, wish)
+}
+
+// Изменение желания ребенком. Описание меняется, пока родитель не назначил
+// стоимость; приоритет можно менять, пока желание ждет решения.
+func (h *WishHandlers) Update(c *gin.Context) {
+	wish, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !wish.Open() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Родитель уже принял решение по желанию"})
+		return
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if req.Priority != 0 {
+		updates["priority"] = req.Priority
+	}
+	if req.Title != "" || req.Description != nil || req.Link != nil || req.SuggestedPoints != nil {
+		if wish.Status != models.WishPending {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Стоимость желания уже назначена, его нельзя изменить"})
+			return
+		}
+		if req.Title != "" {
+			updates["title"] = req.Title
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.Link != nil {
+			updates["link"] = *req.Link
+		}
+		if req.SuggestedPoints != nil {
+			updates["suggested_points"] = *req.SuggestedPoints
+		}
+	}
+
+	// Статус проверяется повторно на случай одновременного решения родителя
+	if err := services.SetStatus(h.db, &wish, wish.ID, wish.Status, updates); err != nil {
+		if errors.Is(err, services.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Желание изменилось, обновите страницу"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении желания"})
+		return
+	}
+
+	h.db.Preload("Reward").First(&wish, "id = ?", wish.ID)
+	h.respond(c, http.StatusOK, wish)
+}
+
+// Назначение стоимости желания родителем
+func (h *WishHandlers) Price(c *gin.Context) {
+	var req PriceWishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.decide(c, services.WishDecision{Action: services.WishPrice, Points: &req.Points, Note: req.Note})
+}
+
+// Принятие желания: по нему в контракте создается награда
+func (h *WishHandlers) Accept(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req AcceptWishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wish, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	// Награда добавляется только в контракт того же ребенка
+	var contract models.Contract
+	if err := h.db.Where("id = ? AND child_id = ? AND child_id IN (?)", req.ContractID, wish.ChildID, services.GuardedChildren(h.db, userID)).
+		First(&contract).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Контракт не найден или недостаточно прав"})
+		return
+	}
+	if !contract.TermsEditable() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя добавлять награды в контракт в текущем статусе"})
+		return
+	}
+
+	h.decide(c, services.WishDecision{
+		Action:     services.WishAccept,
+		Points:     req.Points,
+		ContractID: contract.ID,
+		IPAddress:  c.ClientIP(),
+	})
+}
+
+// Отклонение желания родителем с указанием причины
+func (h *WishHandlers) Reject(c *gin.Context) {
+	var req RejectWishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.decide(c, services.WishDecision{Action: services.WishReject, Note: req.Reason})
+}
+
+// Отзыв желания ребенком
+func (h *WishHandlers) Withdraw(c *gin.Context) {
+	h.decide(c, services.WishDecision{Action: services.WishWithdraw})
+}
+
+// Получение истории статусов желания
+func (h *WishHandlers) Transitions(c *gin.Context) {
+	role, _ := c.Get("role")
+
+	wish, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	respondTransitions(c, h.db, services.WishMachine, wish.ID, wish.Status, role.(string))
+}
+
+func (h *WishHandlers) decide(c *gin.Context, decision services.WishDecision) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+	decision.ActorID = userID.(string)
+
+	var wish *models.Wish
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var found models.Wish
+		if err := h.visible(tx, userID, role).
+			Select("wishes.id").
+			Where("wishes.id = ?", c.Param("id")).
+			First(&found).Error; err != nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		wish, err = services.DecideWish(tx, found.ID, role.(string), decision)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Желание не найдено"})
+		return
+	}
+	if !respondTransitionError(c, err, "Действие недоступно в текущем статусе желания") {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обработке желания"})
+		return
+	}
+
+	h.respond(c, http.StatusOK, *wish)
+}
+
+// respond отвечает желанием вместе с прогрессом накопления баллов
+func (h *WishHandlers) respond(c *gin.Context, status int, wish models.Wish) {
+	wishes := []models.Wish{wish}
+	if err := services.LoadWishProgress(h.db, wishes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении желания"})
+		return
+	}
+
+	c.JSON(status, WishResponse{Wish: wishes[0]})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -8,6 +9,9 @@ import (
 	"github.com/soulfeelings/parents-children-contracts/backend/database"
 	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
 	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/scheduler"
+	"github.com/soulfeelings/parents-children-contracts/backend/storage"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
 )
 
 func main() {
@@ -17,6 +21,9 @@ func main() {
 		log.Fatal("Ошибка загрузки конфигурации:", err)
 	}
 
+	// Настраиваем время жизни токенов
+	utils.ConfigureTokenTTL(cfg.JWTExpiration, cfg.RefreshExpiration)
+
 	// Подключаемся к базе данных
 	db, err := database.Connect(cfg)
 	if err != nil {
@@ -28,6 +35,18 @@ func main() {
 		log.Fatal("Ошибка применения миграций:", err)
 	}
 
+	// Запускаем фоновые задачи: просроченные задачи, истекшие награды,
+	// завершение контрактов и продление повторяющихся задач
+	if cfg.SchedulerEnabled {
+		scheduler.New(db, scheduler.Jobs(cfg.SchedulerInterval, cfg.TaskGracePeriod)...).Start(context.Background())
+	}
+
+	// Подключаем хранилище вложений
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("Ошибка инициализации хранилища файлов:", err)
+	}
+
 	// Инициализируем роутер
 	router := gin.Default()
 
@@ -43,6 +62,20 @@ func main() {
 	taskHandlers := handlers.NewTaskHandlers(db)
 	rewardHandlers := handlers.NewRewardHandlers(db)
 	settingsHandlers := handlers.NewSettingsHandlers(db)
+	ledgerHandlers := handlers.NewLedgerHandlers(db)
+	familyHandlers := handlers.NewFamilyHandlers(db)
+	invitationHandlers := handlers.NewInvitationHandlers(db, cfg.FrontendURL)
+	proposalHandlers := handlers.NewProposalHandlers(db)
+	taskSeriesHandlers := handlers.NewTaskSeriesHandlers(db)
+	ruleHandlers := handlers.NewContractRuleHandlers(db)
+	streakHandlers := handlers.NewStreakHandlers(db)
+	achievementHandlers := handlers.NewAchievementHandlers(db)
+	attachmentHandlers := handlers.NewAttachmentHandlers(db, store, cfg.AttachmentMaxSize)
+	templateHandlers := handlers.NewContractTemplateHandlers(db)
+	claimHandlers := handlers.NewRewardClaimHandlers(db)
+	catalogHandlers := handlers.NewRewardCatalogHandlers(db, store, cfg.AttachmentMaxSize)
+	wishHandlers := handlers.NewWishHandlers(db)
+	savingsGoalHandlers := handlers.NewSavingsGoalHandlers(db)
 
 	// Группы маршрутов
 	api := router.Group("/api")
@@ -51,12 +84,27 @@ func main() {
 		{
 			auth.POST("/register", authHandlers.Register)
 			auth.POST("/login", authHandlers.Login)
+			auth.POST("/pin-login", authHandlers.PinLogin)
+			auth.POST("/refresh", authHandlers.Refresh)
 		}
 
+		// Скачивание вложений по подписанной ссылке, без заголовка авторизации
+		api.GET("/attachments/:id/download", attachmentHandlers.Download)
+		api.GET("/reward-catalog/:id/image/download", catalogHandlers.DownloadImage)
+
 		// Защищенные маршруты
 		authorized := api.Group("")
-		authorized.Use(middleware.AuthMiddleware())
+		authorized.Use(middleware.AuthMiddleware(db))
 		{
+			session := authorized.Group("/auth")
+			{
+				session.POST("/logout", authHandlers.Logout)
+				session.POST("/logout-all", authHandlers.LogoutAll)
+				session.GET("/me", authHandlers.Me)
+				session.GET("/sessions", authHandlers.Sessions)
+				session.DELETE("/sessions/:id", authHandlers.RevokeSession)
+			}
+
 			contracts := authorized.Group("/contracts")
 			{
 				contracts.GET("/", contractHandlers.List)
@@ -64,6 +112,38 @@ func main() {
 				contracts.GET("/:id", contractHandlers.Get)
 				contracts.PUT("/:id", contractHandlers.Update)
 				contracts.DELETE("/:id", middleware.RoleMiddleware("parent"), contractHandlers.Delete)
+				contracts.GET("/:id/signatures", contractHandlers.Signatures)
+				contracts.GET("/:id/versions", contractHandlers.Versions)
+				contracts.GET("/:id/versions/:version", contractHandlers.Version)
+				contracts.GET("/:id/diff", contractHandlers.Diff)
+				contracts.POST("/:id/propose", middleware.RoleMiddleware("parent"), contractHandlers.Propose)
+				contracts.POST("/:id/withdraw", middleware.RoleMiddleware("parent"), contractHandlers.Withdraw)
+				contracts.POST("/:id/renew", middleware.RoleMiddleware("parent"), contractHandlers.Renew)
+				contracts.POST("/:id/sign", contractHandlers.Sign)
+				contracts.GET("/:id/transitions", contractHandlers.Transitions)
+				contracts.POST("/:id/transitions", contractHandlers.Transition)
+				contracts.GET("/:id/proposals", proposalHandlers.List)
+				contracts.POST("/:id/proposals", proposalHandlers.Create)
+			}
+
+			templates := authorized.Group("/contract-templates")
+			templates.Use(middleware.RoleMiddleware("parent"))
+			{
+				templates.GET("/", templateHandlers.List)
+				templates.POST("/", templateHandlers.Create)
+				templates.POST("/from-contract", templateHandlers.SaveFromContract)
+				templates.GET("/:id", templateHandlers.Get)
+				templates.PUT("/:id", templateHandlers.Update)
+				templates.DELETE("/:id", templateHandlers.Delete)
+				templates.POST("/:id/instantiate", templateHandlers.Instantiate)
+			}
+
+			proposals := authorized.Group("/proposals")
+			{
+				proposals.POST("/:id/accept", proposalHandlers.Accept)
+				proposals.POST("/:id/reject", proposalHandlers.Reject)
+				proposals.POST("/:id/counter", proposalHandlers.Counter)
+				proposals.POST("/:id/withdraw", proposalHandlers.Withdraw)
 			}
 
 			tasks := authorized.Group("/tasks")
@@ -73,6 +153,64 @@ func main() {
 				tasks.GET("/:id", taskHandlers.Get)
 				tasks.PUT("/:id", taskHandlers.Update)
 				tasks.DELETE("/:id", middleware.RoleMiddleware("parent"), taskHandlers.Delete)
+				tasks.GET("/:id/submissions", taskHandlers.Submissions)
+				tasks.GET("/:id/transitions", taskHandlers.Transitions)
+				tasks.POST("/:id/submit", middleware.RoleMiddleware("child"), taskHandlers.Submit)
+				tasks.POST("/:id/approve", middleware.RoleMiddleware("parent"), taskHandlers.Approve)
+				tasks.POST("/:id/reject", middleware.RoleMiddleware("parent"), taskHandlers.Reject)
+				tasks.POST("/:id/checklist", middleware.RoleMiddleware("parent"), taskHandlers.AddChecklistItem)
+				tasks.POST("/:id/checklist/reorder", middleware.RoleMiddleware("parent"), taskHandlers.ReorderChecklist)
+				tasks.PUT("/:id/checklist/:itemId", taskHandlers.UpdateChecklistItem)
+				tasks.DELETE("/:id/checklist/:itemId", middleware.RoleMiddleware("parent"), taskHandlers.DeleteChecklistItem)
+				tasks.GET("/:id/attachments", attachmentHandlers.List)
+				tasks.POST("/:id/attachments", attachmentHandlers.UploadToTask)
+				tasks.POST("/:id/submissions/:submissionId/attachments", middleware.RoleMiddleware("child"), attachmentHandlers.UploadToSubmission)
+			}
+
+			attachments := authorized.Group("/attachments")
+			{
+				attachments.GET("/:id", attachmentHandlers.Get)
+				attachments.DELETE("/:id", attachmentHandlers.Delete)
+			}
+
+			series := authorized.Group("/task-series")
+			{
+				series.GET("/", taskSeriesHandlers.List)
+				series.POST("/", middleware.RoleMiddleware("parent"), taskSeriesHandlers.Create)
+				series.GET("/:id", taskSeriesHandlers.Get)
+				series.PUT("/:id", middleware.RoleMiddleware("parent"), taskSeriesHandlers.Update)
+				series.DELETE("/:id", middleware.RoleMiddleware("parent"), taskSeriesHandlers.Delete)
+			}
+
+			rules := authorized.Group("/contract-rules")
+			{
+				rules.GET("/", ruleHandlers.List)
+				rules.POST("/", middleware.RoleMiddleware("parent"), ruleHandlers.Create)
+				rules.POST("/dry-run", middleware.RoleMiddleware("parent"), ruleHandlers.DryRunDraft)
+				rules.GET("/:id", ruleHandlers.Get)
+				rules.PUT("/:id", middleware.RoleMiddleware("parent"), ruleHandlers.Update)
+				rules.DELETE("/:id", middleware.RoleMiddleware("parent"), ruleHandlers.Delete)
+				rules.GET("/:id/firings", ruleHandlers.Firings)
+				rules.GET("/:id/dry-run", middleware.RoleMiddleware("parent"), ruleHandlers.DryRun)
+			}
+
+			streakBonuses := authorized.Group("/streak-bonuses")
+			{
+				streakBonuses.GET("/", streakHandlers.List)
+				streakBonuses.POST("/", middleware.RoleMiddleware("parent"), streakHandlers.Create)
+				streakBonuses.GET("/:id", streakHandlers.Get)
+				streakBonuses.PUT("/:id", middleware.RoleMiddleware("parent"), streakHandlers.Update)
+				streakBonuses.DELETE("/:id", middleware.RoleMiddleware("parent"), streakHandlers.Delete)
+				streakBonuses.GET("/:id/awards", streakHandlers.Awards)
+			}
+
+			achievements := authorized.Group("/achievements")
+			{
+				achievements.GET("/", achievementHandlers.List)
+				achievements.POST("/", middleware.RoleMiddleware("parent"), achievementHandlers.Create)
+				achievements.GET("/:id", achievementHandlers.Get)
+				achievements.PUT("/:id", middleware.RoleMiddleware("parent"), achievementHandlers.Update)
+				achievements.DELETE("/:id", middleware.RoleMiddleware("parent"), achievementHandlers.Delete)
 			}
 
 			rewards := authorized.Group("/rewards")
@@ -82,9 +220,109 @@ func main() {
 				rewards.GET("/:id", rewardHandlers.Get)
 				rewards.PUT("/:id", rewardHandlers.Update)
 				rewards.DELETE("/:id", middleware.RoleMiddleware("parent"), rewardHandlers.Delete)
+				rewards.GET("/:id/transitions", rewardHandlers.Transitions)
+				rewards.POST("/:id/claim", middleware.RoleMiddleware("child"), claimHandlers.Create)
+			}
+
+			claims := authorized.Group("/reward-claims")
+			{
+				claims.GET("/", claimHandlers.List)
+				claims.GET("/:id", claimHandlers.Get)
+				claims.GET("/:id/transitions", claimHandlers.Transitions)
+				claims.POST("/:id/approve", middleware.RoleMiddleware("parent"), claimHandlers.Approve)
+				claims.POST("/:id/reject", middleware.RoleMiddleware("parent"), claimHandlers.Reject)
+				claims.POST("/:id/fulfil", middleware.RoleMiddleware("parent"), claimHandlers.Fulfil)
+				claims.POST("/:id/cancel", middleware.RoleMiddleware("child"), claimHandlers.Cancel)
+			}
+
+			catalog := authorized.Group("/reward-catalog")
+			{
+				catalog.GET("/", catalogHandlers.List)
+				catalog.POST("/", middleware.RoleMiddleware("parent"), catalogHandlers.Create)
+				catalog.GET("/:id", catalogHandlers.Get)
+				catalog.PUT("/:id", middleware.RoleMiddleware("parent"), catalogHandlers.Update)
+				catalog.DELETE("/:id", middleware.RoleMiddleware("parent"), catalogHandlers.Delete)
+				catalog.POST("/:id/assign", middleware.RoleMiddleware("parent"), catalogHandlers.Assign)
+				catalog.GET("/:id/image", catalogHandlers.Image)
+				catalog.PUT("/:id/image", middleware.RoleMiddleware("parent"), catalogHandlers.UploadImage)
+				catalog.DELETE("/:id/image", middleware.RoleMiddleware("parent"), catalogHandlers.DeleteImage)
+			}
+
+			categories := authorized.Group("/reward-categories")
+			{
+				categories.GET("/", catalogHandlers.ListCategories)
+				categories.POST("/", middleware.RoleMiddleware("parent"), catalogHandlers.CreateCategory)
+				categories.PUT("/:id", middleware.RoleMiddleware("parent"), catalogHandlers.UpdateCategory)
+				categories.DELETE("/:id", middleware.RoleMiddleware("parent"), catalogHandlers.DeleteCategory)
+			}
+
+			wishes := authorized.Group("/wishes")
+			{
+				wishes.GET("/", wishHandlers.List)
+				wishes.POST("/", middleware.RoleMiddleware("child"), wishHandlers.Create)
+				wishes.GET("/:id", wishHandlers.Get)
+				wishes.PUT("/:id", middleware.RoleMiddleware("child"), wishHandlers.Update)
+				wishes.GET("/:id/transitions", wishHandlers.Transitions)
+				wishes.POST("/:id/price", middleware.RoleMiddleware("parent"), wishHandlers.Price)
+				wishes.POST("/:id/accept", middleware.RoleMiddleware("parent"), wishHandlers.Accept)
+				wishes.POST("/:id/reject", middleware.RoleMiddleware("parent"), wishHandlers.Reject)
+				wishes.POST("/:id/withdraw", middleware.RoleMiddleware("child"), wishHandlers.Withdraw)
+			}
+
+			savingsGoals := authorized.Group("/savings-goals")
+			{
+				savingsGoals.GET("/", savingsGoalHandlers.List)
+				savingsGoals.POST("/", savingsGoalHandlers.Create)
+				savingsGoals.GET("/:id", savingsGoalHandlers.Get)
+				savingsGoals.PUT("/:id", savingsGoalHandlers.Update)
+				savingsGoals.GET("/:id/progress", savingsGoalHandlers.Progress)
+				savingsGoals.GET("/:id/transitions", savingsGoalHandlers.Transitions)
+				savingsGoals.POST("/:id/deposit", savingsGoalHandlers.Deposit)
+				savingsGoals.POST("/:id/withdraw", savingsGoalHandlers.Withdraw)
+				savingsGoals.POST("/:id/claim", middleware.RoleMiddleware("child"), savingsGoalHandlers.Claim)
+				savingsGoals.POST("/:id/cancel", savingsGoalHandlers.Cancel)
+			}
+
+			families := authorized.Group("/families")
+			families.Use(middleware.FullAccessMiddleware())
+			{
+				families.GET("/", familyHandlers.List)
+				families.POST("/", middleware.RoleMiddleware("parent"), familyHandlers.Create)
+				families.GET("/:id", familyHandlers.Get)
+				families.PUT("/:id", middleware.RoleMiddleware("parent"), familyHandlers.Update)
+				families.DELETE("/:id/members/:userId", familyHandlers.RemoveMember)
+				families.POST("/:id/guardianships", middleware.RoleMiddleware("parent"), familyHandlers.CreateGuardianship)
+				families.DELETE("/:id/guardianships/:guardianshipId", middleware.RoleMiddleware("parent"), familyHandlers.DeleteGuardianship)
+				families.POST("/:id/children", middleware.RoleMiddleware("parent"), familyHandlers.CreateChildAccount)
+				families.PUT("/:id/children/:childId/pin", middleware.RoleMiddleware("parent"), familyHandlers.ResetChildPin)
+				families.GET("/:id/invitations", middleware.RoleMiddleware("parent"), invitationHandlers.List)
+				families.POST("/:id/invitations", middleware.RoleMiddleware("parent"), invitationHandlers.Create)
+			}
+
+			invitations := authorized.Group("/invitations")
+			invitations.Use(middleware.FullAccessMiddleware())
+			{
+				invitations.GET("/code/:code", invitationHandlers.Preview)
+				invitations.POST("/accept", invitationHandlers.Accept)
+				invitations.DELETE("/:id", middleware.RoleMiddleware("parent"), invitationHandlers.Revoke)
+			}
+
+			children := authorized.Group("/children")
+			{
+				children.GET("/", middleware.RoleMiddleware("parent"), familyHandlers.Children)
+				children.GET("/:id/balance", ledgerHandlers.Balance)
+				children.GET("/:id/streaks", streakHandlers.Streaks)
+				children.GET("/:id/achievements", achievementHandlers.Child)
+			}
+
+			ledger := authorized.Group("/ledger")
+			{
+				ledger.GET("/", ledgerHandlers.List)
+				ledger.POST("/adjustments", middleware.RoleMiddleware("parent"), ledgerHandlers.Adjust)
 			}
 
 			settings := authorized.Group("/settings")
+			settings.Use(middleware.FullAccessMiddleware())
 			{
 				settings.GET("/profile", settingsHandlers.GetProfile)
 				settings.PUT("/profile", settingsHandlers.UpdateProfile)
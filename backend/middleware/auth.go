@@ -5,10 +5,13 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
 	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware проверяет access-токен и то, что его сессия не отозвана
+func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -31,8 +34,31 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims.SessionID == "" || !services.SessionActive(db, claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		services.TouchSession(db, claims.SessionID, c.ClientIP())
+
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("scope", claims.Scope)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
+
+// FullAccessMiddleware запрещает доступ токенам с ограниченной областью
+// (например, ребенку, вошедшему по PIN-коду)
+func FullAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scope, _ := c.Get("scope"); scope == utils.ScopeChild {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,13 @@
+ALTER TABLE rewards DROP COLUMN IF EXISTS expiry_date;
+
+DROP FUNCTION IF EXISTS points_ledger_rewrite(TEXT);
+DROP TRIGGER IF EXISTS trg_points_ledger_immutable ON points_ledger;
+DROP FUNCTION IF EXISTS points_ledger_immutable();
+
+DROP INDEX IF EXISTS idx_points_ledger_created_at;
+DROP INDEX IF EXISTS idx_points_ledger_reward_id;
+DROP INDEX IF EXISTS idx_points_ledger_task_id;
+DROP INDEX IF EXISTS idx_points_ledger_contract_id;
+DROP INDEX IF EXISTS idx_points_ledger_child_contract;
+
+DROP TABLE IF EXISTS points_ledger;
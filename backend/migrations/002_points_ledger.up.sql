@@ -0,0 +1,49 @@
+-- Журнал начисления и списания баллов (только добавление записей)
+CREATE TABLE IF NOT EXISTS points_ledger (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    child_id UUID NOT NULL REFERENCES users(id),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    entry_type VARCHAR(20) NOT NULL CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund')),
+    amount INTEGER NOT NULL CHECK (amount <> 0),
+    task_id UUID NULL REFERENCES tasks(id),
+    reward_id UUID NULL REFERENCES rewards(id),
+    description TEXT,
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (
+        (entry_type = 'earn' AND amount > 0) OR
+        (entry_type = 'spend' AND amount < 0) OR
+        (entry_type = 'refund' AND amount > 0) OR
+        entry_type = 'adjust'
+    )
+);
+
+CREATE INDEX idx_points_ledger_child_contract ON points_ledger(child_id, contract_id);
+CREATE INDEX idx_points_ledger_contract_id ON points_ledger(contract_id);
+CREATE INDEX idx_points_ledger_task_id ON points_ledger(task_id);
+CREATE INDEX idx_points_ledger_reward_id ON points_ledger(reward_id);
+CREATE INDEX idx_points_ledger_created_at ON points_ledger(created_at);
+
+-- Запрещаем изменение и удаление записей журнала
+CREATE OR REPLACE FUNCTION points_ledger_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'points_ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_points_ledger_immutable
+    BEFORE UPDATE OR DELETE ON points_ledger
+    FOR EACH ROW EXECUTE FUNCTION points_ledger_immutable();
+
+-- Откат миграций, которые меняют или удаляют записи журнала, выполняет
+-- переданный запрос с временно отключенным триггером
+CREATE OR REPLACE FUNCTION points_ledger_rewrite(statement TEXT) RETURNS VOID AS $$
+BEGIN
+    ALTER TABLE points_ledger DISABLE TRIGGER trg_points_ledger_immutable;
+    EXECUTE statement;
+    ALTER TABLE points_ledger ENABLE TRIGGER trg_points_ledger_immutable;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Срок действия награды (модель Reward уже ожидает эту колонку)
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS expiry_date TIMESTAMP WITH TIME ZONE NULL;
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_task_submissions_status;
+DROP INDEX IF EXISTS idx_task_submissions_task_id;
+DROP TABLE IF EXISTS task_submissions;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS rejection_reason;
+ALTER TABLE tasks DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE tasks DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS submitted_at;
+
+UPDATE tasks SET status = 'pending' WHERE status = 'submitted';
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'completed', 'failed'));
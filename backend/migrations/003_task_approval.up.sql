@@ -0,0 +1,26 @@
+-- Статус submitted: задача отправлена ребенком на проверку родителю
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'submitted', 'completed', 'failed'));
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reviewed_by UUID NULL REFERENCES users(id);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+
+-- История отправок задачи на проверку
+CREATE TABLE IF NOT EXISTS task_submissions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id UUID NOT NULL REFERENCES tasks(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    note TEXT,
+    status VARCHAR(50) NOT NULL CHECK (status IN ('submitted', 'approved', 'rejected')),
+    review_reason TEXT,
+    reviewed_by UUID NULL REFERENCES users(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_task_submissions_task_id ON task_submissions(task_id);
+CREATE INDEX idx_task_submissions_status ON task_submissions(status);
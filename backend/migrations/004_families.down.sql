@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_guardianships_family_id;
+DROP INDEX IF EXISTS idx_guardianships_child_id;
+DROP INDEX IF EXISTS idx_family_members_user_id;
+DROP INDEX IF EXISTS idx_families_deleted_at;
+DROP INDEX IF EXISTS idx_families_owner_id;
+
+DROP TABLE IF EXISTS guardianships;
+DROP TABLE IF EXISTS family_members;
+DROP TABLE IF EXISTS families;
@@ -0,0 +1,60 @@
+-- Семьи: родитель-владелец и участники семьи
+CREATE TABLE IF NOT EXISTS families (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    owner_id UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE TABLE IF NOT EXISTS family_members (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    role VARCHAR(50) NOT NULL CHECK (role IN ('parent', 'child')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (family_id, user_id)
+);
+
+-- Опекунство: явная связь родителя с ребенком
+CREATE TABLE IF NOT EXISTS guardianships (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL REFERENCES families(id),
+    parent_id UUID NOT NULL REFERENCES users(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (parent_id, child_id)
+);
+
+CREATE INDEX idx_families_owner_id ON families(owner_id);
+CREATE INDEX idx_families_deleted_at ON families(deleted_at);
+CREATE INDEX idx_family_members_user_id ON family_members(user_id);
+CREATE INDEX idx_guardianships_child_id ON guardianships(child_id);
+CREATE INDEX idx_guardianships_family_id ON guardianships(family_id);
+
+-- Переносим существующие связи из контрактов: каждый родитель с контрактами
+-- получает семью, а дети из его контрактов становятся ее участниками
+INSERT INTO families (name, owner_id)
+SELECT 'Семья ' || u.username, u.id
+FROM users u
+WHERE u.role = 'parent'
+  AND u.deleted_at IS NULL
+  AND EXISTS (SELECT 1 FROM contracts c WHERE c.parent_id = u.id);
+
+INSERT INTO family_members (family_id, user_id, role)
+SELECT f.id, f.owner_id, 'parent'
+FROM families f
+ON CONFLICT DO NOTHING;
+
+INSERT INTO family_members (family_id, user_id, role)
+SELECT DISTINCT f.id, c.child_id, 'child'
+FROM families f
+JOIN contracts c ON c.parent_id = f.owner_id
+ON CONFLICT DO NOTHING;
+
+INSERT INTO guardianships (family_id, parent_id, child_id)
+SELECT DISTINCT ON (c.parent_id, c.child_id) f.id, c.parent_id, c.child_id
+FROM families f
+JOIN contracts c ON c.parent_id = f.owner_id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,16 @@
+-- Приглашения в семью для второго родителя или ребенка
+CREATE TABLE IF NOT EXISTS family_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL REFERENCES families(id),
+    code VARCHAR(32) NOT NULL UNIQUE,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('parent', 'child')),
+    email VARCHAR(255),
+    invited_by UUID NOT NULL REFERENCES users(id),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE NULL,
+    accepted_by UUID NULL REFERENCES users(id),
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_family_invitations_family_id ON family_invitations(family_id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_families_code;
+ALTER TABLE families DROP COLUMN IF EXISTS code;
+
+ALTER TABLE users DROP COLUMN IF EXISTS pin_locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_pin_attempts;
+ALTER TABLE users DROP COLUMN IF EXISTS managed_by;
+ALTER TABLE users DROP COLUMN IF EXISTS pin_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
+
+UPDATE users SET email = id::text || '@managed.local' WHERE email IS NULL;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
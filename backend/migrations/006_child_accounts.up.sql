@@ -0,0 +1,13 @@
+-- Детские аккаунты, которыми управляет родитель: без email, вход по PIN-коду
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pin_hash VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS managed_by UUID NULL REFERENCES users(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_pin_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pin_locked_until TIMESTAMP WITH TIME ZONE NULL;
+
+-- Код семьи, который ребенок вводит при входе по PIN-коду
+ALTER TABLE families ADD COLUMN IF NOT EXISTS code VARCHAR(16);
+UPDATE families SET code = upper(substr(md5(random()::text || id::text), 1, 8)) WHERE code IS NULL;
+ALTER TABLE families ALTER COLUMN code SET NOT NULL;
+CREATE UNIQUE INDEX idx_families_code ON families(code);
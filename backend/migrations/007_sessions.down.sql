@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_session_id;
+DROP INDEX IF EXISTS idx_sessions_user_id;
+
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
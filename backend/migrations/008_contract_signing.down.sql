@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_contract_signatures_contract_id;
+DROP TABLE IF EXISTS contract_signatures;
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS activated_at;
+ALTER TABLE contracts DROP COLUMN IF EXISTS proposed_at;
+ALTER TABLE contracts DROP COLUMN IF EXISTS terms_hash;
+ALTER TABLE contracts DROP COLUMN IF EXISTS co_signer_id;
+
+UPDATE contracts SET status = 'cancelled' WHERE status IN ('draft', 'pending_signature');
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_status_check;
+ALTER TABLE contracts ADD CONSTRAINT contracts_status_check
+    CHECK (status IN ('active', 'completed', 'cancelled'));
@@ -0,0 +1,25 @@
+-- Жизненный цикл контракта: draft -> pending_signature -> active
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_status_check;
+ALTER TABLE contracts ADD CONSTRAINT contracts_status_check
+    CHECK (status IN ('draft', 'pending_signature', 'active', 'completed', 'cancelled'));
+
+-- Второй родитель, чья подпись тоже требуется (необязательно)
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS co_signer_id UUID NULL REFERENCES users(id);
+-- Хеш условий, предложенных на подпись
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS terms_hash VARCHAR(64);
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS proposed_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS activated_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- Подписи сторон с хешем условий на момент подписания
+CREATE TABLE IF NOT EXISTS contract_signatures (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    signer_id UUID NOT NULL REFERENCES users(id),
+    signer_role VARCHAR(50) NOT NULL CHECK (signer_role IN ('parent', 'child', 'co_parent')),
+    terms_hash VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(64),
+    signed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (contract_id, signer_id, terms_hash)
+);
+
+CREATE INDEX idx_contract_signatures_contract_id ON contract_signatures(contract_id);
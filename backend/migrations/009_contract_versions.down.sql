@@ -0,0 +1,4 @@
+ALTER TABLE contract_signatures DROP COLUMN IF EXISTS version;
+ALTER TABLE contracts DROP COLUMN IF EXISTS current_version;
+
+DROP TABLE IF EXISTS contract_versions;
@@ -0,0 +1,16 @@
+-- Неизменяемые версии условий контракта
+CREATE TABLE IF NOT EXISTS contract_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    version INTEGER NOT NULL CHECK (version > 0),
+    terms JSONB NOT NULL,
+    terms_hash VARCHAR(64) NOT NULL,
+    summary TEXT,
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (contract_id, version)
+);
+
+-- Номер версии, под которой стоят подписи
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS current_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE contract_signatures ADD COLUMN IF NOT EXISTS version INTEGER NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_contract_proposals_open;
+DROP INDEX IF EXISTS idx_contract_proposals_contract_id;
+DROP TABLE IF EXISTS contract_proposals;
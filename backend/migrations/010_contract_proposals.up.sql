@@ -0,0 +1,23 @@
+-- Предложения об изменении условий контракта (переговоры ребенка и родителя)
+CREATE TABLE IF NOT EXISTS contract_proposals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    -- Предложение, на которое это предложение является встречным
+    parent_proposal_id UUID NULL REFERENCES contract_proposals(id),
+    author_id UUID NOT NULL REFERENCES users(id),
+    author_role VARCHAR(50) NOT NULL CHECK (author_role IN ('parent', 'child')),
+    status VARCHAR(50) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'accepted', 'rejected', 'countered', 'withdrawn')),
+    message TEXT,
+    changes JSONB NOT NULL,
+    base_version INTEGER NOT NULL DEFAULT 0,
+    applied_version INTEGER NULL,
+    response_message TEXT,
+    resolved_by UUID NULL REFERENCES users(id),
+    resolved_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_contract_proposals_contract_id ON contract_proposals(contract_id);
+-- По контракту может быть открыто только одно предложение
+CREATE UNIQUE INDEX idx_contract_proposals_open ON contract_proposals(contract_id) WHERE status = 'open';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_tasks_series_occurrence;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS is_exception;
+ALTER TABLE tasks DROP COLUMN IF EXISTS occurrence_date;
+ALTER TABLE tasks DROP COLUMN IF EXISTS series_id;
+
+DROP INDEX IF EXISTS idx_task_series_contract_id;
+DROP TABLE IF EXISTS task_series;
@@ -0,0 +1,32 @@
+-- Повторяющиеся задачи: правило повторения и шаблон задачи
+CREATE TABLE IF NOT EXISTS task_series (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    points INTEGER NOT NULL CHECK (points >= 0),
+    -- Правило в формате RRULE (подмножество RFC 5545)
+    rule VARCHAR(255) NOT NULL,
+    -- Время дня, к которому нужно выполнить задачу, ЧЧ:ММ
+    due_time VARCHAR(5) NOT NULL DEFAULT '20:00',
+    -- Часовой пояс семьи (IANA), в котором задано время выполнения
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    starts_on DATE NOT NULL,
+    ends_on DATE NULL,
+    -- Дата, до которой экземпляры задач уже созданы
+    generated_until DATE NULL,
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX idx_task_series_contract_id ON task_series(contract_id);
+
+-- Экземпляр серии: дата повторения и признак ручного изменения
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS series_id UUID NULL REFERENCES task_series(id);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS occurrence_date DATE NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS is_exception BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Одна задача на дату серии, включая удаленные: удаленное повторение не создается заново
+CREATE UNIQUE INDEX idx_tasks_series_occurrence ON tasks(series_id, occurrence_date) WHERE series_id IS NOT NULL;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS scheduler_runs;
+
+DROP INDEX IF EXISTS idx_contracts_end_date;
+DROP INDEX IF EXISTS idx_rewards_expiry_date;
+DROP INDEX IF EXISTS idx_tasks_due_date_pending;
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS failed_at;
+
+UPDATE rewards SET status = 'available' WHERE status = 'expired';
+ALTER TABLE rewards DROP CONSTRAINT IF EXISTS rewards_status_check;
+ALTER TABLE rewards ADD CONSTRAINT rewards_status_check
+    CHECK (status IN ('available', 'claimed', 'completed'));
@@ -0,0 +1,22 @@
+-- Награды с истекшим сроком получают статус expired
+ALTER TABLE rewards DROP CONSTRAINT IF EXISTS rewards_status_check;
+ALTER TABLE rewards ADD CONSTRAINT rewards_status_check
+    CHECK (status IN ('available', 'claimed', 'completed', 'expired'));
+
+-- Время автоматического завершения задач и контрактов
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- Индексы для периодических задач планировщика
+CREATE INDEX IF NOT EXISTS idx_tasks_due_date_pending ON tasks(due_date) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_rewards_expiry_date ON rewards(expiry_date) WHERE status = 'available';
+CREATE INDEX IF NOT EXISTS idx_contracts_end_date ON contracts(end_date) WHERE status = 'active';
+
+-- Последний запуск каждой задачи планировщика
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+    job VARCHAR(100) PRIMARY KEY,
+    last_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_duration_ms INTEGER NOT NULL DEFAULT 0,
+    affected INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT
+);
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS partial_credit;
+DROP TABLE IF EXISTS task_checklist_items;
@@ -0,0 +1,72 @@
+-- Шаблоны контрактов: заготовки задач и наград для быстрого создания контрактов
+CREATE TABLE IF NOT EXISTS contract_templates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- Владелец шаблона; у встроенных шаблонов владельца нет
+    owner_id UUID NULL REFERENCES users(id),
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    duration_days INTEGER NOT NULL CHECK (duration_days > 0),
+    tasks JSONB NOT NULL DEFAULT '[]',
+    rewards JSONB NOT NULL DEFAULT '[]',
+    is_builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL,
+    CHECK (is_builtin OR owner_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_contract_templates_owner_id ON contract_templates(owner_id);
+CREATE INDEX IF NOT EXISTS idx_contract_templates_deleted_at ON contract_templates(deleted_at);
+
+-- Встроенные стартовые шаблоны
+INSERT INTO contract_templates (id, title, description, duration_days, tasks, rewards, is_builtin) VALUES
+(
+    '00000000-0000-4000-8000-000000000101',
+    'Домашние дела на неделю',
+    'Ежедневные и еженедельные обязанности по дому',
+    7,
+    '[
+        {"title": "Заправить кровать", "points": 5, "due_day": 0, "due_time": "09:00", "rule": "FREQ=DAILY"},
+        {"title": "Помыть посуду после ужина", "points": 5, "due_day": 0, "due_time": "21:00", "rule": "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+        {"title": "Убрать в комнате", "points": 20, "due_day": 5, "due_time": "18:00",
+         "checklist": ["Убрать игрушки", "Протереть пыль", "Пропылесосить", "Вынести мусор"], "partial_credit": true}
+    ]',
+    '[
+        {"title": "Час мультфильмов", "points": 30},
+        {"title": "Поход в кино", "points": 100}
+    ]',
+    TRUE
+),
+(
+    '00000000-0000-4000-8000-000000000102',
+    'Учебная четверть',
+    'Домашние задания, чтение и итоговые оценки за четверть',
+    60,
+    '[
+        {"title": "Сделать домашнее задание", "points": 10, "due_day": 0, "due_time": "19:00", "rule": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+        {"title": "Читать 30 минут", "points": 5, "due_day": 0, "due_time": "21:00", "rule": "FREQ=DAILY"},
+        {"title": "Закончить четверть без троек", "points": 200, "due_day": 59, "due_time": "18:00"}
+    ]',
+    '[
+        {"title": "Новая книга", "points": 150},
+        {"title": "Поездка в парк развлечений", "points": 400}
+    ]',
+    TRUE
+),
+(
+    '00000000-0000-4000-8000-000000000103',
+    'Летние каникулы',
+    'Активный отдых, чтение и помощь по дому летом',
+    90,
+    '[
+        {"title": "Прогулка или спорт на свежем воздухе", "points": 5, "due_day": 0, "due_time": "20:00", "rule": "FREQ=DAILY"},
+        {"title": "Прочитать главу книги", "points": 10, "due_day": 0, "due_time": "21:00", "rule": "FREQ=WEEKLY;BYDAY=TU,TH,SA"},
+        {"title": "Помочь с уборкой квартиры", "points": 15, "due_day": 0, "due_time": "14:00", "rule": "FREQ=WEEKLY;BYDAY=SU"}
+    ]',
+    '[
+        {"title": "Ночевка у друга", "points": 120},
+        {"title": "Поход в аквапарк", "points": 300}
+    ]',
+    TRUE
+)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,19 @@
+-- Перенесенные баллы остаются в балансе как ручные корректировки
+SELECT points_ledger_rewrite($$UPDATE points_ledger SET entry_type = 'adjust' WHERE entry_type = 'carry_over'$$);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    entry_type = 'adjust'
+);
+
+DROP INDEX IF EXISTS idx_contracts_predecessor_id;
+ALTER TABLE contracts DROP COLUMN IF EXISTS carry_over_points;
+ALTER TABLE contracts DROP COLUMN IF EXISTS auto_renew;
+ALTER TABLE contracts DROP COLUMN IF EXISTS successor_id;
+ALTER TABLE contracts DROP COLUMN IF EXISTS predecessor_id;
@@ -0,0 +1,21 @@
+-- Продление контракта на следующий период: связь с предыдущим и следующим контрактом
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS predecessor_id UUID NULL REFERENCES contracts(id);
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS successor_id UUID NULL REFERENCES contracts(id);
+
+-- Настройки продления: автоматически при завершении и с переносом баллов
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS carry_over_points BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contracts_predecessor_id ON contracts(predecessor_id) WHERE predecessor_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Перенос непотраченных баллов: списание со старого контракта и зачисление на новый
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    entry_type IN ('adjust', 'carry_over')
+);
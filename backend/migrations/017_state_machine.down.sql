@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS state_transitions;
+
+UPDATE tasks SET status = 'failed' WHERE status = 'cancelled';
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'submitted', 'completed', 'failed'));
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS terminated_at;
+UPDATE contracts SET status = 'cancelled' WHERE status = 'terminated';
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_status_check;
+ALTER TABLE contracts ADD CONSTRAINT contracts_status_check
+    CHECK (status IN ('draft', 'pending_signature', 'active', 'completed', 'cancelled'));
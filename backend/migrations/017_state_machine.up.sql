@@ -0,0 +1,29 @@
+-- Расторгнутый контракт: статус cancelled заменяется на terminated,
+-- которым пользуется API
+UPDATE contracts SET status = 'terminated' WHERE status = 'cancelled';
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_status_check;
+ALTER TABLE contracts ADD CONSTRAINT contracts_status_check
+    CHECK (status IN ('draft', 'pending_signature', 'active', 'completed', 'terminated'));
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS terminated_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- Задачи расторгнутого контракта отменяются
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'submitted', 'completed', 'failed', 'cancelled'));
+
+-- История переходов контрактов, задач и наград между состояниями
+CREATE TABLE IF NOT EXISTS state_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    entity_type VARCHAR(20) NOT NULL CHECK (entity_type IN ('contract', 'task', 'reward')),
+    entity_id UUID NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    -- Пусто для переходов, выполненных планировщиком
+    actor_id UUID NULL REFERENCES users(id),
+    actor_role VARCHAR(20) NOT NULL CHECK (actor_role IN ('parent', 'child', 'system')),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_state_transitions_entity ON state_transitions(entity_type, entity_id, created_at);
@@ -0,0 +1,9 @@
+DELETE FROM state_transitions WHERE entity_type = 'reward_claim';
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward'));
+
+DROP INDEX IF EXISTS idx_points_ledger_claim_id;
+ALTER TABLE points_ledger DROP COLUMN IF EXISTS claim_id;
+
+DROP TABLE IF EXISTS reward_claims;
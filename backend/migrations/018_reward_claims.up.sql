@@ -0,0 +1,50 @@
+-- Запросы наград: каждый запрос - отдельная запись со своим жизненным циклом
+CREATE TABLE IF NOT EXISTS reward_claims (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reward_id UUID NOT NULL REFERENCES rewards(id),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled', 'fulfilled')),
+    -- Баллы, зарезервированные при запросе
+    points INTEGER NOT NULL CHECK (points >= 0),
+    note TEXT,
+    reviewed_by UUID NULL REFERENCES users(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE NULL,
+    rejection_reason TEXT,
+    fulfilled_by UUID NULL REFERENCES users(id),
+    fulfilled_at TIMESTAMP WITH TIME ZONE NULL,
+    fulfilment_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reward_claims_reward_id ON reward_claims(reward_id);
+CREATE INDEX idx_reward_claims_child_status ON reward_claims(child_id, status);
+
+-- Резервирование и возврат баллов ссылаются на запрос
+ALTER TABLE points_ledger ADD COLUMN IF NOT EXISTS claim_id UUID NULL REFERENCES reward_claims(id);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_claim_id ON points_ledger(claim_id);
+
+-- История переходов запросов хранится вместе с остальными сущностями
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward', 'reward_claim'));
+
+-- Уже запрошенные и выданные награды получают запись о запросе. Запрос
+-- резервирует столько баллов, сколько за награду действительно списано:
+-- при отклонении запроса без записи о списании ничего не возвращается
+INSERT INTO reward_claims (reward_id, contract_id, child_id, status, points, fulfilled_at, created_at, updated_at)
+SELECT rewards.id, rewards.contract_id, contracts.child_id,
+    CASE WHEN rewards.status = 'completed' THEN 'fulfilled' ELSE 'pending' END,
+    GREATEST(COALESCE(spent.points, 0), 0),
+    CASE WHEN rewards.status = 'completed' THEN rewards.updated_at END,
+    rewards.updated_at, rewards.updated_at
+FROM rewards
+JOIN contracts ON contracts.id = rewards.contract_id
+LEFT JOIN (
+    SELECT reward_id, -SUM(amount) AS points
+    FROM points_ledger
+    WHERE reward_id IS NOT NULL AND entry_type IN ('spend', 'refund')
+    GROUP BY reward_id
+) spent ON spent.reward_id = rewards.id
+WHERE rewards.status IN ('claimed', 'completed') AND rewards.deleted_at IS NULL;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_reward_claims_reward_created;
+
+ALTER TABLE rewards DROP CONSTRAINT IF EXISTS rewards_period_limit_check;
+ALTER TABLE rewards DROP COLUMN IF EXISTS timezone;
+ALTER TABLE rewards DROP COLUMN IF EXISTS cooldown_minutes;
+ALTER TABLE rewards DROP COLUMN IF EXISTS limit_period;
+ALTER TABLE rewards DROP COLUMN IF EXISTS period_limit;
+ALTER TABLE rewards DROP COLUMN IF EXISTS stock;
+ALTER TABLE rewards DROP COLUMN IF EXISTS repeatable;
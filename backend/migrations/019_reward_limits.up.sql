@@ -0,0 +1,17 @@
+-- Повторяемые награды: запас, лимит за период и пауза между запросами
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS repeatable BOOLEAN NOT NULL DEFAULT FALSE;
+-- Сколько раз награду можно получить всего; NULL - без ограничений
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS stock INTEGER NULL CHECK (stock >= 0);
+-- Не больше period_limit запросов за календарный день, неделю или месяц
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS period_limit INTEGER NOT NULL DEFAULT 0 CHECK (period_limit >= 0);
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS limit_period VARCHAR(10) NOT NULL DEFAULT ''
+    CHECK (limit_period IN ('', 'day', 'week', 'month'));
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS cooldown_minutes INTEGER NOT NULL DEFAULT 0 CHECK (cooldown_minutes >= 0);
+-- Часовой пояс, в котором отсчитываются периоды
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE rewards ADD CONSTRAINT rewards_period_limit_check
+    CHECK ((period_limit = 0) = (limit_period = ''));
+
+-- Подсчет запросов награды за период
+CREATE INDEX IF NOT EXISTS idx_reward_claims_reward_created ON reward_claims(reward_id, created_at);
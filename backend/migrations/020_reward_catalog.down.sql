@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_rewards_catalog_reward_id;
+
+ALTER TABLE rewards
+    DROP COLUMN IF EXISTS custom_points,
+    DROP COLUMN IF EXISTS catalog_reward_id;
+
+DROP TABLE IF EXISTS catalog_rewards;
+DROP TABLE IF EXISTS reward_categories;
@@ -0,0 +1,44 @@
+-- Категории семейного каталога наград
+CREATE TABLE IF NOT EXISTS reward_categories (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reward_categories_family_name
+    ON reward_categories(family_id, LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_reward_categories_deleted_at ON reward_categories(deleted_at);
+
+-- Семейный каталог наград: одна запись используется во многих контрактах
+CREATE TABLE IF NOT EXISTS catalog_rewards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    category_id UUID NULL REFERENCES reward_categories(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    points INTEGER NOT NULL CHECK (points >= 0),
+    -- Изображение награды в файловом хранилище
+    image_key VARCHAR(500) NULL UNIQUE,
+    image_content_type VARCHAR(100) NULL,
+    image_size BIGINT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_rewards_family_id ON catalog_rewards(family_id);
+CREATE INDEX IF NOT EXISTS idx_catalog_rewards_category_id ON catalog_rewards(category_id);
+CREATE INDEX IF NOT EXISTS idx_catalog_rewards_deleted_at ON catalog_rewards(deleted_at);
+
+-- Награда контракта может ссылаться на запись каталога. custom_points
+-- означает, что стоимость задана для этого контракта отдельно.
+ALTER TABLE rewards
+    ADD COLUMN IF NOT EXISTS catalog_reward_id UUID NULL REFERENCES catalog_rewards(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS custom_points BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_rewards_catalog_reward_id ON rewards(catalog_reward_id);
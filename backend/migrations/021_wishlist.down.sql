@@ -0,0 +1,6 @@
+DELETE FROM state_transitions WHERE entity_type = 'wish';
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward', 'reward_claim'));
+
+DROP TABLE IF EXISTS wishes;
@@ -0,0 +1,35 @@
+-- Список желаний ребенка: предложенные награды, которые родители оценивают
+-- и добавляют в контракт или отклоняют
+CREATE TABLE IF NOT EXISTS wishes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    child_id UUID NOT NULL REFERENCES users(id),
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    link VARCHAR(2000),
+    -- Стоимость, которую предлагает ребенок
+    suggested_points INTEGER NOT NULL CHECK (suggested_points >= 0),
+    priority SMALLINT NOT NULL DEFAULT 3 CHECK (priority BETWEEN 1 AND 5),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'priced', 'accepted', 'rejected', 'withdrawn')),
+    -- Стоимость, назначенная родителем
+    price INTEGER NULL CHECK (price >= 0),
+    price_note TEXT,
+    priced_by UUID NULL REFERENCES users(id),
+    priced_at TIMESTAMP WITH TIME ZONE NULL,
+    reviewed_by UUID NULL REFERENCES users(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE NULL,
+    rejection_reason TEXT,
+    -- Награда, созданная по желанию
+    contract_id UUID NULL REFERENCES contracts(id),
+    reward_id UUID NULL REFERENCES rewards(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (status <> 'accepted' OR reward_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wishes_child_status ON wishes(child_id, status);
+CREATE INDEX IF NOT EXISTS idx_wishes_reward_id ON wishes(reward_id);
+
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward', 'reward_claim', 'wish'));
@@ -0,0 +1,23 @@
+DELETE FROM state_transitions WHERE entity_type = 'savings_goal';
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward', 'reward_claim', 'wish'));
+
+-- Отложенные баллы возвращаются в баланс вместе с удалением записей
+SELECT points_ledger_rewrite($$DELETE FROM points_ledger WHERE entry_type IN ('lock', 'release')$$);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    entry_type IN ('adjust', 'carry_over')
+);
+
+DROP INDEX IF EXISTS idx_points_ledger_goal_id;
+ALTER TABLE points_ledger DROP COLUMN IF EXISTS goal_id;
+
+DROP TABLE IF EXISTS savings_goals;
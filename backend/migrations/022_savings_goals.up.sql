@@ -0,0 +1,46 @@
+-- Цели накопления: ребенок откладывает баллы контракта на крупную награду
+-- или на произвольную сумму
+CREATE TABLE IF NOT EXISTS savings_goals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    child_id UUID NOT NULL REFERENCES users(id),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    title VARCHAR(255) NOT NULL,
+    -- Цель - награда контракта или сумма баллов
+    reward_id UUID NULL REFERENCES rewards(id),
+    target_points INTEGER NULL CHECK (target_points > 0),
+    deadline DATE NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'reached', 'claimed', 'cancelled')),
+    -- Запрос награды, в который превратилась достигнутая цель
+    claim_id UUID NULL REFERENCES reward_claims(id),
+    created_by UUID NOT NULL REFERENCES users(id),
+    reached_at TIMESTAMP WITH TIME ZONE NULL,
+    closed_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((reward_id IS NULL) <> (target_points IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_savings_goals_child_status ON savings_goals(child_id, status);
+CREATE INDEX IF NOT EXISTS idx_savings_goals_contract_id ON savings_goals(contract_id);
+
+-- Отложенные баллы: lock убирает их из доступного баланса, release возвращает
+ALTER TABLE points_ledger ADD COLUMN IF NOT EXISTS goal_id UUID NULL REFERENCES savings_goals(id);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_goal_id ON points_ledger(goal_id);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over', 'lock', 'release'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    (entry_type = 'lock' AND amount < 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'release' AND amount > 0 AND goal_id IS NOT NULL) OR
+    entry_type IN ('adjust', 'carry_over')
+);
+
+ALTER TABLE state_transitions DROP CONSTRAINT IF EXISTS state_transitions_entity_type_check;
+ALTER TABLE state_transitions ADD CONSTRAINT state_transitions_entity_type_check
+    CHECK (entity_type IN ('contract', 'task', 'reward', 'reward_claim', 'wish', 'savings_goal'));
@@ -0,0 +1,21 @@
+-- Бонусы и штрафы правил удаляются из журнала вместе с правилами
+SELECT points_ledger_rewrite($$DELETE FROM points_ledger WHERE entry_type IN ('bonus', 'penalty')$$);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over', 'lock', 'release'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    (entry_type = 'lock' AND amount < 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'release' AND amount > 0 AND goal_id IS NOT NULL) OR
+    entry_type IN ('adjust', 'carry_over')
+);
+
+DROP INDEX IF EXISTS idx_points_ledger_rule_id;
+ALTER TABLE points_ledger DROP COLUMN IF EXISTS rule_id;
+
+DROP TABLE IF EXISTS rule_firings;
+DROP TABLE IF EXISTS contract_rules;
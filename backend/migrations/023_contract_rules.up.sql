@@ -0,0 +1,70 @@
+-- Правила контракта: бонусы и штрафы, которые начисляются автоматически
+-- по событиям задач и итогам периодов
+CREATE TABLE IF NOT EXISTS contract_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    name VARCHAR(255) NOT NULL,
+    trigger VARCHAR(30) NOT NULL
+        CHECK (trigger IN ('task_submitted', 'task_completed', 'task_failed', 'task_overdue', 'schedule')),
+    -- Сколько раз правило может сработать: по разу на задачу, период или контракт
+    scope VARCHAR(20) NOT NULL DEFAULT 'task'
+        CHECK (scope IN ('task', 'day', 'week', 'month', 'contract')),
+    conditions JSONB NOT NULL DEFAULT '[]',
+    action VARCHAR(20) NOT NULL CHECK (action IN ('award', 'deduct', 'unlock_reward')),
+    points INTEGER NOT NULL DEFAULT 0 CHECK (points >= 0),
+    reward_id UUID NULL REFERENCES rewards(id),
+    -- Часовой пояс, в котором отсчитываются периоды и день недели события
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL,
+    CHECK ((action = 'unlock_reward') = (reward_id IS NOT NULL)),
+    CHECK (action = 'unlock_reward' OR points > 0),
+    CHECK (trigger <> 'schedule' OR scope <> 'task')
+);
+
+CREATE INDEX IF NOT EXISTS idx_contract_rules_contract_trigger ON contract_rules(contract_id, trigger)
+    WHERE deleted_at IS NULL AND enabled;
+CREATE INDEX IF NOT EXISTS idx_contract_rules_reward_id ON contract_rules(reward_id);
+
+-- Срабатывания правил. Ключ определяет задачу или период, поэтому
+-- повторная проверка того же события ничего не начисляет
+CREATE TABLE IF NOT EXISTS rule_firings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rule_id UUID NOT NULL REFERENCES contract_rules(id),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    task_id UUID NULL REFERENCES tasks(id),
+    firing_key VARCHAR(100) NOT NULL,
+    trigger VARCHAR(30) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    -- Фактически начисленные (или списанные, со знаком минус) баллы
+    points INTEGER NOT NULL DEFAULT 0,
+    reward_id UUID NULL REFERENCES rewards(id),
+    fired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (rule_id, firing_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_firings_contract_id ON rule_firings(contract_id, fired_at);
+CREATE INDEX IF NOT EXISTS idx_rule_firings_reward_id ON rule_firings(reward_id) WHERE reward_id IS NOT NULL;
+
+-- Бонусы и штрафы по правилам
+ALTER TABLE points_ledger ADD COLUMN IF NOT EXISTS rule_id UUID NULL REFERENCES contract_rules(id);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_rule_id ON points_ledger(rule_id);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over', 'lock', 'release', 'bonus', 'penalty'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    (entry_type = 'lock' AND amount < 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'release' AND amount > 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'bonus' AND amount > 0 AND rule_id IS NOT NULL) OR
+    (entry_type = 'penalty' AND amount < 0 AND rule_id IS NOT NULL) OR
+    entry_type IN ('adjust', 'carry_over')
+);
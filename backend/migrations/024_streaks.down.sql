@@ -0,0 +1,23 @@
+-- Бонусы за серии удаляются из журнала вместе с серией
+SELECT points_ledger_rewrite($$DELETE FROM points_ledger WHERE entry_type = 'streak'$$);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over', 'lock', 'release', 'bonus', 'penalty'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    (entry_type = 'lock' AND amount < 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'release' AND amount > 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'bonus' AND amount > 0 AND rule_id IS NOT NULL) OR
+    (entry_type = 'penalty' AND amount < 0 AND rule_id IS NOT NULL) OR
+    entry_type IN ('adjust', 'carry_over')
+);
+
+DROP INDEX IF EXISTS idx_points_ledger_streak_bonus_id;
+ALTER TABLE points_ledger DROP COLUMN IF EXISTS streak_bonus_id;
+
+DROP TABLE IF EXISTS streak_awards;
+DROP TABLE IF EXISTS streak_bonuses;
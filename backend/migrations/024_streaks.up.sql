@@ -0,0 +1,69 @@
+-- Бонусы за серии: множитель баллов, пока серия не короче length,
+-- или разовый бонус, когда серия достигает length
+CREATE TABLE IF NOT EXISTS streak_bonuses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    -- day - дни подряд, в которые все задачи выполнены вовремя,
+    -- series - повторения одной повторяющейся задачи подряд
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('day', 'series')),
+    -- Только для series; пустое значение - любая повторяющаяся задача
+    series_id UUID NULL REFERENCES task_series(id),
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('multiplier', 'milestone')),
+    length INTEGER NOT NULL CHECK (length BETWEEN 2 AND 366),
+    -- Множитель в процентах: 150 - это x1.5
+    multiplier_percent INTEGER NOT NULL DEFAULT 0,
+    points INTEGER NOT NULL DEFAULT 0,
+    -- Часовой пояс, в котором отсчитываются дни серии
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL,
+    CHECK (
+        (kind = 'multiplier' AND multiplier_percent BETWEEN 101 AND 1000 AND points = 0) OR
+        (kind = 'milestone' AND points > 0 AND multiplier_percent = 0)
+    ),
+    CHECK (scope = 'series' OR series_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_streak_bonuses_contract_id ON streak_bonuses(contract_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_streak_bonuses_series_id ON streak_bonuses(series_id);
+
+-- Начисленные бонусы за серии. Множитель начисляется по разу на задачу,
+-- бонус за рубеж - по разу на день или повторение, в котором серия его достигла
+CREATE TABLE IF NOT EXISTS streak_awards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    bonus_id UUID NOT NULL REFERENCES streak_bonuses(id),
+    contract_id UUID NOT NULL REFERENCES contracts(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    task_id UUID NOT NULL REFERENCES tasks(id),
+    award_key VARCHAR(100) NOT NULL,
+    -- Длина серии на момент начисления
+    length INTEGER NOT NULL,
+    points INTEGER NOT NULL CHECK (points > 0),
+    awarded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (bonus_id, award_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_streak_awards_task_id ON streak_awards(task_id);
+CREATE INDEX IF NOT EXISTS idx_streak_awards_contract_id ON streak_awards(contract_id, awarded_at);
+
+-- Бонусы за серии в журнале баллов
+ALTER TABLE points_ledger ADD COLUMN IF NOT EXISTS streak_bonus_id UUID NULL REFERENCES streak_bonuses(id);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_streak_bonus_id ON points_ledger(streak_bonus_id);
+
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_entry_type_check;
+ALTER TABLE points_ledger DROP CONSTRAINT IF EXISTS points_ledger_check;
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_entry_type_check
+    CHECK (entry_type IN ('earn', 'spend', 'adjust', 'refund', 'carry_over', 'lock', 'release', 'bonus', 'penalty', 'streak'));
+ALTER TABLE points_ledger ADD CONSTRAINT points_ledger_check CHECK (
+    (entry_type = 'earn' AND amount > 0) OR
+    (entry_type = 'spend' AND amount < 0) OR
+    (entry_type = 'refund' AND amount > 0) OR
+    (entry_type = 'lock' AND amount < 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'release' AND amount > 0 AND goal_id IS NOT NULL) OR
+    (entry_type = 'bonus' AND amount > 0 AND rule_id IS NOT NULL) OR
+    (entry_type = 'penalty' AND amount < 0 AND rule_id IS NOT NULL) OR
+    (entry_type = 'streak' AND amount > 0 AND streak_bonus_id IS NOT NULL AND task_id IS NOT NULL) OR
+    entry_type IN ('adjust', 'carry_over')
+);
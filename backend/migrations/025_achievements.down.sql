@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS child_achievements;
+DROP TABLE IF EXISTS achievements;
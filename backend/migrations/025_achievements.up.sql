@@ -0,0 +1,50 @@
+-- Достижения: встроенные (без семьи, доступны всем детям) и заданные
+-- родителями семьи. Достижение открывается, когда показатель ребенка
+-- metric достигает threshold.
+CREATE TABLE IF NOT EXISTS achievements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    family_id UUID NULL REFERENCES families(id),
+    -- Код встроенного достижения
+    code VARCHAR(50) NULL UNIQUE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    icon VARCHAR(50) NOT NULL DEFAULT '',
+    metric VARCHAR(50) NOT NULL CHECK (metric IN (
+        'tasks.completed', 'tasks.on_time', 'streak.days', 'points.earned',
+        'rewards.received', 'goals.reached', 'goals.max_target', 'contracts.completed'
+    )),
+    threshold INTEGER NOT NULL CHECK (threshold > 0),
+    created_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL,
+    CHECK ((family_id IS NULL) = (code IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_achievements_family_id ON achievements(family_id) WHERE deleted_at IS NULL;
+
+-- Открытые достижения. Каждое достижение открывается ребенку один раз.
+CREATE TABLE IF NOT EXISTS child_achievements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    achievement_id UUID NOT NULL REFERENCES achievements(id),
+    child_id UUID NOT NULL REFERENCES users(id),
+    -- Значение показателя в момент открытия
+    value INTEGER NOT NULL,
+    -- Открыто пересчетом истории, а не событием
+    backfilled BOOLEAN NOT NULL DEFAULT FALSE,
+    awarded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (achievement_id, child_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_child_achievements_child_id ON child_achievements(child_id, awarded_at);
+
+INSERT INTO achievements (code, title, description, icon, metric, threshold) VALUES
+    ('first_task', 'Первая задача', 'Выполнить первую задачу', 'star', 'tasks.completed', 1),
+    ('first_week', 'Первая неделя', 'Семь дней подряд выполнять все задачи вовремя', 'calendar', 'streak.days', 7),
+    ('tasks_100', '100 задач', 'Выполнить сто задач', 'trophy', 'tasks.completed', 100),
+    ('on_time_50', 'Пунктуальность', 'Выполнить вовремя пятьдесят задач', 'clock', 'tasks.on_time', 50),
+    ('points_1000', 'Тысяча баллов', 'Заработать тысячу баллов', 'coins', 'points.earned', 1000),
+    ('first_reward', 'Первая награда', 'Получить первую награду', 'gift', 'rewards.received', 1),
+    ('big_saver', 'Большая цель', 'Накопить на цель стоимостью от 500 баллов', 'piggy-bank', 'goals.max_target', 500),
+    ('contract_completed', 'Контракт выполнен', 'Довести контракт до завершения', 'handshake', 'contracts.completed', 1)
+ON CONFLICT (code) DO NOTHING;
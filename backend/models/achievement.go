@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Показатели ребенка, по которым открываются достижения. Считаются по
+// всем контрактам ребенка.
+const (
+	MetricTasksCompleted = "tasks.completed"
+	// Задачи, отправленные на проверку до срока
+	MetricTasksOnTime = "tasks.on_time"
+	// Лучшая серия дней подряд, в которые все задачи выполнены вовремя
+	MetricStreakDays = "streak.days"
+	// Заработанные баллы: за задачи, бонусы правил и серий
+	MetricPointsEarned = "points.earned"
+	// Выданные награды
+	MetricRewardsReceived = "rewards.received"
+	MetricGoalsReached    = "goals.reached"
+	// Наибольшая достигнутая цель накопления, в баллах
+	MetricGoalsMaxTarget     = "goals.max_target"
+	MetricContractsCompleted = "contracts.completed"
+)
+
+// Achievement - определение достижения. Встроенные достижения имеют код
+// и доступны всем детям, остальные задают родители для своей семьи.
+type Achievement struct {
+	ID          string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID    *string        `gorm:"type:uuid" json:"family_id"`
+	Code        *string        `json:"code"`
+	Title       string         `gorm:"not null" json:"title"`
+	Description string         `json:"description"`
+	Icon        string         `json:"icon"`
+	Metric      string         `gorm:"not null" json:"metric"`
+	Threshold   int            `gorm:"not null" json:"threshold"`
+	CreatedBy   *string        `gorm:"type:uuid" json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BuiltIn сообщает, что достижение встроенное и не изменяется родителями
+func (a *Achievement) BuiltIn() bool {
+	return a.FamilyID == nil
+}
+
+// ChildAchievement - достижение, открытое ребенком
+type ChildAchievement struct {
+	ID            string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	AchievementID string `gorm:"type:uuid;not null" json:"achievement_id"`
+	ChildID       string `gorm:"type:uuid;not null" json:"child_id"`
+	// Значение показателя в момент открытия
+	Value int `gorm:"not null" json:"value"`
+	// Открыто пересчетом истории, а не событием
+	Backfilled bool      `gorm:"not null" json:"backfilled"`
+	AwardedAt  time.Time `json:"awarded_at"`
+}
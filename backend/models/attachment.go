@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment - файл, приложенный к задаче или к отправке задачи на проверку.
+// Содержимое лежит в файловом хранилище под ключом StorageKey.
+type Attachment struct {
+	ID           string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TaskID       string         `gorm:"type:uuid;not null" json:"task_id"`
+	SubmissionID *string        `gorm:"type:uuid" json:"submission_id"`
+	UploadedBy   string         `gorm:"type:uuid;not null" json:"uploaded_by"`
+	FileName     string         `gorm:"not null" json:"file_name"`
+	ContentType  string         `gorm:"not null" json:"content_type"`
+	Size         int64          `gorm:"not null" json:"size"`
+	StorageKey   string         `gorm:"not null" json:"-"`
+	Checksum     string         `gorm:"not null" json:"checksum"`
+	DownloadURL  string         `gorm:"-" json:"download_url,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
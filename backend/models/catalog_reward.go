@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RewardCategory - категория семейного каталога наград
+type RewardCategory struct {
+	ID        string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID  string         `gorm:"type:uuid;not null" json:"family_id"`
+	Name      string         `gorm:"not null" json:"name"`
+	Position  int            `gorm:"not null" json:"position"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CatalogReward - награда семейного каталога. Контракты ссылаются на нее
+// своими наградами и могут задавать собственную стоимость.
+type CatalogReward struct {
+	ID          string          `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID    string          `gorm:"type:uuid;not null" json:"family_id"`
+	CategoryID  *string         `gorm:"type:uuid" json:"category_id"`
+	Category    *RewardCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Title       string          `gorm:"not null" json:"title"`
+	Description string          `json:"description"`
+	Points      int             `gorm:"not null" json:"points"`
+	// Изображение лежит в файловом хранилище под ключом ImageKey
+	ImageKey         *string        `json:"-"`
+	ImageContentType *string        `json:"-"`
+	ImageSize        *int64         `json:"-"`
+	ImageURL         string         `gorm:"-" json:"image_url,omitempty"`
+	CreatedBy        string         `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
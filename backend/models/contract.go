@@ -16,10 +16,47 @@ type Contract struct {
 	Child       User          `gorm:"foreignKey:ChildID" json:"child"`
 	Tasks       []Task        `gorm:"foreignKey:ContractID" json:"tasks"`
 	Rewards     []Reward      `gorm:"foreignKey:ContractID" json:"rewards"`
-	Status      string        `gorm:"not null" json:"status"` // active, completed, terminated
+	Status      string        `gorm:"not null" json:"status"` // draft, pending_signature, active, completed, terminated
 	StartDate   time.Time     `gorm:"not null" json:"start_date"`
 	EndDate     time.Time     `gorm:"not null" json:"end_date"`
+	CoSignerID  *string       `gorm:"type:uuid" json:"co_signer_id"`
+	TermsHash   string        `json:"terms_hash"`
+	CurrentVersion int        `json:"current_version"`
+	ProposedAt  *time.Time    `json:"proposed_at"`
+	ActivatedAt *time.Time    `json:"activated_at"`
+	CompletedAt *time.Time    `json:"completed_at"`
+	TerminatedAt *time.Time   `json:"terminated_at"`
+	PredecessorID   *string   `gorm:"type:uuid" json:"predecessor_id"`
+	SuccessorID     *string   `gorm:"type:uuid" json:"successor_id"`
+	AutoRenew       bool      `json:"auto_renew"`
+	CarryOverPoints bool      `json:"carry_over_points"`
+	Signatures  []ContractSignature `gorm:"foreignKey:ContractID" json:"signatures,omitempty"`
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-} 
\ No newline at end of file
+} 
+// Статусы контракта
+const (
+	ContractDraft            = "draft"
+	ContractPendingSignature = "pending_signature"
+	ContractActive           = "active"
+	ContractCompleted        = "completed"
+	ContractTerminated       = "terminated"
+)
+
+// TermsEditable сообщает, можно ли менять условия контракта (задачи и награды).
+// Изменение условий предложенного или подписанного контракта создает новую
+// версию и требует повторного подписания.
+func (c *Contract) TermsEditable() bool {
+	return c.Status == ContractDraft || c.Status == ContractPendingSignature || c.Status == ContractActive
+}
+
+// Versioned сообщает, нужно ли фиксировать изменения условий в истории версий
+func (c *Contract) Versioned() bool {
+	return c.Status != ContractDraft
+}
+
+// Actionable сообщает, можно ли выполнять задачи и получать награды по контракту
+func (c *Contract) Actionable() bool {
+	return c.Status == ContractActive
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// Состояния предложения об изменении условий контракта
+const (
+	ProposalOpen      = "open"
+	ProposalAccepted  = "accepted"
+	ProposalRejected  = "rejected"
+	ProposalCountered = "countered"
+	ProposalWithdrawn = "withdrawn"
+)
+
+// ContractProposal - предложение ребенка или родителя изменить условия
+// контракта. Встречное предложение ссылается на исходное через ParentProposalID.
+type ContractProposal struct {
+	ID               string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID       string     `gorm:"type:uuid;not null" json:"contract_id"`
+	ParentProposalID *string    `gorm:"type:uuid" json:"parent_proposal_id"`
+	AuthorID         string     `gorm:"type:uuid;not null" json:"author_id"`
+	Author           User       `gorm:"foreignKey:AuthorID" json:"author"`
+	AuthorRole       string     `gorm:"not null" json:"author_role"` // parent, child
+	Status           string     `gorm:"not null" json:"status"`      // open, accepted, rejected, countered, withdrawn
+	Message          string     `json:"message"`
+	Changes          JSON       `gorm:"type:jsonb;not null" json:"changes"`
+	BaseVersion      int        `gorm:"not null" json:"base_version"`
+	AppliedVersion   *int       `json:"applied_version"`
+	ResponseMessage  string     `json:"response_message"`
+	ResolvedBy       *string    `gorm:"type:uuid" json:"resolved_by"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// События, на которые срабатывают правила контракта
+const (
+	RuleTaskSubmitted = "task_submitted"
+	RuleTaskCompleted = "task_completed"
+	RuleTaskFailed    = "task_failed"
+	// Задача в работе просрочена; проверяется планировщиком
+	RuleTaskOverdue = "task_overdue"
+	// Итоги периода или всего контракта; проверяются после их окончания
+	RuleSchedule = "schedule"
+)
+
+// Области правила: правило срабатывает не больше одного раза на задачу,
+// календарный период или контракт. Показатели задач контракта для
+// периодов считаются по задачам со сроком в этом периоде.
+const (
+	RuleScopeTask     = "task"
+	RuleScopeContract = "contract"
+)
+
+// Действия правила
+const (
+	RuleAward        = "award"
+	RuleDeduct       = "deduct"
+	RuleUnlockReward = "unlock_reward"
+)
+
+// ContractRule - правило контракта: при событии Trigger, если выполнены
+// все условия, ребенок получает или теряет баллы либо открывается награда.
+// Пока правило открытия награды не сработало, награду нельзя запросить.
+type ContractRule struct {
+	ID         string   `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID string   `gorm:"type:uuid;not null" json:"contract_id"`
+	Contract   Contract `gorm:"foreignKey:ContractID" json:"-"`
+	Name       string   `gorm:"not null" json:"name"`
+	Trigger    string   `gorm:"not null" json:"trigger"` // task_submitted, task_completed, task_failed, task_overdue, schedule
+	Scope      string   `gorm:"not null" json:"scope"`   // task, day, week, month, contract
+	// Список условий вида {"field": "task.days_late", "op": "gt", "value": 2}
+	Conditions JSON           `gorm:"type:jsonb;not null" json:"conditions"`
+	Action     string         `gorm:"not null" json:"action"` // award, deduct, unlock_reward
+	Points     int            `gorm:"not null" json:"points"`
+	RewardID   *string        `gorm:"type:uuid" json:"reward_id"`
+	Timezone   string         `gorm:"not null;default:UTC" json:"timezone"`
+	Enabled    bool           `gorm:"not null" json:"enabled"`
+	CreatedBy  *string        `gorm:"type:uuid" json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// RuleFiring - срабатывание правила по задаче или за период
+type RuleFiring struct {
+	ID         string  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	RuleID     string  `gorm:"type:uuid;not null" json:"rule_id"`
+	ContractID string  `gorm:"type:uuid;not null" json:"contract_id"`
+	ChildID    string  `gorm:"type:uuid;not null" json:"child_id"`
+	TaskID     *string `gorm:"type:uuid" json:"task_id"`
+	// Задача или период, за который правило уже сработало
+	Key     string `gorm:"column:firing_key;not null" json:"key"`
+	Trigger string `gorm:"not null" json:"trigger"`
+	Action  string `gorm:"not null" json:"action"`
+	// Начисленные баллы, у штрафа со знаком минус
+	Points   int       `gorm:"not null" json:"points"`
+	RewardID *string   `gorm:"type:uuid" json:"reward_id"`
+	FiredAt  time.Time `json:"fired_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ContractSignature - подпись стороны контракта. TermsHash фиксирует,
+// какие именно условия были подписаны.
+type ContractSignature struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID string    `gorm:"type:uuid;not null" json:"contract_id"`
+	SignerID   string    `gorm:"type:uuid;not null" json:"signer_id"`
+	Signer     User      `gorm:"foreignKey:SignerID" json:"signer"`
+	SignerRole string    `gorm:"not null" json:"signer_role"` // parent, child, co_parent
+	TermsHash  string    `gorm:"not null" json:"terms_hash"`
+	Version    *int      `json:"version"`
+	IPAddress  string    `json:"ip_address"`
+	SignedAt   time.Time `json:"signed_at"`
+}
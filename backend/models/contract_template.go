@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ContractTemplate - шаблон контракта с заготовками задач и наград.
+// Встроенные шаблоны (IsBuiltin) поставляются с приложением и не имеют владельца.
+type ContractTemplate struct {
+	ID           string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	OwnerID      *string        `gorm:"type:uuid" json:"owner_id"`
+	Title        string         `gorm:"not null" json:"title"`
+	Description  string         `json:"description"`
+	DurationDays int            `gorm:"not null" json:"duration_days"`
+	Tasks        JSON           `gorm:"type:jsonb;not null" json:"tasks"`
+	Rewards      JSON           `gorm:"type:jsonb;not null" json:"rewards"`
+	IsBuiltin    bool           `gorm:"not null" json:"is_builtin"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ContractVersion - неизменяемый снимок условий контракта. Новая версия
+// создается при каждом предложении контракта на подпись и при каждом
+// изменении условий после этого.
+type ContractVersion struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID string    `gorm:"type:uuid;not null" json:"contract_id"`
+	Version    int       `gorm:"not null" json:"version"`
+	Terms      JSON      `gorm:"type:jsonb;not null" json:"terms"`
+	TermsHash  string    `gorm:"not null" json:"terms_hash"`
+	Summary    string    `json:"summary"`
+	CreatedBy  *string   `gorm:"type:uuid" json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
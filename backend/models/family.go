@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Family struct {
+	ID        string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Name      string         `gorm:"not null" json:"name"`
+	Code      string         `gorm:"not null;uniqueIndex" json:"code"` // код семьи для входа ребенка по PIN-коду
+	OwnerID   string         `gorm:"type:uuid;not null" json:"owner_id"`
+	Owner     User           `gorm:"foreignKey:OwnerID" json:"owner"`
+	Members   []FamilyMember `gorm:"foreignKey:FamilyID" json:"members"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type FamilyMember struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID  string    `gorm:"type:uuid;not null" json:"family_id"`
+	UserID    string    `gorm:"type:uuid;not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Role      string    `gorm:"not null" json:"role"` // parent или child
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Guardianship - связь родителя-опекуна с ребенком
+type Guardianship struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID  string    `gorm:"type:uuid;not null" json:"family_id"`
+	ParentID  string    `gorm:"type:uuid;not null" json:"parent_id"`
+	Parent    User      `gorm:"foreignKey:ParentID" json:"parent"`
+	ChildID   string    `gorm:"type:uuid;not null" json:"child_id"`
+	Child     User      `gorm:"foreignKey:ChildID" json:"child"`
+	CreatedAt time.Time `json:"created_at"`
+}
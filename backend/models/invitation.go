@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// Состояния приглашения в семью
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// FamilyInvitation - одноразовое приглашение в семью с ограниченным сроком действия
+type FamilyInvitation struct {
+	ID         string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	FamilyID   string     `gorm:"type:uuid;not null" json:"family_id"`
+	Family     Family     `gorm:"foreignKey:FamilyID" json:"family"`
+	Code       string     `gorm:"not null;uniqueIndex" json:"code"`
+	Role       string     `gorm:"not null" json:"role"` // parent или child
+	Email      string     `json:"email,omitempty"`
+	InvitedBy  string     `gorm:"type:uuid;not null" json:"invited_by"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	AcceptedBy *string    `gorm:"type:uuid" json:"accepted_by"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Status     string     `gorm:"-" json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// State вычисляет текущее состояние приглашения
+func (i *FamilyInvitation) State(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
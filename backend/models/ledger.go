@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Типы записей журнала баллов
+const (
+	LedgerEarn   = "earn"
+	LedgerSpend  = "spend"
+	LedgerAdjust = "adjust"
+	LedgerRefund = "refund"
+	// Перенос непотраченных баллов при продлении контракта
+	LedgerCarryOver = "carry_over"
+	// Баллы, отложенные на цель накопления, и их возврат в баланс
+	LedgerLock    = "lock"
+	LedgerRelease = "release"
+	// Бонусы и штрафы по правилам контракта
+	LedgerBonus   = "bonus"
+	LedgerPenalty = "penalty"
+	// Бонус за серию задач, выполненных вовремя
+	LedgerStreak = "streak"
+)
+
+// LedgerEntry - запись журнала баллов. Записи только добавляются,
+// баланс ребенка вычисляется как сумма Amount по контракту.
+type LedgerEntry struct {
+	ID            string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChildID       string    `gorm:"type:uuid;not null" json:"child_id"`
+	ContractID    string    `gorm:"type:uuid;not null" json:"contract_id"`
+	Type          string    `gorm:"column:entry_type;not null" json:"type"` // earn, spend, adjust, refund, carry_over, lock, release, bonus, penalty, streak
+	Amount        int       `gorm:"not null" json:"amount"`
+	TaskID        *string   `gorm:"type:uuid" json:"task_id,omitempty"`
+	RewardID      *string   `gorm:"type:uuid" json:"reward_id,omitempty"`
+	ClaimID       *string   `gorm:"type:uuid" json:"claim_id,omitempty"`
+	GoalID        *string   `gorm:"type:uuid" json:"goal_id,omitempty"`
+	RuleID        *string   `gorm:"type:uuid" json:"rule_id,omitempty"`
+	StreakBonusID *string   `gorm:"type:uuid" json:"streak_bonus_id,omitempty"`
+	Description   string    `json:"description"`
+	CreatedBy     *string   `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "points_ledger"
+}
@@ -12,10 +12,52 @@ type Reward struct {
 	Description string         `json:"description"`
 	ContractID  string        `gorm:"type:uuid;not null" json:"contract_id"`
 	Contract    Contract      `gorm:"foreignKey:ContractID" json:"contract"`
-	Status      string        `gorm:"not null" json:"status"` // available, claimed, expired
-	PointsCost  int           `gorm:"not null" json:"points_cost"`
-	ExpiryDate  time.Time     `gorm:"not null" json:"expiry_date"`
+	Status      string        `gorm:"not null" json:"status"` // available, claimed, completed, expired
+	PointsCost  int           `gorm:"column:points;not null" json:"points_cost"`
+	ExpiryDate  *time.Time    `json:"expiry_date"`
+	// Повторяемую награду можно запрашивать снова, пока позволяют
+	// запас, лимит за период и пауза между запросами
+	Repeatable      bool    `json:"repeatable"`
+	Stock           *int    `json:"stock"`
+	PeriodLimit     int     `gorm:"not null" json:"period_limit"`
+	LimitPeriod     string  `gorm:"not null" json:"limit_period"` // day, week, month
+	CooldownMinutes int     `gorm:"not null" json:"cooldown_minutes"`
+	Timezone        string  `gorm:"not null;default:UTC" json:"timezone"`
+	Availability    *RewardAvailability `gorm:"-" json:"availability,omitempty"`
+	// Награду открывает правило контракта, которое еще не сработало
+	Locked          bool    `gorm:"-" json:"locked,omitempty"`
+	// Запись семейного каталога, из которой создана награда; CustomPoints
+	// означает, что стоимость задана для контракта отдельно
+	CatalogRewardID *string `gorm:"type:uuid" json:"catalog_reward_id"`
+	CustomPoints    bool    `json:"custom_points"`
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-} 
\ No newline at end of file
+}
+
+// Статусы награды
+const (
+	RewardAvailable = "available"
+	RewardClaimed   = "claimed"
+	RewardCompleted = "completed"
+	RewardExpired   = "expired"
+)
+
+// Периоды лимита запросов награды
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// RewardAvailability - сколько еще раз можно запросить повторяемую награду
+type RewardAvailability struct {
+	// Запросы, которые не были отклонены или отменены
+	Claimed   int  `json:"claimed"`
+	Remaining *int `json:"remaining"`
+	// Запросы и остаток в текущем периоде
+	PeriodClaimed   int  `json:"period_claimed"`
+	PeriodRemaining *int `json:"period_remaining"`
+	// Когда закончится пауза после последнего запроса
+	NextClaimAt *time.Time `json:"next_claim_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы запроса награды
+const (
+	ClaimPending   = "pending"
+	ClaimApproved  = "approved"
+	ClaimRejected  = "rejected"
+	ClaimCancelled = "cancelled"
+	ClaimFulfilled = "fulfilled"
+)
+
+// RewardClaim - запрос ребенка на получение награды. Стоимость награды
+// резервируется при запросе и возвращается, если запрос отклонен или отменен.
+type RewardClaim struct {
+	ID              string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	RewardID        string     `gorm:"type:uuid;not null" json:"reward_id"`
+	Reward          *Reward    `gorm:"foreignKey:RewardID" json:"reward,omitempty"`
+	ContractID      string     `gorm:"type:uuid;not null" json:"contract_id"`
+	ChildID         string     `gorm:"type:uuid;not null" json:"child_id"`
+	Status          string     `gorm:"not null" json:"status"` // pending, approved, rejected, cancelled, fulfilled
+	Points          int        `gorm:"not null" json:"points"`
+	Note            string     `json:"note"`
+	ReviewedBy      *string    `gorm:"type:uuid" json:"reviewed_by"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+	RejectionReason string     `json:"rejection_reason"`
+	FulfilledBy     *string    `gorm:"type:uuid" json:"fulfilled_by"`
+	FulfilledAt     *time.Time `json:"fulfilled_at"`
+	FulfilmentNote  string     `json:"fulfilment_note"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Open сообщает, ждет ли запрос решения или выдачи награды
+func (c *RewardClaim) Open() bool {
+	return c.Status == ClaimPending || c.Status == ClaimApproved
+}
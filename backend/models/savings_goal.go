@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы цели накопления
+const (
+	GoalActive = "active"
+	// Накоплено достаточно, но награду еще не удалось запросить
+	// или цель задана суммой баллов
+	GoalReached   = "reached"
+	GoalClaimed   = "claimed"
+	GoalCancelled = "cancelled"
+)
+
+// SavingsGoal - цель накопления ребенка. Отложенные баллы записываются в
+// журнал записями lock и не входят в доступный баланс контракта.
+type SavingsGoal struct {
+	ID           string        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChildID      string        `gorm:"type:uuid;not null" json:"child_id"`
+	ContractID   string        `gorm:"type:uuid;not null" json:"contract_id"`
+	Title        string        `gorm:"not null" json:"title"`
+	RewardID     *string       `gorm:"type:uuid" json:"reward_id"`
+	Reward       *Reward       `gorm:"foreignKey:RewardID" json:"reward,omitempty"`
+	TargetPoints *int          `json:"target_points"`
+	Deadline     *time.Time    `gorm:"type:date" json:"deadline"`
+	Status       string        `gorm:"not null" json:"status"` // active, reached, claimed, cancelled
+	ClaimID      *string       `gorm:"type:uuid" json:"claim_id"`
+	CreatedBy    string        `gorm:"type:uuid;not null" json:"created_by"`
+	ReachedAt    *time.Time    `json:"reached_at"`
+	ClosedAt     *time.Time    `json:"closed_at"`
+	Progress     *GoalProgress `gorm:"-" json:"progress,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// GoalProgress - накопленные баллы и прогноз достижения цели
+type GoalProgress struct {
+	Target  int `json:"target"`
+	Saved   int `json:"saved"`
+	Percent int `json:"percent"`
+	// Доступный баланс контракта, который еще можно отложить
+	Available int `json:"available"`
+	// Средний заработок в день за последние дни по выполненным задачам
+	DailyRate float64 `json:"daily_rate"`
+	// Когда цель будет достигнута при текущем темпе; пусто, если заработка нет
+	ProjectedAt *time.Time `json:"projected_at"`
+	// Успевает ли ребенок к сроку; пусто, если срок не задан
+	OnTrack *bool `json:"on_track,omitempty"`
+}
+
+// Open сообщает, можно ли еще откладывать баллы или забирать их из цели
+func (g *SavingsGoal) Open() bool {
+	return g.Status == GoalActive || g.Status == GoalReached
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Session - сессия пользователя на конкретном устройстве
+type Session struct {
+	ID            string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID        string     `gorm:"type:uuid;not null" json:"user_id"`
+	Scope         string     `gorm:"not null;default:''" json:"scope"`
+	UserAgent     string     `json:"user_agent"`
+	DeviceName    string     `json:"device_name"`
+	IPAddress     string     `json:"ip_address"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+}
+
+// RefreshToken - одноразовый refresh-токен сессии, хранится только хеш
+type RefreshToken struct {
+	ID        string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SessionID string     `gorm:"type:uuid;not null" json:"session_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
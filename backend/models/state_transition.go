@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// StateTransition - запись истории перехода контракта, задачи или награды
+// из одного состояния в другое
+type StateTransition struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	EntityType string    `gorm:"not null" json:"entity_type"` // contract, task, reward
+	EntityID   string    `gorm:"type:uuid;not null" json:"entity_id"`
+	Action     string    `gorm:"not null" json:"action"`
+	FromStatus string    `gorm:"not null" json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	ActorID    *string   `gorm:"type:uuid" json:"actor_id"`
+	ActorRole  string    `gorm:"not null" json:"actor_role"` // parent, child, system
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
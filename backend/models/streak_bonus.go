@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Виды серий: дни подряд, в которые все задачи контракта выполнены
+// вовремя, или повторения одной повторяющейся задачи подряд
+const (
+	StreakDays   = "day"
+	StreakSeries = "series"
+)
+
+// Виды бонусов за серию
+const (
+	// Баллы за задачу умножаются, пока серия не короче Length
+	StreakMultiplier = "multiplier"
+	// Разовый бонус, когда серия достигает Length
+	StreakMilestone = "milestone"
+)
+
+// StreakBonus - бонус контракта за серию задач, выполненных вовремя.
+// Начисляется при подтверждении задачи отдельной записью журнала баллов.
+type StreakBonus struct {
+	ID         string   `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID string   `gorm:"type:uuid;not null" json:"contract_id"`
+	Contract   Contract `gorm:"foreignKey:ContractID" json:"-"`
+	Scope      string   `gorm:"not null" json:"scope"` // day, series
+	// Повторяющаяся задача серии; пустое значение - любая
+	SeriesID *string `gorm:"type:uuid" json:"series_id"`
+	Kind     string  `gorm:"not null" json:"kind"` // multiplier, milestone
+	Length   int     `gorm:"not null" json:"length"`
+	// Множитель в процентах: 150 - это x1.5
+	MultiplierPercent int            `gorm:"not null" json:"multiplier_percent"`
+	Points            int            `gorm:"not null" json:"points"`
+	Timezone          string         `gorm:"not null;default:UTC" json:"timezone"`
+	CreatedBy         *string        `gorm:"type:uuid" json:"created_by"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// StreakAward - начисленный бонус за серию
+type StreakAward struct {
+	ID         string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	BonusID    string `gorm:"type:uuid;not null" json:"bonus_id"`
+	ContractID string `gorm:"type:uuid;not null" json:"contract_id"`
+	ChildID    string `gorm:"type:uuid;not null" json:"child_id"`
+	TaskID     string `gorm:"type:uuid;not null" json:"task_id"`
+	// Задача или день, за которые бонус уже начислен
+	Key string `gorm:"column:award_key;not null" json:"key"`
+	// Длина серии на момент начисления
+	Length    int       `gorm:"not null" json:"length"`
+	Points    int       `gorm:"not null" json:"points"`
+	AwardedAt time.Time `json:"awarded_at"`
+}
@@ -12,10 +12,30 @@ type Task struct {
 	Description string         `json:"description"`
 	ContractID  string        `gorm:"type:uuid;not null" json:"contract_id"`
 	Contract    Contract      `gorm:"foreignKey:ContractID" json:"contract"`
-	Status      string        `gorm:"not null" json:"status"` // pending, completed, failed
+	Status      string        `gorm:"not null" json:"status"` // pending, submitted, completed, failed, cancelled
 	DueDate     time.Time     `gorm:"not null" json:"due_date"`
 	Points      int           `gorm:"not null" json:"points"`
+	SubmittedAt     *time.Time `json:"submitted_at"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+	ReviewedBy      *string    `gorm:"type:uuid" json:"reviewed_by"`
+	RejectionReason string     `json:"rejection_reason"`
+	SeriesID        *string    `gorm:"type:uuid" json:"series_id"`
+	OccurrenceDate  *time.Time `gorm:"type:date" json:"occurrence_date"`
+	IsException     bool       `json:"is_exception"`
+	FailedAt        *time.Time `json:"failed_at"`
+	PartialCredit   bool       `json:"partial_credit"`
+	Checklist       []TaskChecklistItem `gorm:"foreignKey:TaskID" json:"checklist,omitempty"`
+	Progress        *TaskProgress       `gorm:"-" json:"progress,omitempty"`
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-} 
\ No newline at end of file
+}
+
+// Статусы задачи
+const (
+	TaskPending   = "pending"
+	TaskSubmitted = "submitted"
+	TaskCompleted = "completed"
+	TaskFailed    = "failed"
+	TaskCancelled = "cancelled"
+)
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TaskChecklistItem - пункт чек-листа задачи
+type TaskChecklistItem struct {
+	ID          string     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TaskID      string     `gorm:"type:uuid;not null" json:"task_id"`
+	Title       string     `gorm:"not null" json:"title"`
+	Position    int        `gorm:"not null" json:"position"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CompletedBy *string    `gorm:"type:uuid" json:"completed_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TaskProgress - прогресс выполнения чек-листа задачи
+type TaskProgress struct {
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Label string `json:"label"` // например, "3/5"
+}
+
+// NewTaskProgress создает прогресс с готовой подписью для интерфейса
+func NewTaskProgress(done, total int) *TaskProgress {
+	return &TaskProgress{Done: done, Total: total, Label: fmt.Sprintf("%d/%d", done, total)}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskSeries - повторяющаяся задача. Конкретные задачи создаются
+// генератором по правилу повторения на несколько дней вперед.
+type TaskSeries struct {
+	ID             string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ContractID     string         `gorm:"type:uuid;not null" json:"contract_id"`
+	Contract       Contract       `gorm:"foreignKey:ContractID" json:"contract"`
+	Title          string         `gorm:"not null" json:"title"`
+	Description    string         `json:"description"`
+	Points         int            `gorm:"not null" json:"points"`
+	Rule           string         `gorm:"not null" json:"rule"`
+	DueTime        string         `gorm:"not null" json:"due_time"`
+	Timezone       string         `gorm:"not null" json:"timezone"`
+	StartsOn       time.Time      `gorm:"type:date;not null" json:"starts_on"`
+	EndsOn         *time.Time     `gorm:"type:date" json:"ends_on"`
+	GeneratedUntil *time.Time     `gorm:"type:date" json:"generated_until"`
+	CreatedBy      *string        `gorm:"type:uuid" json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (TaskSeries) TableName() string {
+	return "task_series"
+}
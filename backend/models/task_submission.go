@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskSubmission - отправка задачи ребенком на проверку родителю
+type TaskSubmission struct {
+	ID           string       `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TaskID       string       `gorm:"type:uuid;not null" json:"task_id"`
+	ChildID      string       `gorm:"type:uuid;not null" json:"child_id"`
+	Note         string       `json:"note"`
+	Status       string       `gorm:"not null" json:"status"` // submitted, approved, rejected
+	ReviewReason string       `json:"review_reason"`
+	ReviewedBy   *string      `gorm:"type:uuid" json:"reviewed_by"`
+	ReviewedAt   *time.Time   `json:"reviewed_at"`
+	Attachments  []Attachment `gorm:"foreignKey:SubmissionID" json:"attachments"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
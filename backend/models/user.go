@@ -7,12 +7,17 @@ import (
 )
 
 type User struct {
-	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	Username  string    `gorm:"uniqueIndex;not null" json:"username"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string    `gorm:"not null" json:"-"`
-	Role      string    `gorm:"not null" json:"role"` // parent или child
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                string         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Username          string         `gorm:"uniqueIndex;not null" json:"username"`
+	Email             *string        `gorm:"uniqueIndex" json:"email"` // у детских аккаунтов, созданных родителем, email нет
+	Password          string         `gorm:"not null" json:"-"`
+	Role              string         `gorm:"not null" json:"role"` // parent или child
+	DisplayName       string         `json:"display_name"`
+	PinHash           string         `json:"-"`
+	ManagedBy         *string        `gorm:"type:uuid" json:"managed_by,omitempty"`
+	FailedPinAttempts int            `gorm:"not null;default:0" json:"-"`
+	PinLockedUntil    *time.Time     `json:"-"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 } 
\ No newline at end of file
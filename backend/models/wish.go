@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы желания
+const (
+	WishPending   = "pending"
+	WishPriced    = "priced"
+	WishAccepted  = "accepted"
+	WishRejected  = "rejected"
+	WishWithdrawn = "withdrawn"
+)
+
+// Wish - награда, которую предлагает ребенок. Родитель назначает ей
+// стоимость, добавляет в контракт как обычную награду или отклоняет.
+type Wish struct {
+	ID              string        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ChildID         string        `gorm:"type:uuid;not null" json:"child_id"`
+	Title           string        `gorm:"not null" json:"title"`
+	Description     string        `json:"description"`
+	Link            string        `json:"link"`
+	SuggestedPoints int           `gorm:"not null" json:"suggested_points"`
+	Priority        int           `gorm:"not null" json:"priority"` // от 1 до 5, 5 - самое желанное
+	Status          string        `gorm:"not null" json:"status"`   // pending, priced, accepted, rejected, withdrawn
+	Price           *int          `json:"price"`
+	PriceNote       string        `json:"price_note"`
+	PricedBy        *string       `gorm:"type:uuid" json:"priced_by"`
+	PricedAt        *time.Time    `json:"priced_at"`
+	ReviewedBy      *string       `gorm:"type:uuid" json:"reviewed_by"`
+	ReviewedAt      *time.Time    `json:"reviewed_at"`
+	RejectionReason string        `json:"rejection_reason"`
+	ContractID      *string       `gorm:"type:uuid" json:"contract_id"`
+	RewardID        *string       `gorm:"type:uuid" json:"reward_id"`
+	Reward          *Reward       `gorm:"foreignKey:RewardID" json:"reward,omitempty"`
+	Progress        *WishProgress `gorm:"-" json:"progress,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// WishProgress - насколько ребенок близок к желанию. Баллы не переносятся
+// между контрактами, поэтому сравнивается баланс одного контракта.
+type WishProgress struct {
+	Cost       int    `json:"cost"`
+	ContractID string `json:"contract_id,omitempty"`
+	Balance    int    `json:"balance"`
+	// Сколько баллов не хватает и процент накопленного
+	Missing int `json:"missing"`
+	Percent int `json:"percent"`
+}
+
+// Open сообщает, ждет ли желание решения родителя
+func (w *Wish) Open() bool {
+	return w.Status == WishPending || w.Status == WishPriced
+}
+
+// Cost возвращает текущую стоимость желания: назначенную родителем,
+// а до этого - предложенную ребенком
+func (w *Wish) Cost() int {
+	if w.Reward != nil {
+		return w.Reward.PointsCost
+	}
+	if w.Price != nil {
+		return *w.Price
+	}
+	return w.SuggestedPoints
+}
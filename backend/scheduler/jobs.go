@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"gorm.io/gorm"
+)
+
+// Jobs возвращает стандартный набор задач планировщика
+func Jobs(interval, taskGracePeriod time.Duration) []Job {
+	return []Job{
+		{Name: "fail_overdue_tasks", Interval: interval, Run: FailOverdueTasks(taskGracePeriod)},
+		{Name: "expire_rewards", Interval: interval, Run: ExpireRewards},
+		{Name: "complete_contracts", Interval: interval, Run: CompleteContracts},
+		{Name: "generate_task_series", Interval: interval, Run: GenerateTaskSeries},
+		{Name: "apply_contract_rules", Interval: interval, Run: services.ApplyScheduledRules},
+	}
+}
+
+// inSavepoint выполняет обработку одной записи в своей точке сохранения:
+// ошибка записи откатывает только ее изменения и записывается в лог, чтобы
+// не мешать обработке остальных. Возвращает false, если запись пропущена.
+func inSavepoint(tx *gorm.DB, name, id string, step func() error) (bool, error) {
+	if err := tx.SavePoint(name).Error; err != nil {
+		return false, err
+	}
+	if err := step(); err != nil {
+		log.Printf("Планировщик: %s %s: %v", name, id, err)
+		return false, tx.RollbackTo(name).Error
+	}
+	return true, nil
+}
+
+// FailOverdueTasks отмечает проваленными задачи в работе, срок которых
+// истек более чем grace назад. Задачи, отправленные на проверку, ждут
+// решения родителя и не трогаются. Каждая задача обрабатывается отдельно,
+// ошибка одной задачи не мешает обработать остальные.
+func FailOverdueTasks(grace time.Duration) func(tx *gorm.DB, now time.Time) (int64, error) {
+	return func(tx *gorm.DB, now time.Time) (int64, error) {
+		transition, err := services.TaskMachine.Find(services.TaskFail, models.TaskPending, services.ActorSystem)
+		if err != nil {
+			return 0, err
+		}
+
+		var ids []string
+		if err := tx.Raw(`
+			SELECT tasks.id FROM tasks
+			JOIN contracts ON contracts.id = tasks.contract_id
+			WHERE contracts.status IN (?, ?)
+				AND contracts.deleted_at IS NULL
+				AND tasks.status = ?
+				AND tasks.due_date < ?
+				AND tasks.deleted_at IS NULL`,
+			models.ContractActive, models.ContractCompleted, models.TaskPending, now.Add(-grace)).
+			Scan(&ids).Error; err != nil {
+			return 0, err
+		}
+
+		var failed int64
+		for _, id := range ids {
+			ok, err := inSavepoint(tx, "fail_task", id, func() error {
+				if err := tx.Exec(`
+					UPDATE tasks SET status = ?, failed_at = ?, updated_at = ?
+					WHERE id = ? AND status = ?`,
+					transition.To, now, now, id, models.TaskPending).Error; err != nil {
+					return err
+				}
+				if err := services.RecordTransitions(tx, services.EntityTask, []string{id}, transition, models.TaskPending,
+					services.ActorSystem, "", "Срок выполнения истек"); err != nil {
+					return err
+				}
+				return services.ApplyTaskRules(tx, id, models.RuleTaskFailed, "", now)
+			})
+			if err != nil {
+				return failed, err
+			}
+			if ok {
+				failed++
+			}
+		}
+		return failed, nil
+	}
+}
+
+// ExpireRewards переводит доступные награды с истекшим сроком в статус expired
+func ExpireRewards(tx *gorm.DB, now time.Time) (int64, error) {
+	transition, err := services.RewardMachine.Find(services.RewardExpire, models.RewardAvailable, services.ActorSystem)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	if err := tx.Raw(`
+		UPDATE rewards SET status = ?, updated_at = ?
+		WHERE status = ?
+			AND expiry_date IS NOT NULL
+			AND expiry_date < ?
+			AND deleted_at IS NULL
+		RETURNING id`,
+		transition.To, now, models.RewardAvailable, now).
+		Scan(&ids).Error; err != nil {
+		return 0, err
+	}
+
+	err = services.RecordTransitions(tx, services.EntityReward, ids, transition, models.RewardAvailable,
+		services.ActorSystem, "", "Срок награды истек")
+	return int64(len(ids)), err
+}
+
+// CompleteContracts завершает действующие контракты, срок которых закончился.
+// Контракты с автопродлением продлеваются на следующий период, новый
+// контракт сразу предлагается ребенку на подпись. Ошибка одного контракта
+// не мешает завершить остальные.
+func CompleteContracts(tx *gorm.DB, now time.Time) (int64, error) {
+	var ids []string
+	if err := tx.Model(&models.Contract{}).
+		Where("status = ? AND end_date < ?", models.ContractActive, now).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	var completed int64
+	for _, id := range ids {
+		ok, err := inSavepoint(tx, "complete_contract", id, func() error {
+			_, err := services.TransitionContract(tx, id, services.ContractComplete,
+				services.ActorSystem, "", "", "Срок контракта истек")
+			return err
+		})
+		if err != nil {
+			return completed, err
+		}
+		if ok {
+			completed++
+		}
+	}
+	return completed, nil
+}
+
+// GenerateTaskSeries продлевает повторяющиеся задачи на SeriesHorizon вперед.
+// Ошибка одной серии не мешает продлить остальные.
+func GenerateTaskSeries(tx *gorm.DB, now time.Time) (int64, error) {
+	horizon := now.Add(services.SeriesHorizon)
+
+	var series []models.TaskSeries
+	if err := tx.Preload("Contract").
+		Joins("Contract").
+		Where("Contract.status IN ? AND Contract.deleted_at IS NULL",
+			[]string{models.ContractDraft, models.ContractPendingSignature, models.ContractActive}).
+		Where("(task_series.generated_until IS NULL OR task_series.generated_until < ?)", services.Date(horizon)).
+		Find(&series).Error; err != nil {
+		return 0, err
+	}
+
+	var created int64
+	for i := range series {
+		var n int
+		if _, err := inSavepoint(tx, "generate_series", series[i].ID, func() error {
+			var err error
+			n, err = services.GenerateSeries(tx, &series[i], &series[i].Contract, horizon)
+			return err
+		}); err != nil {
+			return created, err
+		}
+		created += int64(n)
+	}
+	return created, nil
+}
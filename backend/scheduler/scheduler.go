@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job - периодическая задача планировщика. Run выполняется в транзакции
+// и возвращает число затронутых записей.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(tx *gorm.DB, now time.Time) (int64, error)
+}
+
+// Run - сведения о последнем запуске задачи
+type Run struct {
+	Job            string    `gorm:"primaryKey" json:"job"`
+	LastRunAt      time.Time `json:"last_run_at"`
+	LastDurationMs int64     `json:"last_duration_ms"`
+	Affected       int64     `json:"affected"`
+	LastError      string    `json:"last_error"`
+}
+
+func (Run) TableName() string {
+	return "scheduler_runs"
+}
+
+// Scheduler запускает задачи по расписанию внутри процесса. Каждая задача
+// выполняется под advisory-блокировкой Postgres, поэтому при нескольких
+// репликах бэкенда в каждый момент ее выполняет только одна из них.
+type Scheduler struct {
+	db   *gorm.DB
+	jobs []Job
+}
+
+// New создает планировщик с набором задач
+func New(db *gorm.DB, jobs ...Job) *Scheduler {
+	return &Scheduler{db: db, jobs: jobs}
+}
+
+// Start запускает все задачи и возвращает управление сразу.
+// Задачи останавливаются при отмене контекста.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+	log.Printf("Планировщик запущен, задач: %d", len(s.jobs))
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(ctx, job); err != nil {
+			log.Printf("Планировщик: ошибка задачи %s: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce выполняет задачу один раз, если ее не выполняет другая реплика.
+// Возвращает false, если блокировку получить не удалось.
+func (s *Scheduler) RunOnce(ctx context.Context, job Job) (bool, error) {
+	started := time.Now()
+	locked := false
+	var affected int64
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Блокировка снимается автоматически при завершении транзакции
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", "scheduler:"+job.Name).
+			Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var err error
+		affected, err = job.Run(tx, started)
+		return err
+	})
+	if !locked && err == nil {
+		return false, nil
+	}
+
+	run := Run{
+		Job:            job.Name,
+		LastRunAt:      started,
+		LastDurationMs: time.Since(started).Milliseconds(),
+		Affected:       affected,
+	}
+	if err != nil {
+		run.LastError = err.Error()
+	} else if affected > 0 {
+		log.Printf("Планировщик: задача %s обработала записей: %d", job.Name, affected)
+	}
+	s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&run)
+
+	return true, err
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidAchievement возвращается для некорректного определения достижения
+var ErrInvalidAchievement = errors.New("некорректное достижение")
+
+// maxAchievementThreshold ограничивает порог достижения
+const maxAchievementThreshold = 1000000
+
+// metricFunc считает показатель ребенка по всем его контрактам
+type metricFunc func(db *gorm.DB, childID string, now time.Time) (int, error)
+
+var achievementMetrics = map[string]metricFunc{
+	models.MetricTasksCompleted: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		return countRows(childTasks(db, childID).Where("tasks.status = ?", models.TaskCompleted))
+	},
+	models.MetricTasksOnTime: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		return countRows(childTasks(db, childID).
+			Where("tasks.status = ? AND COALESCE(tasks.submitted_at, tasks.reviewed_at) <= tasks.due_date", models.TaskCompleted))
+	},
+	models.MetricStreakDays: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		var contractIDs []string
+		if err := db.Model(&models.Contract{}).Where("child_id = ?", childID).Pluck("id", &contractIDs).Error; err != nil {
+			return 0, err
+		}
+		best := 0
+		for _, contractID := range contractIDs {
+			tasks, err := loadSnapshots(db, contractID)
+			if err != nil {
+				return 0, err
+			}
+			best = max(best, CountDayStreak(tasks, time.UTC, now, now).Best)
+		}
+		return best, nil
+	},
+	models.MetricPointsEarned: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		var points int
+		err := db.Model(&models.LedgerEntry{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("child_id = ? AND entry_type IN ?", childID, []string{models.LedgerEarn, models.LedgerBonus, models.LedgerStreak}).
+			Scan(&points).Error
+		return points, err
+	},
+	models.MetricRewardsReceived: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		return countRows(db.Model(&models.RewardClaim{}).Where("child_id = ? AND status = ?", childID, models.ClaimFulfilled))
+	},
+	models.MetricGoalsReached: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		return countRows(db.Model(&models.SavingsGoal{}).Where("child_id = ? AND reached_at IS NOT NULL", childID))
+	},
+	models.MetricGoalsMaxTarget: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		// Цель-награда достигнута при стоимости награды на тот момент;
+		// цели со снятой наградой не учитываются
+		var target int
+		err := db.Model(&models.SavingsGoal{}).
+			Select("COALESCE(MAX(COALESCE(savings_goals.target_points, rewards.points_cost)), 0)").
+			Joins("LEFT JOIN rewards ON rewards.id = savings_goals.reward_id").
+			Where("savings_goals.child_id = ? AND savings_goals.reached_at IS NOT NULL", childID).
+			Scan(&target).Error
+		return target, err
+	},
+	models.MetricContractsCompleted: func(db *gorm.DB, childID string, now time.Time) (int, error) {
+		return countRows(db.Model(&models.Contract{}).Where("child_id = ? AND status = ?", childID, models.ContractCompleted))
+	},
+}
+
+func childTasks(db *gorm.DB, childID string) *gorm.DB {
+	return db.Model(&models.Task{}).
+		Joins("JOIN contracts ON contracts.id = tasks.contract_id").
+		Where("contracts.child_id = ?", childID)
+}
+
+func countRows(query *gorm.DB) (int, error) {
+	var count int64
+	err := query.Count(&count).Error
+	return int(count), err
+}
+
+// AchievementProgress - достижение и продвижение ребенка к нему
+type AchievementProgress struct {
+	models.Achievement
+	Value    int  `json:"value"`
+	Percent  int  `json:"percent"`
+	Unlocked bool `json:"unlocked"`
+	// Когда достижение открыто; пусто, если еще не открыто
+	AwardedAt  *time.Time `json:"awarded_at,omitempty"`
+	Backfilled bool       `json:"backfilled,omitempty"`
+}
+
+// ValidateAchievement проверяет определение достижения
+func ValidateAchievement(achievement *models.Achievement) error {
+	if _, ok := achievementMetrics[achievement.Metric]; !ok {
+		return ErrInvalidAchievement
+	}
+	if achievement.Threshold <= 0 || achievement.Threshold > maxAchievementThreshold {
+		return ErrInvalidAchievement
+	}
+	return nil
+}
+
+// AchievementPercent возвращает продвижение к порогу в процентах, не больше 100
+func AchievementPercent(value, threshold int) int {
+	if threshold <= 0 || value >= threshold {
+		return 100
+	}
+	if value <= 0 {
+		return 0
+	}
+	return value * 100 / threshold
+}
+
+// UnlockedAchievements отбирает еще не открытые достижения, порог которых
+// достигнут при показателях metrics
+func UnlockedAchievements(achievements []models.Achievement, metrics map[string]int, awarded map[string]bool) []models.Achievement {
+	var unlocked []models.Achievement
+	for _, achievement := range achievements {
+		if awarded[achievement.ID] {
+			continue
+		}
+		if value, ok := metrics[achievement.Metric]; ok && value >= achievement.Threshold {
+			unlocked = append(unlocked, achievement)
+		}
+	}
+	return unlocked
+}
+
+// ChildMetrics считает показатели ребенка из списка metrics
+func ChildMetrics(db *gorm.DB, childID string, metrics []string, now time.Time) (map[string]int, error) {
+	values := make(map[string]int, len(metrics))
+	for _, metric := range metrics {
+		if _, ok := values[metric]; ok {
+			continue
+		}
+		count, ok := achievementMetrics[metric]
+		if !ok {
+			return nil, ErrInvalidAchievement
+		}
+		value, err := count(db, childID, now)
+		if err != nil {
+			return nil, err
+		}
+		values[metric] = value
+	}
+	return values, nil
+}
+
+// ChildAchievementDefinitions возвращает запрос достижений, доступных
+// ребенку: встроенных и заданных в его семьях
+func ChildAchievementDefinitions(db *gorm.DB, childID string) *gorm.DB {
+	return db.Model(&models.Achievement{}).
+		Where("family_id IS NULL OR family_id IN (?)", UserFamilies(db, childID))
+}
+
+// AwardAchievements проверяет достижения ребенка после события в задачах,
+// наградах или контрактах и открывает те, порог которых достигнут.
+// Повторная проверка ничего не открывает повторно.
+func AwardAchievements(tx *gorm.DB, childID string, now time.Time) (int64, error) {
+	var achievements []models.Achievement
+	if err := ChildAchievementDefinitions(tx, childID).
+		Where("id NOT IN (?)", tx.Model(&models.ChildAchievement{}).Select("achievement_id").Where("child_id = ?", childID)).
+		Find(&achievements).Error; err != nil {
+		return 0, err
+	}
+	return awardAchievements(tx, childID, achievements, false, now)
+}
+
+func awardAchievements(tx *gorm.DB, childID string, achievements []models.Achievement, backfilled bool, now time.Time) (int64, error) {
+	if len(achievements) == 0 {
+		return 0, nil
+	}
+
+	// Считаются только показатели еще не открытых достижений
+	metrics := make([]string, len(achievements))
+	for i, achievement := range achievements {
+		metrics[i] = achievement.Metric
+	}
+	values, err := ChildMetrics(tx, childID, metrics, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var awarded int64
+	for _, achievement := range UnlockedAchievements(achievements, values, nil) {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ChildAchievement{
+			AchievementID: achievement.ID,
+			ChildID:       childID,
+			Value:         values[achievement.Metric],
+			Backfilled:    backfilled,
+			AwardedAt:     now,
+		})
+		if result.Error != nil {
+			return awarded, result.Error
+		}
+		awarded += result.RowsAffected
+	}
+	return awarded, nil
+}
+
+// BackfillAchievement проверяет достижение по накопленной истории всех
+// детей, которым оно доступно: встроенное - всем детям, семейное - детям
+// семьи. Каждый ребенок проверяется в отдельной транзакции.
+func BackfillAchievement(db *gorm.DB, achievement *models.Achievement, now time.Time) (int64, error) {
+	var childIDs []string
+	var err error
+	if achievement.BuiltIn() {
+		err = db.Model(&models.User{}).Where("role = ?", "child").Pluck("id", &childIDs).Error
+	} else {
+		err = db.Model(&models.FamilyMember{}).
+			Where("family_id = ? AND role = ?", *achievement.FamilyID, "child").
+			Pluck("user_id", &childIDs).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var awarded int64
+	for _, childID := range childIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			tx.Model(&models.ChildAchievement{}).
+				Where("achievement_id = ? AND child_id = ?", achievement.ID, childID).
+				Count(&count)
+			if count > 0 {
+				return nil
+			}
+			n, err := awardAchievements(tx, childID, []models.Achievement{*achievement}, true, now)
+			awarded += n
+			return err
+		})
+		if err != nil {
+			return awarded, err
+		}
+	}
+	return awarded, nil
+}
+
+// LoadChildAchievements возвращает все доступные ребенку достижения с его
+// продвижением: сначала открытые в порядке открытия, затем остальные
+func LoadChildAchievements(db *gorm.DB, childID string, now time.Time) ([]AchievementProgress, error) {
+	var achievements []models.Achievement
+	if err := ChildAchievementDefinitions(db, childID).Order("threshold, title").Find(&achievements).Error; err != nil {
+		return nil, err
+	}
+	var awards []models.ChildAchievement
+	if err := db.Where("child_id = ?", childID).Order("awarded_at").Find(&awards).Error; err != nil {
+		return nil, err
+	}
+
+	metrics := make([]string, len(achievements))
+	for i, achievement := range achievements {
+		metrics[i] = achievement.Metric
+	}
+	values, err := ChildMetrics(db, childID, metrics, now)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.Achievement, len(achievements))
+	for _, achievement := range achievements {
+		byID[achievement.ID] = achievement
+	}
+	progress := make([]AchievementProgress, 0, len(achievements))
+	for i := range awards {
+		achievement, ok := byID[awards[i].AchievementID]
+		if !ok {
+			continue
+		}
+		delete(byID, achievement.ID)
+		progress = append(progress, AchievementProgress{
+			Achievement: achievement,
+			Value:       values[achievement.Metric],
+			Percent:     100,
+			Unlocked:    true,
+			AwardedAt:   &awards[i].AwardedAt,
+			Backfilled:  awards[i].Backfilled,
+		})
+	}
+	for _, achievement := range achievements {
+		if _, ok := byID[achievement.ID]; !ok {
+			continue
+		}
+		value := values[achievement.Metric]
+		progress = append(progress, AchievementProgress{
+			Achievement: achievement,
+			Value:       value,
+			Percent:     AchievementPercent(value, achievement.Threshold),
+		})
+	}
+	return progress, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+)
+
+// Возможности пользователя, которые клиент использует для показа нужных разделов
+var (
+	parentCapabilities = []string{
+		"contracts:manage",
+		"tasks:manage",
+		"tasks:approve",
+		"rewards:manage",
+		"ledger:adjust",
+		"families:manage",
+		"invitations:manage",
+		"children:manage",
+		"settings:manage",
+	}
+	childCapabilities = []string{
+		"contracts:view",
+		"tasks:submit",
+		"rewards:claim",
+		"invitations:accept",
+		"settings:manage",
+	}
+	// Ребенок, вошедший по PIN-коду, не может менять настройки и вступать в семьи
+	childScopedCapabilities = []string{
+		"contracts:view",
+		"tasks:submit",
+		"rewards:claim",
+	}
+)
+
+// Capabilities возвращает список возможностей для роли и области доступа токена
+func Capabilities(role, scope string) []string {
+	switch {
+	case role == "parent":
+		return parentCapabilities
+	case scope == utils.ScopeChild:
+		return childScopedCapabilities
+	default:
+		return childCapabilities
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// LinkCatalogReward заполняет награду контракта по записи каталога.
+// Если points задан, стоимость в этом контракте отличается от каталожной
+// и не меняется вместе с каталогом.
+func LinkCatalogReward(reward *models.Reward, item *models.CatalogReward, points *int) {
+	reward.CatalogRewardID = &item.ID
+	reward.Title = item.Title
+	reward.Description = item.Description
+	reward.PointsCost = item.Points
+	reward.CustomPoints = points != nil
+	if points != nil {
+		reward.PointsCost = *points
+	}
+}
+
+// SyncCatalogReward переносит изменения записи каталога в награды черновиков.
+// Условия предложенных и подписанных контрактов уже согласованы сторонами,
+// поэтому они сохраняют прежние название и стоимость.
+func SyncCatalogReward(tx *gorm.DB, item *models.CatalogReward) error {
+	drafts := tx.Model(&models.Contract{}).Select("id").Where("status = ?", models.ContractDraft)
+	linked := tx.Model(&models.Reward{}).
+		Where("catalog_reward_id = ? AND contract_id IN (?)", item.ID, drafts)
+
+	now := time.Now()
+	if err := linked.Session(&gorm.Session{}).Updates(map[string]interface{}{
+		"title":       item.Title,
+		"description": item.Description,
+		"updated_at":  now,
+	}).Error; err != nil {
+		return err
+	}
+	return linked.Session(&gorm.Session{}).
+		Where("NOT custom_points").
+		Update("points", item.Points).Error
+}
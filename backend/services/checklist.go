@@ -0,0 +1,87 @@
+package services
+
+import (
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// PartialPoints возвращает баллы, пропорциональные выполненным пунктам
+// чек-листа, с округлением вниз. Без чек-листа начисляются все баллы.
+func PartialPoints(points, done, total int) int {
+	if total <= 0 {
+		return points
+	}
+	if done > total {
+		done = total
+	}
+	return points * done / total
+}
+
+// LoadChecklists загружает пункты чек-листов задач, упорядоченные по позиции
+func LoadChecklists(db *gorm.DB, taskIDs []string) (map[string][]models.TaskChecklistItem, error) {
+	checklists := make(map[string][]models.TaskChecklistItem)
+	if len(taskIDs) == 0 {
+		return checklists, nil
+	}
+
+	var items []models.TaskChecklistItem
+	if err := db.Where("task_id IN ?", taskIDs).
+		Order("position, created_at, id").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		checklists[item.TaskID] = append(checklists[item.TaskID], item)
+	}
+	return checklists, nil
+}
+
+// ChecklistProgress возвращает прогресс чек-листа задачи
+func ChecklistProgress(db *gorm.DB, taskID string) (*models.TaskProgress, error) {
+	var counts struct {
+		Done  int
+		Total int
+	}
+	err := db.Model(&models.TaskChecklistItem{}).
+		Select("COUNT(completed_at) AS done, COUNT(*) AS total").
+		Where("task_id = ?", taskID).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return models.NewTaskProgress(counts.Done, counts.Total), nil
+}
+
+// LoadProgress заполняет прогресс чек-листов для списка задач.
+// Задачам без чек-листа прогресс не назначается.
+func LoadProgress(db *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	var rows []struct {
+		TaskID string
+		Done   int
+		Total  int
+	}
+	if err := db.Model(&models.TaskChecklistItem{}).
+		Select("task_id, COUNT(completed_at) AS done, COUNT(*) AS total").
+		Where("task_id IN ?", ids).
+		Group("task_id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	progress := make(map[string]*models.TaskProgress, len(rows))
+	for _, row := range rows {
+		progress[row.TaskID] = models.NewTaskProgress(row.Done, row.Total)
+	}
+	for i := range tasks {
+		tasks[i].Progress = progress[tasks[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TransitionContract выполняет действие над контрактом по правилам
+// ContractMachine вместе с побочными эффектами перехода.
+// actorID пуст, если действие выполняет планировщик.
+func TransitionContract(tx *gorm.DB, contractID, action, role, actorID, ipAddress, reason string) (*models.Contract, error) {
+	var contract models.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&contract, "id = ?", contractID).Error; err != nil {
+		return nil, err
+	}
+
+	// Предложение фиксирует версию условий и подписывается родителем
+	if action == ContractPropose {
+		if _, err := ContractMachine.Find(action, contract.Status, role); err != nil {
+			return nil, err
+		}
+		return &contract, ProposeContract(tx, &contract, actorID, ipAddress)
+	}
+
+	// Отозвать можно только новый контракт, изменения подписанного
+	// контракта можно лишь скорректировать новой версией
+	if action == ContractWithdraw && contract.ActivatedAt != nil {
+		return nil, ErrInvalidTransition
+	}
+
+	from := contract.Status
+	t, err := ContractMachine.Fire(tx, contract.ID, action, from, role, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     t.To,
+		"updated_at": now,
+	}
+	switch action {
+	case ContractWithdraw:
+		updates["proposed_at"] = nil
+	case ContractComplete:
+		updates["completed_at"] = now
+	case ContractTerminate:
+		updates["terminated_at"] = now
+	}
+	if err := SetStatus(tx, &contract, contract.ID, from, updates); err != nil {
+		return nil, err
+	}
+	contract.Status = t.To
+
+	switch action {
+	case ContractComplete:
+		// Итоги контракта подводятся до переноса баллов в следующий контракт
+		if err := ApplyClosingRules(tx, &contract, actorID); err != nil {
+			return nil, err
+		}
+		if _, err := AwardAchievements(tx, contract.ChildID, now); err != nil {
+			return nil, err
+		}
+		// Контракт с автопродлением сразу продлевается на следующий период,
+		// новый контракт предлагается ребенку на подпись
+		if contract.AutoRenew && contract.SuccessorID == nil {
+			if _, err := RenewContract(tx, contract.ID, actorID, ipAddress, RenewOptions{
+				CarryOver: contract.CarryOverPoints,
+				Propose:   true,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	case ContractTerminate:
+		if err := terminateContract(tx, &contract, actorID, now); err != nil {
+			return nil, err
+		}
+	}
+	return &contract, nil
+}
+
+// terminateContract отменяет незавершенные задачи расторгнутого контракта,
+// отклоняет ожидающие проверки отправки и отзывает открытые предложения.
+// Начисленные баллы и полученные награды остаются в силе.
+func terminateContract(tx *gorm.DB, contract *models.Contract, actorID string, now time.Time) error {
+	const reason = "Контракт расторгнут"
+
+	for _, status := range []string{models.TaskPending, models.TaskSubmitted} {
+		var ids []string
+		if err := tx.Model(&models.Task{}).
+			Where("contract_id = ? AND status = ?", contract.ID, status).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		t, err := TaskMachine.Find(TaskCancel, status, ActorSystem)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Task{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{"status": t.To, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := RecordTransitions(tx, EntityTask, ids, t, status, ActorSystem, actorID, reason); err != nil {
+			return err
+		}
+	}
+
+	var reviewedBy interface{}
+	if actorID != "" {
+		reviewedBy = actorID
+	}
+	if err := tx.Model(&models.TaskSubmission{}).
+		Where("status = ? AND task_id IN (?)", "submitted",
+			tx.Model(&models.Task{}).Select("id").Where("contract_id = ?", contract.ID)).
+		Updates(map[string]interface{}{
+			"status":        "rejected",
+			"review_reason": reason,
+			"reviewed_by":   reviewedBy,
+			"reviewed_at":   now,
+			"updated_at":    now,
+		}).Error; err != nil {
+		return err
+	}
+
+	// Отложенные баллы возвращаются в баланс, копить по расторгнутому контракту больше не на что
+	if err := CancelContractGoals(tx, contract.ID, actorID, reason); err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ContractProposal{}).
+		Where("contract_id = ? AND status = ?", contract.ID, models.ProposalOpen).
+		Updates(map[string]interface{}{
+			"status":           models.ProposalWithdrawn,
+			"resolved_by":      reviewedBy,
+			"resolved_at":      now,
+			"response_message": reason,
+			"updated_at":       now,
+		}).Error
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Роли подписантов контракта
+const (
+	SignerParent   = "parent"
+	SignerChild    = "child"
+	SignerCoParent = "co_parent"
+)
+
+// RequiredSigners возвращает роли, чьи подписи нужны для вступления контракта в силу
+func RequiredSigners(contract *models.Contract) []string {
+	signers := []string{SignerParent, SignerChild}
+	if contract.CoSignerID != nil {
+		signers = append(signers, SignerCoParent)
+	}
+	return signers
+}
+
+// MissingSigners возвращает роли, которые еще не подписали текущие условия контракта
+func MissingSigners(db *gorm.DB, contract *models.Contract) ([]string, error) {
+	var signed []string
+	if err := db.Model(&models.ContractSignature{}).
+		Where("contract_id = ? AND terms_hash = ?", contract.ID, contract.TermsHash).
+		Distinct().
+		Pluck("signer_role", &signed).Error; err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(signed))
+	for _, role := range signed {
+		done[role] = true
+	}
+
+	missing := make([]string, 0)
+	for _, role := range RequiredSigners(contract) {
+		if !done[role] {
+			missing = append(missing, role)
+		}
+	}
+	return missing, nil
+}
+
+// SignContract сохраняет подпись под текущими условиями контракта и, если
+// подписали все стороны, переводит контракт в статус active.
+// Контракт должен быть заблокирован вызывающей транзакцией.
+func SignContract(tx *gorm.DB, contract *models.Contract, signerID, signerRole, ipAddress string) error {
+	now := time.Now()
+	version := contract.CurrentVersion
+	signature := models.ContractSignature{
+		ContractID: contract.ID,
+		SignerID:   signerID,
+		SignerRole: signerRole,
+		TermsHash:  contract.TermsHash,
+		Version:    &version,
+		IPAddress:  ipAddress,
+		SignedAt:   now,
+	}
+	if err := tx.Omit("Signer").Clauses(clause.OnConflict{DoNothing: true}).Create(&signature).Error; err != nil {
+		return err
+	}
+
+	missing, err := MissingSigners(tx, contract)
+	if err != nil || len(missing) > 0 {
+		return err
+	}
+
+	// Контракт вступает в силу автоматически после последней подписи
+	if _, err := ContractMachine.Fire(tx, contract.ID, ContractActivate, contract.Status, ActorSystem, signerID, ""); err != nil {
+		return err
+	}
+
+	contract.Status = models.ContractActive
+	contract.ActivatedAt = &now
+	return tx.Model(contract).Updates(map[string]interface{}{
+		"status":       models.ContractActive,
+		"activated_at": now,
+		"updated_at":   now,
+	}).Error
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// TaskTerms - условия одной задачи контракта
+type TaskTerms struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Points        int       `json:"points"`
+	DueDate       time.Time `json:"due_date"`
+	Checklist     []string  `json:"checklist,omitempty"`
+	PartialCredit bool      `json:"partial_credit,omitempty"`
+}
+
+// RewardTerms - условия одной награды контракта
+type RewardTerms struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Points      int    `json:"points"`
+	// Ограничения повторяемой награды; у разовых наград не заполняются,
+	// поэтому хеш условий прежних контрактов не меняется
+	RewardLimits
+}
+
+// SeriesTerms - условия повторяющейся задачи
+type SeriesTerms struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Points      int        `json:"points"`
+	Rule        string     `json:"rule"`
+	DueTime     string     `json:"due_time"`
+	StartsOn    time.Time  `json:"starts_on"`
+	EndsOn      *time.Time `json:"ends_on,omitempty"`
+}
+
+// RuleTerms - правило бонусов и штрафов контракта
+type RuleTerms struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Trigger    string          `json:"trigger"`
+	Scope      string          `json:"scope"`
+	Conditions []RuleCondition `json:"conditions"`
+	Action     string          `json:"action"`
+	Points     int             `json:"points"`
+	RewardID   string          `json:"reward_id,omitempty"`
+	Timezone   string          `json:"timezone"`
+	Enabled    bool            `json:"enabled"`
+}
+
+// StreakTerms - бонус контракта за серию задач, выполненных вовремя
+type StreakTerms struct {
+	ID                string `json:"id"`
+	Scope             string `json:"scope"`
+	SeriesID          string `json:"series_id,omitempty"`
+	Kind              string `json:"kind"`
+	Length            int    `json:"length"`
+	MultiplierPercent int    `json:"multiplier_percent,omitempty"`
+	Points            int    `json:"points,omitempty"`
+	Timezone          string `json:"timezone"`
+}
+
+// ContractTerms - условия контракта, под которыми ставятся подписи
+type ContractTerms struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	ParentID    string        `json:"parent_id"`
+	ChildID     string        `json:"child_id"`
+	CoSignerID  string        `json:"co_signer_id,omitempty"`
+	StartDate   time.Time     `json:"start_date"`
+	EndDate     time.Time     `json:"end_date"`
+	Tasks       []TaskTerms   `json:"tasks"`
+	Rewards     []RewardTerms `json:"rewards"`
+	Series      []SeriesTerms `json:"series,omitempty"`
+	// Правила и бонусы за серии не заполняются у контрактов без них,
+	// поэтому хеш условий прежних контрактов не меняется
+	Rules   []RuleTerms   `json:"rules,omitempty"`
+	Streaks []StreakTerms `json:"streaks,omitempty"`
+}
+
+// LoadTerms собирает текущие условия контракта вместе с его задачами и наградами
+func LoadTerms(db *gorm.DB, contract *models.Contract) (*ContractTerms, error) {
+	// Экземпляры повторяющихся задач входят в условия через свою серию,
+	// отдельно учитываются только измененные вручную повторения
+	var tasks []models.Task
+	if err := db.Where("contract_id = ? AND (series_id IS NULL OR is_exception)", contract.ID).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskIDs := make([]string, len(tasks))
+	for i, task := range tasks {
+		taskIDs[i] = task.ID
+	}
+	checklists, err := LoadChecklists(db, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	var series []models.TaskSeries
+	if err := db.Where("contract_id = ?", contract.ID).Find(&series).Error; err != nil {
+		return nil, err
+	}
+	var rewards []models.Reward
+	if err := db.Where("contract_id = ?", contract.ID).Find(&rewards).Error; err != nil {
+		return nil, err
+	}
+	var rules []models.ContractRule
+	if err := db.Where("contract_id = ?", contract.ID).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	var streaks []models.StreakBonus
+	if err := db.Where("contract_id = ?", contract.ID).Find(&streaks).Error; err != nil {
+		return nil, err
+	}
+
+	terms := &ContractTerms{
+		Title:       contract.Title,
+		Description: contract.Description,
+		ParentID:    contract.ParentID,
+		ChildID:     contract.ChildID,
+		StartDate:   contract.StartDate.UTC(),
+		EndDate:     contract.EndDate.UTC(),
+		Tasks:       make([]TaskTerms, 0, len(tasks)),
+		Rewards:     make([]RewardTerms, 0, len(rewards)),
+	}
+	if contract.CoSignerID != nil {
+		terms.CoSignerID = *contract.CoSignerID
+	}
+	for _, task := range tasks {
+		item := TaskTerms{
+			ID:            task.ID,
+			Title:         task.Title,
+			Description:   task.Description,
+			Points:        task.Points,
+			DueDate:       task.DueDate.UTC(),
+			PartialCredit: task.PartialCredit,
+		}
+		// Отметки о выполнении пунктов в условия не входят, только их состав
+		for _, checklistItem := range checklists[task.ID] {
+			item.Checklist = append(item.Checklist, checklistItem.Title)
+		}
+		terms.Tasks = append(terms.Tasks, item)
+	}
+	for _, reward := range rewards {
+		item := RewardTerms{
+			ID:           reward.ID,
+			Title:        reward.Title,
+			Description:  reward.Description,
+			Points:       reward.PointsCost,
+			RewardLimits: LimitsOf(&reward),
+		}
+		terms.Rewards = append(terms.Rewards, item)
+	}
+
+	for _, item := range series {
+		terms.Series = append(terms.Series, SeriesTerms{
+			ID:          item.ID,
+			Title:       item.Title,
+			Description: item.Description,
+			Points:      item.Points,
+			Rule:        item.Rule,
+			DueTime:     item.DueTime,
+			StartsOn:    Date(item.StartsOn),
+			EndsOn:      item.EndsOn,
+		})
+	}
+
+	for _, rule := range rules {
+		conditions, err := ParseConditions(rule.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		item := RuleTerms{
+			ID:         rule.ID,
+			Name:       rule.Name,
+			Trigger:    rule.Trigger,
+			Scope:      rule.Scope,
+			Conditions: conditions,
+			Action:     rule.Action,
+			Points:     rule.Points,
+			Timezone:   rule.Timezone,
+			Enabled:    rule.Enabled,
+		}
+		if rule.RewardID != nil {
+			item.RewardID = *rule.RewardID
+		}
+		terms.Rules = append(terms.Rules, item)
+	}
+
+	for _, bonus := range streaks {
+		item := StreakTerms{
+			ID:                bonus.ID,
+			Scope:             bonus.Scope,
+			Kind:              bonus.Kind,
+			Length:            bonus.Length,
+			MultiplierPercent: bonus.MultiplierPercent,
+			Points:            bonus.Points,
+			Timezone:          bonus.Timezone,
+		}
+		if bonus.SeriesID != nil {
+			item.SeriesID = *bonus.SeriesID
+		}
+		terms.Streaks = append(terms.Streaks, item)
+	}
+
+	// Порядок задач и наград не должен влиять на хеш
+	sort.Slice(terms.Tasks, func(i, j int) bool { return terms.Tasks[i].ID < terms.Tasks[j].ID })
+	sort.Slice(terms.Rewards, func(i, j int) bool { return terms.Rewards[i].ID < terms.Rewards[j].ID })
+	sort.Slice(terms.Series, func(i, j int) bool { return terms.Series[i].ID < terms.Series[j].ID })
+	sort.Slice(terms.Rules, func(i, j int) bool { return terms.Rules[i].ID < terms.Rules[j].ID })
+	sort.Slice(terms.Streaks, func(i, j int) bool { return terms.Streaks[i].ID < terms.Streaks[j].ID })
+
+	return terms, nil
+}
+
+// Hash возвращает SHA-256 канонического JSON-представления условий
+func (t *ContractTerms) Hash() string {
+	data, _ := json.Marshal(t)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Виды изменений между версиями контракта
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// TermsChange - одно изменение условий между двумя версиями контракта
+type TermsChange struct {
+	Field  string      `json:"field"`
+	Change string      `json:"change"`
+	ItemID string      `json:"item_id,omitempty"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// RecordVersion сохраняет снимок условий контракта как новую версию
+func RecordVersion(tx *gorm.DB, contract *models.Contract, terms *ContractTerms, actorID, summary string) (*models.ContractVersion, error) {
+	data, err := json.Marshal(terms)
+	if err != nil {
+		return nil, err
+	}
+
+	var last int
+	if err := tx.Model(&models.ContractVersion{}).
+		Select("COALESCE(MAX(version), 0)").
+		Where("contract_id = ?", contract.ID).
+		Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	version := &models.ContractVersion{
+		ContractID: contract.ID,
+		Version:    last + 1,
+		Terms:      models.JSON(data),
+		TermsHash:  terms.Hash(),
+		Summary:    summary,
+		CreatedAt:  time.Now(),
+	}
+	if actorID != "" {
+		version.CreatedBy = &actorID
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return nil, err
+	}
+
+	contract.CurrentVersion = version.Version
+	if err := tx.Model(contract).Update("current_version", version.Version).Error; err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// VersionTerms разбирает снимок условий из версии контракта
+func VersionTerms(version *models.ContractVersion) (*ContractTerms, error) {
+	var terms ContractTerms
+	if err := json.Unmarshal(version.Terms, &terms); err != nil {
+		return nil, err
+	}
+	return &terms, nil
+}
+
+// ErrNotContractParty возвращается, если пользователь не является стороной контракта
+var ErrNotContractParty = errors.New("пользователь не является стороной контракта")
+
+// SignerRole определяет, в каком качестве пользователь подписывает контракт.
+// Другие опекуны ребенка сторонами контракта не являются.
+func SignerRole(contract *models.Contract, userID string) (string, error) {
+	switch {
+	case contract.ChildID == userID:
+		return SignerChild, nil
+	case contract.CoSignerID != nil && *contract.CoSignerID == userID:
+		return SignerCoParent, nil
+	case contract.ParentID == userID:
+		return SignerParent, nil
+	default:
+		return "", ErrNotContractParty
+	}
+}
+
+// ProposeContract отправляет черновик контракта на подпись: сохраняет
+// первую версию условий и подписывает их от имени предлагающего родителя,
+// если он является стороной контракта.
+// Контракт должен быть заблокирован вызывающей транзакцией.
+func ProposeContract(tx *gorm.DB, contract *models.Contract, actorID, ipAddress string) error {
+	if _, err := ContractMachine.Fire(tx, contract.ID, ContractPropose, contract.Status, ActorParent, actorID, ""); err != nil {
+		return err
+	}
+
+	terms, err := LoadTerms(tx, contract)
+	if err != nil {
+		return err
+	}
+
+	if _, err := RecordVersion(tx, contract, terms, actorID, "Контракт предложен на подпись"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	contract.Status = models.ContractPendingSignature
+	contract.TermsHash = terms.Hash()
+	contract.ProposedAt = &now
+	if err := tx.Model(contract).Updates(map[string]interface{}{
+		"status":       contract.Status,
+		"co_signer_id": contract.CoSignerID,
+		"terms_hash":   contract.TermsHash,
+		"proposed_at":  now,
+		"updated_at":   now,
+	}).Error; err != nil {
+		return err
+	}
+
+	// Предложение опекуна, который не является стороной контракта,
+	// подписывает сам родитель-автор
+	role, err := SignerRole(contract, actorID)
+	if err != nil {
+		return nil
+	}
+	return SignContract(tx, contract, actorID, role, ipAddress)
+}
+
+// AmendContract фиксирует изменение условий предложенного или подписанного
+// контракта: создает новую версию, возвращает контракт на подпись и
+// подписывает новые условия от имени стороны, внесшей изменение.
+// Вызывается в той же транзакции, что и само изменение.
+func AmendContract(tx *gorm.DB, contractID, actorID, ipAddress, summary string) error {
+	var contract models.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&contract, "id = ?", contractID).Error; err != nil {
+		return err
+	}
+	if !contract.Versioned() {
+		return nil
+	}
+
+	terms, err := LoadTerms(tx, &contract)
+	if err != nil {
+		return err
+	}
+	hash := terms.Hash()
+	if hash == contract.TermsHash {
+		return nil
+	}
+
+	if _, err := RecordVersion(tx, &contract, terms, actorID, summary); err != nil {
+		return err
+	}
+
+	if _, err := ContractMachine.Fire(tx, contract.ID, ContractAmend, contract.Status, ActorSystem, actorID, summary); err != nil {
+		return err
+	}
+
+	contract.Status = models.ContractPendingSignature
+	contract.TermsHash = hash
+	if err := tx.Model(&contract).Updates(map[string]interface{}{
+		"status":     contract.Status,
+		"terms_hash": hash,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	// Изменение, внесенное опекуном, который не является стороной контракта,
+	// подписывают все стороны заново
+	role, err := SignerRole(&contract, actorID)
+	if err != nil {
+		return nil
+	}
+	return SignContract(tx, &contract, actorID, role, ipAddress)
+}
+
+// DiffTerms возвращает список изменений условий между двумя версиями
+func DiffTerms(from, to *ContractTerms) []TermsChange {
+	changes := make([]TermsChange, 0)
+	field := func(name, itemID string, before, after interface{}) {
+		if before != after {
+			changes = append(changes, TermsChange{Field: name, Change: ChangeChanged, ItemID: itemID, Before: before, After: after})
+		}
+	}
+
+	field("title", "", from.Title, to.Title)
+	field("description", "", from.Description, to.Description)
+	field("co_signer_id", "", from.CoSignerID, to.CoSignerID)
+	if !from.StartDate.Equal(to.StartDate) {
+		field("start_date", "", from.StartDate, to.StartDate)
+	}
+	if !from.EndDate.Equal(to.EndDate) {
+		field("end_date", "", from.EndDate, to.EndDate)
+	}
+
+	oldTasks := make(map[string]TaskTerms, len(from.Tasks))
+	for _, task := range from.Tasks {
+		oldTasks[task.ID] = task
+	}
+	for _, task := range to.Tasks {
+		old, ok := oldTasks[task.ID]
+		if !ok {
+			changes = append(changes, TermsChange{Field: "task", Change: ChangeAdded, ItemID: task.ID, After: task})
+			continue
+		}
+		delete(oldTasks, task.ID)
+		field("task.title", task.ID, old.Title, task.Title)
+		field("task.description", task.ID, old.Description, task.Description)
+		field("task.points", task.ID, old.Points, task.Points)
+		if !old.DueDate.Equal(task.DueDate) {
+			field("task.due_date", task.ID, old.DueDate, task.DueDate)
+		}
+		field("task.checklist", task.ID, strings.Join(old.Checklist, "\n"), strings.Join(task.Checklist, "\n"))
+		field("task.partial_credit", task.ID, old.PartialCredit, task.PartialCredit)
+	}
+	for _, task := range from.Tasks {
+		if _, ok := oldTasks[task.ID]; ok {
+			changes = append(changes, TermsChange{Field: "task", Change: ChangeRemoved, ItemID: task.ID, Before: task})
+		}
+	}
+
+	oldRewards := make(map[string]RewardTerms, len(from.Rewards))
+	for _, reward := range from.Rewards {
+		oldRewards[reward.ID] = reward
+	}
+	for _, reward := range to.Rewards {
+		old, ok := oldRewards[reward.ID]
+		if !ok {
+			changes = append(changes, TermsChange{Field: "reward", Change: ChangeAdded, ItemID: reward.ID, After: reward})
+			continue
+		}
+		delete(oldRewards, reward.ID)
+		field("reward.title", reward.ID, old.Title, reward.Title)
+		field("reward.description", reward.ID, old.Description, reward.Description)
+		field("reward.points", reward.ID, old.Points, reward.Points)
+		field("reward.repeatable", reward.ID, old.Repeatable, reward.Repeatable)
+		field("reward.stock", reward.ID, intValue(old.Stock), intValue(reward.Stock))
+		field("reward.period_limit", reward.ID, old.PeriodLimit, reward.PeriodLimit)
+		field("reward.limit_period", reward.ID, old.LimitPeriod, reward.LimitPeriod)
+		field("reward.cooldown_minutes", reward.ID, old.CooldownMinutes, reward.CooldownMinutes)
+		field("reward.timezone", reward.ID, old.Timezone, reward.Timezone)
+	}
+	for _, reward := range from.Rewards {
+		if _, ok := oldRewards[reward.ID]; ok {
+			changes = append(changes, TermsChange{Field: "reward", Change: ChangeRemoved, ItemID: reward.ID, Before: reward})
+		}
+	}
+
+	oldSeries := make(map[string]SeriesTerms, len(from.Series))
+	for _, item := range from.Series {
+		oldSeries[item.ID] = item
+	}
+	for _, item := range to.Series {
+		old, ok := oldSeries[item.ID]
+		if !ok {
+			changes = append(changes, TermsChange{Field: "series", Change: ChangeAdded, ItemID: item.ID, After: item})
+			continue
+		}
+		delete(oldSeries, item.ID)
+		field("series.title", item.ID, old.Title, item.Title)
+		field("series.description", item.ID, old.Description, item.Description)
+		field("series.points", item.ID, old.Points, item.Points)
+		field("series.rule", item.ID, old.Rule, item.Rule)
+		field("series.due_time", item.ID, old.DueTime, item.DueTime)
+	}
+	for _, item := range from.Series {
+		if _, ok := oldSeries[item.ID]; ok {
+			changes = append(changes, TermsChange{Field: "series", Change: ChangeRemoved, ItemID: item.ID, Before: item})
+		}
+	}
+
+	oldRules := make(map[string]RuleTerms, len(from.Rules))
+	for _, rule := range from.Rules {
+		oldRules[rule.ID] = rule
+	}
+	for _, rule := range to.Rules {
+		old, ok := oldRules[rule.ID]
+		if !ok {
+			changes = append(changes, TermsChange{Field: "rule", Change: ChangeAdded, ItemID: rule.ID, After: rule})
+			continue
+		}
+		delete(oldRules, rule.ID)
+		field("rule.name", rule.ID, old.Name, rule.Name)
+		field("rule.trigger", rule.ID, old.Trigger, rule.Trigger)
+		field("rule.scope", rule.ID, old.Scope, rule.Scope)
+		field("rule.conditions", rule.ID, conditionsText(old.Conditions), conditionsText(rule.Conditions))
+		field("rule.action", rule.ID, old.Action, rule.Action)
+		field("rule.points", rule.ID, old.Points, rule.Points)
+		field("rule.reward_id", rule.ID, old.RewardID, rule.RewardID)
+		field("rule.timezone", rule.ID, old.Timezone, rule.Timezone)
+		field("rule.enabled", rule.ID, old.Enabled, rule.Enabled)
+	}
+	for _, rule := range from.Rules {
+		if _, ok := oldRules[rule.ID]; ok {
+			changes = append(changes, TermsChange{Field: "rule", Change: ChangeRemoved, ItemID: rule.ID, Before: rule})
+		}
+	}
+
+	oldStreaks := make(map[string]StreakTerms, len(from.Streaks))
+	for _, bonus := range from.Streaks {
+		oldStreaks[bonus.ID] = bonus
+	}
+	for _, bonus := range to.Streaks {
+		old, ok := oldStreaks[bonus.ID]
+		if !ok {
+			changes = append(changes, TermsChange{Field: "streak", Change: ChangeAdded, ItemID: bonus.ID, After: bonus})
+			continue
+		}
+		delete(oldStreaks, bonus.ID)
+		field("streak.scope", bonus.ID, old.Scope, bonus.Scope)
+		field("streak.series_id", bonus.ID, old.SeriesID, bonus.SeriesID)
+		field("streak.kind", bonus.ID, old.Kind, bonus.Kind)
+		field("streak.length", bonus.ID, old.Length, bonus.Length)
+		field("streak.multiplier_percent", bonus.ID, old.MultiplierPercent, bonus.MultiplierPercent)
+		field("streak.points", bonus.ID, old.Points, bonus.Points)
+		field("streak.timezone", bonus.ID, old.Timezone, bonus.Timezone)
+	}
+	for _, bonus := range from.Streaks {
+		if _, ok := oldStreaks[bonus.ID]; ok {
+			changes = append(changes, TermsChange{Field: "streak", Change: ChangeRemoved, ItemID: bonus.ID, Before: bonus})
+		}
+	}
+
+	return changes
+}
+
+// conditionsText записывает условия правила строкой, чтобы сравнивать их по значению
+func conditionsText(conditions []RuleCondition) string {
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = fmt.Sprintf("%s %s %d", condition.Field, condition.Op, condition.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// intValue разыменовывает необязательное число, чтобы сравнивать значения, а не указатели
+func intValue(value *int) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const familyCodeLength = 8
+
+// IsGuardian проверяет, является ли родитель опекуном ребенка
+func IsGuardian(db *gorm.DB, parentID, childID string) bool {
+	var count int64
+	db.Model(&models.Guardianship{}).
+		Where("parent_id = ? AND child_id = ?", parentID, childID).
+		Count(&count)
+	return count > 0
+}
+
+// GuardedChildren возвращает подзапрос с ID детей, опекуном которых является родитель
+func GuardedChildren(db *gorm.DB, parentID interface{}) *gorm.DB {
+	return db.Model(&models.Guardianship{}).Select("child_id").Where("parent_id = ?", parentID)
+}
+
+// UserFamilies возвращает подзапрос с ID семей, в которых состоит пользователь
+func UserFamilies(db *gorm.DB, userID interface{}) *gorm.DB {
+	return db.Model(&models.FamilyMember{}).Select("family_id").Where("user_id = ?", userID)
+}
+
+// FamilyParents возвращает подзапрос с ID родителей из всех семей пользователя,
+// включая его самого
+func FamilyParents(db *gorm.DB, userID interface{}) *gorm.DB {
+	return db.Model(&models.FamilyMember{}).Select("user_id").Where("role = ? AND family_id IN (?)", "parent", UserFamilies(db, userID))
+}
+
+// IsFamilyMember проверяет, состоит ли пользователь в семье
+func IsFamilyMember(db *gorm.DB, familyID, userID string) bool {
+	var count int64
+	db.Model(&models.FamilyMember{}).
+		Where("family_id = ? AND user_id = ?", familyID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// IsFamilyParent проверяет, состоит ли пользователь в семье в роли родителя
+func IsFamilyParent(db *gorm.DB, familyID, userID string) bool {
+	var count int64
+	db.Model(&models.FamilyMember{}).
+		Where("family_id = ? AND user_id = ? AND role = ?", familyID, userID, "parent").
+		Count(&count)
+	return count > 0
+}
+
+// AddFamilyMember добавляет пользователя в семью и связывает его опекунством
+// со всеми участниками семьи противоположной роли. Повторное добавление
+// ничего не меняет.
+func AddFamilyMember(tx *gorm.DB, familyID, userID, role string) error {
+	now := time.Now()
+	member := models.FamilyMember{
+		FamilyID:  familyID,
+		UserID:    userID,
+		Role:      role,
+		CreatedAt: now,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		return err
+	}
+
+	var others []models.FamilyMember
+	if err := tx.Where("family_id = ? AND user_id <> ? AND role <> ?", familyID, userID, role).
+		Find(&others).Error; err != nil {
+		return err
+	}
+
+	for _, other := range others {
+		link := models.Guardianship{FamilyID: familyID, CreatedAt: now}
+		if role == "parent" {
+			link.ParentID, link.ChildID = userID, other.UserID
+		} else {
+			link.ParentID, link.ChildID = other.UserID, userID
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFamilyMember исключает пользователя из семьи. Связь опекунства с
+// участником этой семьи удаляется, только если ее не подкрепляет другая
+// общая семья, иначе связь переносится в эту семью.
+func RemoveFamilyMember(tx *gorm.DB, familyID, userID string) error {
+	if err := tx.Where("family_id = ? AND user_id = ?", familyID, userID).
+		Delete(&models.FamilyMember{}).Error; err != nil {
+		return err
+	}
+
+	var links []models.Guardianship
+	if err := tx.Where("parent_id = ? OR child_id = ?", userID, userID).Find(&links).Error; err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		other := link.ChildID
+		if other == userID {
+			other = link.ParentID
+		}
+		if link.FamilyID != familyID && !IsFamilyMember(tx, familyID, other) {
+			continue
+		}
+
+		shared, err := sharedFamily(tx, link.ParentID, link.ChildID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case shared == "":
+			err = tx.Delete(&link).Error
+		case link.FamilyID == familyID:
+			err = tx.Model(&link).Update("family_id", shared).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedFamily возвращает ID семьи, в которой родитель и ребенок состоят
+// вместе, или пустую строку, если такой семьи нет
+func sharedFamily(tx *gorm.DB, parentID, childID string) (string, error) {
+	var ids []string
+	err := tx.Model(&models.FamilyMember{}).
+		Joins("JOIN families ON families.id = family_members.family_id AND families.deleted_at IS NULL").
+		Where("family_members.user_id = ? AND family_members.role = ?", parentID, "parent").
+		Where("family_members.family_id IN (?)",
+			tx.Model(&models.FamilyMember{}).Select("family_id").Where("user_id = ? AND role = ?", childID, "child")).
+		Limit(1).
+		Pluck("family_members.family_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// CreateFamily создает семью, владельцем и первым участником которой становится родитель
+func CreateFamily(tx *gorm.DB, name, ownerID string) (*models.Family, error) {
+	code, err := utils.RandomCode(familyCodeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	family := &models.Family{
+		Name:      name,
+		Code:      code,
+		OwnerID:   ownerID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := tx.Create(family).Error; err != nil {
+		return nil, err
+	}
+	if err := AddFamilyMember(tx, family.ID, ownerID, "parent"); err != nil {
+		return nil, err
+	}
+	return family, nil
+}
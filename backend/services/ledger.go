@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrInsufficientPoints возвращается, если на балансе ребенка недостаточно баллов
+var ErrInsufficientPoints = errors.New("недостаточно баллов")
+
+// ContractBalance - баланс ребенка в рамках одного контракта
+type ContractBalance struct {
+	ContractID string `json:"contract_id"`
+	Title      string `json:"title"`
+	Balance    int    `json:"balance"`
+	// Баллы, отложенные на цели накопления; в Balance не входят
+	Saved int `json:"saved"`
+}
+
+// LockBalance блокирует баланс ребенка по контракту до конца транзакции,
+// чтобы параллельные списания не могли увести баланс в минус
+func LockBalance(tx *gorm.DB, childID, contractID string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "ledger:"+childID+":"+contractID).Error
+}
+
+// Balance возвращает текущий баланс ребенка по контракту
+func Balance(db *gorm.DB, childID, contractID string) (int, error) {
+	var balance int
+	err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("child_id = ? AND contract_id = ?", childID, contractID).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// Balances возвращает балансы ребенка по всем его контрактам
+func Balances(db *gorm.DB, childID string) ([]ContractBalance, error) {
+	var balances []ContractBalance
+	err := balancesQuery(db, childID).
+		Order("contracts.title").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// BestBalance возвращает наибольший баланс ребенка среди действующих
+// контрактов. Если действующих контрактов нет, ContractID пуст.
+func BestBalance(db *gorm.DB, childID string) (ContractBalance, error) {
+	var balances []ContractBalance
+	err := balancesQuery(db, childID).
+		Where("contracts.status = ?", models.ContractActive).
+		Order("balance DESC, contracts.title").
+		Limit(1).
+		Scan(&balances).Error
+	if err != nil || len(balances) == 0 {
+		return ContractBalance{}, err
+	}
+	return balances[0], nil
+}
+
+func balancesQuery(db *gorm.DB, childID string) *gorm.DB {
+	return db.Table("contracts").
+		Select("contracts.id AS contract_id, contracts.title, COALESCE(SUM(points_ledger.amount), 0) AS balance, "+
+			"COALESCE(-SUM(points_ledger.amount) FILTER (WHERE points_ledger.goal_id IS NOT NULL), 0) AS saved").
+		Joins("LEFT JOIN points_ledger ON points_ledger.contract_id = contracts.id AND points_ledger.child_id = contracts.child_id").
+		Where("contracts.child_id = ? AND contracts.deleted_at IS NULL", childID).
+		Group("contracts.id, contracts.title")
+}
+
+// Post добавляет запись в журнал баллов
+func Post(tx *gorm.DB, entry *models.LedgerEntry) error {
+	if entry.Amount == 0 {
+		return nil
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return tx.Create(entry).Error
+}
+
+// TaskPoints возвращает количество баллов, уже начисленных за задачу,
+// вместе с бонусами за серию
+func TaskPoints(tx *gorm.DB, taskID string) (int, error) {
+	var points int
+	err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("task_id = ? AND entry_type IN ?", taskID, []string{models.LedgerEarn, models.LedgerAdjust, models.LedgerStreak}).
+		Scan(&points).Error
+	return points, err
+}
+
+// AwardTask начисляет ребенку баллы за выполненную задачу.
+// Повторный вызов для той же задачи ничего не начисляет.
+func AwardTask(tx *gorm.DB, task *models.Task, childID, actorID string) error {
+	return awardTask(tx, task, childID, actorID, task.Points, fmt.Sprintf("Выполнена задача «%s»", task.Title))
+}
+
+// AwardTaskPartial начисляет баллы пропорционально выполненным пунктам
+// чек-листа задачи. Задача без чек-листа засчитывается полностью.
+func AwardTaskPartial(tx *gorm.DB, task *models.Task, childID, actorID string) error {
+	progress, err := ChecklistProgress(tx, task.ID)
+	if err != nil {
+		return err
+	}
+	if progress.Total == 0 {
+		return AwardTask(tx, task, childID, actorID)
+	}
+	return awardTask(tx, task, childID, actorID, PartialPoints(task.Points, progress.Done, progress.Total),
+		fmt.Sprintf("Выполнена задача «%s» (%s)", task.Title, progress.Label))
+}
+
+func awardTask(tx *gorm.DB, task *models.Task, childID, actorID string, points int, description string) error {
+	if err := LockBalance(tx, childID, task.ContractID); err != nil {
+		return err
+	}
+
+	earned, err := TaskPoints(tx, task.ID)
+	if err != nil {
+		return err
+	}
+	if earned > 0 || points <= 0 {
+		return nil
+	}
+
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     childID,
+		ContractID:  task.ContractID,
+		Type:        models.LedgerEarn,
+		Amount:      points,
+		TaskID:      &task.ID,
+		Description: description,
+		CreatedBy:   &actorID,
+	})
+}
+
+// RevokeTask отменяет начисление баллов за задачу, включая бонусы за
+// серию, корректирующей записью
+func RevokeTask(tx *gorm.DB, task *models.Task, childID, actorID string) error {
+	if err := LockBalance(tx, childID, task.ContractID); err != nil {
+		return err
+	}
+
+	earned, err := TaskPoints(tx, task.ID)
+	if err != nil {
+		return err
+	}
+	if earned <= 0 {
+		return nil
+	}
+
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     childID,
+		ContractID:  task.ContractID,
+		Type:        models.LedgerAdjust,
+		Amount:      -earned,
+		TaskID:      &task.ID,
+		Description: fmt.Sprintf("Отмена выполнения задачи «%s»", task.Title),
+		CreatedBy:   &actorID,
+	})
+}
+
+// SpendReward резервирует стоимость награды по запросу ребенка.
+// Возвращает ErrInsufficientPoints, если баллов не хватает.
+func SpendReward(tx *gorm.DB, reward *models.Reward, claim *models.RewardClaim) error {
+	if err := LockBalance(tx, claim.ChildID, reward.ContractID); err != nil {
+		return err
+	}
+
+	balance, err := Balance(tx, claim.ChildID, reward.ContractID)
+	if err != nil {
+		return err
+	}
+	if balance < claim.Points {
+		return ErrInsufficientPoints
+	}
+
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     claim.ChildID,
+		ContractID:  reward.ContractID,
+		Type:        models.LedgerSpend,
+		Amount:      -claim.Points,
+		RewardID:    &reward.ID,
+		ClaimID:     &claim.ID,
+		Description: fmt.Sprintf("Запрошена награда «%s»", reward.Title),
+		CreatedBy:   &claim.ChildID,
+	})
+}
+
+// RefundClaim возвращает баллы, зарезервированные запросом награды
+func RefundClaim(tx *gorm.DB, reward *models.Reward, claim *models.RewardClaim, actorID string) error {
+	if err := LockBalance(tx, claim.ChildID, claim.ContractID); err != nil {
+		return err
+	}
+
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     claim.ChildID,
+		ContractID:  claim.ContractID,
+		Type:        models.LedgerRefund,
+		Amount:      claim.Points,
+		RewardID:    &reward.ID,
+		ClaimID:     &claim.ID,
+		Description: fmt.Sprintf("Возврат баллов за награду «%s»", reward.Title),
+		CreatedBy:   &actorID,
+	})
+}
+
+// Adjust вносит ручную корректировку баланса. Отрицательная корректировка
+// не может опустить баланс ниже нуля.
+func Adjust(tx *gorm.DB, childID, contractID string, amount int, description, actorID string) (*models.LedgerEntry, error) {
+	if err := LockBalance(tx, childID, contractID); err != nil {
+		return nil, err
+	}
+
+	if amount < 0 {
+		balance, err := Balance(tx, childID, contractID)
+		if err != nil {
+			return nil, err
+		}
+		if balance+amount < 0 {
+			return nil, ErrInsufficientPoints
+		}
+	}
+
+	entry := &models.LedgerEntry{
+		ChildID:     childID,
+		ContractID:  contractID,
+		Type:        models.LedgerAdjust,
+		Amount:      amount,
+		Description: description,
+		CreatedBy:   &actorID,
+	}
+	if err := Post(tx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// Виды изменений, которые можно предложить в рамках переговоров
+const (
+	ProposeTaskPoints   = "task_points"
+	ProposeRewardPoints = "reward_points"
+	ProposeRewardAdd    = "reward_add"
+)
+
+// ErrInvalidProposal возвращается, если предложение не соответствует условиям контракта
+var ErrInvalidProposal = errors.New("некорректное предложение")
+
+// ProposalChange - одно предлагаемое изменение условий контракта
+type ProposalChange struct {
+	Type        string `json:"type" binding:"required,oneof=task_points reward_points reward_add"`
+	TaskID      string `json:"task_id,omitempty"`
+	RewardID    string `json:"reward_id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Points      int    `json:"points" binding:"min=0"`
+}
+
+// ProposalChanges разбирает список изменений из предложения
+func ProposalChanges(proposal *models.ContractProposal) ([]ProposalChange, error) {
+	var changes []ProposalChange
+	if err := json.Unmarshal(proposal.Changes, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ValidateProposal проверяет, что все изменения относятся к задачам и наградам контракта
+func ValidateProposal(db *gorm.DB, contractID string, changes []ProposalChange) error {
+	if len(changes) == 0 {
+		return ErrInvalidProposal
+	}
+	for _, change := range changes {
+		var count int64
+		switch change.Type {
+		case ProposeTaskPoints:
+			db.Model(&models.Task{}).Where("id = ? AND contract_id = ?", change.TaskID, contractID).Count(&count)
+		case ProposeRewardPoints:
+			db.Model(&models.Reward{}).Where("id = ? AND contract_id = ?", change.RewardID, contractID).Count(&count)
+		case ProposeRewardAdd:
+			if change.Title != "" {
+				count = 1
+			}
+		}
+		if count == 0 || change.Points < 0 {
+			return ErrInvalidProposal
+		}
+	}
+	return nil
+}
+
+// ApplyProposal применяет изменения принятого предложения к контракту.
+// Новая версия условий создается вызывающим кодом через AmendContract.
+func ApplyProposal(tx *gorm.DB, contractID string, changes []ProposalChange) error {
+	now := time.Now()
+	for _, change := range changes {
+		var err error
+		switch change.Type {
+		case ProposeTaskPoints:
+			err = tx.Model(&models.Task{}).
+				Where("id = ? AND contract_id = ?", change.TaskID, contractID).
+				Updates(map[string]interface{}{"points": change.Points, "updated_at": now}).Error
+		case ProposeRewardPoints:
+			err = tx.Model(&models.Reward{}).
+				Where("id = ? AND contract_id = ?", change.RewardID, contractID).
+				Updates(map[string]interface{}{"points": change.Points, "updated_at": now}).Error
+		case ProposeRewardAdd:
+			err = tx.Omit("Contract").Create(&models.Reward{
+				Title:       change.Title,
+				Description: change.Description,
+				ContractID:  contractID,
+				PointsCost:  change.Points,
+				Status:      "available",
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
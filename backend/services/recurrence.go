@@ -0,0 +1,285 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частоты повторения задач
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// Ограничения правил повторения
+const (
+	maxRuleInterval = 365
+	// maxRuleSpan - максимальный период, на котором перебираются даты правила
+	maxRuleSpan = 5 * 366
+)
+
+// ErrInvalidRule возвращается при разборе некорректного правила повторения
+var ErrInvalidRule = errors.New("некорректное правило повторения")
+
+var ruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Короткие имена для распространенных расписаний
+var ruleAliases = map[string]string{
+	"DAILY":    "FREQ=DAILY",
+	"WEEKDAYS": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	"WEEKENDS": "FREQ=WEEKLY;BYDAY=SA,SU",
+	"WEEKLY":   "FREQ=WEEKLY",
+	"MONTHLY":  "FREQ=MONTHLY",
+}
+
+// Rule - правило повторения, подмножество RRULE из RFC 5545:
+// FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, BYDAY, BYMONTHDAY, COUNT и UNTIL.
+// Правило работает с календарными датами, время выполнения задается отдельно.
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+// ParseRule разбирает правило вида "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"
+// или один из псевдонимов: daily, weekdays, weekends, weekly, monthly
+func ParseRule(value string) (*Rule, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "RRULE:")
+	if alias, ok := ruleAliases[value]; ok {
+		value = alias
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" || seen[key] {
+			return nil, ErrInvalidRule
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			if val != FreqDaily && val != FreqWeekly && val != FreqMonthly {
+				return nil, ErrInvalidRule
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > maxRuleInterval {
+				return nil, ErrInvalidRule
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := ruleWeekdays[day]
+				if !ok {
+					return nil, ErrInvalidRule
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n > 31 || n < -31 {
+					return nil, ErrInvalidRule
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, ErrInvalidRule
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRuleDate(val)
+			if err != nil {
+				return nil, ErrInvalidRule
+			}
+			rule.Until = &until
+		default:
+			return nil, ErrInvalidRule
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, ErrInvalidRule
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != FreqMonthly {
+		return nil, ErrInvalidRule
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == FreqMonthly {
+		return nil, ErrInvalidRule
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, ErrInvalidRule
+	}
+	return rule, nil
+}
+
+func parseRuleDate(value string) (time.Time, error) {
+	if len(value) > 8 {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return Date(t), nil
+	}
+	return time.Parse("20060102", value)
+}
+
+// String возвращает правило в каноническом виде
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := append([]time.Weekday(nil), r.ByDay...)
+		sort.Slice(days, func(i, j int) bool { return isoWeekday(days[i]) < isoWeekday(days[j]) })
+		names := make([]string, 0, len(days))
+		for _, day := range days {
+			for name, weekday := range ruleWeekdays {
+				if weekday == day {
+					names = append(names, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences возвращает даты повторений в интервале [from, to] для серии,
+// начинающейся в start. Все значения рассматриваются как календарные даты.
+func (r *Rule) Occurrences(start, from, to time.Time) []time.Time {
+	start, from, to = Date(start), Date(from), Date(to)
+	if r.Until != nil && r.Until.Before(to) {
+		to = *r.Until
+	}
+	if limit := start.AddDate(0, 0, maxRuleSpan); limit.Before(to) {
+		to = limit
+	}
+
+	var result []time.Time
+	matched := 0
+	for day := start; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !r.matches(start, day) {
+			continue
+		}
+		matched++
+		if r.Count > 0 && matched > r.Count {
+			break
+		}
+		if !day.Before(from) {
+			result = append(result, day)
+		}
+	}
+	return result
+}
+
+func (r *Rule) matches(start, day time.Time) bool {
+	switch r.Freq {
+	case FreqDaily:
+		if daysBetween(start, day)%r.Interval != 0 {
+			return false
+		}
+		return len(r.ByDay) == 0 || containsWeekday(r.ByDay, day.Weekday())
+	case FreqWeekly:
+		weeks := daysBetween(weekStart(start), weekStart(day)) / 7
+		if weeks%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		return containsWeekday(r.ByDay, day.Weekday())
+	case FreqMonthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByMonthDay) == 0 {
+			return day.Day() == start.Day()
+		}
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, monthDay := range r.ByMonthDay {
+			if monthDay == day.Day() || (monthDay < 0 && last+monthDay+1 == day.Day()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Date отбрасывает время, оставляя календарную дату в UTC
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseTimeOfDay разбирает время дня в формате "ЧЧ:ММ"
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное время %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -(isoWeekday(day.Weekday()) - 1))
+}
+
+func isoWeekday(day time.Weekday) int {
+	if day == time.Sunday {
+		return 7
+	}
+	return int(day)
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAlreadyRenewed возвращается, если у контракта уже есть следующий контракт
+	ErrAlreadyRenewed = errors.New("контракт уже продлен")
+	// ErrNotRenewable возвращается для контрактов, которые еще не вступили в силу
+	ErrNotRenewable = errors.New("контракт нельзя продлить в текущем статусе")
+)
+
+// RenewOptions - параметры продления контракта
+type RenewOptions struct {
+	// Перенести непотраченные баллы на новый контракт
+	CarryOver bool
+	// Сразу предложить новый контракт на подпись от имени родителя
+	Propose bool
+	// Начало нового периода; по умолчанию - окончание текущего контракта
+	StartDate time.Time
+}
+
+// NextPeriod возвращает сроки следующего периода той же длительности
+func NextPeriod(contract *models.Contract, start time.Time) (time.Time, time.Time) {
+	if start.IsZero() {
+		start = contract.EndDate
+	}
+	return start, start.Add(contract.EndDate.Sub(contract.StartDate))
+}
+
+// RenewContract создает контракт на следующий период с теми же задачами,
+// повторяющимися задачами и наградами и связывает его с предыдущим.
+// Сроки задач и наград сдвигаются на разницу между началами периодов.
+// actorID пуст, если продление выполняет планировщик.
+func RenewContract(tx *gorm.DB, contractID, actorID, ipAddress string, opts RenewOptions) (*models.Contract, error) {
+	var contract models.Contract
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&contract, "id = ?", contractID).Error; err != nil {
+		return nil, err
+	}
+	if contract.SuccessorID != nil {
+		return nil, ErrAlreadyRenewed
+	}
+	if contract.Status != models.ContractActive && contract.Status != models.ContractCompleted {
+		return nil, ErrNotRenewable
+	}
+
+	start, end := NextPeriod(&contract, opts.StartDate)
+	shift := start.Sub(contract.StartDate)
+	days := daysBetween(Date(contract.StartDate), Date(start))
+	now := time.Now()
+
+	// Автоматически продленный контракт предлагается от имени его автора
+	proposer := actorID
+	if proposer == "" {
+		proposer = contract.ParentID
+	}
+
+	successor := &models.Contract{
+		Title:           contract.Title,
+		Description:     contract.Description,
+		ParentID:        contract.ParentID,
+		ChildID:         contract.ChildID,
+		Status:          models.ContractDraft,
+		StartDate:       start,
+		EndDate:         end,
+		CoSignerID:      contract.CoSignerID,
+		PredecessorID:   &contract.ID,
+		AutoRenew:       contract.AutoRenew,
+		CarryOverPoints: contract.CarryOverPoints,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := tx.Omit("Parent", "Child", "Tasks", "Rewards", "Signatures").Create(successor).Error; err != nil {
+		return nil, err
+	}
+
+	if err := copyTasks(tx, &contract, successor, shift, now); err != nil {
+		return nil, err
+	}
+	series, err := copySeries(tx, &contract, successor, days, proposer, now)
+	if err != nil {
+		return nil, err
+	}
+	rewards, err := copyRewards(tx, &contract, successor, shift, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyRules(tx, &contract, successor, rewards, proposer, now); err != nil {
+		return nil, err
+	}
+	if err := copyStreakBonuses(tx, &contract, successor, series, proposer, now); err != nil {
+		return nil, err
+	}
+
+	contract.SuccessorID = &successor.ID
+	if err := tx.Model(&contract).Updates(map[string]interface{}{
+		"successor_id": successor.ID,
+		"updated_at":   now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if opts.CarryOver {
+		if err := CarryOverPoints(tx, &contract, successor, actorID); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Propose {
+		if err := ProposeContract(tx, successor, proposer, ipAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	return successor, nil
+}
+
+// CarryOverPoints переносит непотраченные баллы ребенка со старого контракта
+// на новый парой записей журнала. Незавершенные запросы наград переходят
+// на новый контракт вместе с баллами: если запрос отклонят или отменят,
+// зарезервированные баллы вернутся уже на новый контракт.
+func CarryOverPoints(tx *gorm.DB, from, to *models.Contract, actorID string) error {
+	// Отложенные на цели баллы тоже переносятся, цели старого контракта закрываются
+	if err := CancelContractGoals(tx, from.ID, actorID, "Баллы перенесены в новый контракт"); err != nil {
+		return err
+	}
+	if err := LockBalance(tx, from.ChildID, from.ID); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RewardClaim{}).
+		Where("contract_id = ? AND status IN ?", from.ID, []string{models.ClaimPending, models.ClaimApproved}).
+		Updates(map[string]interface{}{
+			"contract_id": to.ID,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+	balance, err := Balance(tx, from.ChildID, from.ID)
+	if err != nil || balance <= 0 {
+		return err
+	}
+
+	var createdBy *string
+	if actorID != "" {
+		createdBy = &actorID
+	}
+	if err := Post(tx, &models.LedgerEntry{
+		ChildID:     from.ChildID,
+		ContractID:  from.ID,
+		Type:        models.LedgerCarryOver,
+		Amount:      -balance,
+		Description: fmt.Sprintf("Перенос баллов в контракт «%s»", to.Title),
+		CreatedBy:   createdBy,
+	}); err != nil {
+		return err
+	}
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     to.ChildID,
+		ContractID:  to.ID,
+		Type:        models.LedgerCarryOver,
+		Amount:      balance,
+		Description: fmt.Sprintf("Баллы, перенесенные из контракта «%s»", from.Title),
+		CreatedBy:   createdBy,
+	})
+}
+
+// copyTasks копирует разовые задачи вместе с чек-листами, без отметок о выполнении
+func copyTasks(tx *gorm.DB, from, to *models.Contract, shift time.Duration, now time.Time) error {
+	var tasks []models.Task
+	if err := tx.Where("contract_id = ? AND series_id IS NULL", from.ID).
+		Order("due_date").
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	checklists, err := LoadChecklists(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		copied := models.Task{
+			Title:         task.Title,
+			Description:   task.Description,
+			ContractID:    to.ID,
+			Status:        "pending",
+			DueDate:       task.DueDate.Add(shift),
+			Points:        task.Points,
+			PartialCredit: task.PartialCredit,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := tx.Omit("Contract").Create(&copied).Error; err != nil {
+			return err
+		}
+		for _, item := range checklists[task.ID] {
+			copiedItem := models.TaskChecklistItem{
+				TaskID:    copied.ID,
+				Title:     item.Title,
+				Position:  item.Position,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := tx.Create(&copiedItem).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copySeries копирует повторяющиеся задачи и создает их первые экземпляры.
+// Серии сдвигаются на целое число дней, чтобы сохранить дни недели и месяца.
+// Возвращает соответствие старых серий новым.
+func copySeries(tx *gorm.DB, from, to *models.Contract, days int, createdBy string, now time.Time) (map[string]string, error) {
+	var series []models.TaskSeries
+	if err := tx.Where("contract_id = ?", from.ID).Order("created_at").Find(&series).Error; err != nil {
+		return nil, err
+	}
+
+	copies := make(map[string]string, len(series))
+	for _, item := range series {
+		copied := models.TaskSeries{
+			ContractID:  to.ID,
+			Title:       item.Title,
+			Description: item.Description,
+			Points:      item.Points,
+			Rule:        item.Rule,
+			DueTime:     item.DueTime,
+			Timezone:    item.Timezone,
+			StartsOn:    Date(item.StartsOn).AddDate(0, 0, days),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if createdBy != "" {
+			copied.CreatedBy = &createdBy
+		}
+		if item.EndsOn != nil {
+			endsOn := Date(*item.EndsOn).AddDate(0, 0, days)
+			copied.EndsOn = &endsOn
+		}
+		if err := tx.Omit("Contract").Create(&copied).Error; err != nil {
+			return nil, err
+		}
+		if _, err := GenerateSeries(tx, &copied, to, now.Add(SeriesHorizon)); err != nil {
+			return nil, err
+		}
+		copies[item.ID] = copied.ID
+	}
+	return copies, nil
+}
+
+// copyRewards копирует награды; все они снова становятся доступными.
+// Возвращает соответствие старых наград новым.
+func copyRewards(tx *gorm.DB, from, to *models.Contract, shift time.Duration, now time.Time) (map[string]string, error) {
+	var rewards []models.Reward
+	if err := tx.Where("contract_id = ?", from.ID).Order("created_at").Find(&rewards).Error; err != nil {
+		return nil, err
+	}
+
+	copies := make(map[string]string, len(rewards))
+	for _, reward := range rewards {
+		copied := models.Reward{
+			Title:       reward.Title,
+			Description: reward.Description,
+			ContractID:  to.ID,
+			Status:      "available",
+			PointsCost:  reward.PointsCost,
+			// Запас и лимиты отсчитываются заново в новом периоде
+			Repeatable:      reward.Repeatable,
+			Stock:           reward.Stock,
+			PeriodLimit:     reward.PeriodLimit,
+			LimitPeriod:     reward.LimitPeriod,
+			CooldownMinutes: reward.CooldownMinutes,
+			Timezone:        reward.Timezone,
+			CatalogRewardID: reward.CatalogRewardID,
+			CustomPoints:    reward.CustomPoints,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if reward.ExpiryDate != nil {
+			expiry := reward.ExpiryDate.Add(shift)
+			copied.ExpiryDate = &expiry
+		}
+		if err := tx.Omit("Contract").Create(&copied).Error; err != nil {
+			return nil, err
+		}
+		copies[reward.ID] = copied.ID
+	}
+	return copies, nil
+}
+
+// copyRules копирует правила контракта; правила открытия награды
+// открывают ее копию. Срабатывания отсчитываются заново.
+func copyRules(tx *gorm.DB, from, to *models.Contract, rewards map[string]string, createdBy string, now time.Time) error {
+	var rules []models.ContractRule
+	if err := tx.Where("contract_id = ?", from.ID).Order("created_at").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		copied := models.ContractRule{
+			ContractID: to.ID,
+			Name:       rule.Name,
+			Trigger:    rule.Trigger,
+			Scope:      rule.Scope,
+			Conditions: rule.Conditions,
+			Action:     rule.Action,
+			Points:     rule.Points,
+			Timezone:   rule.Timezone,
+			Enabled:    rule.Enabled,
+			CreatedBy:  &createdBy,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if rule.RewardID != nil {
+			rewardID, ok := rewards[*rule.RewardID]
+			if !ok {
+				continue
+			}
+			copied.RewardID = &rewardID
+		}
+		if err := tx.Omit("Contract").Create(&copied).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyStreakBonuses копирует бонусы за серии; бонусы повторяющейся задачи
+// относятся к ее копии. Сами серии в новом контракте начинаются заново.
+func copyStreakBonuses(tx *gorm.DB, from, to *models.Contract, series map[string]string, createdBy string, now time.Time) error {
+	var bonuses []models.StreakBonus
+	if err := tx.Where("contract_id = ?", from.ID).Order("created_at").Find(&bonuses).Error; err != nil {
+		return err
+	}
+
+	for _, bonus := range bonuses {
+		copied := models.StreakBonus{
+			ContractID:        to.ID,
+			Scope:             bonus.Scope,
+			Kind:              bonus.Kind,
+			Length:            bonus.Length,
+			MultiplierPercent: bonus.MultiplierPercent,
+			Points:            bonus.Points,
+			Timezone:          bonus.Timezone,
+			CreatedBy:         &createdBy,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+		if bonus.SeriesID != nil {
+			seriesID, ok := series[*bonus.SeriesID]
+			if !ok {
+				continue
+			}
+			copied.SeriesID = &seriesID
+		}
+		if err := tx.Omit("Contract").Create(&copied).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRewardNotAvailable возвращается, если награду нельзя запросить
+	ErrRewardNotAvailable = errors.New("награда недоступна")
+	// ErrContractNotActionable возвращается, если условия контракта еще не
+	// подписаны всеми сторонами, например после их изменения
+	ErrContractNotActionable = errors.New("контракт еще не подписан всеми сторонами")
+)
+
+// checkActionable проверяет, что по контракту можно получать награды и
+// распоряжаться баллами
+func checkActionable(db *gorm.DB, contractID string) error {
+	var contract models.Contract
+	if err := db.Select("id", "status").First(&contract, "id = ?", contractID).Error; err != nil {
+		return err
+	}
+	if !contract.Actionable() {
+		return ErrContractNotActionable
+	}
+	return nil
+}
+
+// ClaimReward создает запрос награды от имени ребенка и резервирует ее
+// стоимость. Разовая награда переходит в статус claimed, повторяемая
+// остается доступной в пределах своих ограничений. Награда блокируется
+// до конца транзакции.
+func ClaimReward(tx *gorm.DB, rewardID, childID, note string) (*models.RewardClaim, error) {
+	var reward models.Reward
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&reward, "id = ?", rewardID).Error; err != nil {
+		return nil, err
+	}
+	// Контракт мог вернуться на подпись после изменения условий
+	if err := checkActionable(tx, reward.ContractID); err != nil {
+		return nil, err
+	}
+
+	// Срок награды мог истечь до того, как планировщик сменил ее статус
+	now := time.Now()
+	if reward.ExpiryDate != nil && reward.ExpiryDate.Before(now) {
+		return nil, ErrRewardNotAvailable
+	}
+	locked, err := LockedRewards(tx, []string{reward.ID})
+	if err != nil {
+		return nil, err
+	}
+	if locked[reward.ID] {
+		return nil, ErrRewardLocked
+	}
+
+	if reward.Repeatable {
+		// Повторяемая награда остается доступной. Запросы сериализуются
+		// блокировкой награды, поэтому запас и лимиты не превышаются
+		// даже при одновременных запросах с разных устройств.
+		if reward.Status != models.RewardAvailable {
+			return nil, ErrRewardNotAvailable
+		}
+		usage, err := LoadRewardUsage(tx, &reward, now)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := EvaluateRewardLimits(&reward, usage, now); err != nil {
+			return nil, err
+		}
+	} else {
+		t, err := RewardMachine.Fire(tx, reward.ID, RewardClaim, reward.Status, ActorChild, childID, note)
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, ErrRewardNotAvailable
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := SetStatus(tx, &reward, reward.ID, reward.Status, map[string]interface{}{
+			"status":     t.To,
+			"updated_at": now,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	claim := &models.RewardClaim{
+		RewardID:   reward.ID,
+		ContractID: reward.ContractID,
+		ChildID:    childID,
+		Status:     models.ClaimPending,
+		Points:     reward.PointsCost,
+		Note:       note,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := tx.Omit("Reward").Create(claim).Error; err != nil {
+		return nil, err
+	}
+	if err := SpendReward(tx, &reward, claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// TransitionClaim выполняет действие над запросом награды по правилам
+// ClaimMachine. При отклонении или отмене баллы возвращаются ребенку,
+// а награда снова становится доступной.
+func TransitionClaim(tx *gorm.DB, claimID, action, role, actorID, note string) (*models.RewardClaim, error) {
+	var claim models.RewardClaim
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&claim, "id = ?", claimID).Error; err != nil {
+		return nil, err
+	}
+
+	from := claim.Status
+	t, err := ClaimMachine.Fire(tx, claim.ID, action, from, role, actorID, note)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     t.To,
+		"updated_at": now,
+	}
+	switch action {
+	case ClaimApprove:
+		updates["reviewed_by"] = actorID
+		updates["reviewed_at"] = now
+	case ClaimReject:
+		updates["reviewed_by"] = actorID
+		updates["reviewed_at"] = now
+		updates["rejection_reason"] = note
+	case ClaimFulfil:
+		// Выдача без отдельного одобрения считается и решением по запросу
+		if claim.ReviewedAt == nil {
+			updates["reviewed_by"] = actorID
+			updates["reviewed_at"] = now
+		}
+		updates["fulfilled_by"] = actorID
+		updates["fulfilled_at"] = now
+		updates["fulfilment_note"] = note
+	}
+	if err := SetStatus(tx, &claim, claim.ID, from, updates); err != nil {
+		return nil, err
+	}
+
+	var reward models.Reward
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&reward, "id = ?", claim.RewardID).Error; err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case ClaimReject, ClaimCancel:
+		if err := RefundClaim(tx, &reward, &claim, actorID); err != nil {
+			return nil, err
+		}
+		if err := rewardFollows(tx, &reward, RewardRelease, ActorSystem, actorID, now); err != nil {
+			return nil, err
+		}
+	case ClaimFulfil:
+		if err := rewardFollows(tx, &reward, RewardFulfil, ActorParent, actorID, now); err != nil {
+			return nil, err
+		}
+		if _, err := AwardAchievements(tx, claim.ChildID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &claim, tx.Preload("Reward").First(&claim, "id = ?", claim.ID).Error
+}
+
+// OpenClaim возвращает последний незавершенный запрос награды
+func OpenClaim(db *gorm.DB, rewardID string) (*models.RewardClaim, error) {
+	var claim models.RewardClaim
+	err := db.Where("reward_id = ? AND status IN ?", rewardID, []string{models.ClaimPending, models.ClaimApproved}).
+		Order("created_at DESC").
+		First(&claim).Error
+	return &claim, err
+}
+
+// rewardFollows переводит награду вслед за ее запросом, если награда
+// ожидает решения по запросу
+func rewardFollows(tx *gorm.DB, reward *models.Reward, action, role, actorID string, now time.Time) error {
+	if reward.Status != models.RewardClaimed {
+		return nil
+	}
+	t, err := RewardMachine.Fire(tx, reward.ID, action, reward.Status, role, actorID, "")
+	if err != nil {
+		return err
+	}
+	return SetStatus(tx, reward, reward.ID, reward.Status, map[string]interface{}{
+		"status":     t.To,
+		"updated_at": now,
+	})
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRewardLimits возвращается для противоречивых настроек повторяемой награды
+	ErrInvalidRewardLimits = errors.New("некорректные ограничения награды")
+	// ErrRewardOutOfStock возвращается, если запас награды исчерпан
+	ErrRewardOutOfStock = errors.New("запас награды исчерпан")
+	// ErrRewardLimitReached возвращается, если исчерпан лимит запросов за период
+	ErrRewardLimitReached = errors.New("лимит запросов награды за период исчерпан")
+	// ErrRewardCooldown возвращается, если пауза после прошлого запроса еще не прошла
+	ErrRewardCooldown = errors.New("награду пока нельзя запросить снова")
+)
+
+// usedClaims - запросы, которые расходуют запас и лимиты награды
+var usedClaims = []string{models.ClaimPending, models.ClaimApproved, models.ClaimFulfilled}
+
+// RewardLimits - ограничения повторяемой награды в условиях контракта,
+// шаблонах и запросах на создание награды
+type RewardLimits struct {
+	Repeatable bool `json:"repeatable,omitempty"`
+	// Сколько раз награду можно получить всего; пусто - без ограничений
+	Stock *int `json:"stock,omitempty" binding:"omitempty,min=0"`
+	// Не больше PeriodLimit запросов за календарный LimitPeriod
+	PeriodLimit     int    `json:"period_limit,omitempty" binding:"min=0"`
+	LimitPeriod     string `json:"limit_period,omitempty" binding:"omitempty,oneof=day week month"`
+	CooldownMinutes int    `json:"cooldown_minutes,omitempty" binding:"min=0"`
+	// Часовой пояс, в котором отсчитываются периоды
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// LimitsOf возвращает ограничения награды в каноническом виде
+func LimitsOf(reward *models.Reward) RewardLimits {
+	limits := RewardLimits{
+		Repeatable:      reward.Repeatable,
+		Stock:           reward.Stock,
+		PeriodLimit:     reward.PeriodLimit,
+		LimitPeriod:     reward.LimitPeriod,
+		CooldownMinutes: reward.CooldownMinutes,
+	}
+	// Часовой пояс важен только для отсчета периодов лимита
+	if reward.LimitPeriod != "" {
+		limits.Timezone = reward.Timezone
+	}
+	return limits
+}
+
+// Apply переносит ограничения в награду и проверяет их
+func (l RewardLimits) Apply(reward *models.Reward) error {
+	reward.Repeatable = l.Repeatable
+	reward.Stock = l.Stock
+	reward.PeriodLimit = l.PeriodLimit
+	reward.LimitPeriod = l.LimitPeriod
+	reward.CooldownMinutes = l.CooldownMinutes
+	reward.Timezone = l.Timezone
+	return ValidateRewardLimits(reward)
+}
+
+// RewardUsage - сколько раз награду уже запрашивали
+type RewardUsage struct {
+	Total       int
+	InPeriod    int
+	LastClaimAt *time.Time
+}
+
+// ValidateRewardLimits проверяет настройки повторяемой награды.
+// Ограничения имеют смысл только для повторяемых наград.
+func ValidateRewardLimits(reward *models.Reward) error {
+	if reward.Timezone == "" {
+		reward.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(reward.Timezone); err != nil {
+		return ErrInvalidRewardLimits
+	}
+	if (reward.PeriodLimit > 0) != (reward.LimitPeriod != "") {
+		return ErrInvalidRewardLimits
+	}
+	switch reward.LimitPeriod {
+	case "", models.PeriodDay, models.PeriodWeek, models.PeriodMonth:
+	default:
+		return ErrInvalidRewardLimits
+	}
+	if reward.PeriodLimit < 0 || reward.CooldownMinutes < 0 || (reward.Stock != nil && *reward.Stock < 0) {
+		return ErrInvalidRewardLimits
+	}
+	if !reward.Repeatable && (reward.Stock != nil || reward.PeriodLimit > 0 || reward.CooldownMinutes > 0) {
+		return ErrInvalidRewardLimits
+	}
+	return nil
+}
+
+// PeriodStart возвращает начало календарного периода, в который попадает now
+func PeriodStart(now time.Time, period string, location *time.Location) time.Time {
+	local := now.In(location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	switch period {
+	case models.PeriodWeek:
+		return weekStart(day)
+	case models.PeriodMonth:
+		return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, location)
+	default:
+		return day
+	}
+}
+
+// usageSince возвращает начало текущего периода лимита награды
+func usageSince(reward *models.Reward, now time.Time) (time.Time, error) {
+	if reward.LimitPeriod == "" {
+		return time.Time{}, nil
+	}
+	location, err := time.LoadLocation(reward.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return PeriodStart(now, reward.LimitPeriod, location), nil
+}
+
+// LoadRewardUsage считает запросы награды: всего, в текущем периоде и время последнего
+func LoadRewardUsage(db *gorm.DB, reward *models.Reward, now time.Time) (*RewardUsage, error) {
+	since, err := usageSince(reward, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var row struct {
+		Total    int
+		InPeriod int
+		Last     *time.Time
+	}
+	if err := db.Model(&models.RewardClaim{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE created_at >= ?) AS in_period, MAX(created_at) AS last", since).
+		Where("reward_id = ? AND status IN ?", reward.ID, usedClaims).
+		Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	return &RewardUsage{Total: row.Total, InPeriod: row.InPeriod, LastClaimAt: row.Last}, nil
+}
+
+// EvaluateRewardLimits считает, сколько еще раз можно запросить награду, и
+// возвращает ошибку первого нарушенного ограничения
+func EvaluateRewardLimits(reward *models.Reward, usage *RewardUsage, now time.Time) (*models.RewardAvailability, error) {
+	availability := &models.RewardAvailability{
+		Claimed:       usage.Total,
+		PeriodClaimed: usage.InPeriod,
+	}
+
+	var err error
+	if reward.Stock != nil {
+		remaining := max(*reward.Stock-usage.Total, 0)
+		availability.Remaining = &remaining
+		if remaining == 0 {
+			err = ErrRewardOutOfStock
+		}
+	}
+	if reward.PeriodLimit > 0 {
+		remaining := max(reward.PeriodLimit-usage.InPeriod, 0)
+		availability.PeriodRemaining = &remaining
+		if remaining == 0 && err == nil {
+			err = ErrRewardLimitReached
+		}
+	}
+	if reward.CooldownMinutes > 0 && usage.LastClaimAt != nil {
+		next := usage.LastClaimAt.Add(time.Duration(reward.CooldownMinutes) * time.Minute)
+		if next.After(now) {
+			availability.NextClaimAt = &next
+			if err == nil {
+				err = ErrRewardCooldown
+			}
+		}
+	}
+	return availability, err
+}
+
+// LoadAvailability заполняет остаток запросов у повторяемых наград и
+// отмечает награды, которые еще не открыло правило контракта. Запросы
+// всех наград считаются одним запросом к базе, у каждой награды со своим
+// началом периода.
+func LoadAvailability(db *gorm.DB, rewards []models.Reward, now time.Time) error {
+	ids := make([]string, len(rewards))
+	periods := make([]string, 0, len(rewards))
+	args := make([]interface{}, 0, 2*len(rewards)+1)
+	for i := range rewards {
+		ids[i] = rewards[i].ID
+		if !rewards[i].Repeatable {
+			continue
+		}
+		since, err := usageSince(&rewards[i], now)
+		if err != nil {
+			return err
+		}
+		periods = append(periods, "(?::uuid, ?::timestamptz)")
+		args = append(args, rewards[i].ID, since)
+	}
+
+	if len(periods) > 0 {
+		var rows []struct {
+			RewardID string
+			Total    int
+			InPeriod int
+			Last     *time.Time
+		}
+		args = append(args, usedClaims)
+		if err := db.Raw(`
+			SELECT periods.reward_id,
+				COUNT(reward_claims.id) AS total,
+				COUNT(reward_claims.id) FILTER (WHERE reward_claims.created_at >= periods.since) AS in_period,
+				MAX(reward_claims.created_at) AS last
+			FROM (VALUES `+strings.Join(periods, ", ")+`) AS periods(reward_id, since)
+			LEFT JOIN reward_claims ON reward_claims.reward_id = periods.reward_id
+				AND reward_claims.status IN ?
+			GROUP BY periods.reward_id`, args...).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		usage := make(map[string]*RewardUsage, len(rows))
+		for _, row := range rows {
+			usage[row.RewardID] = &RewardUsage{Total: row.Total, InPeriod: row.InPeriod, LastClaimAt: row.Last}
+		}
+		for i := range rewards {
+			reward := &rewards[i]
+			if !reward.Repeatable {
+				continue
+			}
+			reward.Availability, _ = EvaluateRewardLimits(reward, usage[reward.ID], now)
+		}
+	}
+
+	locked, err := LockedRewards(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range rewards {
+		rewards[i].Locked = locked[rewards[i].ID]
+	}
+	return nil
+}
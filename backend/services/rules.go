@@ -0,0 +1,812 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidContractRule возвращается для правила с неизвестным событием, условием или действием
+	ErrInvalidContractRule = errors.New("некорректное правило контракта")
+	// ErrRewardLocked возвращается, если награду еще не открыло правило контракта
+	ErrRewardLocked = errors.New("награда еще не открыта правилом контракта")
+)
+
+// Показатели, которые можно использовать в условиях правил
+const (
+	FactTaskPoints = "task.points"
+	// Сколько полных суток прошло после срока задачи до ее отправки на
+	// проверку, а у невыполненной задачи - до события; до срока отрицательно
+	FactTaskDaysLate = "task.days_late"
+	// Показатели задач контракта, а для правил с областью-периодом -
+	// задач со сроком в периоде события. Отмененные задачи не учитываются.
+	FactTasksTotal             = "tasks.total"
+	FactTasksCompleted         = "tasks.completed"
+	FactTasksOpen              = "tasks.open"
+	FactTasksFailed            = "tasks.failed"
+	FactTasksLate              = "tasks.late"
+	FactTasksCompletionPercent = "tasks.completion_percent"
+	FactTasksPointsEarned      = "tasks.points_earned"
+	// День недели (1 - понедельник, 7 - воскресенье) и час события
+	FactEventWeekday = "event.weekday"
+	FactEventHour    = "event.hour"
+)
+
+// ruleFacts - все известные показатели
+var ruleFacts = []string{
+	FactTaskPoints, FactTaskDaysLate,
+	FactTasksTotal, FactTasksCompleted, FactTasksOpen, FactTasksFailed, FactTasksLate,
+	FactTasksCompletionPercent, FactTasksPointsEarned,
+	FactEventWeekday, FactEventHour,
+}
+
+// Статус задачи, при переходе в который срабатывает правило
+var triggerStatuses = map[string]string{
+	models.RuleTaskSubmitted: models.TaskSubmitted,
+	models.RuleTaskCompleted: models.TaskCompleted,
+	models.RuleTaskFailed:    models.TaskFailed,
+}
+
+// Предел дней просрочки, которые проверяет пробный прогон
+const maxOverdueDays = 366
+
+// RuleCondition - условие правила: показатель Field сравнивается с Value
+type RuleCondition struct {
+	Field string `json:"field" binding:"required"`
+	Op    string `json:"op" binding:"required,oneof=eq ne gt gte lt lte"`
+	Value int    `json:"value"`
+}
+
+// TaskSnapshot - состояние задачи, по которому считаются показатели правил
+type TaskSnapshot struct {
+	ID      string
+	Status  string
+	DueDate time.Time
+	Points  int
+	// Когда задачу отправили на проверку или засчитали без отправки
+	DoneAt *time.Time
+	// Повторяющаяся задача, экземпляром которой является задача
+	SeriesID *string
+}
+
+// RuleEvent - событие, на котором проверяется правило
+type RuleEvent struct {
+	Trigger string
+	At      time.Time
+	// Задача события; у итогов периода не заполняется
+	Task *TaskSnapshot
+}
+
+// RuleOutcome - срабатывание правила при пробном прогоне
+type RuleOutcome struct {
+	At       time.Time `json:"at"`
+	Trigger  string    `json:"trigger"`
+	Key      string    `json:"key"`
+	TaskID   *string   `json:"task_id,omitempty"`
+	Action   string    `json:"action"`
+	Points   int       `json:"points"`
+	RewardID *string   `json:"reward_id,omitempty"`
+}
+
+// DryRunResult - что правило сделало бы за историю контракта
+type DryRunResult struct {
+	Outcomes []RuleOutcome `json:"outcomes"`
+	// Итог по баллам; штрафы здесь не ограничиваются балансом ребенка
+	Points int `json:"points"`
+}
+
+// StatusChange - смена статуса задачи из истории переходов
+type StatusChange struct {
+	At     time.Time
+	Status string
+}
+
+// TaskHistory - задача с историей статусов для пробного прогона правил
+type TaskHistory struct {
+	ID        string
+	DueDate   time.Time
+	Points    int
+	CreatedAt time.Time
+	Changes   []StatusChange
+}
+
+// compiledRule - правило с разобранными условиями и часовым поясом
+type compiledRule struct {
+	*models.ContractRule
+	conditions []RuleCondition
+	location   *time.Location
+}
+
+// ParseConditions разбирает условия правила
+func ParseConditions(data models.JSON) ([]RuleCondition, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var conditions []RuleCondition
+	if err := json.Unmarshal(data, &conditions); err != nil {
+		return nil, ErrInvalidContractRule
+	}
+	return conditions, nil
+}
+
+// ValidateRule проверяет правило и его условия и заполняет часовой пояс
+// по умолчанию
+func ValidateRule(rule *models.ContractRule, conditions []RuleCondition) error {
+	if rule.Timezone == "" {
+		rule.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(rule.Timezone); err != nil {
+		return ErrInvalidContractRule
+	}
+
+	switch rule.Trigger {
+	case models.RuleTaskSubmitted, models.RuleTaskCompleted, models.RuleTaskFailed, models.RuleTaskOverdue, models.RuleSchedule:
+	default:
+		return ErrInvalidContractRule
+	}
+	switch rule.Scope {
+	case models.RuleScopeTask, models.RuleScopeContract, models.PeriodDay, models.PeriodWeek, models.PeriodMonth:
+	default:
+		return ErrInvalidContractRule
+	}
+	// Итоги периода не относятся к одной задаче
+	if rule.Trigger == models.RuleSchedule && rule.Scope == models.RuleScopeTask {
+		return ErrInvalidContractRule
+	}
+
+	switch rule.Action {
+	case models.RuleAward, models.RuleDeduct:
+		if rule.Points <= 0 || rule.RewardID != nil {
+			return ErrInvalidContractRule
+		}
+	case models.RuleUnlockReward:
+		if rule.Points != 0 || rule.RewardID == nil {
+			return ErrInvalidContractRule
+		}
+	default:
+		return ErrInvalidContractRule
+	}
+
+	for _, condition := range conditions {
+		if !knownFact(condition.Field) {
+			return ErrInvalidContractRule
+		}
+		// У итогов периода нет задачи и момента события
+		if rule.Trigger == models.RuleSchedule && !strings.HasPrefix(condition.Field, "tasks.") {
+			return ErrInvalidContractRule
+		}
+		switch condition.Op {
+		case "eq", "ne", "gt", "gte", "lt", "lte":
+		default:
+			return ErrInvalidContractRule
+		}
+	}
+	return nil
+}
+
+func knownFact(field string) bool {
+	for _, fact := range ruleFacts {
+		if fact == field {
+			return true
+		}
+	}
+	return false
+}
+
+func compileRule(rule *models.ContractRule) (*compiledRule, error) {
+	conditions, err := ParseConditions(rule.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		return nil, ErrInvalidContractRule
+	}
+	return &compiledRule{ContractRule: rule, conditions: conditions, location: location}, nil
+}
+
+// DaysLate возвращает, на сколько полных суток задача просрочена к моменту
+// отправки или, если она еще не выполнена, к моменту at
+func DaysLate(task *TaskSnapshot, at time.Time) int {
+	if task.DoneAt != nil {
+		at = *task.DoneAt
+	}
+	return int(math.Floor(at.Sub(task.DueDate).Hours() / 24))
+}
+
+// RuleFacts считает показатели для условий правила. Учитываются задачи со
+// сроком в [from, to); нулевые границы означают весь контракт.
+func RuleFacts(event RuleEvent, tasks []TaskSnapshot, from, to time.Time, location *time.Location) map[string]int {
+	facts := map[string]int{
+		FactTasksTotal:             0,
+		FactTasksCompleted:         0,
+		FactTasksOpen:              0,
+		FactTasksFailed:            0,
+		FactTasksLate:              0,
+		FactTasksCompletionPercent: 0,
+		FactTasksPointsEarned:      0,
+	}
+
+	if event.Task != nil {
+		facts[FactTaskPoints] = event.Task.Points
+		facts[FactTaskDaysLate] = DaysLate(event.Task, event.At)
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status == models.TaskCancelled {
+			continue
+		}
+		if !from.IsZero() && task.DueDate.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !task.DueDate.Before(to) {
+			continue
+		}
+
+		facts[FactTasksTotal]++
+		switch task.Status {
+		case models.TaskCompleted:
+			facts[FactTasksCompleted]++
+			facts[FactTasksPointsEarned] += task.Points
+			if task.DoneAt != nil && task.DoneAt.After(task.DueDate) {
+				facts[FactTasksLate]++
+			}
+		case models.TaskFailed:
+			facts[FactTasksFailed]++
+		default:
+			facts[FactTasksOpen]++
+		}
+	}
+	if facts[FactTasksTotal] > 0 {
+		facts[FactTasksCompletionPercent] = facts[FactTasksCompleted] * 100 / facts[FactTasksTotal]
+	}
+
+	local := event.At.In(location)
+	weekday := int(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	facts[FactEventWeekday] = weekday
+	facts[FactEventHour] = local.Hour()
+	return facts
+}
+
+// MatchConditions проверяет, что выполнены все условия. Показатель,
+// которого нет у события (например, задачи у итогов периода), условию
+// не удовлетворяет.
+func MatchConditions(conditions []RuleCondition, facts map[string]int) (bool, error) {
+	for _, condition := range conditions {
+		value, ok := facts[condition.Field]
+		if !ok {
+			if !knownFact(condition.Field) {
+				return false, ErrInvalidContractRule
+			}
+			return false, nil
+		}
+
+		var matched bool
+		switch condition.Op {
+		case "eq":
+			matched = value == condition.Value
+		case "ne":
+			matched = value != condition.Value
+		case "gt":
+			matched = value > condition.Value
+		case "gte":
+			matched = value >= condition.Value
+		case "lt":
+			matched = value < condition.Value
+		case "lte":
+			matched = value <= condition.Value
+		default:
+			return false, ErrInvalidContractRule
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// periodEnd возвращает начало следующего периода
+func periodEnd(start time.Time, period string) time.Time {
+	switch period {
+	case models.PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case models.PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// window возвращает задачи, по которым считаются показатели события, и
+// ключ срабатывания: правило срабатывает не больше раза на задачу, период
+// или контракт
+func (r *compiledRule) window(event RuleEvent) (from, to time.Time, key string) {
+	switch r.Scope {
+	case models.RuleScopeTask:
+		return from, to, "task:" + event.Task.ID
+	case models.RuleScopeContract:
+		return from, to, models.RuleScopeContract
+	}
+	from = PeriodStart(event.At, r.Scope, r.location)
+	return from, periodEnd(from, r.Scope), r.Scope + ":" + from.Format("2006-01-02")
+}
+
+// match проверяет правило на событии и возвращает ключ срабатывания
+func (r *compiledRule) match(event RuleEvent, tasks []TaskSnapshot) (string, bool, error) {
+	from, to, key := r.window(event)
+	matched, err := MatchConditions(r.conditions, RuleFacts(event, tasks, from, to, r.location))
+	return key, matched, err
+}
+
+// signedPoints возвращает баллы правила со знаком
+func (r *compiledRule) signedPoints() int {
+	switch r.Action {
+	case models.RuleAward:
+		return r.Points
+	case models.RuleDeduct:
+		return -r.Points
+	}
+	return 0
+}
+
+// TaskTrigger возвращает событие правил для перехода задачи в статус
+// status или пустую строку, если на такой переход правила не срабатывают
+func TaskTrigger(status string) string {
+	for trigger, to := range triggerStatuses {
+		if to == status {
+			return trigger
+		}
+	}
+	return ""
+}
+
+// ApplyTaskRules проверяет включенные правила контракта задачи на событии
+// trigger. actorID пуст, если событие вызвал планировщик.
+func ApplyTaskRules(tx *gorm.DB, taskID, trigger, actorID string, now time.Time) error {
+	var task models.Task
+	if err := tx.Preload("Contract").First(&task, "id = ?", taskID).Error; err != nil {
+		return err
+	}
+	rules, err := loadRules(tx, task.ContractID, trigger)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	tasks, err := loadSnapshots(tx, task.ContractID)
+	if err != nil {
+		return err
+	}
+
+	event := RuleEvent{Trigger: trigger, At: now}
+	for i := range tasks {
+		if tasks[i].ID == task.ID {
+			event.Task = &tasks[i]
+		}
+	}
+	if event.Task == nil {
+		return nil
+	}
+	for _, rule := range rules {
+		if _, err := applyRule(tx, rule, &task.Contract, event, tasks, actorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyScheduledRules проверяет правила действующих контрактов, которые
+// срабатывают по времени: просроченные задачи и итоги закончившихся
+// периодов. Возвращает число срабатываний.
+func ApplyScheduledRules(tx *gorm.DB, now time.Time) (int64, error) {
+	var contractIDs []string
+	if err := tx.Model(&models.ContractRule{}).
+		Joins("JOIN contracts ON contracts.id = contract_rules.contract_id AND contracts.deleted_at IS NULL").
+		Where("contract_rules.enabled AND contract_rules.trigger IN ? AND contracts.status = ?",
+			[]string{models.RuleTaskOverdue, models.RuleSchedule}, models.ContractActive).
+		Distinct().
+		Pluck("contract_rules.contract_id", &contractIDs).Error; err != nil {
+		return 0, err
+	}
+
+	var fired int64
+	for _, contractID := range contractIDs {
+		var contract models.Contract
+		if err := tx.First(&contract, "id = ?", contractID).Error; err != nil {
+			return fired, err
+		}
+		rules, err := loadRules(tx, contract.ID, models.RuleTaskOverdue, models.RuleSchedule)
+		if err != nil {
+			return fired, err
+		}
+		tasks, err := loadSnapshots(tx, contract.ID)
+		if err != nil {
+			return fired, err
+		}
+
+		for _, rule := range rules {
+			for _, event := range scheduledEvents(rule, &contract, tasks, now) {
+				ok, err := applyRule(tx, rule, &contract, event, tasks, "")
+				if err != nil {
+					return fired, err
+				}
+				if ok {
+					fired++
+				}
+			}
+		}
+	}
+	return fired, nil
+}
+
+// scheduledEvents возвращает события правила на момент now: каждую
+// просроченную задачу в работе или последний закончившийся период. Период,
+// закончившийся до создания правила, не проверяется.
+func scheduledEvents(rule *compiledRule, contract *models.Contract, tasks []TaskSnapshot, now time.Time) []RuleEvent {
+	var events []RuleEvent
+	switch {
+	case rule.Trigger == models.RuleTaskOverdue:
+		for i := range tasks {
+			if tasks[i].Status == models.TaskPending && tasks[i].DueDate.Before(now) {
+				events = append(events, RuleEvent{Trigger: rule.Trigger, At: now, Task: &tasks[i]})
+			}
+		}
+	case rule.Scope != models.RuleScopeContract:
+		end := PeriodStart(now, rule.Scope, rule.location)
+		if end.After(rule.CreatedAt) && end.After(contract.StartDate) && !end.After(contract.EndDate) {
+			events = append(events, RuleEvent{Trigger: rule.Trigger, At: end.Add(-time.Nanosecond)})
+		}
+	}
+	return events
+}
+
+// ApplyClosingRules подводит итоги завершающегося контракта: проверяет
+// правила итогов последнего периода и всего контракта
+func ApplyClosingRules(tx *gorm.DB, contract *models.Contract, actorID string) error {
+	rules, err := loadRules(tx, contract.ID, models.RuleSchedule)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	tasks, err := loadSnapshots(tx, contract.ID)
+	if err != nil {
+		return err
+	}
+
+	event := RuleEvent{Trigger: models.RuleSchedule, At: closingAt(contract)}
+	for _, rule := range rules {
+		if _, err := applyRule(tx, rule, contract, event, tasks, actorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closingAt - момент подведения итогов контракта: последний момент его срока
+func closingAt(contract *models.Contract) time.Time {
+	return contract.EndDate.Add(-time.Nanosecond)
+}
+
+// applyRule проверяет правило на событии и, если условия выполнены и
+// правило еще не срабатывало по этой задаче или за этот период, выполняет
+// его действие. Штраф не опускает баланс ниже нуля.
+func applyRule(tx *gorm.DB, rule *compiledRule, contract *models.Contract, event RuleEvent, tasks []TaskSnapshot, actorID string) (bool, error) {
+	key, matched, err := rule.match(event, tasks)
+	if err != nil || !matched {
+		return false, err
+	}
+
+	firing := models.RuleFiring{
+		RuleID:     rule.ID,
+		ContractID: contract.ID,
+		ChildID:    contract.ChildID,
+		Key:        key,
+		Trigger:    event.Trigger,
+		Action:     rule.Action,
+		Points:     rule.signedPoints(),
+		RewardID:   rule.RewardID,
+		FiredAt:    event.At,
+	}
+	if event.Task != nil {
+		firing.TaskID = &event.Task.ID
+	}
+
+	if firing.Points != 0 {
+		if err := LockBalance(tx, contract.ChildID, contract.ID); err != nil {
+			return false, err
+		}
+	}
+	if firing.Points < 0 {
+		balance, err := Balance(tx, contract.ChildID, contract.ID)
+		if err != nil {
+			return false, err
+		}
+		firing.Points = -min(-firing.Points, max(balance, 0))
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&firing)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	// Награда открывается самим срабатыванием, баллы не движутся
+	if firing.Points == 0 {
+		return true, nil
+	}
+
+	entry := &models.LedgerEntry{
+		ChildID:    contract.ChildID,
+		ContractID: contract.ID,
+		Amount:     firing.Points,
+		TaskID:     firing.TaskID,
+		RuleID:     &rule.ID,
+	}
+	if actorID != "" {
+		entry.CreatedBy = &actorID
+	}
+	if firing.Points > 0 {
+		entry.Type = models.LedgerBonus
+		entry.Description = fmt.Sprintf("Бонус по правилу «%s»", rule.Name)
+	} else {
+		entry.Type = models.LedgerPenalty
+		entry.Description = fmt.Sprintf("Штраф по правилу «%s»", rule.Name)
+	}
+	return true, Post(tx, entry)
+}
+
+// loadRules загружает включенные правила контракта для событий triggers
+func loadRules(tx *gorm.DB, contractID string, triggers ...string) ([]*compiledRule, error) {
+	var rules []models.ContractRule
+	if err := tx.Where("contract_id = ? AND enabled AND trigger IN ?", contractID, triggers).
+		Order("created_at").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*compiledRule, 0, len(rules))
+	for i := range rules {
+		rule, err := compileRule(&rules[i])
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// loadSnapshots загружает текущее состояние задач контракта
+func loadSnapshots(tx *gorm.DB, contractID string) ([]TaskSnapshot, error) {
+	var tasks []models.Task
+	if err := tx.Where("contract_id = ?", contractID).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]TaskSnapshot, len(tasks))
+	for i, task := range tasks {
+		snapshots[i] = TaskSnapshot{
+			ID:       task.ID,
+			Status:   task.Status,
+			DueDate:  task.DueDate,
+			Points:   task.Points,
+			SeriesID: task.SeriesID,
+		}
+		switch task.Status {
+		case models.TaskSubmitted:
+			snapshots[i].DoneAt = task.SubmittedAt
+		case models.TaskCompleted:
+			snapshots[i].DoneAt = task.SubmittedAt
+			if snapshots[i].DoneAt == nil {
+				snapshots[i].DoneAt = task.ReviewedAt
+			}
+		}
+	}
+	return snapshots, nil
+}
+
+// LockedRewards возвращает награды из списка, которые открывает включенное
+// правило, еще не сработавшее ни разу
+func LockedRewards(db *gorm.DB, rewardIDs []string) (map[string]bool, error) {
+	locked := make(map[string]bool)
+	if len(rewardIDs) == 0 {
+		return locked, nil
+	}
+
+	var ids []string
+	if err := db.Model(&models.ContractRule{}).
+		Where("contract_rules.action = ? AND contract_rules.enabled AND contract_rules.reward_id IN ?", models.RuleUnlockReward, rewardIDs).
+		Where("NOT EXISTS (SELECT 1 FROM rule_firings WHERE rule_firings.reward_id = contract_rules.reward_id)").
+		Distinct().
+		Pluck("contract_rules.reward_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		locked[id] = true
+	}
+	return locked, nil
+}
+
+// LoadTaskHistory загружает задачи контракта с историей смены их статусов
+func LoadTaskHistory(db *gorm.DB, contractID string) ([]TaskHistory, error) {
+	var tasks []models.Task
+	if err := db.Where("contract_id = ?", contractID).Order("created_at").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	var transitions []models.StateTransition
+	if err := db.Where("entity_type = ? AND entity_id IN ?", EntityTask, ids).
+		Order("created_at").
+		Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	changes := make(map[string][]StatusChange)
+	for _, t := range transitions {
+		changes[t.EntityID] = append(changes[t.EntityID], StatusChange{At: t.CreatedAt, Status: t.ToStatus})
+	}
+
+	history := make([]TaskHistory, len(tasks))
+	for i, task := range tasks {
+		history[i] = TaskHistory{
+			ID:        task.ID,
+			DueDate:   task.DueDate,
+			Points:    task.Points,
+			CreatedAt: task.CreatedAt,
+			Changes:   changes[task.ID],
+		}
+	}
+	return history, nil
+}
+
+// SnapshotAt восстанавливает состояние задач на момент at. Задачи,
+// созданные позже, не учитываются.
+func SnapshotAt(history []TaskHistory, at time.Time) []TaskSnapshot {
+	snapshots := make([]TaskSnapshot, 0, len(history))
+	for _, task := range history {
+		if task.CreatedAt.After(at) {
+			continue
+		}
+
+		snapshot := TaskSnapshot{ID: task.ID, Status: models.TaskPending, DueDate: task.DueDate, Points: task.Points}
+		for _, change := range task.Changes {
+			if change.At.After(at) {
+				break
+			}
+			switch change.Status {
+			case models.TaskSubmitted:
+				doneAt := change.At
+				snapshot.DoneAt = &doneAt
+			case models.TaskCompleted:
+				// Подтвержденная задача выполнена в момент отправки
+				if snapshot.Status != models.TaskSubmitted {
+					doneAt := change.At
+					snapshot.DoneAt = &doneAt
+				}
+			default:
+				snapshot.DoneAt = nil
+			}
+			snapshot.Status = change.Status
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// DryRunRule показывает, что правило сделало бы за историю контракта до
+// момента now: на каждом событии задач, на каждой проверке просроченных
+// задач планировщиком (раз в сутки после срока) и при подведении итогов
+// периодов и контракта. Правило проверяется на состоянии задач в момент
+// события; сроки и баллы задач берутся текущие.
+func DryRunRule(rule *models.ContractRule, contract *models.Contract, history []TaskHistory, now time.Time) (*DryRunResult, error) {
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	until := now
+	if contract.EndDate.Before(until) {
+		until = contract.EndDate
+	}
+
+	type timed struct {
+		event RuleEvent
+		// Задача события, состояние которой берется из снимка
+		taskID string
+	}
+	var events []timed
+	switch rule.Trigger {
+	case models.RuleTaskSubmitted, models.RuleTaskCompleted, models.RuleTaskFailed:
+		for _, task := range history {
+			for _, change := range task.Changes {
+				if change.Status == triggerStatuses[rule.Trigger] && !change.At.After(now) {
+					events = append(events, timed{RuleEvent{Trigger: rule.Trigger, At: change.At}, task.ID})
+				}
+			}
+		}
+	case models.RuleTaskOverdue:
+		for _, task := range history {
+			for days := 0; days < maxOverdueDays; days++ {
+				at := task.DueDate.Add(time.Duration(days)*24*time.Hour + time.Minute)
+				if at.After(until) {
+					break
+				}
+				events = append(events, timed{RuleEvent{Trigger: rule.Trigger, At: at}, task.ID})
+			}
+		}
+	case models.RuleSchedule:
+		closed := !contract.EndDate.After(now)
+		if compiled.Scope == models.RuleScopeContract {
+			if closed {
+				events = append(events, timed{event: RuleEvent{Trigger: rule.Trigger, At: closingAt(contract)}})
+			}
+			break
+		}
+		for start := PeriodStart(contract.StartDate, compiled.Scope, compiled.location); start.Before(contract.EndDate); start = periodEnd(start, compiled.Scope) {
+			end := periodEnd(start, compiled.Scope)
+			switch {
+			case !end.After(until):
+				events = append(events, timed{event: RuleEvent{Trigger: rule.Trigger, At: end.Add(-time.Nanosecond)}})
+			case closed:
+				events = append(events, timed{event: RuleEvent{Trigger: rule.Trigger, At: closingAt(contract)}})
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].event.At.Before(events[j].event.At) })
+
+	result := &DryRunResult{Outcomes: make([]RuleOutcome, 0)}
+	fired := make(map[string]bool)
+	for _, item := range events {
+		event := item.event
+		tasks := SnapshotAt(history, event.At)
+		if item.taskID != "" {
+			for i := range tasks {
+				if tasks[i].ID == item.taskID {
+					event.Task = &tasks[i]
+				}
+			}
+			// Просрочка проверяется только у задач, которые еще в работе
+			if event.Task == nil || (rule.Trigger == models.RuleTaskOverdue && event.Task.Status != models.TaskPending) {
+				continue
+			}
+		}
+
+		key, matched, err := compiled.match(event, tasks)
+		if err != nil {
+			return nil, err
+		}
+		if !matched || fired[key] {
+			continue
+		}
+		fired[key] = true
+
+		outcome := RuleOutcome{
+			At:       event.At,
+			Trigger:  event.Trigger,
+			Key:      key,
+			Action:   rule.Action,
+			Points:   compiled.signedPoints(),
+			RewardID: rule.RewardID,
+		}
+		if event.Task != nil {
+			taskID := event.Task.ID
+			outcome.TaskID = &taskID
+		}
+		result.Outcomes = append(result.Outcomes, outcome)
+		result.Points += outcome.Points
+	}
+	return result, nil
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidGoal возвращается, если цель накопления задана некорректно
+var ErrInvalidGoal = errors.New("некорректная цель накопления")
+
+// Период, по которому оценивается темп заработка ребенка
+const earningWindowDays = 28
+
+// GoalTarget возвращает, сколько баллов нужно накопить. Для цели-награды
+// это текущая стоимость награды, поэтому награда должна быть загружена.
+func GoalTarget(goal *models.SavingsGoal) int {
+	if goal.Reward != nil {
+		return goal.Reward.PointsCost
+	}
+	if goal.TargetPoints != nil {
+		return *goal.TargetPoints
+	}
+	return 0
+}
+
+// GoalSaved возвращает баллы, отложенные на цель
+func GoalSaved(db *gorm.DB, goalID string) (int, error) {
+	var saved int
+	err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(-SUM(amount), 0)").
+		Where("goal_id = ?", goalID).
+		Scan(&saved).Error
+	return saved, err
+}
+
+// DepositGoal откладывает баллы доступного баланса на цель. Если накоплено
+// достаточно, цель-награда сразу превращается в запрос награды.
+func DepositGoal(tx *gorm.DB, goalID string, amount int, actorID string) (*models.SavingsGoal, error) {
+	goal, err := lockGoal(tx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.Status != models.GoalActive {
+		return nil, ErrInvalidTransition
+	}
+	// Откладывать баллы можно только по подписанным условиям
+	if err := checkActionable(tx, goal.ContractID); err != nil {
+		return nil, err
+	}
+
+	if err := LockBalance(tx, goal.ChildID, goal.ContractID); err != nil {
+		return nil, err
+	}
+	balance, err := Balance(tx, goal.ChildID, goal.ContractID)
+	if err != nil {
+		return nil, err
+	}
+	if balance < amount {
+		return nil, ErrInsufficientPoints
+	}
+	if err := Post(tx, &models.LedgerEntry{
+		ChildID:     goal.ChildID,
+		ContractID:  goal.ContractID,
+		Type:        models.LedgerLock,
+		Amount:      -amount,
+		GoalID:      &goal.ID,
+		Description: fmt.Sprintf("Отложено на цель «%s»", goal.Title),
+		CreatedBy:   &actorID,
+	}); err != nil {
+		return nil, err
+	}
+
+	saved, err := GoalSaved(tx, goal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if saved >= GoalTarget(goal) {
+		if err := reachGoal(tx, goal, actorID); err != nil {
+			return nil, err
+		}
+		if _, err := AwardAchievements(tx, goal.ChildID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return reloadGoal(tx, goal.ID)
+}
+
+// WithdrawGoal возвращает часть отложенных баллов в доступный баланс
+func WithdrawGoal(tx *gorm.DB, goalID string, amount int, actorID string) (*models.SavingsGoal, error) {
+	goal, err := lockGoal(tx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.Status != models.GoalActive {
+		return nil, ErrInvalidTransition
+	}
+
+	saved, err := GoalSaved(tx, goal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if saved < amount {
+		return nil, ErrInsufficientPoints
+	}
+	if err := releaseGoal(tx, goal, amount, actorID); err != nil {
+		return nil, err
+	}
+	return reloadGoal(tx, goal.ID)
+}
+
+// ClaimGoal запрашивает награду достигнутой цели, если при пополнении
+// это не удалось сделать автоматически
+func ClaimGoal(tx *gorm.DB, goalID, role, actorID string) (*models.SavingsGoal, error) {
+	goal, err := lockGoal(tx, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if goal.RewardID == nil {
+		return nil, ErrInvalidGoal
+	}
+	if err := checkActionable(tx, goal.ContractID); err != nil {
+		return nil, err
+	}
+	saved, err := GoalSaved(tx, goal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if saved < GoalTarget(goal) {
+		return nil, ErrInsufficientPoints
+	}
+	if err := claimGoal(tx, goal, saved, role, actorID); err != nil {
+		return nil, err
+	}
+	return reloadGoal(tx, goal.ID)
+}
+
+// CancelGoal закрывает цель и возвращает все отложенные баллы в баланс
+func CancelGoal(tx *gorm.DB, goalID, role, actorID, reason string) (*models.SavingsGoal, error) {
+	goal, err := lockGoal(tx, goalID)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := GoalMachine.Fire(tx, goal.ID, GoalCancel, goal.Status, role, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := GoalSaved(tx, goal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if saved > 0 {
+		if err := releaseGoal(tx, goal, saved, actorID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if err := SetStatus(tx, goal, goal.ID, goal.Status, map[string]interface{}{
+		"status":     t.To,
+		"closed_at":  now,
+		"updated_at": now,
+	}); err != nil {
+		return nil, err
+	}
+	return reloadGoal(tx, goal.ID)
+}
+
+// CancelContractGoals закрывает открытые цели контракта, например перед
+// переносом баллов в следующий контракт
+func CancelContractGoals(tx *gorm.DB, contractID, actorID, reason string) error {
+	var ids []string
+	if err := tx.Model(&models.SavingsGoal{}).
+		Where("contract_id = ? AND status IN ?", contractID, []string{models.GoalActive, models.GoalReached}).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := CancelGoal(tx, id, ActorSystem, actorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reachGoal отмечает достижение цели. Цель-награда превращается в запрос
+// награды; если награду сейчас запросить нельзя (например, действует пауза
+// между запросами), цель остается достигнутой и баллы остаются отложенными.
+func reachGoal(tx *gorm.DB, goal *models.SavingsGoal, actorID string) error {
+	if goal.RewardID != nil {
+		saved, err := GoalSaved(tx, goal.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return claimGoal(tx, goal, saved, ActorSystem, actorID)
+		})
+		if err == nil {
+			return nil
+		}
+		if !isClaimRefusal(err) {
+			return err
+		}
+	}
+
+	t, err := GoalMachine.Fire(tx, goal.ID, GoalReach, goal.Status, ActorSystem, actorID, "")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return SetStatus(tx, goal, goal.ID, goal.Status, map[string]interface{}{
+		"status":     t.To,
+		"reached_at": now,
+		"updated_at": now,
+	})
+}
+
+// claimGoal возвращает отложенные баллы в баланс и сразу тратит их на
+// запрос награды цели
+func claimGoal(tx *gorm.DB, goal *models.SavingsGoal, saved int, role, actorID string) error {
+	t, err := GoalMachine.Fire(tx, goal.ID, GoalClaim, goal.Status, role, actorID, "")
+	if err != nil {
+		return err
+	}
+	if err := releaseGoal(tx, goal, saved, actorID); err != nil {
+		return err
+	}
+	claim, err := ClaimReward(tx, *goal.RewardID, goal.ChildID, fmt.Sprintf("Накоплено на цель «%s»", goal.Title))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     t.To,
+		"claim_id":   claim.ID,
+		"closed_at":  now,
+		"updated_at": now,
+	}
+	if goal.ReachedAt == nil {
+		updates["reached_at"] = now
+	}
+	return SetStatus(tx, goal, goal.ID, goal.Status, updates)
+}
+
+// isClaimRefusal сообщает, что награду нельзя запросить по правилам,
+// а не из-за сбоя
+func isClaimRefusal(err error) bool {
+	return errors.Is(err, ErrRewardNotAvailable) ||
+		errors.Is(err, ErrRewardOutOfStock) ||
+		errors.Is(err, ErrRewardLimitReached) ||
+		errors.Is(err, ErrRewardCooldown) ||
+		errors.Is(err, ErrRewardLocked) ||
+		errors.Is(err, ErrInsufficientPoints)
+}
+
+func releaseGoal(tx *gorm.DB, goal *models.SavingsGoal, amount int, actorID string) error {
+	if err := LockBalance(tx, goal.ChildID, goal.ContractID); err != nil {
+		return err
+	}
+	var createdBy *string
+	if actorID != "" {
+		createdBy = &actorID
+	}
+	return Post(tx, &models.LedgerEntry{
+		ChildID:     goal.ChildID,
+		ContractID:  goal.ContractID,
+		Type:        models.LedgerRelease,
+		Amount:      amount,
+		GoalID:      &goal.ID,
+		Description: fmt.Sprintf("Возврат баллов с цели «%s»", goal.Title),
+		CreatedBy:   createdBy,
+	})
+}
+
+func lockGoal(tx *gorm.DB, goalID string) (*models.SavingsGoal, error) {
+	var goal models.SavingsGoal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&goal, "id = ?", goalID).Error; err != nil {
+		return nil, err
+	}
+	if goal.RewardID != nil {
+		var reward models.Reward
+		if err := tx.First(&reward, "id = ?", *goal.RewardID).Error; err != nil {
+			return nil, err
+		}
+		goal.Reward = &reward
+	}
+	return &goal, nil
+}
+
+func reloadGoal(tx *gorm.DB, goalID string) (*models.SavingsGoal, error) {
+	var goal models.SavingsGoal
+	return &goal, tx.Preload("Reward").First(&goal, "id = ?", goalID).Error
+}
+
+// EarningRate возвращает средний заработок ребенка в день по задачам
+// контракта, выполненным за последние earningWindowDays дней
+func EarningRate(db *gorm.DB, contractID string, now time.Time) (float64, error) {
+	var earned int
+	err := db.Model(&models.Task{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("contract_id = ? AND status = ? AND COALESCE(reviewed_at, updated_at) >= ?",
+			contractID, models.TaskCompleted, now.AddDate(0, 0, -earningWindowDays)).
+		Scan(&earned).Error
+	return float64(earned) / earningWindowDays, err
+}
+
+// ProjectGoal считает прогресс цели. Будущий заработок и доступный баланс
+// считаются отложенными на цель, поэтому прогноз - это дата, когда их
+// вместе с уже отложенными баллами хватит на цель.
+func ProjectGoal(target, saved, available int, rate float64, deadline *time.Time, now time.Time) *models.GoalProgress {
+	progress := &models.GoalProgress{
+		Target:    target,
+		Saved:     saved,
+		Percent:   100,
+		Available: available,
+		DailyRate: math.Round(rate*100) / 100,
+	}
+	if target > 0 {
+		progress.Percent = min(max(saved, 0)*100/target, 100)
+	}
+
+	needed := target - saved - max(available, 0)
+	switch {
+	case needed <= 0:
+		progress.ProjectedAt = &now
+	case rate > 0:
+		projected := now.AddDate(0, 0, int(math.Ceil(float64(needed)/rate)))
+		progress.ProjectedAt = &projected
+	}
+
+	if deadline != nil {
+		// Срок включает весь день дедлайна
+		onTrack := progress.ProjectedAt != nil && progress.ProjectedAt.Before(deadline.AddDate(0, 0, 1))
+		progress.OnTrack = &onTrack
+	}
+	return progress
+}
+
+// LoadGoalProgress заполняет прогресс открытых целей. Награда целей должна
+// быть загружена.
+func LoadGoalProgress(db *gorm.DB, goals []models.SavingsGoal, now time.Time) error {
+	for i := range goals {
+		goal := &goals[i]
+		if !goal.Open() {
+			continue
+		}
+
+		saved, err := GoalSaved(db, goal.ID)
+		if err != nil {
+			return err
+		}
+		available, err := Balance(db, goal.ChildID, goal.ContractID)
+		if err != nil {
+			return err
+		}
+		rate, err := EarningRate(db, goal.ContractID, now)
+		if err != nil {
+			return err
+		}
+		goal.Progress = ProjectGoal(GoalTarget(goal), saved, available, rate, goal.Deadline, now)
+	}
+	return nil
+}
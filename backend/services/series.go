@@ -0,0 +1,93 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeriesHorizon - на сколько дней вперед создаются экземпляры повторяющихся задач
+const SeriesHorizon = 14 * 24 * time.Hour
+
+// GenerateSeries создает экземпляры задач серии от текущей даты до horizon,
+// не выходя за рамки серии и сроков контракта. Уже созданные и удаленные
+// повторения не создаются повторно. Возвращает число созданных задач.
+func GenerateSeries(tx *gorm.DB, series *models.TaskSeries, contract *models.Contract, horizon time.Time) (int, error) {
+	rule, err := ParseRule(series.Rule)
+	if err != nil {
+		return 0, err
+	}
+	dueTime, err := ParseTimeOfDay(series.DueTime)
+	if err != nil {
+		return 0, err
+	}
+	location, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return 0, err
+	}
+
+	// Прошедшие даты не создаем, продолжаем с места, где остановились
+	from := Date(time.Now().In(location))
+	if series.GeneratedUntil != nil {
+		if next := Date(*series.GeneratedUntil).AddDate(0, 0, 1); next.After(from) {
+			from = next
+		}
+	}
+	if start := Date(contract.StartDate); start.After(from) {
+		from = start
+	}
+
+	to := Date(horizon)
+	if end := Date(contract.EndDate); end.Before(to) {
+		to = end
+	}
+	if series.EndsOn != nil && Date(*series.EndsOn).Before(to) {
+		to = Date(*series.EndsOn)
+	}
+	if to.Before(from) {
+		return 0, nil
+	}
+
+	created := 0
+	now := time.Now()
+	for _, day := range rule.Occurrences(series.StartsOn, from, to) {
+		occurrence := day
+		task := models.Task{
+			Title:          series.Title,
+			Description:    series.Description,
+			ContractID:     series.ContractID,
+			Status:         "pending",
+			DueDate:        time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location).Add(dueTime),
+			Points:         series.Points,
+			SeriesID:       &series.ID,
+			OccurrenceDate: &occurrence,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		result := tx.Omit("Contract").Clauses(clause.OnConflict{DoNothing: true}).Create(&task)
+		if result.Error != nil {
+			return created, result.Error
+		}
+		created += int(result.RowsAffected)
+	}
+
+	series.GeneratedUntil = &to
+	return created, tx.Model(series).Update("generated_until", to).Error
+}
+
+// ResetSeries удаляет будущие нетронутые экземпляры серии, чтобы после
+// изменения правила или шаблона они были созданы заново. Повторения,
+// измененные или удаленные вручную, а также отправленные на проверку,
+// сохраняются.
+func ResetSeries(tx *gorm.DB, series *models.TaskSeries) error {
+	if err := tx.Unscoped().
+		Where("series_id = ? AND is_exception = FALSE AND status = ? AND due_date > ? AND deleted_at IS NULL",
+			series.ID, "pending", time.Now()).
+		Delete(&models.Task{}).Error; err != nil {
+		return err
+	}
+	series.GeneratedUntil = nil
+	return tx.Model(series).Update("generated_until", nil).Error
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Причины отзыва сессии
+const (
+	RevokeLogout         = "logout"
+	RevokeLogoutAll      = "logout_all"
+	RevokePasswordChange = "password_change"
+	RevokeAccountDeleted = "account_deleted"
+	RevokeTokenReuse     = "refresh_token_reuse"
+	RevokeByUser         = "revoked_by_user"
+)
+
+var (
+	// ErrInvalidRefreshToken - refresh-токен не найден, истек или его сессия отозвана
+	ErrInvalidRefreshToken = errors.New("недействительный refresh-токен")
+	// ErrRefreshTokenReused - повторное использование refresh-токена, сессия отозвана
+	ErrRefreshTokenReused = errors.New("повторное использование refresh-токена")
+)
+
+// SessionMeta - сведения об устройстве, с которого открыта сессия
+type SessionMeta struct {
+	UserAgent  string
+	IPAddress  string
+	DeviceName string
+}
+
+// TokenPair - пара токенов, выдаваемая при входе и обновлении сессии
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	SessionID    string
+}
+
+// IssueSession открывает новую сессию пользователя и выдает пару токенов
+func IssueSession(tx *gorm.DB, user *models.User, scope string, meta SessionMeta) (*TokenPair, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		Scope:      scope,
+		UserAgent:  meta.UserAgent,
+		DeviceName: meta.DeviceName,
+		IPAddress:  meta.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenTTL()),
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return issueTokens(tx, &session, user)
+}
+
+// RefreshSession обменивает refresh-токен на новую пару токенов (ротация).
+// Повторное предъявление уже использованного токена отзывает всю сессию.
+func RefreshSession(db *gorm.DB, refreshToken string, meta SessionMeta) (*TokenPair, *models.User, error) {
+	var pair *TokenPair
+	var user models.User
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(refreshToken)).
+			First(&token).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		var session models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&session, "id = ?", token.SessionID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil {
+			// Токен уже обменивали: вероятно, он украден, отзываем сессию целиком
+			reused = true
+			return revokeSession(tx, &session, RevokeTokenReuse)
+		}
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) || !now.Before(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, "id = ?", session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"last_seen_at": now}
+		if meta.IPAddress != "" {
+			updates["ip_address"] = meta.IPAddress
+		}
+		if meta.UserAgent != "" {
+			updates["user_agent"] = meta.UserAgent
+		}
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokens(tx, &session, &user)
+		return err
+	})
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// SessionActive проверяет, что сессия существует, не истекла и не отозвана
+func SessionActive(db *gorm.DB, sessionID string) bool {
+	var count int64
+	db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// TouchSession обновляет время последней активности сессии не чаще раза в минуту
+func TouchSession(db *gorm.DB, sessionID, ipAddress string) error {
+	now := time.Now()
+	return db.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", sessionID, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   ipAddress,
+		}).Error
+}
+
+// ActiveSessions возвращает действующие сессии пользователя
+func ActiveSessions(db *gorm.DB, userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession отзывает одну сессию пользователя
+func RevokeSession(db *gorm.DB, userID, sessionID, reason string) (bool, error) {
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeUserSessions отзывает все сессии пользователя, кроме exceptSessionID
+func RevokeUserSessions(db *gorm.DB, userID, exceptSessionID, reason string) error {
+	query := db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptSessionID != "" {
+		query = query.Where("id <> ?", exceptSessionID)
+	}
+	return query.Updates(map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"revoked_reason": reason,
+	}).Error
+}
+
+func revokeSession(tx *gorm.DB, session *models.Session, reason string) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return tx.Model(session).Updates(map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"revoked_reason": reason,
+	}).Error
+}
+
+func issueTokens(tx *gorm.DB, session *models.Session, user *models.User) (*TokenPair, error) {
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, err := utils.GenerateToken(user.ID, user.Role, session.Scope, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// Участники переходов между состояниями
+const (
+	ActorParent = "parent"
+	ActorChild  = "child"
+	// Переходы, которые выполняются автоматически: планировщиком или как
+	// следствие другого действия (например, последней подписи)
+	ActorSystem = "system"
+)
+
+// Сущности, история состояний которых сохраняется
+const (
+	EntityContract = "contract"
+	EntityTask     = "task"
+	EntityReward   = "reward"
+	// Запрос награды
+	EntityRewardClaim = "reward_claim"
+	// Желание из списка ребенка
+	EntityWish = "wish"
+	// Цель накопления
+	EntitySavingsGoal = "savings_goal"
+)
+
+// Действия над контрактом
+const (
+	ContractPropose   = "propose"
+	ContractWithdraw  = "withdraw"
+	ContractActivate  = "activate"
+	ContractAmend     = "amend"
+	ContractComplete  = "complete"
+	ContractTerminate = "terminate"
+)
+
+// Действия над задачей
+const (
+	TaskSubmit  = "submit"
+	TaskApprove = "approve"
+	TaskReject  = "reject"
+	TaskFail    = "fail"
+	TaskReopen  = "reopen"
+	TaskCancel  = "cancel"
+)
+
+// Действия над наградой
+const (
+	RewardClaim  = "claim"
+	RewardFulfil = "fulfil"
+	RewardExpire = "expire"
+	// Награда снова доступна после отклонения или отмены запроса
+	RewardRelease = "release"
+)
+
+// Действия над запросом награды
+const (
+	ClaimApprove = "approve"
+	ClaimReject  = "reject"
+	ClaimCancel  = "cancel"
+	ClaimFulfil  = "fulfil"
+)
+
+// Действия над желанием
+const (
+	WishPrice    = "price"
+	WishAccept   = "accept"
+	WishReject   = "reject"
+	WishWithdraw = "withdraw"
+)
+
+// Действия над целью накопления
+const (
+	GoalReach  = "reach"
+	GoalClaim  = "claim"
+	GoalCancel = "cancel"
+)
+
+var (
+	// ErrInvalidTransition возвращается, если действие недопустимо в текущем состоянии
+	ErrInvalidTransition = errors.New("недопустимый переход между состояниями")
+	// ErrTransitionForbidden возвращается, если действие недоступно роли пользователя
+	ErrTransitionForbidden = errors.New("переход недоступен для этой роли")
+)
+
+// Transition - допустимый переход: действие, исходные состояния,
+// итоговое состояние и роли, которым оно доступно
+type Transition struct {
+	Action string
+	From   []string
+	To     string
+	Roles  []string
+}
+
+// StateMachine описывает жизненный цикл сущности
+type StateMachine struct {
+	Entity      string
+	Transitions []Transition
+}
+
+// ContractMachine - жизненный цикл контракта. Ребенок участвует в нем только
+// подписью: вступление в силу выполняется системой после последней подписи.
+var ContractMachine = &StateMachine{
+	Entity: EntityContract,
+	Transitions: []Transition{
+		{Action: ContractPropose, From: []string{models.ContractDraft}, To: models.ContractPendingSignature, Roles: []string{ActorParent}},
+		{Action: ContractWithdraw, From: []string{models.ContractPendingSignature}, To: models.ContractDraft, Roles: []string{ActorParent}},
+		{Action: ContractActivate, From: []string{models.ContractPendingSignature}, To: models.ContractActive, Roles: []string{ActorSystem}},
+		{Action: ContractAmend, From: []string{models.ContractPendingSignature, models.ContractActive}, To: models.ContractPendingSignature, Roles: []string{ActorSystem}},
+		{Action: ContractComplete, From: []string{models.ContractActive}, To: models.ContractCompleted, Roles: []string{ActorParent, ActorSystem}},
+		{Action: ContractTerminate, From: []string{models.ContractPendingSignature, models.ContractActive}, To: models.ContractTerminated, Roles: []string{ActorParent}},
+	},
+}
+
+// TaskMachine - жизненный цикл задачи
+var TaskMachine = &StateMachine{
+	Entity: EntityTask,
+	Transitions: []Transition{
+		{Action: TaskSubmit, From: []string{models.TaskPending}, To: models.TaskSubmitted, Roles: []string{ActorChild}},
+		// Родитель может засчитать задачу в работе, не дожидаясь отправки
+		{Action: TaskApprove, From: []string{models.TaskPending, models.TaskSubmitted}, To: models.TaskCompleted, Roles: []string{ActorParent}},
+		{Action: TaskReject, From: []string{models.TaskSubmitted}, To: models.TaskPending, Roles: []string{ActorParent}},
+		{Action: TaskFail, From: []string{models.TaskPending}, To: models.TaskFailed, Roles: []string{ActorParent, ActorSystem}},
+		{Action: TaskReopen, From: []string{models.TaskFailed, models.TaskCompleted}, To: models.TaskPending, Roles: []string{ActorParent}},
+		{Action: TaskCancel, From: []string{models.TaskPending, models.TaskSubmitted}, To: models.TaskCancelled, Roles: []string{ActorSystem}},
+	},
+}
+
+// RewardMachine - жизненный цикл награды
+var RewardMachine = &StateMachine{
+	Entity: EntityReward,
+	Transitions: []Transition{
+		{Action: RewardClaim, From: []string{models.RewardAvailable}, To: models.RewardClaimed, Roles: []string{ActorChild}},
+		{Action: RewardFulfil, From: []string{models.RewardClaimed}, To: models.RewardCompleted, Roles: []string{ActorParent}},
+		{Action: RewardExpire, From: []string{models.RewardAvailable}, To: models.RewardExpired, Roles: []string{ActorSystem}},
+		{Action: RewardRelease, From: []string{models.RewardClaimed}, To: models.RewardAvailable, Roles: []string{ActorSystem}},
+	},
+}
+
+// ClaimMachine - жизненный цикл запроса награды. Родитель может выдать
+// награду сразу, не одобряя запрос отдельно.
+var ClaimMachine = &StateMachine{
+	Entity: EntityRewardClaim,
+	Transitions: []Transition{
+		{Action: ClaimApprove, From: []string{models.ClaimPending}, To: models.ClaimApproved, Roles: []string{ActorParent}},
+		{Action: ClaimReject, From: []string{models.ClaimPending, models.ClaimApproved}, To: models.ClaimRejected, Roles: []string{ActorParent}},
+		{Action: ClaimCancel, From: []string{models.ClaimPending}, To: models.ClaimCancelled, Roles: []string{ActorChild}},
+		{Action: ClaimFulfil, From: []string{models.ClaimPending, models.ClaimApproved}, To: models.ClaimFulfilled, Roles: []string{ActorParent}},
+	},
+}
+
+// WishMachine - жизненный цикл желания. Родитель может назначить стоимость
+// повторно или принять желание, не назначая ее отдельно.
+var WishMachine = &StateMachine{
+	Entity: EntityWish,
+	Transitions: []Transition{
+		{Action: WishPrice, From: []string{models.WishPending, models.WishPriced}, To: models.WishPriced, Roles: []string{ActorParent}},
+		{Action: WishAccept, From: []string{models.WishPending, models.WishPriced}, To: models.WishAccepted, Roles: []string{ActorParent}},
+		{Action: WishReject, From: []string{models.WishPending, models.WishPriced}, To: models.WishRejected, Roles: []string{ActorParent}},
+		{Action: WishWithdraw, From: []string{models.WishPending, models.WishPriced}, To: models.WishWithdrawn, Roles: []string{ActorChild}},
+	},
+}
+
+// GoalMachine - жизненный цикл цели накопления. Достижение цели и запрос
+// награды по ней выполняются системой при пополнении; если награду не
+// удалось запросить сразу, ребенок запрашивает ее сам.
+var GoalMachine = &StateMachine{
+	Entity: EntitySavingsGoal,
+	Transitions: []Transition{
+		{Action: GoalReach, From: []string{models.GoalActive}, To: models.GoalReached, Roles: []string{ActorSystem}},
+		{Action: GoalClaim, From: []string{models.GoalActive, models.GoalReached}, To: models.GoalClaimed, Roles: []string{ActorChild, ActorSystem}},
+		{Action: GoalCancel, From: []string{models.GoalActive, models.GoalReached}, To: models.GoalCancelled, Roles: []string{ActorParent, ActorChild, ActorSystem}},
+	},
+}
+
+// Find возвращает переход для действия из состояния from.
+// Если действие из этого состояния допустимо, но не для роли role,
+// возвращается ErrTransitionForbidden.
+func (m *StateMachine) Find(action, from, role string) (*Transition, error) {
+	for i := range m.Transitions {
+		t := &m.Transitions[i]
+		if t.Action != action || !slices.Contains(t.From, from) {
+			continue
+		}
+		if !slices.Contains(t.Roles, role) {
+			return nil, ErrTransitionForbidden
+		}
+		return t, nil
+	}
+	return nil, ErrInvalidTransition
+}
+
+// ActionTo возвращает действие, переводящее сущность из from в to.
+// Используется для запросов, которые по-прежнему передают новый статус.
+func (m *StateMachine) ActionTo(from, to, role string) (*Transition, error) {
+	forbidden := false
+	for i := range m.Transitions {
+		t := &m.Transitions[i]
+		if t.To != to || !slices.Contains(t.From, from) {
+			continue
+		}
+		if slices.Contains(t.Roles, role) {
+			return t, nil
+		}
+		forbidden = true
+	}
+	if forbidden {
+		return nil, ErrTransitionForbidden
+	}
+	return nil, ErrInvalidTransition
+}
+
+// Available возвращает действия, доступные роли в состоянии from
+func (m *StateMachine) Available(from, role string) []string {
+	actions := make([]string, 0)
+	for _, t := range m.Transitions {
+		if slices.Contains(t.From, from) && slices.Contains(t.Roles, role) && !slices.Contains(actions, t.Action) {
+			actions = append(actions, t.Action)
+		}
+	}
+	return actions
+}
+
+// Fire проверяет переход и записывает его в историю. Само изменение
+// статуса и побочные эффекты выполняет вызывающий код в той же транзакции.
+func (m *StateMachine) Fire(tx *gorm.DB, entityID, action, from, role, actorID, reason string) (*Transition, error) {
+	t, err := m.Find(action, from, role)
+	if err != nil {
+		return nil, err
+	}
+	return t, RecordTransitions(tx, m.Entity, []string{entityID}, t, from, role, actorID, reason)
+}
+
+// RecordTransitions записывает в историю один и тот же переход нескольких
+// сущностей, например задач, проваленных планировщиком
+func RecordTransitions(tx *gorm.DB, entity string, ids []string, t *Transition, from, role, actorID, reason string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var actor *string
+	if actorID != "" {
+		actor = &actorID
+	}
+	now := time.Now()
+	records := make([]models.StateTransition, len(ids))
+	for i, id := range ids {
+		records[i] = models.StateTransition{
+			EntityType: entity,
+			EntityID:   id,
+			Action:     t.Action,
+			FromStatus: from,
+			ToStatus:   t.To,
+			ActorID:    actor,
+			ActorRole:  role,
+			Reason:     reason,
+			CreatedAt:  now,
+		}
+	}
+	return tx.Create(&records).Error
+}
+
+// SetStatus меняет статус строки, только если он не изменился с момента
+// чтения, и возвращает ErrInvalidTransition, если это не так
+func SetStatus(tx *gorm.DB, model interface{}, id, from string, updates map[string]interface{}) error {
+	result := tx.Model(model).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// TransitionHistory возвращает историю переходов сущности в порядке времени
+func TransitionHistory(db *gorm.DB, entity, id string) ([]models.StateTransition, error) {
+	var history []models.StateTransition
+	err := db.Where("entity_type = ? AND entity_id = ?", entity, id).
+		Order("created_at").
+		Find(&history).Error
+	return history, err
+}
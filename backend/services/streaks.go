@@ -0,0 +1,435 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidStreakBonus возвращается для некорректного бонуса за серию
+var ErrInvalidStreakBonus = errors.New("некорректный бонус за серию")
+
+// Ограничения бонуса за серию
+const (
+	minStreakLength            = 2
+	maxStreakLength            = 366
+	maxStreakMultiplierPercent = 1000
+)
+
+// Вклад задачи в серию
+const (
+	// Задача еще не решена или отменена: серию не продолжает и не прерывает
+	streakNeutral = iota
+	streakKept
+	streakBroken
+)
+
+// Streak - серия задач, выполненных вовремя
+type Streak struct {
+	Current int `json:"current"`
+	Best    int `json:"best"`
+	// День или срок последнего повторения текущей серии
+	LastKeptAt *time.Time `json:"last_kept_at,omitempty"`
+	// Множитель, который действует при текущей длине серии
+	MultiplierPercent int `json:"multiplier_percent,omitempty"`
+}
+
+// SeriesStreak - серия повторений одной повторяющейся задачи
+type SeriesStreak struct {
+	SeriesID string `json:"series_id"`
+	Title    string `json:"title"`
+	Streak
+}
+
+// ContractStreaks - серии ребенка в рамках контракта
+type ContractStreaks struct {
+	ContractID string         `json:"contract_id"`
+	Title      string         `json:"title"`
+	Timezone   string         `json:"timezone"`
+	Days       Streak         `json:"days"`
+	Series     []SeriesStreak `json:"series"`
+}
+
+type streakUnit struct {
+	at    time.Time
+	state int
+}
+
+// ValidateStreakBonus проверяет бонус за серию и заполняет часовой пояс
+// по умолчанию
+func ValidateStreakBonus(bonus *models.StreakBonus) error {
+	if bonus.Timezone == "" {
+		bonus.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(bonus.Timezone); err != nil {
+		return ErrInvalidStreakBonus
+	}
+
+	switch bonus.Scope {
+	case models.StreakDays:
+		if bonus.SeriesID != nil {
+			return ErrInvalidStreakBonus
+		}
+	case models.StreakSeries:
+	default:
+		return ErrInvalidStreakBonus
+	}
+	if bonus.Length < minStreakLength || bonus.Length > maxStreakLength {
+		return ErrInvalidStreakBonus
+	}
+
+	switch bonus.Kind {
+	case models.StreakMultiplier:
+		if bonus.MultiplierPercent <= 100 || bonus.MultiplierPercent > maxStreakMultiplierPercent || bonus.Points != 0 {
+			return ErrInvalidStreakBonus
+		}
+	case models.StreakMilestone:
+		if bonus.Points <= 0 || bonus.MultiplierPercent != 0 {
+			return ErrInvalidStreakBonus
+		}
+	default:
+		return ErrInvalidStreakBonus
+	}
+	return nil
+}
+
+// streakState определяет вклад задачи в серию к моменту now. Серию
+// прерывают проваленные, выполненные с опозданием и просроченные задачи.
+func streakState(task *TaskSnapshot, now time.Time) int {
+	switch task.Status {
+	case models.TaskCompleted:
+		if task.DoneAt != nil && task.DoneAt.After(task.DueDate) {
+			return streakBroken
+		}
+		return streakKept
+	case models.TaskFailed:
+		return streakBroken
+	case models.TaskSubmitted:
+		// Отправленная вовремя задача ждет проверки и серию не прерывает
+		if task.DoneAt != nil && task.DoneAt.After(task.DueDate) {
+			return streakBroken
+		}
+		return streakNeutral
+	case models.TaskPending:
+		if task.DueDate.Before(now) {
+			return streakBroken
+		}
+		return streakNeutral
+	default:
+		return streakNeutral
+	}
+}
+
+// countStreak считает текущую и лучшую серию по упорядоченным единицам.
+// Нейтральные единицы пропускаются.
+func countStreak(units []streakUnit) Streak {
+	var streak Streak
+	for i := range units {
+		switch units[i].state {
+		case streakKept:
+			streak.Current++
+			streak.LastKeptAt = &units[i].at
+			if streak.Current > streak.Best {
+				streak.Best = streak.Current
+			}
+		case streakBroken:
+			streak.Current = 0
+			streak.LastKeptAt = nil
+		}
+	}
+	return streak
+}
+
+// CountDayStreak считает серию дней подряд, в которые все задачи контракта
+// выполнены вовремя. Дни без задач серию не прерывают. Учитываются дни
+// не позже дня until в часовом поясе location.
+func CountDayStreak(tasks []TaskSnapshot, location *time.Location, until, now time.Time) Streak {
+	last := PeriodStart(until, models.PeriodDay, location)
+	days := make(map[time.Time]int)
+	for i := range tasks {
+		day := PeriodStart(tasks[i].DueDate, models.PeriodDay, location)
+		if day.After(last) {
+			continue
+		}
+		// День засчитывается, только если в нем нет прерывающих задач
+		state := streakState(&tasks[i], now)
+		if current, ok := days[day]; !ok || state > current {
+			days[day] = state
+		}
+	}
+
+	units := make([]streakUnit, 0, len(days))
+	for day, state := range days {
+		units = append(units, streakUnit{at: day, state: state})
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].at.Before(units[j].at) })
+	return countStreak(units)
+}
+
+// CountSeriesStreak считает серию повторений повторяющейся задачи подряд,
+// выполненных вовремя. Учитываются повторения со сроком не позже until.
+func CountSeriesStreak(tasks []TaskSnapshot, seriesID string, until, now time.Time) Streak {
+	var units []streakUnit
+	for i := range tasks {
+		task := &tasks[i]
+		if task.SeriesID == nil || *task.SeriesID != seriesID || task.DueDate.After(until) {
+			continue
+		}
+		units = append(units, streakUnit{at: task.DueDate, state: streakState(task, now)})
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].at.Before(units[j].at) })
+	return countStreak(units)
+}
+
+// StreakMultiplier выбирает наибольший множитель, который дает серия
+// длины length. Множители разных бонусов не складываются.
+func StreakMultiplier(bonuses []models.StreakBonus, scope string, seriesID *string, length int) *models.StreakBonus {
+	var best *models.StreakBonus
+	for i := range bonuses {
+		bonus := &bonuses[i]
+		if bonus.Kind != models.StreakMultiplier || !bonusCovers(bonus, scope, seriesID) || length < bonus.Length {
+			continue
+		}
+		if best == nil || bonus.MultiplierPercent > best.MultiplierPercent {
+			best = bonus
+		}
+	}
+	return best
+}
+
+func bonusCovers(bonus *models.StreakBonus, scope string, seriesID *string) bool {
+	if bonus.Scope != scope {
+		return false
+	}
+	if scope == models.StreakSeries {
+		return seriesID != nil && (bonus.SeriesID == nil || *bonus.SeriesID == *seriesID)
+	}
+	return true
+}
+
+// StreakExtra возвращает баллы сверх points, которые дает множитель
+// percent. Дробная часть округляется до ближайшего целого.
+func StreakExtra(points, percent int) int {
+	if points <= 0 || percent <= 100 {
+		return 0
+	}
+	return (points*(percent-100) + 50) / 100
+}
+
+// multiplierText форматирует множитель в процентах: 150 - «×1.5»
+func multiplierText(percent int) string {
+	return "×" + strconv.FormatFloat(float64(percent)/100, 'f', -1, 64)
+}
+
+// streakText описывает серию для журнала баллов
+func streakText(bonus *models.StreakBonus, length int, series string) string {
+	if bonus.Scope == models.StreakSeries {
+		return fmt.Sprintf("серия «%s»: %d раз подряд", series, length)
+	}
+	return fmt.Sprintf("серия %d дн. подряд", length)
+}
+
+// ApplyStreakBonuses начисляет бонусы за серии при подтверждении задачи.
+// Множитель применяется к баллам последнего начисления за задачу,
+// бонус за рубеж - когда серия с этой задачей ровно достигает его длины.
+// Задача, выполненная с опозданием, бонусов не получает.
+func ApplyStreakBonuses(tx *gorm.DB, task *models.Task, childID, actorID string, now time.Time) error {
+	var bonuses []models.StreakBonus
+	if err := tx.Where("contract_id = ?", task.ContractID).Find(&bonuses).Error; err != nil {
+		return err
+	}
+	if len(bonuses) == 0 {
+		return nil
+	}
+
+	tasks, err := loadSnapshots(tx, task.ContractID)
+	if err != nil {
+		return err
+	}
+	var current *TaskSnapshot
+	for i := range tasks {
+		if tasks[i].ID == task.ID {
+			current = &tasks[i]
+		}
+	}
+	if current == nil || streakState(current, now) != streakKept {
+		return nil
+	}
+
+	var earned models.LedgerEntry
+	if err := tx.Where("task_id = ? AND entry_type = ?", task.ID, models.LedgerEarn).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&earned).Error; err != nil {
+		return err
+	}
+
+	seriesTitle := task.Title
+	if task.SeriesID != nil {
+		var series models.TaskSeries
+		if err := tx.Select("title").Where("id = ?", *task.SeriesID).Take(&series).Error; err == nil {
+			seriesTitle = series.Title
+		}
+	}
+
+	// Длина серии, которую продолжает задача, для каждого бонуса
+	lengths := make(map[string]int, len(bonuses))
+	dayKeys := make(map[string]string, len(bonuses))
+	for i := range bonuses {
+		bonus := &bonuses[i]
+		switch {
+		case bonusCovers(bonus, models.StreakDays, nil):
+			location, err := time.LoadLocation(bonus.Timezone)
+			if err != nil {
+				return err
+			}
+			lengths[bonus.ID] = CountDayStreak(tasks, location, task.DueDate, now).Current
+			dayKeys[bonus.ID] = "day:" + PeriodStart(task.DueDate, models.PeriodDay, location).Format("2006-01-02")
+		case bonusCovers(bonus, models.StreakSeries, task.SeriesID):
+			lengths[bonus.ID] = CountSeriesStreak(tasks, *task.SeriesID, task.DueDate, now).Current
+		}
+	}
+
+	// Из множителей дневной серии и серии повторений действует наибольший
+	var multiplier *models.StreakBonus
+	for i := range bonuses {
+		bonus := &bonuses[i]
+		length, ok := lengths[bonus.ID]
+		if bonus.Kind != models.StreakMultiplier || !ok || length < bonus.Length {
+			continue
+		}
+		if multiplier == nil || bonus.MultiplierPercent > multiplier.MultiplierPercent {
+			multiplier = bonus
+		}
+	}
+	if multiplier != nil {
+		extra := StreakExtra(earned.Amount, multiplier.MultiplierPercent)
+		description := fmt.Sprintf("%s за задачу «%s» (%s)", multiplierText(multiplier.MultiplierPercent), task.Title,
+			streakText(multiplier, lengths[multiplier.ID], seriesTitle))
+		if err := awardStreak(tx, multiplier, task, childID, actorID, "task:"+task.ID, lengths[multiplier.ID], extra, description, now); err != nil {
+			return err
+		}
+	}
+
+	for i := range bonuses {
+		bonus := &bonuses[i]
+		length, ok := lengths[bonus.ID]
+		if bonus.Kind != models.StreakMilestone || !ok || length != bonus.Length {
+			continue
+		}
+		// Дневную серию могут продолжать несколько задач одного дня,
+		// бонус за рубеж начисляется один раз
+		key := "task:" + task.ID
+		if bonus.Scope == models.StreakDays {
+			key = dayKeys[bonus.ID]
+		}
+		description := fmt.Sprintf("Бонус за рубеж: %s", streakText(bonus, length, seriesTitle))
+		if err := awardStreak(tx, bonus, task, childID, actorID, key, length, bonus.Points, description, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func awardStreak(tx *gorm.DB, bonus *models.StreakBonus, task *models.Task, childID, actorID, key string, length, points int, description string, now time.Time) error {
+	if points <= 0 {
+		return nil
+	}
+	if err := LockBalance(tx, childID, task.ContractID); err != nil {
+		return err
+	}
+
+	award := models.StreakAward{
+		BonusID:    bonus.ID,
+		ContractID: task.ContractID,
+		ChildID:    childID,
+		TaskID:     task.ID,
+		Key:        key,
+		Length:     length,
+		Points:     points,
+		AwardedAt:  now,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&award)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	entry := &models.LedgerEntry{
+		ChildID:       childID,
+		ContractID:    task.ContractID,
+		Type:          models.LedgerStreak,
+		Amount:        points,
+		TaskID:        &task.ID,
+		StreakBonusID: &bonus.ID,
+		Description:   description,
+	}
+	if actorID != "" {
+		entry.CreatedBy = &actorID
+	}
+	return Post(tx, entry)
+}
+
+// RevokeStreakBonuses снимает отметки о бонусах за серию при отмене
+// выполнения задачи: баллы бонусов списывает RevokeTask, а при повторном
+// подтверждении серия будет пересчитана
+func RevokeStreakBonuses(tx *gorm.DB, taskID string) error {
+	return tx.Where("task_id = ?", taskID).Delete(&models.StreakAward{}).Error
+}
+
+// LoadStreaks считает текущие серии ребенка по контракту: дневную и по
+// каждой повторяющейся задаче. Серии отсчитываются заново в каждом
+// контракте, в том числе продленном. Если location не задан, дни
+// отсчитываются в часовом поясе бонуса за дни подряд.
+func LoadStreaks(db *gorm.DB, contract *models.Contract, location *time.Location, now time.Time) (*ContractStreaks, error) {
+	tasks, err := loadSnapshots(db, contract.ID)
+	if err != nil {
+		return nil, err
+	}
+	var bonuses []models.StreakBonus
+	if err := db.Where("contract_id = ?", contract.ID).Find(&bonuses).Error; err != nil {
+		return nil, err
+	}
+	var series []models.TaskSeries
+	if err := db.Where("contract_id = ?", contract.ID).Order("created_at").Find(&series).Error; err != nil {
+		return nil, err
+	}
+
+	// Без явного часового пояса дни отсчитываются так же, как для бонусов
+	if location == nil {
+		location = time.UTC
+		for _, bonus := range bonuses {
+			if bonus.Scope != models.StreakDays {
+				continue
+			}
+			if bonusLocation, err := time.LoadLocation(bonus.Timezone); err == nil {
+				location = bonusLocation
+				break
+			}
+		}
+	}
+
+	streaks := &ContractStreaks{
+		ContractID: contract.ID,
+		Title:      contract.Title,
+		Timezone:   location.String(),
+		Days:       CountDayStreak(tasks, location, now, now),
+		Series:     make([]SeriesStreak, 0, len(series)),
+	}
+	if bonus := StreakMultiplier(bonuses, models.StreakDays, nil, streaks.Days.Current); bonus != nil {
+		streaks.Days.MultiplierPercent = bonus.MultiplierPercent
+	}
+	for _, item := range series {
+		streak := CountSeriesStreak(tasks, item.ID, now, now)
+		if bonus := StreakMultiplier(bonuses, models.StreakSeries, &item.ID, streak.Current); bonus != nil {
+			streak.MultiplierPercent = bonus.MultiplierPercent
+		}
+		streaks.Series = append(streaks.Series, SeriesStreak{SeriesID: item.ID, Title: item.Title, Streak: streak})
+	}
+	return streaks, nil
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTemplate возвращается при некорректных заготовках шаблона
+var ErrInvalidTemplate = errors.New("некорректный шаблон контракта")
+
+// Ограничения шаблона контракта
+const (
+	MaxTemplateDuration = 366
+	defaultDueTime      = "20:00"
+	defaultTimezone     = "UTC"
+)
+
+// TaskBlueprint - заготовка задачи в шаблоне. Задача с правилом повторения
+// становится повторяющейся, остальные - разовыми со сроком DueDay дней
+// от начала контракта.
+type TaskBlueprint struct {
+	Title         string   `json:"title" binding:"required,max=255"`
+	Description   string   `json:"description"`
+	Points        int      `json:"points" binding:"min=0"`
+	DueDay        int      `json:"due_day" binding:"min=0"`
+	DueTime       string   `json:"due_time,omitempty"`
+	Rule          string   `json:"rule,omitempty"`
+	Timezone      string   `json:"timezone,omitempty"`
+	Checklist     []string `json:"checklist,omitempty" binding:"omitempty,max=50,dive,required,max=255"`
+	PartialCredit bool     `json:"partial_credit,omitempty"`
+}
+
+// RewardBlueprint - заготовка награды в шаблоне
+type RewardBlueprint struct {
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description"`
+	Points      int    `json:"points" binding:"min=0"`
+	RewardLimits
+}
+
+// TemplateBlueprints разбирает заготовки задач и наград шаблона
+func TemplateBlueprints(template *models.ContractTemplate) ([]TaskBlueprint, []RewardBlueprint, error) {
+	var tasks []TaskBlueprint
+	if err := json.Unmarshal(template.Tasks, &tasks); err != nil {
+		return nil, nil, err
+	}
+	var rewards []RewardBlueprint
+	if err := json.Unmarshal(template.Rewards, &rewards); err != nil {
+		return nil, nil, err
+	}
+	return tasks, rewards, nil
+}
+
+// SetBlueprints проверяет заготовки и сохраняет их в шаблон.
+// Правила повторения приводятся к каноническому виду.
+func SetBlueprints(template *models.ContractTemplate, tasks []TaskBlueprint, rewards []RewardBlueprint) error {
+	if err := ValidateBlueprints(template.DurationDays, tasks, rewards); err != nil {
+		return err
+	}
+	if tasks == nil {
+		tasks = []TaskBlueprint{}
+	}
+	if rewards == nil {
+		rewards = []RewardBlueprint{}
+	}
+
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	template.Tasks = models.JSON(data)
+
+	data, err = json.Marshal(rewards)
+	if err != nil {
+		return err
+	}
+	template.Rewards = models.JSON(data)
+	return nil
+}
+
+// ValidateBlueprints проверяет срок шаблона, расписания и сроки задач
+func ValidateBlueprints(durationDays int, tasks []TaskBlueprint, rewards []RewardBlueprint) error {
+	if durationDays <= 0 || durationDays > MaxTemplateDuration {
+		return ErrInvalidTemplate
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Title == "" || len(task.Title) > 255 || task.Points < 0 || len(task.Checklist) > 50 {
+			return ErrInvalidTemplate
+		}
+		for _, item := range task.Checklist {
+			if item == "" || len(item) > 255 {
+				return ErrInvalidTemplate
+			}
+		}
+		if task.DueTime != "" {
+			if _, err := ParseTimeOfDay(task.DueTime); err != nil {
+				return ErrInvalidTemplate
+			}
+		}
+		if task.Rule == "" {
+			// Разовая задача должна укладываться в срок контракта
+			if task.DueDay < 0 || task.DueDay >= durationDays || task.Timezone != "" {
+				return ErrInvalidTemplate
+			}
+			continue
+		}
+		// Чек-листы поддерживаются только у разовых задач
+		if len(task.Checklist) > 0 || task.PartialCredit {
+			return ErrInvalidTemplate
+		}
+		rule, err := ParseRule(task.Rule)
+		if err != nil {
+			return ErrInvalidTemplate
+		}
+		task.Rule = rule.String()
+		if task.Timezone != "" {
+			if _, err := time.LoadLocation(task.Timezone); err != nil {
+				return ErrInvalidTemplate
+			}
+		}
+	}
+	for _, reward := range rewards {
+		if reward.Title == "" || len(reward.Title) > 255 || reward.Points < 0 {
+			return ErrInvalidTemplate
+		}
+		if err := reward.RewardLimits.Apply(&models.Reward{}); err != nil {
+			return ErrInvalidTemplate
+		}
+	}
+	return nil
+}
+
+// BlueprintsFromContract собирает заготовки задач и наград из контракта.
+// Экземпляры повторяющихся задач не переносятся, вместо них переносится серия.
+func BlueprintsFromContract(db *gorm.DB, contract *models.Contract) ([]TaskBlueprint, []RewardBlueprint, int, error) {
+	var tasks []models.Task
+	if err := db.Where("contract_id = ? AND series_id IS NULL", contract.ID).
+		Order("due_date, created_at").
+		Find(&tasks).Error; err != nil {
+		return nil, nil, 0, err
+	}
+	var series []models.TaskSeries
+	if err := db.Where("contract_id = ?", contract.ID).
+		Order("created_at").
+		Find(&series).Error; err != nil {
+		return nil, nil, 0, err
+	}
+	var rewards []models.Reward
+	if err := db.Where("contract_id = ?", contract.ID).
+		Order("created_at").
+		Find(&rewards).Error; err != nil {
+		return nil, nil, 0, err
+	}
+
+	taskIDs := make([]string, len(tasks))
+	for i, task := range tasks {
+		taskIDs[i] = task.ID
+	}
+	checklists, err := LoadChecklists(db, taskIDs)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	start := Date(contract.StartDate)
+	durationDays := daysBetween(start, Date(contract.EndDate))
+	if durationDays < 1 {
+		durationDays = 1
+	}
+
+	taskBlueprints := make([]TaskBlueprint, 0, len(tasks)+len(series))
+	for _, item := range series {
+		taskBlueprints = append(taskBlueprints, TaskBlueprint{
+			Title:       item.Title,
+			Description: item.Description,
+			Points:      item.Points,
+			DueDay:      clampDay(daysBetween(start, Date(item.StartsOn)), durationDays),
+			DueTime:     item.DueTime,
+			Rule:        item.Rule,
+			Timezone:    item.Timezone,
+		})
+	}
+	for _, task := range tasks {
+		due := task.DueDate.UTC()
+		blueprint := TaskBlueprint{
+			Title:         task.Title,
+			Description:   task.Description,
+			Points:        task.Points,
+			DueDay:        clampDay(daysBetween(start, Date(due)), durationDays),
+			DueTime:       due.Format("15:04"),
+			PartialCredit: task.PartialCredit,
+		}
+		for _, item := range checklists[task.ID] {
+			blueprint.Checklist = append(blueprint.Checklist, item.Title)
+		}
+		taskBlueprints = append(taskBlueprints, blueprint)
+	}
+
+	rewardBlueprints := make([]RewardBlueprint, 0, len(rewards))
+	for _, reward := range rewards {
+		rewardBlueprints = append(rewardBlueprints, RewardBlueprint{
+			Title:        reward.Title,
+			Description:  reward.Description,
+			Points:       reward.PointsCost,
+			RewardLimits: LimitsOf(&reward),
+		})
+	}
+
+	return taskBlueprints, rewardBlueprints, durationDays, nil
+}
+
+func clampDay(day, durationDays int) int {
+	if day < 0 {
+		return 0
+	}
+	if day >= durationDays {
+		return durationDays - 1
+	}
+	return day
+}
+
+// TemplateDueDate возвращает срок разовой задачи шаблона для контракта,
+// начинающегося в start
+func TemplateDueDate(start time.Time, blueprint TaskBlueprint) time.Time {
+	dueTime := blueprint.DueTime
+	if dueTime == "" {
+		dueTime = defaultDueTime
+	}
+	offset, err := ParseTimeOfDay(dueTime)
+	if err != nil {
+		offset, _ = ParseTimeOfDay(defaultDueTime)
+	}
+	return Date(start).AddDate(0, 0, blueprint.DueDay).Add(offset)
+}
+
+// InstantiateTemplate создает по шаблону черновик контракта с ребенком
+// вместе с задачами, повторяющимися задачами и наградами
+func InstantiateTemplate(tx *gorm.DB, template *models.ContractTemplate, parentID, childID, title string, start time.Time) (*models.Contract, error) {
+	tasks, rewards, err := TemplateBlueprints(template)
+	if err != nil {
+		return nil, err
+	}
+	if title == "" {
+		title = template.Title
+	}
+
+	now := time.Now()
+	start = Date(start)
+	contract := &models.Contract{
+		Title:       title,
+		Description: template.Description,
+		ParentID:    parentID,
+		ChildID:     childID,
+		Status:      models.ContractDraft,
+		StartDate:   start,
+		EndDate:     start.AddDate(0, 0, template.DurationDays),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := tx.Create(contract).Error; err != nil {
+		return nil, err
+	}
+
+	for _, blueprint := range tasks {
+		if blueprint.Rule != "" {
+			if err := createSeriesFromBlueprint(tx, contract, blueprint, parentID, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		task := models.Task{
+			Title:         blueprint.Title,
+			Description:   blueprint.Description,
+			ContractID:    contract.ID,
+			Status:        "pending",
+			DueDate:       TemplateDueDate(start, blueprint),
+			Points:        blueprint.Points,
+			PartialCredit: blueprint.PartialCredit,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := tx.Omit("Contract").Create(&task).Error; err != nil {
+			return nil, err
+		}
+		for i, title := range blueprint.Checklist {
+			item := models.TaskChecklistItem{TaskID: task.ID, Title: title, Position: i, CreatedAt: now, UpdatedAt: now}
+			if err := tx.Create(&item).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, blueprint := range rewards {
+		reward := models.Reward{
+			Title:       blueprint.Title,
+			Description: blueprint.Description,
+			ContractID:  contract.ID,
+			Status:      "available",
+			PointsCost:  blueprint.Points,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := blueprint.RewardLimits.Apply(&reward); err != nil {
+			return nil, ErrInvalidTemplate
+		}
+		if err := tx.Omit("Contract").Create(&reward).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return contract, nil
+}
+
+func createSeriesFromBlueprint(tx *gorm.DB, contract *models.Contract, blueprint TaskBlueprint, parentID string, now time.Time) error {
+	series := models.TaskSeries{
+		ContractID:  contract.ID,
+		Title:       blueprint.Title,
+		Description: blueprint.Description,
+		Points:      blueprint.Points,
+		Rule:        blueprint.Rule,
+		DueTime:     blueprint.DueTime,
+		Timezone:    blueprint.Timezone,
+		StartsOn:    Date(contract.StartDate).AddDate(0, 0, blueprint.DueDay),
+		CreatedBy:   &parentID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if series.DueTime == "" {
+		series.DueTime = defaultDueTime
+	}
+	if series.Timezone == "" {
+		series.Timezone = defaultTimezone
+	}
+	if err := tx.Omit("Contract").Create(&series).Error; err != nil {
+		return err
+	}
+	_, err := GenerateSeries(tx, &series, contract, now.Add(SeriesHorizon))
+	return err
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WishDecision - решение родителя по желанию
+type WishDecision struct {
+	Action  string
+	ActorID string
+	// Стоимость для price и accept; для accept по умолчанию текущая стоимость желания
+	Points *int
+	// Контракт, в который желание добавляется наградой (accept)
+	ContractID string
+	IPAddress  string
+	// Пояснение к стоимости или причина отказа
+	Note string
+}
+
+// DecideWish выполняет действие над желанием по правилам WishMachine.
+// При принятии в контракт создается награда, а условия контракта
+// изменяются как при добавлении награды родителем.
+func DecideWish(tx *gorm.DB, wishID, role string, decision WishDecision) (*models.Wish, error) {
+	var wish models.Wish
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&wish, "id = ?", wishID).Error; err != nil {
+		return nil, err
+	}
+
+	from := wish.Status
+	t, err := WishMachine.Fire(tx, wish.ID, decision.Action, from, role, decision.ActorID, decision.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     t.To,
+		"updated_at": now,
+	}
+	switch decision.Action {
+	case WishPrice:
+		updates["price"] = *decision.Points
+		updates["price_note"] = decision.Note
+		updates["priced_by"] = decision.ActorID
+		updates["priced_at"] = now
+	case WishAccept:
+		points := wish.Cost()
+		if decision.Points != nil {
+			points = *decision.Points
+		}
+		reward := models.Reward{
+			Title:       wish.Title,
+			Description: wish.Description,
+			ContractID:  decision.ContractID,
+			PointsCost:  points,
+			Status:      models.RewardAvailable,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Omit("Contract").Create(&reward).Error; err != nil {
+			return nil, err
+		}
+		if err := AmendContract(tx, decision.ContractID, decision.ActorID, decision.IPAddress, "Добавлена награда из списка желаний «"+reward.Title+"»"); err != nil {
+			return nil, err
+		}
+		updates["price"] = points
+		updates["contract_id"] = decision.ContractID
+		updates["reward_id"] = reward.ID
+		updates["reviewed_by"] = decision.ActorID
+		updates["reviewed_at"] = now
+	case WishReject:
+		updates["reviewed_by"] = decision.ActorID
+		updates["reviewed_at"] = now
+		updates["rejection_reason"] = decision.Note
+	}
+	if err := SetStatus(tx, &wish, wish.ID, from, updates); err != nil {
+		return nil, err
+	}
+
+	return &wish, tx.Preload("Reward").First(&wish, "id = ?", wish.ID).Error
+}
+
+// WishProgress считает, сколько баллов осталось накопить до желания
+func WishProgress(cost int, balance ContractBalance) *models.WishProgress {
+	progress := &models.WishProgress{
+		Cost:       cost,
+		ContractID: balance.ContractID,
+		Balance:    balance.Balance,
+		Missing:    max(cost-balance.Balance, 0),
+		Percent:    100,
+	}
+	if cost > 0 {
+		progress.Percent = min(max(balance.Balance, 0)*100/cost, 100)
+	}
+	return progress
+}
+
+// LoadWishProgress заполняет прогресс открытых желаний и принятых, награду
+// по которым еще можно запросить. Для принятого желания берется баланс
+// контракта его награды, для открытого - наибольший баланс ребенка среди
+// действующих контрактов. Награда желаний должна быть загружена.
+func LoadWishProgress(db *gorm.DB, wishes []models.Wish) error {
+	best := make(map[string]ContractBalance)
+	for i := range wishes {
+		wish := &wishes[i]
+
+		var balance ContractBalance
+		switch {
+		case wish.Open():
+			cached, ok := best[wish.ChildID]
+			if !ok {
+				var err error
+				if cached, err = BestBalance(db, wish.ChildID); err != nil {
+					return err
+				}
+				best[wish.ChildID] = cached
+			}
+			balance = cached
+		case wish.Reward != nil && wish.Reward.Status == models.RewardAvailable:
+			points, err := Balance(db, wish.ChildID, wish.Reward.ContractID)
+			if err != nil {
+				return err
+			}
+			balance = ContractBalance{ContractID: wish.Reward.ContractID, Balance: points}
+		default:
+			continue
+		}
+		wish.Progress = WishProgress(wish.Cost(), balance)
+	}
+	return nil
+}
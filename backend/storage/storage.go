@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound возвращается, если объект отсутствует в хранилище
+var ErrNotFound = errors.New("объект не найден")
+
+// Storage - хранилище файлов вложений
+type Storage interface {
+	// Put сохраняет объект под ключом key
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get открывает объект для чтения, вызывающий обязан закрыть его
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет объект; отсутствие объекта ошибкой не считается
+	Delete(ctx context.Context, key string) error
+}
+
+// Config - параметры хранилища
+type Config struct {
+	Driver      string // local или s3
+	LocalPath   string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+}
+
+// New создает хранилище по конфигурации
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.LocalPath)
+	case "s3":
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PathStyle)
+	default:
+		return nil, fmt.Errorf("неизвестный драйвер хранилища: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAchievement(t *testing.T) {
+	assert.NoError(t, services.ValidateAchievement(&models.Achievement{Metric: models.MetricTasksCompleted, Threshold: 10}))
+	assert.NoError(t, services.ValidateAchievement(&models.Achievement{Metric: models.MetricStreakDays, Threshold: 7}))
+
+	invalid := []models.Achievement{
+		{Metric: "tasks.failed", Threshold: 10},
+		{Metric: models.MetricPointsEarned, Threshold: 0},
+		{Metric: models.MetricPointsEarned, Threshold: -5},
+		{Metric: models.MetricPointsEarned, Threshold: 2000000},
+	}
+	for _, item := range invalid {
+		assert.ErrorIs(t, services.ValidateAchievement(&item), services.ErrInvalidAchievement, "%+v", item)
+	}
+}
+
+func TestAchievementPercent(t *testing.T) {
+	assert.Equal(t, 0, services.AchievementPercent(0, 7))
+	assert.Equal(t, 42, services.AchievementPercent(3, 7))
+	assert.Equal(t, 100, services.AchievementPercent(7, 7))
+	// Превышение порога не дает больше 100%
+	assert.Equal(t, 100, services.AchievementPercent(1500, 1000))
+}
+
+func TestUnlockedAchievements(t *testing.T) {
+	achievements := []models.Achievement{
+		{ID: "first", Metric: models.MetricTasksCompleted, Threshold: 1},
+		{ID: "hundred", Metric: models.MetricTasksCompleted, Threshold: 100},
+		{ID: "week", Metric: models.MetricStreakDays, Threshold: 7},
+		{ID: "saver", Metric: models.MetricGoalsMaxTarget, Threshold: 500},
+	}
+	metrics := map[string]int{
+		models.MetricTasksCompleted: 12,
+		models.MetricStreakDays:     7,
+	}
+
+	unlocked := services.UnlockedAchievements(achievements, metrics, nil)
+	ids := make([]string, len(unlocked))
+	for i, achievement := range unlocked {
+		ids[i] = achievement.ID
+	}
+	// Порог включается; показатель, который не считался, ничего не открывает
+	assert.Equal(t, []string{"first", "week"}, ids)
+
+	// Уже открытые достижения не открываются повторно
+	unlocked = services.UnlockedAchievements(achievements, metrics, map[string]bool{"first": true, "week": true})
+	assert.Empty(t, unlocked)
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkCatalogReward(t *testing.T) {
+	item := &models.CatalogReward{
+		ID:          "catalog-1",
+		Title:       "Поход в кино",
+		Description: "Любой фильм на выбор",
+		Points:      100,
+	}
+
+	var reward models.Reward
+	services.LinkCatalogReward(&reward, item, nil)
+	require.NotNil(t, reward.CatalogRewardID)
+	assert.Equal(t, item.ID, *reward.CatalogRewardID)
+	assert.Equal(t, item.Title, reward.Title)
+	assert.Equal(t, item.Description, reward.Description)
+	assert.Equal(t, 100, reward.PointsCost)
+	assert.False(t, reward.CustomPoints)
+
+	// Собственная стоимость в контракте не следует за каталогом
+	points := 60
+	services.LinkCatalogReward(&reward, item, &points)
+	assert.Equal(t, 60, reward.PointsCost)
+	assert.True(t, reward.CustomPoints)
+
+	// Нулевая стоимость тоже считается заданной отдельно
+	free := 0
+	services.LinkCatalogReward(&reward, item, &free)
+	assert.Equal(t, 0, reward.PointsCost)
+	assert.True(t, reward.CustomPoints)
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialPoints(t *testing.T) {
+	tests := []struct {
+		name                string
+		points, done, total int
+		expected            int
+	}{
+		{name: "Без чек-листа", points: 10, done: 0, total: 0, expected: 10},
+		{name: "Все пункты", points: 10, done: 5, total: 5, expected: 10},
+		{name: "Часть пунктов", points: 10, done: 3, total: 5, expected: 6},
+		{name: "Округление вниз", points: 10, done: 1, total: 3, expected: 3},
+		{name: "Ничего не выполнено", points: 10, done: 0, total: 4, expected: 0},
+		{name: "Выполнено больше, чем пунктов", points: 10, done: 7, total: 5, expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, services.PartialPoints(tt.points, tt.done, tt.total))
+		})
+	}
+}
+
+func TestTaskProgressLabel(t *testing.T) {
+	assert.Equal(t, &models.TaskProgress{Done: 3, Total: 5, Label: "3/5"}, models.NewTaskProgress(3, 5))
+}
+
+func TestDiffTermsChecklist(t *testing.T) {
+	from := baseTerms()
+	to := baseTerms()
+	to.Tasks[1].Checklist = []string{"Заправить кровать", "Убрать игрушки"}
+	to.Tasks[1].PartialCredit = true
+
+	changes := services.DiffTerms(from, to)
+
+	assert.Contains(t, changes, services.TermsChange{Field: "task.checklist", Change: services.ChangeChanged, ItemID: "t2", Before: "", After: "Заправить кровать\nУбрать игрушки"})
+	assert.Contains(t, changes, services.TermsChange{Field: "task.partial_credit", Change: services.ChangeChanged, ItemID: "t2", Before: false, After: true})
+	assert.Len(t, changes, 2)
+	assert.NotEqual(t, from.Hash(), to.Hash())
+}
@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupContractSigningRouter(db *gorm.DB) *gin.Engine {
+	contractHandlers := handlers.NewContractHandlers(db)
+	router, _, authorized := newAPIRouter(db)
+	contracts := authorized.Group("/contracts")
+	{
+		contracts.POST("/:id/propose", middleware.RoleMiddleware("parent"), contractHandlers.Propose)
+		contracts.POST("/:id/withdraw", middleware.RoleMiddleware("parent"), contractHandlers.Withdraw)
+		contracts.POST("/:id/sign", contractHandlers.Sign)
+	}
+	return router
+}
+
+type contractSigningFixture struct {
+	db            *gorm.DB
+	router        *gin.Engine
+	contract      models.Contract
+	task          models.Task
+	guardian      models.User
+	parentToken   string
+	childToken    string
+	guardianToken string
+}
+
+// newContractSigningFixture создает черновик контракта в семье с двумя
+// родителями-опекунами
+func newContractSigningFixture(t *testing.T) contractSigningFixture {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	guardian := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, parent, child)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return services.AddFamilyMember(tx, family.ID, guardian.ID, "parent")
+	}))
+	contract := createContract(t, db, parent, child, models.ContractDraft)
+	return contractSigningFixture{
+		db:            db,
+		router:        setupContractSigningRouter(db),
+		contract:      contract,
+		task:          createTask(t, db, contract, 10, "pending", time.Now().AddDate(0, 0, 3)),
+		guardian:      guardian,
+		parentToken:   openSession(t, db, parent, "").AccessToken,
+		childToken:    openSession(t, db, child, "").AccessToken,
+		guardianToken: openSession(t, db, guardian, "").AccessToken,
+	}
+}
+
+func (f contractSigningFixture) reload(t *testing.T) models.Contract {
+	t.Helper()
+	var contract models.Contract
+	require.NoError(t, f.db.First(&contract, "id = ?", f.contract.ID).Error)
+	return contract
+}
+
+func (f contractSigningFixture) propose(t *testing.T, coSignerID string) models.Contract {
+	t.Helper()
+	w := doJSON(f.router, http.MethodPost, "/api/contracts/"+f.contract.ID+"/propose", f.parentToken, map[string]string{"co_signer_id": coSignerID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return f.reload(t)
+}
+
+func (f contractSigningFixture) sign(token, termsHash string) int {
+	w := doJSON(f.router, http.MethodPost, "/api/contracts/"+f.contract.ID+"/sign", token, map[string]string{"terms_hash": termsHash})
+	return w.Code
+}
+
+func (f contractSigningFixture) missing(t *testing.T) []string {
+	t.Helper()
+	contract := f.reload(t)
+	missing, err := services.MissingSigners(f.db, &contract)
+	require.NoError(t, err)
+	return missing
+}
+
+func TestContractSignStaleHash(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, "")
+	require.NotEmpty(t, contract.TermsHash)
+
+	// Подпись под условиями, которые подписант видел раньше, не принимается
+	assert.Equal(t, http.StatusConflict, f.sign(f.childToken, "stale"))
+
+	// Условия изменены в обход версии: хеш больше не совпадает
+	require.NoError(t, f.db.Model(&f.task).Update("points", 20).Error)
+	assert.Equal(t, http.StatusConflict, f.sign(f.childToken, contract.TermsHash))
+	assert.Equal(t, http.StatusConflict, f.sign(f.childToken, ""))
+
+	assert.Equal(t, models.ContractPendingSignature, f.reload(t).Status)
+	assert.Equal(t, []string{services.SignerChild}, f.missing(t))
+}
+
+func TestContractSignOnlyBySigners(t *testing.T) {
+	f := newContractSigningFixture(t)
+
+	// Предложение другого опекуна не считается подписью автора контракта
+	w := doJSON(f.router, http.MethodPost, "/api/contracts/"+f.contract.ID+"/propose", f.guardianToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	contract := f.reload(t)
+	assert.Equal(t, []string{services.SignerParent, services.SignerChild}, f.missing(t))
+
+	// Опекун, не являющийся стороной контракта, подписать его не может
+	assert.Equal(t, http.StatusForbidden, f.sign(f.guardianToken, contract.TermsHash))
+	var signatures int64
+	f.db.Model(&models.ContractSignature{}).Where("contract_id = ? AND signer_id = ?", contract.ID, f.guardian.ID).Count(&signatures)
+	assert.Zero(t, signatures)
+
+	require.Equal(t, http.StatusOK, f.sign(f.parentToken, contract.TermsHash))
+	assert.Equal(t, []string{services.SignerChild}, f.missing(t))
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	assert.Equal(t, models.ContractActive, f.reload(t).Status)
+}
+
+func TestContractSignActivatesAfterLastSigner(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, f.guardian.ID)
+	assert.Equal(t, []string{services.SignerChild, services.SignerCoParent}, f.missing(t))
+
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	contract = f.reload(t)
+	assert.Equal(t, models.ContractPendingSignature, contract.Status)
+	assert.Nil(t, contract.ActivatedAt)
+	assert.Equal(t, []string{services.SignerCoParent}, f.missing(t))
+
+	// Повторная подпись не дублирует запись и не активирует контракт
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	var signatures int64
+	f.db.Model(&models.ContractSignature{}).Where("contract_id = ?", contract.ID).Count(&signatures)
+	assert.Equal(t, int64(2), signatures)
+
+	// Последняя подпись сразу вводит контракт в силу
+	require.Equal(t, http.StatusOK, f.sign(f.guardianToken, contract.TermsHash))
+	contract = f.reload(t)
+	assert.Equal(t, models.ContractActive, contract.Status)
+	assert.NotNil(t, contract.ActivatedAt)
+	assert.Empty(t, f.missing(t))
+
+	// Действующий контракт подписи не ожидает
+	assert.Equal(t, http.StatusBadRequest, f.sign(f.childToken, contract.TermsHash))
+}
+
+func TestContractResignAfterAmend(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, "")
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	require.Equal(t, models.ContractActive, f.reload(t).Status)
+	oldHash := contract.TermsHash
+
+	// Изменение условий действующего контракта возвращает его на подпись,
+	// родитель, внесший изменение, подписывает новые условия сразу
+	require.NoError(t, f.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&f.task).Update("points", 25).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, f.contract.ID, f.contract.ParentID, "127.0.0.1", "Изменены баллы задачи")
+	}))
+	contract = f.reload(t)
+	assert.Equal(t, models.ContractPendingSignature, contract.Status)
+	assert.NotEqual(t, oldHash, contract.TermsHash)
+	assert.Equal(t, 2, contract.CurrentVersion)
+
+	// Подпись ребенка под старыми условиями не учитывается
+	assert.Equal(t, []string{services.SignerChild}, f.missing(t))
+	assert.Equal(t, http.StatusConflict, f.sign(f.childToken, oldHash))
+
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	assert.Equal(t, models.ContractActive, f.reload(t).Status)
+	assert.Empty(t, f.missing(t))
+}
+
+func TestContractAmendByOtherGuardian(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, "")
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	require.Equal(t, models.ContractActive, f.reload(t).Status)
+
+	// Изменение от опекуна, не являющегося стороной контракта, не
+	// подписывается от его имени: подписать заново должны все стороны
+	require.NoError(t, f.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&f.task).Update("points", 30).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, f.contract.ID, f.guardian.ID, "127.0.0.1", "Изменены баллы задачи")
+	}))
+	contract = f.reload(t)
+	assert.Equal(t, models.ContractPendingSignature, contract.Status)
+	assert.Equal(t, []string{services.SignerParent, services.SignerChild}, f.missing(t))
+	assert.Equal(t, http.StatusForbidden, f.sign(f.guardianToken, contract.TermsHash))
+
+	require.Equal(t, http.StatusOK, f.sign(f.parentToken, contract.TermsHash))
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	assert.Equal(t, models.ContractActive, f.reload(t).Status)
+}
+
+func TestContractProposeCoSignerMustBeGuardian(t *testing.T) {
+	f := newContractSigningFixture(t)
+	stranger := createUser(t, f.db, "parent")
+	createFamily(t, f.db, stranger)
+	path := "/api/contracts/" + f.contract.ID + "/propose"
+
+	// Чужой родитель и сам предлагающий не могут быть вторым подписантом
+	for _, coSignerID := range []string{stranger.ID, f.contract.ParentID, f.contract.ChildID} {
+		w := doJSON(f.router, http.MethodPost, path, f.parentToken, map[string]string{"co_signer_id": coSignerID})
+		assert.Equal(t, http.StatusBadRequest, w.Code, coSignerID)
+	}
+	contract := f.reload(t)
+	assert.Equal(t, models.ContractDraft, contract.Status)
+	assert.Nil(t, contract.CoSignerID)
+
+	// Чужой родитель не может и предложить контракт сам
+	w := doJSON(f.router, http.MethodPost, path, authToken(t, f.db, stranger), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	contract = f.propose(t, f.guardian.ID)
+	require.NotNil(t, contract.CoSignerID)
+	assert.Equal(t, f.guardian.ID, *contract.CoSignerID)
+	assert.Equal(t, []string{services.SignerChild, services.SignerCoParent}, f.missing(t))
+}
+
+func TestContractWithdraw(t *testing.T) {
+	f := newContractSigningFixture(t)
+	path := "/api/contracts/" + f.contract.ID + "/withdraw"
+
+	// Черновик отзывать нечего
+	w := doJSON(f.router, http.MethodPost, path, f.parentToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	f.propose(t, f.guardian.ID)
+	w = doJSON(f.router, http.MethodPost, path, f.childToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Отозвать предложение может любой опекун ребенка
+	w = doJSON(f.router, http.MethodPost, path, f.guardianToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	contract := f.reload(t)
+	assert.Equal(t, models.ContractDraft, contract.Status)
+	assert.Nil(t, contract.ProposedAt)
+
+	// После повторного предложения и подписания отзыв невозможен
+	contract = f.propose(t, "")
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+	w = doJSON(f.router, http.MethodPost, path, f.parentToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Изменения подписанного контракта тоже не отзываются
+	require.NoError(t, f.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&f.task).Update("points", 15).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, f.contract.ID, f.contract.ParentID, "127.0.0.1", "Изменены баллы задачи")
+	}))
+	require.Equal(t, models.ContractPendingSignature, f.reload(t).Status)
+	w = doJSON(f.router, http.MethodPost, path, f.parentToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, models.ContractPendingSignature, f.reload(t).Status)
+}
@@ -32,7 +32,7 @@ func setupContractTestRouter() *gin.Engine {
 	api := router.Group("/api")
 	{
 		contracts := api.Group("/contracts")
-		contracts.Use(middleware.AuthMiddleware())
+		contracts.Use(middleware.AuthMiddleware(db))
 		{
 			contracts.GET("/", contractHandlers.List)
 			contracts.POST("/", middleware.RoleMiddleware("parent"), contractHandlers.Create)
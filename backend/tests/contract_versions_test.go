@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func baseTerms() *services.ContractTerms {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &services.ContractTerms{
+		Title:     "Учеба",
+		ParentID:  "parent",
+		ChildID:   "child",
+		StartDate: start,
+		EndDate:   start.AddDate(0, 1, 0),
+		Tasks: []services.TaskTerms{
+			{ID: "t1", Title: "Домашнее задание", Points: 10, DueDate: start.AddDate(0, 0, 7)},
+			{ID: "t2", Title: "Уборка", Points: 5, DueDate: start.AddDate(0, 0, 3)},
+		},
+		Rewards: []services.RewardTerms{
+			{ID: "r1", Title: "Кино", Points: 30},
+		},
+	}
+}
+
+func TestTermsHashStable(t *testing.T) {
+	assert.Equal(t, baseTerms().Hash(), baseTerms().Hash())
+
+	changed := baseTerms()
+	changed.Tasks[0].Points = 15
+	assert.NotEqual(t, baseTerms().Hash(), changed.Hash())
+}
+
+func TestDiffTerms(t *testing.T) {
+	from := baseTerms()
+	to := baseTerms()
+	to.Title = "Учеба и дом"
+	to.Tasks[0].Points = 15
+	to.Tasks = to.Tasks[:1]
+	to.Rewards = append(to.Rewards, services.RewardTerms{ID: "r2", Title: "Пицца", Points: 20})
+
+	changes := services.DiffTerms(from, to)
+
+	assert.Contains(t, changes, services.TermsChange{Field: "title", Change: services.ChangeChanged, Before: "Учеба", After: "Учеба и дом"})
+	assert.Contains(t, changes, services.TermsChange{Field: "task.points", Change: services.ChangeChanged, ItemID: "t1", Before: 10, After: 15})
+	assert.Contains(t, changes, services.TermsChange{Field: "task", Change: services.ChangeRemoved, ItemID: "t2", Before: from.Tasks[1]})
+	assert.Contains(t, changes, services.TermsChange{Field: "reward", Change: services.ChangeAdded, ItemID: "r2", After: to.Rewards[1]})
+	assert.Len(t, changes, 4)
+}
+
+func TestDiffTermsNoChanges(t *testing.T) {
+	assert.Empty(t, services.DiffTerms(baseTerms(), baseTerms()))
+}
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/config"
+	"github.com/soulfeelings/parents-children-contracts/backend/database"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBConn *gorm.DB
+	testDBErr  error
+
+	nameCounter int64
+)
+
+// testDB подключается к тестовой базе и применяет миграции один раз на
+// запуск. Если база не настроена или недоступна, тест пропускается.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	testDBOnce.Do(func() {
+		// Тесты запускаются из backend/tests, миграции лежат уровнем выше
+		if os.Getenv("MIGRATION_PATH") == "" {
+			path, err := filepath.Abs(filepath.Join("..", "migrations"))
+			if err != nil {
+				testDBErr = err
+				return
+			}
+			os.Setenv("MIGRATION_PATH", path)
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			testDBErr = err
+			return
+		}
+		db, err := database.Connect(cfg)
+		if err != nil {
+			testDBErr = err
+			return
+		}
+		if err := db.Exec("SELECT 1").Error; err != nil {
+			testDBErr = err
+			return
+		}
+		if err := database.RunMigrations(cfg); err != nil {
+			testDBErr = err
+			return
+		}
+		testDBConn = db
+	})
+	if testDBErr != nil {
+		t.Skipf("тестовая база данных недоступна: %v", testDBErr)
+	}
+	return testDBConn
+}
+
+// uniqueName возвращает уникальное имя, чтобы тесты не мешали друг другу
+// в общей базе
+func uniqueName(t *testing.T, prefix string) string {
+	t.Helper()
+	return fmt.Sprintf("%s_%d_%d", prefix, time.Now().UnixNano(), atomic.AddInt64(&nameCounter, 1))
+}
+
+// createUser создает пользователя с паролем password123
+func createUser(t *testing.T, db *gorm.DB, role string) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	username := uniqueName(t, role)
+	email := username + "@example.com"
+	user := models.User{
+		Username:  username,
+		Email:     &email,
+		Password:  string(hash),
+		Role:      role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, db.Create(&user).Error)
+	return user
+}
+
+// createFamily создает семью родителя и добавляет в нее детей
+func createFamily(t *testing.T, db *gorm.DB, parent models.User, children ...models.User) *models.Family {
+	t.Helper()
+	var family *models.Family
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		family, err = services.CreateFamily(tx, uniqueName(t, "family"), parent.ID)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := services.AddFamilyMember(tx, family.ID, child.ID, "child"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return family
+}
+
+// createContract создает контракт родителя с ребенком в статусе status
+func createContract(t *testing.T, db *gorm.DB, parent, child models.User, status string) models.Contract {
+	t.Helper()
+	now := time.Now()
+	contract := models.Contract{
+		Title:     uniqueName(t, "contract"),
+		ParentID:  parent.ID,
+		ChildID:   child.ID,
+		Status:    status,
+		StartDate: now.AddDate(0, 0, -7),
+		EndDate:   now.AddDate(0, 1, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if status == models.ContractActive {
+		contract.ActivatedAt = &now
+	}
+	require.NoError(t, db.Omit("Parent", "Child").Create(&contract).Error)
+	return contract
+}
+
+// createTask создает задачу контракта в статусе status
+func createTask(t *testing.T, db *gorm.DB, contract models.Contract, points int, status string, due time.Time) models.Task {
+	t.Helper()
+	task := models.Task{
+		Title:      uniqueName(t, "task"),
+		ContractID: contract.ID,
+		Status:     status,
+		DueDate:    due,
+		Points:     points,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	require.NoError(t, db.Omit("Contract").Create(&task).Error)
+	return task
+}
+
+// createReward создает доступную награду контракта
+func createReward(t *testing.T, db *gorm.DB, contract models.Contract, cost int) models.Reward {
+	t.Helper()
+	reward := models.Reward{
+		Title:      uniqueName(t, "reward"),
+		ContractID: contract.ID,
+		Status:     "available",
+		PointsCost: cost,
+		Timezone:   "UTC",
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	require.NoError(t, db.Omit("Contract").Create(&reward).Error)
+	return reward
+}
+
+// earn начисляет ребенку баллы по контракту ручной корректировкой
+func earn(t *testing.T, db *gorm.DB, contract models.Contract, amount int) {
+	t.Helper()
+	require.NoError(t, services.Post(db, &models.LedgerEntry{
+		ChildID:     contract.ChildID,
+		ContractID:  contract.ID,
+		Type:        models.LedgerAdjust,
+		Amount:      amount,
+		Description: "Начисление для теста",
+	}))
+}
+
+// openSession открывает сессию пользователя и возвращает пару токенов
+func openSession(t *testing.T, db *gorm.DB, user models.User, scope string) *services.TokenPair {
+	t.Helper()
+	tokens, err := services.IssueSession(db, &user, scope, services.SessionMeta{DeviceName: "test"})
+	require.NoError(t, err)
+	return tokens
+}
+
+// authToken открывает сессию с полным доступом и возвращает access-токен
+func authToken(t *testing.T, db *gorm.DB, user models.User) string {
+	t.Helper()
+	return openSession(t, db, user, "").AccessToken
+}
+
+// newAPIRouter создает роутер с группой /api без авторизации и группой
+// маршрутов, требующих access-токен
+func newAPIRouter(db *gorm.DB) (*gin.Engine, *gin.RouterGroup, *gin.RouterGroup) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api")
+	authorized := api.Group("")
+	authorized.Use(middleware.AuthMiddleware(db))
+	return router, api, authorized
+}
+
+// doJSON выполняет запрос к роутеру с JSON-телом и access-токеном
+func doJSON(router http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeJSON разбирает тело ответа
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), target), w.Body.String())
+}
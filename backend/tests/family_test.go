@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func addFamilyMember(t *testing.T, db *gorm.DB, family *models.Family, user models.User) {
+	t.Helper()
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return services.AddFamilyMember(tx, family.ID, user.ID, user.Role)
+	}))
+}
+
+func removeFamilyMember(t *testing.T, db *gorm.DB, family *models.Family, user models.User) {
+	t.Helper()
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return services.RemoveFamilyMember(tx, family.ID, user.ID)
+	}))
+}
+
+func TestFamilyGuardianship(t *testing.T) {
+	db := testDB(t)
+	owner := createUser(t, db, "parent")
+	guardian := createUser(t, db, "parent")
+	stranger := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, owner, child)
+	createFamily(t, db, stranger)
+	contract := createContract(t, db, owner, child, "active")
+
+	// Второй родитель становится опекуном всех детей семьи
+	addFamilyMember(t, db, family, guardian)
+	assert.True(t, services.IsGuardian(db, owner.ID, child.ID))
+	assert.True(t, services.IsGuardian(db, guardian.ID, child.ID))
+	assert.False(t, services.IsGuardian(db, stranger.ID, child.ID))
+
+	// Повторное добавление не дублирует связи
+	addFamilyMember(t, db, family, guardian)
+	var links int64
+	db.Model(&models.Guardianship{}).Where("parent_id = ? AND child_id = ?", guardian.ID, child.ID).Count(&links)
+	assert.Equal(t, int64(1), links)
+
+	familyHandlers := handlers.NewFamilyHandlers(db)
+	contractHandlers := handlers.NewContractHandlers(db)
+	router, _, authorized := newAPIRouter(db)
+	authorized.GET("/children", middleware.RoleMiddleware("parent"), familyHandlers.Children)
+	authorized.GET("/contracts/:id", contractHandlers.Get)
+	authorized.DELETE("/families/:id/members/:userId", familyHandlers.RemoveMember)
+
+	ownerToken := authToken(t, db, owner)
+	guardianToken := authToken(t, db, guardian)
+	strangerToken := authToken(t, db, stranger)
+
+	w := doJSON(router, http.MethodGet, "/api/contracts/"+contract.ID, guardianToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/children", guardianToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var children handlers.ChildrenResponse
+	decodeJSON(t, w, &children)
+	require.Len(t, children.Children, 1)
+	assert.Equal(t, child.ID, children.Children[0].Child.ID)
+	assert.Len(t, children.Children[0].Guardians, 2)
+
+	// Чужой родитель не видит ни контракт, ни ребенка
+	w = doJSON(router, http.MethodGet, "/api/contracts/"+contract.ID, strangerToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/children", strangerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &children)
+	assert.Empty(t, children.Children)
+
+	// Исключенный из семьи родитель теряет доступ
+	w = doJSON(router, http.MethodDelete, "/api/families/"+family.ID+"/members/"+guardian.ID, ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, services.IsGuardian(db, guardian.ID, child.ID))
+	assert.True(t, services.IsGuardian(db, owner.ID, child.ID))
+	w = doJSON(router, http.MethodGet, "/api/contracts/"+contract.ID, guardianToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFamilyRemoveChild(t *testing.T) {
+	db := testDB(t)
+	owner := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, owner, child)
+
+	var guarded []string
+	require.NoError(t, services.GuardedChildren(db, owner.ID).Pluck("child_id", &guarded).Error)
+	assert.Equal(t, []string{child.ID}, guarded)
+
+	// Ребенок не может сам покинуть семью
+	familyHandlers := handlers.NewFamilyHandlers(db)
+	router, _, authorized := newAPIRouter(db)
+	authorized.DELETE("/families/:id/members/:userId", familyHandlers.RemoveMember)
+	w := doJSON(router, http.MethodDelete, "/api/families/"+family.ID+"/members/"+child.ID, authToken(t, db, child), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.True(t, services.IsFamilyMember(db, family.ID, child.ID))
+	assert.True(t, services.IsGuardian(db, owner.ID, child.ID))
+
+	w = doJSON(router, http.MethodDelete, "/api/families/"+family.ID+"/members/"+child.ID, authToken(t, db, owner), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, services.IsFamilyMember(db, family.ID, child.ID))
+	assert.False(t, services.IsGuardian(db, owner.ID, child.ID))
+}
+
+func TestFamilyRemoveKeepsSharedGuardianship(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	coParent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	first := createFamily(t, db, parent, child)
+	second := createFamily(t, db, coParent, child)
+	addFamilyMember(t, db, second, parent)
+
+	// Связь родителя с ребенком записана за первой семьей, но ее
+	// подкрепляет и вторая
+	var link models.Guardianship
+	require.NoError(t, db.Where("parent_id = ? AND child_id = ?", parent.ID, child.ID).First(&link).Error)
+	require.Equal(t, first.ID, link.FamilyID)
+
+	removeFamilyMember(t, db, first, child)
+	require.NoError(t, db.First(&link, "id = ?", link.ID).Error)
+	assert.Equal(t, second.ID, link.FamilyID)
+	assert.True(t, services.IsGuardian(db, parent.ID, child.ID))
+	assert.True(t, services.IsGuardian(db, coParent.ID, child.ID))
+
+	// После выхода из последней общей семьи связь удаляется
+	removeFamilyMember(t, db, second, parent)
+	assert.False(t, services.IsGuardian(db, parent.ID, child.ID))
+	assert.True(t, services.IsGuardian(db, coParent.ID, child.ID))
+}
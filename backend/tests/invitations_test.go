@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupInvitationRouter(db *gorm.DB) *gin.Engine {
+	invitationHandlers := handlers.NewInvitationHandlers(db, "http://localhost:3000")
+	router, _, authorized := newAPIRouter(db)
+	authorized.POST("/families/:id/invitations", middleware.RoleMiddleware("parent"), invitationHandlers.Create)
+	invitations := authorized.Group("/invitations")
+	{
+		invitations.POST("/accept", invitationHandlers.Accept)
+		invitations.DELETE("/:id", middleware.RoleMiddleware("parent"), invitationHandlers.Revoke)
+	}
+	return router
+}
+
+func createInvitation(t *testing.T, router *gin.Engine, familyID, token, role string) models.FamilyInvitation {
+	t.Helper()
+	w := doJSON(router, http.MethodPost, "/api/families/"+familyID+"/invitations", token, map[string]string{"role": role})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response handlers.InvitationResponse
+	decodeJSON(t, w, &response)
+	return response.Invitation
+}
+
+func TestInvitationSingleUse(t *testing.T) {
+	db := testDB(t)
+	owner := createUser(t, db, "parent")
+	first := createUser(t, db, "parent")
+	second := createUser(t, db, "parent")
+	family := createFamily(t, db, owner)
+	router := setupInvitationRouter(db)
+
+	invitation := createInvitation(t, router, family.ID, authToken(t, db, owner), "parent")
+
+	firstToken := authToken(t, db, first)
+	w := doJSON(router, http.MethodPost, "/api/invitations/accept", firstToken, map[string]string{"code": invitation.Code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, services.IsFamilyMember(db, family.ID, first.ID))
+
+	// Принятое приглашение нельзя использовать ни повторно, ни другим пользователем
+	w = doJSON(router, http.MethodPost, "/api/invitations/accept", firstToken, map[string]string{"code": invitation.Code})
+	assert.Equal(t, http.StatusGone, w.Code)
+	w = doJSON(router, http.MethodPost, "/api/invitations/accept", authToken(t, db, second), map[string]string{"code": invitation.Code})
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.False(t, services.IsFamilyMember(db, family.ID, second.ID))
+
+	var members int64
+	db.Model(&models.FamilyMember{}).Where("family_id = ? AND user_id = ?", family.ID, first.ID).Count(&members)
+	assert.Equal(t, int64(1), members)
+}
+
+func TestInvitationRevoked(t *testing.T) {
+	db := testDB(t)
+	owner := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, owner)
+	router := setupInvitationRouter(db)
+
+	ownerToken := authToken(t, db, owner)
+	invitation := createInvitation(t, router, family.ID, ownerToken, "child")
+
+	w := doJSON(router, http.MethodDelete, "/api/invitations/"+invitation.ID, ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doJSON(router, http.MethodPost, "/api/invitations/accept", authToken(t, db, child), map[string]string{"code": invitation.Code})
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.False(t, services.IsFamilyMember(db, family.ID, child.ID))
+	assert.False(t, services.IsGuardian(db, owner.ID, child.ID))
+
+	// Отозванное приглашение нельзя отозвать повторно
+	w = doJSON(router, http.MethodDelete, "/api/invitations/"+invitation.ID, ownerToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestInvitationExpired(t *testing.T) {
+	db := testDB(t)
+	owner := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, owner)
+	router := setupInvitationRouter(db)
+
+	code, err := utils.RandomCode(10)
+	require.NoError(t, err)
+	invitation := models.FamilyInvitation{
+		FamilyID:  family.ID,
+		Code:      code,
+		Role:      "child",
+		InvitedBy: owner.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+		CreatedAt: time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, db.Omit("Family").Create(&invitation).Error)
+
+	w := doJSON(router, http.MethodPost, "/api/invitations/accept", authToken(t, db, child), map[string]string{"code": code})
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.False(t, services.IsFamilyMember(db, family.ID, child.ID))
+
+	var stored models.FamilyInvitation
+	require.NoError(t, db.First(&stored, "id = ?", invitation.ID).Error)
+	assert.Nil(t, stored.AcceptedAt)
+}
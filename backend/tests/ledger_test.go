@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func balanceOf(t *testing.T, db *gorm.DB, contract models.Contract) int {
+	t.Helper()
+	balance, err := services.Balance(db, contract.ChildID, contract.ID)
+	require.NoError(t, err)
+	return balance
+}
+
+func ledgerTypes(t *testing.T, db *gorm.DB, contract models.Contract) []string {
+	t.Helper()
+	var entries []models.LedgerEntry
+	require.NoError(t, db.Where("contract_id = ?", contract.ID).Order("created_at").Find(&entries).Error)
+	types := make([]string, len(entries))
+	for i, entry := range entries {
+		types[i] = entry.Type
+	}
+	return types
+}
+
+func TestLedgerEarnSpendRefund(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	task := createTask(t, db, contract, 10, models.TaskCompleted, time.Now().Add(time.Hour))
+	reward := createReward(t, db, contract, 6)
+
+	// Повторное начисление за ту же задачу ничего не добавляет
+	for i := 0; i < 2; i++ {
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			return services.AwardTask(tx, &task, child.ID, parent.ID)
+		}))
+	}
+	assert.Equal(t, 10, balanceOf(t, db, contract))
+
+	var claim *models.RewardClaim
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		claim, err = services.ClaimReward(tx, reward.ID, child.ID, "")
+		return err
+	}))
+	assert.Equal(t, 4, balanceOf(t, db, contract))
+
+	// Отклоненный запрос возвращает зарезервированные баллы
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.TransitionClaim(tx, claim.ID, services.ClaimReject, services.ActorParent, parent.ID, "Не сегодня")
+		return err
+	}))
+	assert.Equal(t, 10, balanceOf(t, db, contract))
+	assert.Equal(t, []string{models.LedgerEarn, models.LedgerSpend, models.LedgerRefund}, ledgerTypes(t, db, contract))
+}
+
+func TestLedgerInsufficientPoints(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	reward := createReward(t, db, contract, 50)
+	earn(t, db, contract, 20)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.ClaimReward(tx, reward.ID, child.ID, "")
+		return err
+	})
+	assert.ErrorIs(t, err, services.ErrInsufficientPoints)
+
+	// Транзакция откатывается целиком: награда доступна, запроса нет
+	var stored models.Reward
+	require.NoError(t, db.First(&stored, "id = ?", reward.ID).Error)
+	assert.Equal(t, models.RewardAvailable, stored.Status)
+	var claims int64
+	db.Model(&models.RewardClaim{}).Where("reward_id = ?", reward.ID).Count(&claims)
+	assert.Zero(t, claims)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.Adjust(tx, child.ID, contract.ID, -21, "Штраф", parent.ID)
+		return err
+	})
+	assert.ErrorIs(t, err, services.ErrInsufficientPoints)
+	assert.Equal(t, 20, balanceOf(t, db, contract))
+	assert.Equal(t, []string{models.LedgerAdjust}, ledgerTypes(t, db, contract))
+}
+
+func TestLedgerRevokeTask(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	task := createTask(t, db, contract, 15, models.TaskCompleted, time.Now().Add(time.Hour))
+
+	award := func() {
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			return services.AwardTask(tx, &task, child.ID, parent.ID)
+		}))
+	}
+	revoke := func() {
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			return services.RevokeTask(tx, &task, child.ID, parent.ID)
+		}))
+	}
+
+	award()
+	revoke()
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	points, err := services.TaskPoints(db, task.ID)
+	require.NoError(t, err)
+	assert.Zero(t, points)
+
+	// Повторная отмена ничего не списывает, повторное подтверждение
+	// снова начисляет баллы
+	revoke()
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	award()
+	assert.Equal(t, 15, balanceOf(t, db, contract))
+
+	var adjustments int64
+	db.Model(&models.LedgerEntry{}).Where("task_id = ? AND entry_type = ?", task.ID, models.LedgerAdjust).Count(&adjustments)
+	assert.Equal(t, int64(1), adjustments)
+}
+
+func TestLedgerAppendOnly(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	earn(t, db, contract, 5)
+
+	var entry models.LedgerEntry
+	require.NoError(t, db.Where("contract_id = ?", contract.ID).First(&entry).Error)
+
+	err := db.Model(&entry).Update("amount", 500).Error
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "append-only")
+
+	err = db.Delete(&entry).Error
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "append-only")
+
+	assert.Equal(t, 5, balanceOf(t, db, contract))
+}
+
+func TestLedgerGuardianAccess(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	stranger := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	createFamily(t, db, stranger)
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	earn(t, db, contract, 30)
+
+	ledgerHandlers := handlers.NewLedgerHandlers(db)
+	router, _, authorized := newAPIRouter(db)
+	authorized.GET("/children/:id/balance", ledgerHandlers.Balance)
+	authorized.POST("/ledger/adjustments", ledgerHandlers.Adjust)
+
+	adjustment := map[string]interface{}{"contract_id": contract.ID, "amount": -10, "description": "Штраф"}
+
+	// Чужой родитель не видит ребенка и не может менять его баланс
+	strangerToken := authToken(t, db, stranger)
+	w := doJSON(router, http.MethodGet, "/api/children/"+child.ID+"/balance", strangerToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doJSON(router, http.MethodPost, "/api/ledger/adjustments", strangerToken, adjustment)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 30, balanceOf(t, db, contract))
+
+	// Ребенок видит только свой баланс
+	w = doJSON(router, http.MethodGet, "/api/children/"+child.ID+"/balance", authToken(t, db, createUser(t, db, "child")), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	parentToken := authToken(t, db, parent)
+	w = doJSON(router, http.MethodPost, "/api/ledger/adjustments", parentToken, adjustment)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created handlers.LedgerEntryResponse
+	decodeJSON(t, w, &created)
+	assert.Equal(t, 20, created.Balance)
+
+	w = doJSON(router, http.MethodGet, "/api/children/"+child.ID+"/balance", parentToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var balance handlers.BalanceResponse
+	decodeJSON(t, w, &balance)
+	assert.Equal(t, 20, balance.Total)
+}
+
+func TestClaimRewardAfterAmendment(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	task := createTask(t, db, contract, 10, models.TaskPending, time.Now().AddDate(0, 0, 3))
+	reward := createReward(t, db, contract, 30)
+	earn(t, db, contract, 100)
+
+	// Изменение условий возвращает контракт на подпись ребенку
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Update("points", 20).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, parent.ID, "127.0.0.1", "Изменены баллы задачи")
+	}))
+
+	// До подписи новых условий награду получить нельзя
+	claim := func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			_, err := services.ClaimReward(tx, reward.ID, child.ID, "")
+			return err
+		})
+	}
+	assert.ErrorIs(t, claim(), services.ErrContractNotActionable)
+	var claims int64
+	db.Model(&models.RewardClaim{}).Where("reward_id = ?", reward.ID).Count(&claims)
+	assert.Zero(t, claims)
+	assert.Equal(t, 100, balanceOf(t, db, contract))
+
+	// После подписи ребенка награду можно запросить
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", contract.ID).Error; err != nil {
+			return err
+		}
+		return services.SignContract(tx, &locked, child.ID, services.SignerChild, "127.0.0.1")
+	}))
+	require.NoError(t, claim())
+	assert.Equal(t, 70, balanceOf(t, db, contract))
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func setupPinLoginRouter(db *gorm.DB) *gin.Engine {
+	authHandlers := handlers.NewAuthHandlers(db)
+	familyHandlers := handlers.NewFamilyHandlers(db)
+	invitationHandlers := handlers.NewInvitationHandlers(db, "http://localhost:3000")
+	settingsHandlers := handlers.NewSettingsHandlers(db)
+
+	router, api, authorized := newAPIRouter(db)
+	api.POST("/auth/pin-login", authHandlers.PinLogin)
+
+	families := authorized.Group("/families")
+	families.Use(middleware.FullAccessMiddleware())
+	families.GET("/", familyHandlers.List)
+
+	invitations := authorized.Group("/invitations")
+	invitations.Use(middleware.FullAccessMiddleware())
+	invitations.POST("/accept", invitationHandlers.Accept)
+
+	settings := authorized.Group("/settings")
+	settings.Use(middleware.FullAccessMiddleware())
+	settings.GET("/profile", settingsHandlers.GetProfile)
+
+	return router
+}
+
+// createPinChild создает ребенка с PIN-кодом в семье родителя
+func createPinChild(t *testing.T, db *gorm.DB, pin string) (models.User, *models.Family) {
+	t.Helper()
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&child).Update("pin_hash", string(hash)).Error)
+	return child, createFamily(t, db, parent, child)
+}
+
+func pinLogin(router *gin.Engine, family *models.Family, child models.User, pin string) int {
+	w := doJSON(router, http.MethodPost, "/api/auth/pin-login", "", map[string]string{
+		"family_code": family.Code,
+		"username":    child.Username,
+		"pin":         pin,
+	})
+	return w.Code
+}
+
+func TestPinLoginLockout(t *testing.T) {
+	db := testDB(t)
+	router := setupPinLoginRouter(db)
+	child, family := createPinChild(t, db, "1234")
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, pinLogin(router, family, child, "0000"))
+	}
+	// Пятая неудачная попытка блокирует вход
+	assert.Equal(t, http.StatusTooManyRequests, pinLogin(router, family, child, "0000"))
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", child.ID).Error)
+	require.NotNil(t, stored.PinLockedUntil)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *stored.PinLockedUntil, time.Minute)
+
+	// Во время блокировки не проходит даже верный PIN-код
+	assert.Equal(t, http.StatusTooManyRequests, pinLogin(router, family, child, "1234"))
+
+	// После окончания блокировки верный PIN-код снова работает
+	require.NoError(t, db.Model(&stored).Update("pin_locked_until", time.Now().Add(-time.Second)).Error)
+	assert.Equal(t, http.StatusOK, pinLogin(router, family, child, "1234"))
+	require.NoError(t, db.First(&stored, "id = ?", child.ID).Error)
+	assert.Nil(t, stored.PinLockedUntil)
+	assert.Zero(t, stored.FailedPinAttempts)
+}
+
+func TestPinLoginResetsAttempts(t *testing.T) {
+	db := testDB(t)
+	router := setupPinLoginRouter(db)
+	child, family := createPinChild(t, db, "4321")
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, pinLogin(router, family, child, "0000"))
+	}
+	assert.Equal(t, http.StatusOK, pinLogin(router, family, child, "4321"))
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", child.ID).Error)
+	assert.Zero(t, stored.FailedPinAttempts)
+
+	// Счетчик начался заново: четыре ошибки подряд еще не блокируют вход
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, pinLogin(router, family, child, "0000"))
+	}
+	assert.Equal(t, http.StatusOK, pinLogin(router, family, child, "4321"))
+}
+
+func TestPinScopeRestricted(t *testing.T) {
+	db := testDB(t)
+	router := setupPinLoginRouter(db)
+	child, family := createPinChild(t, db, "1111")
+
+	w := doJSON(router, http.MethodPost, "/api/auth/pin-login", "", map[string]string{
+		"family_code": family.Code,
+		"username":    child.Username,
+		"pin":         "1111",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response handlers.AuthResponse
+	decodeJSON(t, w, &response)
+
+	claims, err := utils.ValidateToken(response.Token)
+	require.NoError(t, err)
+	assert.Equal(t, utils.ScopeChild, claims.Scope)
+
+	requests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodGet, "/api/families/", nil},
+		{http.MethodPost, "/api/invitations/accept", map[string]string{"code": "ABCDEFGHJK"}},
+		{http.MethodGet, "/api/settings/profile", nil},
+	}
+	for _, r := range requests {
+		w := doJSON(router, r.method, r.path, response.Token, r.body)
+		assert.Equal(t, http.StatusForbidden, w.Code, r.path)
+	}
+
+	// Тот же ребенок с полным доступом проходит проверку области
+	fullToken := authToken(t, db, child)
+	w = doJSON(router, http.MethodGet, "/api/families/", fullToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
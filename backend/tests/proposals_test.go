@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposalWithdraw(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, "")
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+
+	proposalHandlers := handlers.NewProposalHandlers(f.db)
+	router, _, authorized := newAPIRouter(f.db)
+	authorized.POST("/contracts/:id/proposals", proposalHandlers.Create)
+	authorized.POST("/proposals/:id/withdraw", proposalHandlers.Withdraw)
+
+	request := handlers.CreateProposalRequest{
+		Message: "Хочу больше баллов за задачу",
+		Changes: []services.ProposalChange{{Type: services.ProposeTaskPoints, TaskID: f.task.ID, Points: 20}},
+	}
+	w := doJSON(router, http.MethodPost, "/api/contracts/"+contract.ID+"/proposals", f.childToken, request)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response handlers.ProposalResponse
+	decodeJSON(t, w, &response)
+	proposal := response.Proposal
+	path := "/api/proposals/" + proposal.ID + "/withdraw"
+
+	// Пока предложение открыто, второе создать нельзя
+	w = doJSON(router, http.MethodPost, "/api/contracts/"+contract.ID+"/proposals", f.childToken, request)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Отозвать предложение может только его автор
+	w = doJSON(router, http.MethodPost, path, f.parentToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(router, http.MethodPost, path, f.childToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var stored models.ContractProposal
+	require.NoError(t, f.db.First(&stored, "id = ?", proposal.ID).Error)
+	assert.Equal(t, models.ProposalWithdrawn, stored.Status)
+	require.NotNil(t, stored.ResolvedBy)
+	assert.Equal(t, f.contract.ChildID, *stored.ResolvedBy)
+	assert.NotNil(t, stored.ResolvedAt)
+
+	// Отозванное предложение не меняет условия и не отзывается повторно
+	var task models.Task
+	require.NoError(t, f.db.First(&task, "id = ?", f.task.ID).Error)
+	assert.Equal(t, 10, task.Points)
+	assert.Equal(t, 1, f.reload(t).CurrentVersion)
+	w = doJSON(router, http.MethodPost, path, f.childToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// После отзыва можно сделать новое предложение
+	w = doJSON(router, http.MethodPost, "/api/contracts/"+contract.ID+"/proposals", f.childToken, request)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestProposalAcceptFromGuardian(t *testing.T) {
+	f := newContractSigningFixture(t)
+	contract := f.propose(t, "")
+	require.Equal(t, http.StatusOK, f.sign(f.childToken, contract.TermsHash))
+
+	proposalHandlers := handlers.NewProposalHandlers(f.db)
+	router, _, authorized := newAPIRouter(f.db)
+	authorized.POST("/contracts/:id/proposals", proposalHandlers.Create)
+	authorized.POST("/proposals/:id/accept", proposalHandlers.Accept)
+
+	request := handlers.CreateProposalRequest{
+		Changes: []services.ProposalChange{{Type: services.ProposeTaskPoints, TaskID: f.task.ID, Points: 20}},
+	}
+	w := doJSON(router, http.MethodPost, "/api/contracts/"+contract.ID+"/proposals", f.guardianToken, request)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response handlers.ProposalResponse
+	decodeJSON(t, w, &response)
+
+	w = doJSON(router, http.MethodPost, "/api/proposals/"+response.Proposal.ID+"/accept", f.childToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Опекун, не являющийся стороной контракта, за родителя не подписывает
+	contract = f.reload(t)
+	assert.Equal(t, models.ContractPendingSignature, contract.Status)
+	assert.Equal(t, []string{services.SignerParent}, f.missing(t))
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Ежедневно", rule: "FREQ=DAILY", expected: "FREQ=DAILY"},
+		{name: "Псевдоним будней", rule: "weekdays", expected: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{name: "Префикс RRULE", rule: "RRULE:FREQ=DAILY;INTERVAL=3", expected: "FREQ=DAILY;INTERVAL=3"},
+		{name: "Дни недели по порядку", rule: "FREQ=WEEKLY;BYDAY=SU,MO", expected: "FREQ=WEEKLY;BYDAY=MO,SU"},
+		{name: "Ежемесячно", rule: "FREQ=MONTHLY;BYMONTHDAY=1,-1", expected: "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{name: "Окончание", rule: "FREQ=DAILY;UNTIL=20240131", expected: "FREQ=DAILY;UNTIL=20240131"},
+		{name: "Без частоты", rule: "INTERVAL=2", wantErr: true},
+		{name: "Неизвестная частота", rule: "FREQ=HOURLY", wantErr: true},
+		{name: "Неизвестный день", rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		{name: "День месяца для недели", rule: "FREQ=WEEKLY;BYMONTHDAY=1", wantErr: true},
+		{name: "COUNT и UNTIL вместе", rule: "FREQ=DAILY;COUNT=3;UNTIL=20240131", wantErr: true},
+		{name: "Нулевой интервал", rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := services.ParseRule(tt.rule)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, services.ErrInvalidRule)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rule.String())
+		})
+	}
+}
+
+func TestRuleOccurrences(t *testing.T) {
+	// 1 января 2024 года - понедельник
+	start := day(2024, 1, 1)
+
+	tests := []struct {
+		name     string
+		rule     string
+		from, to time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "Каждые три дня",
+			rule:     "FREQ=DAILY;INTERVAL=3",
+			from:     start,
+			to:       day(2024, 1, 10),
+			expected: []time.Time{day(2024, 1, 1), day(2024, 1, 4), day(2024, 1, 7), day(2024, 1, 10)},
+		},
+		{
+			name:     "Будни со сдвигом начала периода",
+			rule:     "weekdays",
+			from:     day(2024, 1, 5),
+			to:       day(2024, 1, 9),
+			expected: []time.Time{day(2024, 1, 5), day(2024, 1, 8), day(2024, 1, 9)},
+		},
+		{
+			name:     "Раз в две недели по средам",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=WE",
+			from:     start,
+			to:       day(2024, 1, 31),
+			expected: []time.Time{day(2024, 1, 3), day(2024, 1, 17), day(2024, 1, 31)},
+		},
+		{
+			name:     "Последний день месяца",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1",
+			from:     start,
+			to:       day(2024, 3, 31),
+			expected: []time.Time{day(2024, 1, 31), day(2024, 2, 29), day(2024, 3, 31)},
+		},
+		{
+			name:     "Ограничение по количеству считается от начала серии",
+			rule:     "FREQ=DAILY;COUNT=3",
+			from:     day(2024, 1, 2),
+			to:       day(2024, 1, 10),
+			expected: []time.Time{day(2024, 1, 2), day(2024, 1, 3)},
+		},
+		{
+			name:     "Дата окончания правила",
+			rule:     "FREQ=DAILY;UNTIL=20240102",
+			from:     start,
+			to:       day(2024, 1, 10),
+			expected: []time.Time{day(2024, 1, 1), day(2024, 1, 2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := services.ParseRule(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rule.Occurrences(start, tt.from, tt.to))
+		})
+	}
+}
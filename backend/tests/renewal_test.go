@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/scheduler"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestNextPeriod(t *testing.T) {
+	contract := &models.Contract{
+		StartDate: time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 9, 9, 0, 0, 0, 0, time.UTC),
+	}
+
+	start, end := services.NextPeriod(contract, time.Time{})
+	assert.Equal(t, contract.EndDate, start)
+	assert.Equal(t, time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC), end)
+
+	// Новый период может начаться позже, длительность сохраняется
+	start, end = services.NextPeriod(contract, time.Date(2024, 9, 23, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 9, 23, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), end)
+}
+
+// renew продлевает контракт от имени actorID
+func renew(t *testing.T, db *gorm.DB, contract models.Contract, actorID string, opts services.RenewOptions) (*models.Contract, error) {
+	t.Helper()
+	var successor *models.Contract
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		successor, err = services.RenewContract(tx, contract.ID, actorID, "127.0.0.1", opts)
+		return err
+	})
+	return successor, err
+}
+
+func TestRenewContract(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	task := createTask(t, db, contract, 5, models.TaskCompleted, contract.StartDate.AddDate(0, 0, 1))
+	reward := createReward(t, db, contract, 10)
+	require.NoError(t, db.Model(&reward).Update("status", models.RewardClaimed).Error)
+
+	successor, err := renew(t, db, contract, contract.ParentID, services.RenewOptions{})
+	require.NoError(t, err)
+
+	// Новый контракт начинается с окончания текущего и связан с ним
+	assert.Equal(t, models.ContractDraft, successor.Status)
+	assert.True(t, successor.StartDate.Equal(contract.EndDate))
+	require.NotNil(t, successor.PredecessorID)
+	assert.Equal(t, contract.ID, *successor.PredecessorID)
+	var stored models.Contract
+	require.NoError(t, db.First(&stored, "id = ?", contract.ID).Error)
+	require.NotNil(t, stored.SuccessorID)
+	assert.Equal(t, successor.ID, *stored.SuccessorID)
+
+	// Задачи и награды копируются без отметок о выполнении и получении
+	var tasks []models.Task
+	require.NoError(t, db.Where("contract_id = ?", successor.ID).Find(&tasks).Error)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.Title, tasks[0].Title)
+	assert.Equal(t, models.TaskPending, tasks[0].Status)
+	assert.True(t, tasks[0].DueDate.Equal(task.DueDate.Add(contract.EndDate.Sub(contract.StartDate))))
+	var rewards []models.Reward
+	require.NoError(t, db.Where("contract_id = ?", successor.ID).Find(&rewards).Error)
+	require.Len(t, rewards, 1)
+	assert.Equal(t, models.RewardAvailable, rewards[0].Status)
+	assert.Equal(t, 10, rewards[0].PointsCost)
+
+	// Без переноса баллы остаются на старом контракте
+	assert.Equal(t, 0, balanceOf(t, db, *successor))
+
+	// Контракт продлевается только один раз
+	_, err = renew(t, db, contract, contract.ParentID, services.RenewOptions{})
+	assert.ErrorIs(t, err, services.ErrAlreadyRenewed)
+
+	// Черновик продлить нельзя
+	_, err = renew(t, db, *successor, contract.ParentID, services.RenewOptions{})
+	assert.ErrorIs(t, err, services.ErrNotRenewable)
+}
+
+func TestRenewContractCarryOver(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	earn(t, db, contract, 30)
+	reward := createReward(t, db, contract, 12)
+	var claim *models.RewardClaim
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		claim, err = services.ClaimReward(tx, reward.ID, contract.ChildID, "")
+		return err
+	}))
+	require.Equal(t, 18, balanceOf(t, db, contract))
+
+	successor, err := renew(t, db, contract, contract.ParentID, services.RenewOptions{CarryOver: true})
+	require.NoError(t, err)
+
+	// Непотраченный остаток переходит на новый контракт парой записей
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	assert.Equal(t, 18, balanceOf(t, db, *successor))
+	var entries []models.LedgerEntry
+	require.NoError(t, db.Where("entry_type = ? AND contract_id IN ?", models.LedgerCarryOver,
+		[]string{contract.ID, successor.ID}).Find(&entries).Error)
+	require.Len(t, entries, 2)
+	amounts := map[string]int{}
+	for _, entry := range entries {
+		amounts[entry.ContractID] = entry.Amount
+		require.NotNil(t, entry.CreatedBy)
+		assert.Equal(t, contract.ParentID, *entry.CreatedBy)
+	}
+	assert.Equal(t, -18, amounts[contract.ID])
+	assert.Equal(t, 18, amounts[successor.ID])
+
+	// Незавершенный запрос переходит на новый контракт, и баллы за
+	// отклоненный запрос возвращаются туда, а не на закрытый контракт
+	var stored models.RewardClaim
+	require.NoError(t, db.First(&stored, "id = ?", claim.ID).Error)
+	assert.Equal(t, successor.ID, stored.ContractID)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.TransitionClaim(tx, claim.ID, services.ClaimReject, services.ActorParent, contract.ParentID, "Не сегодня")
+		return err
+	}))
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	assert.Equal(t, 30, balanceOf(t, db, *successor))
+
+	// Нулевой остаток не переносится
+	require.NoError(t, db.Model(successor).Update("status", models.ContractActive).Error)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.Adjust(tx, contract.ChildID, successor.ID, -30, "Списание для теста", contract.ParentID)
+		return err
+	}))
+	next, err := renew(t, db, *successor, contract.ParentID, services.RenewOptions{CarryOver: true})
+	require.NoError(t, err)
+	assert.Equal(t, 0, balanceOf(t, db, *next))
+	var count int64
+	db.Model(&models.LedgerEntry{}).Where("contract_id = ?", next.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestRenewContractPropose(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	guardian := createUser(t, db, "parent")
+	createFamily(t, db, guardian, models.User{ID: contract.ChildID, Role: "child"})
+
+	// Продление, предложенное другим опекуном, родитель-автор подписывает сам
+	successor, err := renew(t, db, contract, guardian.ID, services.RenewOptions{Propose: true})
+	require.NoError(t, err)
+	assert.Equal(t, models.ContractPendingSignature, successor.Status)
+	missing, err := services.MissingSigners(db, successor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{services.SignerParent, services.SignerChild}, missing)
+
+	// Автоматическое продление предлагается от имени родителя-автора
+	other := newSchedulerContract(t, db)
+	successor, err = renew(t, db, other, "", services.RenewOptions{Propose: true})
+	require.NoError(t, err)
+	missing, err = services.MissingSigners(db, successor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{services.SignerChild}, missing)
+}
+
+func TestCompleteContractsAutoRenew(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+	contract := newSchedulerContract(t, db)
+	earn(t, db, contract, 7)
+	require.NoError(t, db.Model(&contract).Updates(map[string]interface{}{
+		"end_date":          now.Add(-time.Hour),
+		"auto_renew":        true,
+		"carry_over_points": true,
+	}).Error)
+
+	assert.Equal(t, int64(1), runJob(t, db, scheduler.CompleteContracts, now))
+
+	var stored models.Contract
+	require.NoError(t, db.First(&stored, "id = ?", contract.ID).Error)
+	assert.Equal(t, models.ContractCompleted, stored.Status)
+	require.NotNil(t, stored.SuccessorID)
+
+	var successor models.Contract
+	require.NoError(t, db.First(&successor, "id = ?", *stored.SuccessorID).Error)
+	assert.Equal(t, models.ContractPendingSignature, successor.Status)
+	assert.True(t, successor.AutoRenew)
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	assert.Equal(t, 7, balanceOf(t, db, successor))
+}
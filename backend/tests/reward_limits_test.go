@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPeriodStart(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// Четверг, 23:30 UTC - в Москве уже пятница
+	now := time.Date(2024, 5, 16, 23, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 5, 17, 0, 0, 0, 0, moscow), services.PeriodStart(now, models.PeriodDay, moscow))
+	assert.Equal(t, time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC), services.PeriodStart(now, models.PeriodDay, time.UTC))
+	assert.Equal(t, time.Date(2024, 5, 13, 0, 0, 0, 0, moscow), services.PeriodStart(now, models.PeriodWeek, moscow))
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, moscow), services.PeriodStart(now, models.PeriodMonth, moscow))
+}
+
+func TestEvaluateRewardLimits(t *testing.T) {
+	now := time.Date(2024, 5, 16, 12, 0, 0, 0, time.UTC)
+	stock := 3
+	reward := &models.Reward{
+		Repeatable:      true,
+		Stock:           &stock,
+		PeriodLimit:     2,
+		LimitPeriod:     models.PeriodWeek,
+		CooldownMinutes: 60,
+	}
+
+	availability, err := services.EvaluateRewardLimits(reward, &services.RewardUsage{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *availability.Remaining)
+	assert.Equal(t, 2, *availability.PeriodRemaining)
+	assert.Nil(t, availability.NextClaimAt)
+
+	// Пауза после недавнего запроса
+	last := now.Add(-20 * time.Minute)
+	availability, err = services.EvaluateRewardLimits(reward, &services.RewardUsage{Total: 1, InPeriod: 1, LastClaimAt: &last}, now)
+	assert.ErrorIs(t, err, services.ErrRewardCooldown)
+	assert.Equal(t, now.Add(40*time.Minute), *availability.NextClaimAt)
+
+	// Лимит за неделю исчерпан, хотя запас еще есть
+	last = now.Add(-2 * time.Hour)
+	availability, err = services.EvaluateRewardLimits(reward, &services.RewardUsage{Total: 2, InPeriod: 2, LastClaimAt: &last}, now)
+	assert.ErrorIs(t, err, services.ErrRewardLimitReached)
+	assert.Equal(t, 1, *availability.Remaining)
+	assert.Equal(t, 0, *availability.PeriodRemaining)
+
+	// Исчерпанный запас важнее остальных ограничений
+	_, err = services.EvaluateRewardLimits(reward, &services.RewardUsage{Total: 3, InPeriod: 2, LastClaimAt: &last}, now)
+	assert.ErrorIs(t, err, services.ErrRewardOutOfStock)
+
+	// Повторяемая награда без ограничений доступна всегда
+	availability, err = services.EvaluateRewardLimits(&models.Reward{Repeatable: true}, &services.RewardUsage{Total: 10, LastClaimAt: &last}, now)
+	require.NoError(t, err)
+	assert.Nil(t, availability.Remaining)
+	assert.Nil(t, availability.PeriodRemaining)
+}
+
+func TestValidateRewardLimits(t *testing.T) {
+	stock := 5
+	reward := &models.Reward{Repeatable: true, Stock: &stock, PeriodLimit: 1, LimitPeriod: models.PeriodDay}
+	require.NoError(t, services.ValidateRewardLimits(reward))
+	assert.NotEmpty(t, reward.Timezone)
+
+	// Лимит без периода и период без лимита бессмысленны
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{Repeatable: true, PeriodLimit: 1}), services.ErrInvalidRewardLimits)
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{Repeatable: true, LimitPeriod: models.PeriodWeek}), services.ErrInvalidRewardLimits)
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{Repeatable: true, PeriodLimit: 1, LimitPeriod: "year"}), services.ErrInvalidRewardLimits)
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{Repeatable: true, Timezone: "Mars/Olympus"}), services.ErrInvalidRewardLimits)
+
+	// Ограничения есть только у повторяемых наград
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{CooldownMinutes: 30}), services.ErrInvalidRewardLimits)
+	assert.ErrorIs(t, services.ValidateRewardLimits(&models.Reward{Stock: &stock}), services.ErrInvalidRewardLimits)
+	assert.NoError(t, services.ValidateRewardLimits(&models.Reward{}))
+}
+
+func TestLoadAvailability(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	earn(t, db, contract, 100)
+
+	stock := 3
+	limited := createReward(t, db, contract, 5)
+	require.NoError(t, db.Model(&limited).Updates(map[string]interface{}{"repeatable": true, "stock": stock}).Error)
+	periodic := createReward(t, db, contract, 5)
+	require.NoError(t, db.Model(&periodic).Updates(map[string]interface{}{
+		"repeatable": true, "period_limit": 2, "limit_period": models.PeriodDay,
+	}).Error)
+	single := createReward(t, db, contract, 5)
+
+	for _, id := range []string{limited.ID, limited.ID, periodic.ID} {
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			_, err := services.ClaimReward(tx, id, contract.ChildID, "")
+			return err
+		}))
+	}
+
+	var rewards []models.Reward
+	require.NoError(t, db.Where("id IN ?", []string{limited.ID, periodic.ID, single.ID}).Find(&rewards).Error)
+	require.NoError(t, services.LoadAvailability(db, rewards, time.Now()))
+
+	// Остаток каждой награды считается по ее собственным запросам
+	byID := map[string]models.Reward{}
+	for _, reward := range rewards {
+		byID[reward.ID] = reward
+	}
+	require.NotNil(t, byID[limited.ID].Availability)
+	assert.Equal(t, 2, byID[limited.ID].Availability.Claimed)
+	require.NotNil(t, byID[limited.ID].Availability.Remaining)
+	assert.Equal(t, 1, *byID[limited.ID].Availability.Remaining)
+	require.NotNil(t, byID[periodic.ID].Availability)
+	assert.Equal(t, 1, byID[periodic.ID].Availability.PeriodClaimed)
+	require.NotNil(t, byID[periodic.ID].Availability.PeriodRemaining)
+	assert.Equal(t, 1, *byID[periodic.ID].Availability.PeriodRemaining)
+	assert.Nil(t, byID[single.ID].Availability)
+}
@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ruleWith(trigger, scope, action string, points int, conditions ...services.RuleCondition) *models.ContractRule {
+	data, _ := json.Marshal(conditions)
+	return &models.ContractRule{
+		ID:         "rule-1",
+		Name:       "Правило",
+		Trigger:    trigger,
+		Scope:      scope,
+		Conditions: models.JSON(data),
+		Action:     action,
+		Points:     points,
+		Timezone:   "UTC",
+		Enabled:    true,
+	}
+}
+
+func at(day, hour int) time.Time {
+	return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC)
+}
+
+// Контракт с понедельника 4 марта до конца месяца
+func rulesContract() *models.Contract {
+	return &models.Contract{ID: "contract-1", ChildID: "child-1", StartDate: at(4, 0), EndDate: at(31, 0)}
+}
+
+func TestValidateRule(t *testing.T) {
+	rewardID := "reward-1"
+	late := services.RuleCondition{Field: services.FactTaskDaysLate, Op: "gt", Value: 2}
+
+	rule := ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleDeduct, 5)
+	rule.Timezone = ""
+	assert.NoError(t, services.ValidateRule(rule, []services.RuleCondition{late}))
+	assert.Equal(t, "UTC", rule.Timezone)
+
+	// Штраф без баллов и открытие награды без награды
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleDeduct, 0), nil),
+		services.ErrInvalidContractRule)
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleUnlockReward, 0), nil),
+		services.ErrInvalidContractRule)
+	unlock := ruleWith(models.RuleTaskCompleted, models.PeriodWeek, models.RuleUnlockReward, 0)
+	unlock.RewardID = &rewardID
+	assert.NoError(t, services.ValidateRule(unlock, nil))
+
+	// Итоги периода не относятся к задаче и не знают момента события
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleSchedule, models.RuleScopeTask, models.RuleAward, 10), nil),
+		services.ErrInvalidContractRule)
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleSchedule, models.PeriodWeek, models.RuleAward, 10), []services.RuleCondition{late}),
+		services.ErrInvalidContractRule)
+
+	// Неизвестные показатели, операции и события
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleAward, 10),
+		[]services.RuleCondition{{Field: "task.color", Op: "eq"}}), services.ErrInvalidContractRule)
+	assert.ErrorIs(t, services.ValidateRule(ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleAward, 10),
+		[]services.RuleCondition{{Field: services.FactTaskPoints, Op: "like"}}), services.ErrInvalidContractRule)
+	assert.ErrorIs(t, services.ValidateRule(ruleWith("task_deleted", models.RuleScopeTask, models.RuleAward, 10), nil),
+		services.ErrInvalidContractRule)
+}
+
+func TestRuleFacts(t *testing.T) {
+	done := at(6, 12)
+	tasks := []services.TaskSnapshot{
+		{ID: "t1", Status: models.TaskCompleted, DueDate: at(5, 10), Points: 10, DoneAt: &done},
+		{ID: "t2", Status: models.TaskPending, DueDate: at(7, 10), Points: 20},
+		{ID: "t3", Status: models.TaskFailed, DueDate: at(8, 10), Points: 5},
+		{ID: "t4", Status: models.TaskCancelled, DueDate: at(8, 10), Points: 5},
+		// Следующая неделя
+		{ID: "t5", Status: models.TaskCompleted, DueDate: at(12, 10), Points: 30},
+	}
+
+	// Четверг, 7 марта
+	event := services.RuleEvent{Trigger: models.RuleTaskCompleted, At: at(7, 15), Task: &tasks[0]}
+	facts := services.RuleFacts(event, tasks, at(4, 0), at(11, 0), time.UTC)
+	assert.Equal(t, 10, facts[services.FactTaskPoints])
+	assert.Equal(t, 1, facts[services.FactTaskDaysLate])
+	assert.Equal(t, 3, facts[services.FactTasksTotal])
+	assert.Equal(t, 1, facts[services.FactTasksCompleted])
+	assert.Equal(t, 1, facts[services.FactTasksOpen])
+	assert.Equal(t, 1, facts[services.FactTasksFailed])
+	assert.Equal(t, 1, facts[services.FactTasksLate])
+	assert.Equal(t, 33, facts[services.FactTasksCompletionPercent])
+	assert.Equal(t, 10, facts[services.FactTasksPointsEarned])
+	assert.Equal(t, 4, facts[services.FactEventWeekday])
+	assert.Equal(t, 15, facts[services.FactEventHour])
+
+	// Без границ учитывается весь контракт
+	facts = services.RuleFacts(event, tasks, time.Time{}, time.Time{}, time.UTC)
+	assert.Equal(t, 4, facts[services.FactTasksTotal])
+	assert.Equal(t, 40, facts[services.FactTasksPointsEarned])
+}
+
+func TestDaysLate(t *testing.T) {
+	task := services.TaskSnapshot{DueDate: at(5, 10)}
+	assert.Equal(t, -1, services.DaysLate(&task, at(5, 9)))
+	assert.Equal(t, 0, services.DaysLate(&task, at(6, 9)))
+	assert.Equal(t, 2, services.DaysLate(&task, at(7, 11)))
+
+	// Просрочка отправленной задачи считается на момент отправки
+	submitted := at(6, 11)
+	task.DoneAt = &submitted
+	assert.Equal(t, 1, services.DaysLate(&task, at(20, 0)))
+}
+
+func TestMatchConditions(t *testing.T) {
+	facts := map[string]int{services.FactTasksOpen: 0, services.FactEventWeekday: 4}
+
+	matched, err := services.MatchConditions([]services.RuleCondition{
+		{Field: services.FactTasksOpen, Op: "eq", Value: 0},
+		{Field: services.FactEventWeekday, Op: "lt", Value: 5},
+	}, facts)
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = services.MatchConditions([]services.RuleCondition{
+		{Field: services.FactEventWeekday, Op: "gte", Value: 5},
+	}, facts)
+	require.NoError(t, err)
+	assert.False(t, matched)
+
+	// Правило без условий срабатывает на каждом событии
+	matched, err = services.MatchConditions(nil, facts)
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	// Показателя задачи нет у итогов периода
+	matched, err = services.MatchConditions([]services.RuleCondition{
+		{Field: services.FactTaskPoints, Op: "gt", Value: 0},
+	}, facts)
+	require.NoError(t, err)
+	assert.False(t, matched)
+
+	_, err = services.MatchConditions([]services.RuleCondition{{Field: "task.color", Op: "eq"}}, facts)
+	assert.ErrorIs(t, err, services.ErrInvalidContractRule)
+}
+
+func TestSnapshotAt(t *testing.T) {
+	history := []services.TaskHistory{
+		{ID: "t1", DueDate: at(6, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(5, 18), Status: models.TaskSubmitted},
+			{At: at(6, 20), Status: models.TaskCompleted},
+		}},
+		{ID: "t2", DueDate: at(20, 10), Points: 10, CreatedAt: at(10, 0)},
+	}
+
+	snapshot := services.SnapshotAt(history, at(5, 12))
+	require.Len(t, snapshot, 1)
+	assert.Equal(t, models.TaskPending, snapshot[0].Status)
+	assert.Nil(t, snapshot[0].DoneAt)
+
+	// Подтвержденная задача выполнена в момент отправки, а не проверки
+	snapshot = services.SnapshotAt(history, at(12, 0))
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, models.TaskCompleted, snapshot[0].Status)
+	require.NotNil(t, snapshot[0].DoneAt)
+	assert.Equal(t, at(5, 18), *snapshot[0].DoneAt)
+	assert.Equal(t, models.TaskPending, snapshot[1].Status)
+}
+
+func TestTaskTrigger(t *testing.T) {
+	assert.Equal(t, models.RuleTaskCompleted, services.TaskTrigger(models.TaskCompleted))
+	assert.Equal(t, models.RuleTaskFailed, services.TaskTrigger(models.TaskFailed))
+	assert.Empty(t, services.TaskTrigger(models.TaskPending))
+}
+
+func rulesHistory() []services.TaskHistory {
+	return []services.TaskHistory{
+		// Выполнена вовремя во вторник
+		{ID: "t1", DueDate: at(6, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(5, 12), Status: models.TaskCompleted},
+		}},
+		// Отправлена на три дня позже срока
+		{ID: "t2", DueDate: at(7, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(10, 12), Status: models.TaskSubmitted},
+			{At: at(10, 20), Status: models.TaskCompleted},
+		}},
+		// Не выполнена
+		{ID: "t3", DueDate: at(13, 10), Points: 10, CreatedAt: at(1, 0)},
+	}
+}
+
+func TestDryRunLatePenalty(t *testing.T) {
+	rule := ruleWith(models.RuleTaskCompleted, models.RuleScopeTask, models.RuleDeduct, 5,
+		services.RuleCondition{Field: services.FactTaskDaysLate, Op: "gt", Value: 2})
+
+	result, err := services.DryRunRule(rule, rulesContract(), rulesHistory(), at(20, 0))
+	require.NoError(t, err)
+	require.Len(t, result.Outcomes, 1)
+	assert.Equal(t, "t2", *result.Outcomes[0].TaskID)
+	assert.Equal(t, "task:t2", result.Outcomes[0].Key)
+	assert.Equal(t, -5, result.Outcomes[0].Points)
+	assert.Equal(t, -5, result.Points)
+}
+
+func TestDryRunOverdue(t *testing.T) {
+	// Просрочка проверяется раз в сутки, правило срабатывает по задаче один раз
+	rule := ruleWith(models.RuleTaskOverdue, models.RuleScopeTask, models.RuleDeduct, 3,
+		services.RuleCondition{Field: services.FactTaskDaysLate, Op: "gte", Value: 2})
+
+	result, err := services.DryRunRule(rule, rulesContract(), rulesHistory(), at(20, 0))
+	require.NoError(t, err)
+	require.Len(t, result.Outcomes, 2)
+	// Вторая задача была просрочена на двое суток до отправки
+	assert.Equal(t, "t2", *result.Outcomes[0].TaskID)
+	assert.Equal(t, at(9, 10).Add(time.Minute), result.Outcomes[0].At)
+	assert.Equal(t, "t3", *result.Outcomes[1].TaskID)
+	assert.Equal(t, -6, result.Points)
+}
+
+func TestDryRunWeeklySchedule(t *testing.T) {
+	// Бонус за неделю без проваленных и просроченных задач
+	rule := ruleWith(models.RuleSchedule, models.PeriodWeek, models.RuleAward, 20,
+		services.RuleCondition{Field: services.FactTasksTotal, Op: "gt", Value: 0},
+		services.RuleCondition{Field: services.FactTasksLate, Op: "eq", Value: 0},
+		services.RuleCondition{Field: services.FactTasksOpen, Op: "eq", Value: 0})
+
+	history := rulesHistory()[:1]
+	result, err := services.DryRunRule(rule, rulesContract(), history, at(20, 0))
+	require.NoError(t, err)
+	// Неделя с 11 марта без задач, неделя с 18 марта еще не закончилась
+	require.Len(t, result.Outcomes, 1)
+	assert.Equal(t, "week:2024-03-04", result.Outcomes[0].Key)
+	assert.Nil(t, result.Outcomes[0].TaskID)
+	assert.Equal(t, 20, result.Points)
+
+	// Опоздание во второй задаче лишает бонуса
+	result, err = services.DryRunRule(rule, rulesContract(), rulesHistory()[:2], at(20, 0))
+	require.NoError(t, err)
+	assert.Empty(t, result.Outcomes)
+}
+
+func TestDryRunAllTasksBeforeFriday(t *testing.T) {
+	// Все задачи недели выполнены до пятницы - бонус, не больше раза в неделю
+	rule := ruleWith(models.RuleTaskCompleted, models.PeriodWeek, models.RuleAward, 50,
+		services.RuleCondition{Field: services.FactTasksOpen, Op: "eq", Value: 0},
+		services.RuleCondition{Field: services.FactEventWeekday, Op: "lt", Value: 5})
+
+	history := []services.TaskHistory{
+		{ID: "t1", DueDate: at(6, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(5, 12), Status: models.TaskCompleted},
+		}},
+		{ID: "t2", DueDate: at(8, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(7, 12), Status: models.TaskCompleted},
+		}},
+		// На следующей неделе последняя задача выполнена в субботу
+		{ID: "t3", DueDate: at(16, 10), Points: 10, CreatedAt: at(1, 0), Changes: []services.StatusChange{
+			{At: at(16, 12), Status: models.TaskCompleted},
+		}},
+	}
+
+	result, err := services.DryRunRule(rule, rulesContract(), history, at(20, 0))
+	require.NoError(t, err)
+	require.Len(t, result.Outcomes, 1)
+	assert.Equal(t, at(7, 12), result.Outcomes[0].At)
+	assert.Equal(t, "t2", *result.Outcomes[0].TaskID)
+	assert.Equal(t, 50, result.Points)
+}
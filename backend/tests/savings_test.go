@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestGoalMachine(t *testing.T) {
+	m := services.GoalMachine
+
+	// Достижение цели отмечает только система при пополнении
+	_, err := m.Find(services.GoalReach, models.GoalActive, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+	transition, err := m.Find(services.GoalReach, models.GoalActive, services.ActorSystem)
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.GoalReached, transition.To)
+	}
+
+	// Награду достигнутой цели запрашивает ребенок
+	transition, err = m.Find(services.GoalClaim, models.GoalReached, services.ActorChild)
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.GoalClaimed, transition.To)
+	}
+	_, err = m.Find(services.GoalClaim, models.GoalReached, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+
+	// Закрыть открытую цель может и родитель, и ребенок
+	for _, role := range []string{services.ActorParent, services.ActorChild} {
+		transition, err = m.Find(services.GoalCancel, models.GoalReached, role)
+		if assert.NoError(t, err) {
+			assert.Equal(t, models.GoalCancelled, transition.To)
+		}
+	}
+	_, err = m.Find(services.GoalCancel, models.GoalClaimed, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+}
+
+func TestGoalTarget(t *testing.T) {
+	target := 300
+	goal := models.SavingsGoal{TargetPoints: &target}
+	assert.Equal(t, 300, services.GoalTarget(&goal))
+
+	// Цель-награда следует за текущей стоимостью награды
+	goal = models.SavingsGoal{Reward: &models.Reward{PointsCost: 120}}
+	assert.Equal(t, 120, services.GoalTarget(&goal))
+}
+
+func TestProjectGoal(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Не хватает 100 баллов при заработке 10 в день - 10 дней
+	progress := services.ProjectGoal(200, 50, 50, 10, nil, now)
+	assert.Equal(t, 25, progress.Percent)
+	require.NotNil(t, progress.ProjectedAt)
+	assert.Equal(t, now.AddDate(0, 0, 10), *progress.ProjectedAt)
+	assert.Nil(t, progress.OnTrack)
+
+	// Неполный день округляется вверх
+	progress = services.ProjectGoal(100, 0, 0, 3, nil, now)
+	require.NotNil(t, progress.ProjectedAt)
+	assert.Equal(t, now.AddDate(0, 0, 34), *progress.ProjectedAt)
+
+	// Доступного баланса уже хватает
+	progress = services.ProjectGoal(100, 40, 60, 0, nil, now)
+	require.NotNil(t, progress.ProjectedAt)
+	assert.Equal(t, now, *progress.ProjectedAt)
+
+	// Без заработка прогноза нет, и к сроку цель не успеть
+	deadline := now.AddDate(0, 0, 30)
+	progress = services.ProjectGoal(100, 10, 0, 0, &deadline, now)
+	assert.Nil(t, progress.ProjectedAt)
+	require.NotNil(t, progress.OnTrack)
+	assert.False(t, *progress.OnTrack)
+	assert.Equal(t, 0.0, progress.DailyRate)
+}
+
+func TestProjectGoalDeadline(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deadline := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	// Прогноз в день дедлайна укладывается в срок
+	progress := services.ProjectGoal(200, 100, 0, 10, &deadline, now)
+	require.NotNil(t, progress.OnTrack)
+	assert.True(t, *progress.OnTrack)
+
+	progress = services.ProjectGoal(200, 90, 0, 10, &deadline, now)
+	require.NotNil(t, progress.OnTrack)
+	assert.False(t, *progress.OnTrack)
+}
+
+func TestGoalAfterAmendment(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	task := createTask(t, db, contract, 10, models.TaskPending, time.Now().AddDate(0, 0, 3))
+	reward := createReward(t, db, contract, 30)
+	earn(t, db, contract, 100)
+
+	createGoal := func(rewardID *string, target *int) models.SavingsGoal {
+		goal := models.SavingsGoal{
+			ChildID:      child.ID,
+			ContractID:   contract.ID,
+			Title:        uniqueName(t, "goal"),
+			RewardID:     rewardID,
+			TargetPoints: target,
+			Status:       models.GoalActive,
+			CreatedBy:    child.ID,
+		}
+		require.NoError(t, db.Omit("Reward").Create(&goal).Error)
+		return goal
+	}
+	target := 50
+	rewardGoal := createGoal(&reward.ID, nil)
+	pointsGoal := createGoal(nil, &target)
+
+	// Пока награда занята, накопленная цель остается достигнутой
+	require.NoError(t, db.Model(&reward).Update("status", models.RewardClaimed).Error)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.DepositGoal(tx, rewardGoal.ID, 30, child.ID)
+		return err
+	}))
+	require.NoError(t, db.Model(&reward).Update("status", models.RewardAvailable).Error)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.DepositGoal(tx, pointsGoal.ID, 10, child.ID)
+		return err
+	}))
+
+	// Изменение условий возвращает контракт на подпись ребенку
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Update("points", 20).Error; err != nil {
+			return err
+		}
+		return services.AmendContract(tx, contract.ID, parent.ID, "127.0.0.1", "Изменены баллы задачи")
+	}))
+
+	// До подписи новых условий нельзя ни копить, ни получать награды
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.DepositGoal(tx, pointsGoal.ID, 10, child.ID)
+		return err
+	})
+	assert.ErrorIs(t, err, services.ErrContractNotActionable)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.ClaimGoal(tx, rewardGoal.ID, services.ActorChild, child.ID)
+		return err
+	})
+	assert.ErrorIs(t, err, services.ErrContractNotActionable)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := services.ClaimReward(tx, reward.ID, child.ID, "")
+		return err
+	})
+	assert.ErrorIs(t, err, services.ErrContractNotActionable)
+
+	var claims int64
+	db.Model(&models.RewardClaim{}).Where("reward_id = ?", reward.ID).Count(&claims)
+	assert.Zero(t, claims)
+	saved, err := services.GoalSaved(db, pointsGoal.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 10, saved)
+	assert.Equal(t, 60, balanceOf(t, db, contract))
+
+	// После подписи ребенка награду цели можно получить
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Contract
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", contract.ID).Error; err != nil {
+			return err
+		}
+		return services.SignContract(tx, &locked, child.ID, services.SignerChild, "127.0.0.1")
+	}))
+	var goal *models.SavingsGoal
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		goal, err = services.ClaimGoal(tx, rewardGoal.ID, services.ActorChild, child.ID)
+		return err
+	}))
+	assert.Equal(t, models.GoalClaimed, goal.Status)
+	assert.NotNil(t, goal.ClaimID)
+}
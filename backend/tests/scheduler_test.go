@@ -0,0 +1,296 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/scheduler"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// runJob выполняет задачу планировщика в транзакции, как это делает Scheduler
+func runJob(t *testing.T, db *gorm.DB, job func(tx *gorm.DB, now time.Time) (int64, error), now time.Time) int64 {
+	t.Helper()
+	var affected int64
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		affected, err = job(tx, now)
+		return err
+	}))
+	return affected
+}
+
+// newSchedulerContract создает действующий контракт ребенка в семье родителя
+func newSchedulerContract(t *testing.T, db *gorm.DB) models.Contract {
+	t.Helper()
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	return createContract(t, db, parent, child, models.ContractActive)
+}
+
+func taskStatus(t *testing.T, db *gorm.DB, id string) string {
+	t.Helper()
+	var task models.Task
+	require.NoError(t, db.First(&task, "id = ?", id).Error)
+	return task.Status
+}
+
+func transitionCount(db *gorm.DB, entity, id string) int64 {
+	var count int64
+	db.Model(&models.StateTransition{}).Where("entity_type = ? AND entity_id = ?", entity, id).Count(&count)
+	return count
+}
+
+// newTaskSeries создает ежедневную серию задач контракта
+func newTaskSeries(t *testing.T, db *gorm.DB, contract models.Contract, timezone string) models.TaskSeries {
+	t.Helper()
+	now := time.Now()
+	series := models.TaskSeries{
+		ContractID: contract.ID,
+		Title:      uniqueName(t, "series"),
+		Points:     3,
+		Rule:       "daily",
+		DueTime:    "18:00",
+		Timezone:   timezone,
+		StartsOn:   services.Date(now),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	require.NoError(t, db.Omit("Contract").Create(&series).Error)
+	return series
+}
+
+func TestFailOverdueTasksGracePeriod(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	now := time.Now()
+
+	overdue := createTask(t, db, contract, 5, models.TaskPending, now.Add(-3*time.Hour))
+	inGrace := createTask(t, db, contract, 5, models.TaskPending, now.Add(-30*time.Minute))
+	submitted := createTask(t, db, contract, 5, models.TaskSubmitted, now.Add(-3*time.Hour))
+	draft := createContract(t, db, createUser(t, db, "parent"), createUser(t, db, "child"), models.ContractDraft)
+	draftTask := createTask(t, db, draft, 5, models.TaskPending, now.Add(-3*time.Hour))
+
+	assert.Equal(t, int64(1), runJob(t, db, scheduler.FailOverdueTasks(time.Hour), now))
+
+	var failed models.Task
+	require.NoError(t, db.First(&failed, "id = ?", overdue.ID).Error)
+	assert.Equal(t, models.TaskFailed, failed.Status)
+	assert.NotNil(t, failed.FailedAt)
+	assert.Equal(t, int64(1), transitionCount(db, services.EntityTask, overdue.ID))
+
+	// Задачи в пределах льготного периода, на проверке и в черновике не трогаются
+	assert.Equal(t, models.TaskPending, taskStatus(t, db, inGrace.ID))
+	assert.Equal(t, models.TaskSubmitted, taskStatus(t, db, submitted.ID))
+	assert.Equal(t, models.TaskPending, taskStatus(t, db, draftTask.ID))
+	assert.Zero(t, transitionCount(db, services.EntityTask, inGrace.ID))
+
+	// Повторный запуск не проваливает задачу второй раз
+	assert.Zero(t, runJob(t, db, scheduler.FailOverdueTasks(time.Hour), now))
+	assert.Equal(t, int64(1), transitionCount(db, services.EntityTask, overdue.ID))
+
+	// По окончании льготного периода проваливается и вторая задача
+	runJob(t, db, scheduler.FailOverdueTasks(time.Hour), now.Add(time.Hour))
+	assert.Equal(t, models.TaskFailed, taskStatus(t, db, inGrace.ID))
+}
+
+func TestExpireRewards(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	now := time.Now()
+
+	expired := createReward(t, db, contract, 10)
+	active := createReward(t, db, contract, 10)
+	unlimited := createReward(t, db, contract, 10)
+	require.NoError(t, db.Model(&expired).Update("expiry_date", now.Add(-time.Hour)).Error)
+	require.NoError(t, db.Model(&active).Update("expiry_date", now.Add(time.Hour)).Error)
+
+	runJob(t, db, scheduler.ExpireRewards, now)
+
+	status := func(id string) string {
+		var reward models.Reward
+		require.NoError(t, db.First(&reward, "id = ?", id).Error)
+		return reward.Status
+	}
+	assert.Equal(t, models.RewardExpired, status(expired.ID))
+	assert.Equal(t, models.RewardAvailable, status(active.ID))
+	assert.Equal(t, models.RewardAvailable, status(unlimited.ID))
+	assert.Equal(t, int64(1), transitionCount(db, services.EntityReward, expired.ID))
+	assert.Zero(t, transitionCount(db, services.EntityReward, active.ID))
+}
+
+func TestCompleteContractsSkipsFailures(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+
+	ended := newSchedulerContract(t, db)
+	broken := newSchedulerContract(t, db)
+	running := newSchedulerContract(t, db)
+	for _, contract := range []models.Contract{ended, broken} {
+		require.NoError(t, db.Model(&contract).Update("end_date", now.Add(-time.Hour)).Error)
+	}
+
+	// Правило с неизвестным часовым поясом не дает подвести итоги контракта
+	rule := models.ContractRule{
+		ContractID: broken.ID,
+		Name:       "Итоги контракта",
+		Trigger:    models.RuleSchedule,
+		Scope:      models.RuleScopeContract,
+		Conditions: models.JSON("[]"),
+		Action:     models.RuleAward,
+		Points:     5,
+		Timezone:   "Invalid/Zone",
+		Enabled:    true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	require.NoError(t, db.Omit("Contract").Create(&rule).Error)
+	t.Cleanup(func() {
+		db.Model(&rule).Update("enabled", false)
+	})
+
+	runJob(t, db, scheduler.CompleteContracts, now)
+
+	status := func(id string) string {
+		var contract models.Contract
+		require.NoError(t, db.First(&contract, "id = ?", id).Error)
+		return contract.Status
+	}
+	var contract models.Contract
+	require.NoError(t, db.First(&contract, "id = ?", ended.ID).Error)
+	assert.Equal(t, models.ContractCompleted, contract.Status)
+	assert.NotNil(t, contract.CompletedAt)
+	assert.Equal(t, int64(1), transitionCount(db, services.EntityContract, ended.ID))
+	assert.Equal(t, models.ContractActive, status(running.ID))
+
+	// Изменения контракта с ошибкой откатываются целиком
+	assert.Equal(t, models.ContractActive, status(broken.ID))
+	assert.Zero(t, transitionCount(db, services.EntityContract, broken.ID))
+
+	// После исправления правила контракт завершается при следующем запуске
+	require.NoError(t, db.Model(&rule).Update("timezone", "UTC").Error)
+	runJob(t, db, scheduler.CompleteContracts, now)
+	assert.Equal(t, models.ContractCompleted, status(broken.ID))
+}
+
+func TestFailOverdueTasksSkipsFailures(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+
+	contract := newSchedulerContract(t, db)
+	brokenContract := newSchedulerContract(t, db)
+	overdue := createTask(t, db, contract, 5, models.TaskPending, now.Add(-3*time.Hour))
+	broken := createTask(t, db, brokenContract, 5, models.TaskPending, now.Add(-3*time.Hour))
+
+	// Правило с неизвестным часовым поясом не дает провалить задачу
+	rule := models.ContractRule{
+		ContractID: brokenContract.ID,
+		Name:       "Штраф за просрочку",
+		Trigger:    models.RuleTaskFailed,
+		Scope:      models.RuleScopeTask,
+		Conditions: models.JSON("[]"),
+		Action:     models.RuleDeduct,
+		Points:     2,
+		Timezone:   "Invalid/Zone",
+		Enabled:    true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	require.NoError(t, db.Omit("Contract").Create(&rule).Error)
+	t.Cleanup(func() {
+		db.Model(&rule).Update("enabled", false)
+	})
+
+	assert.Equal(t, int64(1), runJob(t, db, scheduler.FailOverdueTasks(time.Hour), now))
+	assert.Equal(t, models.TaskFailed, taskStatus(t, db, overdue.ID))
+
+	// Задача с ошибкой остается в работе и без записи в истории
+	assert.Equal(t, models.TaskPending, taskStatus(t, db, broken.ID))
+	assert.Zero(t, transitionCount(db, services.EntityTask, broken.ID))
+
+	require.NoError(t, db.Model(&rule).Update("timezone", "UTC").Error)
+	assert.Equal(t, int64(1), runJob(t, db, scheduler.FailOverdueTasks(time.Hour), now))
+	assert.Equal(t, models.TaskFailed, taskStatus(t, db, broken.ID))
+}
+
+func TestGenerateTaskSeries(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	now := time.Now()
+
+	series := newTaskSeries(t, db, contract, "UTC")
+	// Серия с неизвестным часовым поясом пропускается и не мешает остальным
+	broken := newTaskSeries(t, db, contract, "Invalid/Zone")
+
+	runJob(t, db, scheduler.GenerateTaskSeries, now)
+
+	var tasks []models.Task
+	require.NoError(t, db.Where("series_id = ?", series.ID).Order("due_date").Find(&tasks).Error)
+	horizon := services.Date(now.Add(services.SeriesHorizon))
+	require.NotEmpty(t, tasks)
+	for _, task := range tasks {
+		assert.Equal(t, 3, task.Points)
+		assert.Equal(t, models.TaskPending, task.Status)
+		assert.False(t, services.Date(task.DueDate).After(horizon))
+	}
+
+	var stored models.TaskSeries
+	require.NoError(t, db.First(&stored, "id = ?", series.ID).Error)
+	require.NotNil(t, stored.GeneratedUntil)
+	assert.Equal(t, horizon, services.Date(*stored.GeneratedUntil))
+
+	var count int64
+	db.Model(&models.Task{}).Where("series_id = ?", broken.ID).Count(&count)
+	assert.Zero(t, count)
+	require.NoError(t, db.First(&stored, "id = ?", broken.ID).Error)
+	assert.Nil(t, stored.GeneratedUntil)
+
+	// Повторный запуск в тот же день новых повторений не создает
+	runJob(t, db, scheduler.GenerateTaskSeries, now)
+	db.Model(&models.Task{}).Where("series_id = ?", series.ID).Count(&count)
+	assert.Equal(t, int64(len(tasks)), count)
+}
+
+func TestApplyScheduledRules(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	now := time.Now()
+
+	overdue := createTask(t, db, contract, 5, models.TaskPending, now.Add(-time.Hour))
+	createTask(t, db, contract, 5, models.TaskPending, now.Add(time.Hour))
+	rule := models.ContractRule{
+		ContractID: contract.ID,
+		Name:       "Напоминание о просрочке",
+		Trigger:    models.RuleTaskOverdue,
+		Scope:      models.RuleScopeTask,
+		Conditions: models.JSON("[]"),
+		Action:     models.RuleAward,
+		Points:     2,
+		Timezone:   "UTC",
+		Enabled:    true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	require.NoError(t, db.Omit("Contract").Create(&rule).Error)
+
+	runJob(t, db, services.ApplyScheduledRules, now)
+
+	var firings []models.RuleFiring
+	require.NoError(t, db.Where("rule_id = ?", rule.ID).Find(&firings).Error)
+	require.Len(t, firings, 1)
+	require.NotNil(t, firings[0].TaskID)
+	assert.Equal(t, overdue.ID, *firings[0].TaskID)
+	assert.Equal(t, 2, balanceOf(t, db, contract))
+
+	// Правило срабатывает по задаче один раз
+	runJob(t, db, services.ApplyScheduledRules, now)
+	var count int64
+	db.Model(&models.RuleFiring{}).Where("rule_id = ?", rule.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 2, balanceOf(t, db, contract))
+}
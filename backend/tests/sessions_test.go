@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupSessionRouter(db *gorm.DB) *gin.Engine {
+	authHandlers := handlers.NewAuthHandlers(db)
+	router, api, authorized := newAPIRouter(db)
+	api.POST("/auth/refresh", authHandlers.Refresh)
+	session := authorized.Group("/auth")
+	{
+		session.POST("/logout", authHandlers.Logout)
+		session.POST("/logout-all", authHandlers.LogoutAll)
+		session.GET("/me", authHandlers.Me)
+		session.GET("/sessions", authHandlers.Sessions)
+		session.DELETE("/sessions/:id", authHandlers.RevokeSession)
+	}
+	return router
+}
+
+func refresh(t *testing.T, router *gin.Engine, refreshToken string) (int, handlers.AuthResponse) {
+	t.Helper()
+	w := doJSON(router, http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+	var response handlers.AuthResponse
+	if w.Code == http.StatusOK {
+		decodeJSON(t, w, &response)
+	}
+	return w.Code, response
+}
+
+func loadSession(t *testing.T, db *gorm.DB, id string) models.Session {
+	t.Helper()
+	var session models.Session
+	require.NoError(t, db.First(&session, "id = ?", id).Error)
+	return session
+}
+
+func TestRefreshRotation(t *testing.T) {
+	db := testDB(t)
+	router := setupSessionRouter(db)
+	user := createUser(t, db, "parent")
+	tokens := openSession(t, db, user, "")
+
+	code, rotated := refresh(t, router, tokens.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+	assert.Equal(t, user.ID, rotated.User.ID)
+
+	// Новый access-токен относится к той же сессии
+	w := doJSON(router, http.MethodGet, "/api/auth/me", rotated.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var me handlers.MeResponse
+	decodeJSON(t, w, &me)
+	assert.Equal(t, tokens.SessionID, me.SessionID)
+
+	// Новый refresh-токен тоже одноразовый и ротируется дальше
+	code, next := refresh(t, router, rotated.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, rotated.RefreshToken, next.RefreshToken)
+	assert.Nil(t, loadSession(t, db, tokens.SessionID).RevokedAt)
+
+	code, _ = refresh(t, router, "not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	db := testDB(t)
+	router := setupSessionRouter(db)
+	user := createUser(t, db, "parent")
+	tokens := openSession(t, db, user, "")
+	other := openSession(t, db, user, "")
+
+	code, rotated := refresh(t, router, tokens.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// Повторное предъявление старого токена завершает всю сессию
+	code, _ = refresh(t, router, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	session := loadSession(t, db, tokens.SessionID)
+	require.NotNil(t, session.RevokedAt)
+	assert.Equal(t, services.RevokeTokenReuse, session.RevokedReason)
+
+	// Отозванная сессия не обновляется и ее access-токены не принимаются
+	code, _ = refresh(t, router, rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	w := doJSON(router, http.MethodGet, "/api/auth/me", rotated.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Другие сессии пользователя продолжают работать
+	w = doJSON(router, http.MethodGet, "/api/auth/me", other.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRevokedSessionAccessToken(t *testing.T) {
+	db := testDB(t)
+	router := setupSessionRouter(db)
+	user := createUser(t, db, "parent")
+	phone := openSession(t, db, user, "")
+	tablet := openSession(t, db, user, "")
+
+	w := doJSON(router, http.MethodPost, "/api/auth/logout", phone.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/auth/me", phone.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/auth/me", tablet.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Выход на всех устройствах отзывает и остальные сессии
+	w = doJSON(router, http.MethodPost, "/api/auth/logout-all", tablet.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/auth/me", tablet.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	code, _ := refresh(t, router, tablet.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	sessions, err := services.ActiveSessions(db, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	assert.Equal(t, services.RevokeLogoutAll, loadSession(t, db, tablet.SessionID).RevokedReason)
+}
+
+func TestMeFamilies(t *testing.T) {
+	db := testDB(t)
+	router := setupSessionRouter(db)
+	owner := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	family := createFamily(t, db, owner, child)
+
+	w := doJSON(router, http.MethodGet, "/api/auth/me", openSession(t, db, owner, "").AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var me handlers.MeResponse
+	decodeJSON(t, w, &me)
+	require.Len(t, me.Families, 1)
+	assert.Equal(t, handlers.FamilyMembership{
+		FamilyID: family.ID,
+		Name:     family.Name,
+		Code:     family.Code,
+		Role:     "parent",
+		IsOwner:  true,
+	}, me.Families[0])
+
+	// Ребенку код семьи не показывается
+	w = doJSON(router, http.MethodGet, "/api/auth/me", openSession(t, db, child, "").AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decodeJSON(t, w, &me)
+	require.Len(t, me.Families, 1)
+	assert.Equal(t, "child", me.Families[0].Role)
+	assert.Empty(t, me.Families[0].Code)
+	assert.False(t, me.Families[0].IsOwner)
+}
+
+func TestSessionsListAndRevoke(t *testing.T) {
+	db := testDB(t)
+	router := setupSessionRouter(db)
+	user := createUser(t, db, "parent")
+	stranger := createUser(t, db, "parent")
+	phone := openSession(t, db, user, "")
+	tablet := openSession(t, db, user, "")
+	strangerSession := openSession(t, db, stranger, "")
+
+	w := doJSON(router, http.MethodGet, "/api/auth/sessions", phone.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list handlers.SessionsResponse
+	decodeJSON(t, w, &list)
+	require.Len(t, list.Sessions, 2)
+	current := map[string]bool{}
+	for _, session := range list.Sessions {
+		assert.Equal(t, user.ID, session.UserID)
+		current[session.ID] = session.Current
+	}
+	assert.Equal(t, map[string]bool{phone.SessionID: true, tablet.SessionID: false}, current)
+
+	// Чужую сессию завершить нельзя, она продолжает работать
+	w = doJSON(router, http.MethodDelete, "/api/auth/sessions/"+strangerSession.SessionID, phone.AccessToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.True(t, services.SessionActive(db, strangerSession.SessionID))
+	w = doJSON(router, http.MethodGet, "/api/auth/me", strangerSession.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(router, http.MethodDelete, "/api/auth/sessions/"+tablet.SessionID, phone.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.RevokeByUser, loadSession(t, db, tablet.SessionID).RevokedReason)
+	w = doJSON(router, http.MethodGet, "/api/auth/me", tablet.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Повторное завершение уже завершенной сессии
+	w = doJSON(router, http.MethodDelete, "/api/auth/sessions/"+tablet.SessionID, phone.AccessToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(router, http.MethodGet, "/api/auth/sessions", phone.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeJSON(t, w, &list)
+	assert.Equal(t, int64(1), list.Total)
+}
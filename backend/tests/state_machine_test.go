@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestContractMachine(t *testing.T) {
+	m := services.ContractMachine
+
+	transition, err := m.Find(services.ContractTerminate, models.ContractActive, services.ActorParent)
+	require.NoError(t, err)
+	assert.Equal(t, models.ContractTerminated, transition.To)
+
+	// Ребенок не может сам завершить или расторгнуть контракт
+	_, err = m.Find(services.ContractComplete, models.ContractActive, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+	_, err = m.ActionTo(models.ContractActive, models.ContractCompleted, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+	assert.Empty(t, m.Available(models.ContractActive, services.ActorChild))
+
+	// Завершенный контракт нельзя расторгнуть, а черновик - завершить
+	_, err = m.Find(services.ContractTerminate, models.ContractCompleted, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	_, err = m.ActionTo(models.ContractDraft, models.ContractCompleted, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+
+	// Вступление в силу выполняется только системой после подписей
+	_, err = m.Find(services.ContractActivate, models.ContractPendingSignature, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+
+	assert.Equal(t, []string{services.ContractComplete, services.ContractTerminate},
+		m.Available(models.ContractActive, services.ActorParent))
+	assert.Equal(t, []string{services.ContractWithdraw, services.ContractTerminate},
+		m.Available(models.ContractPendingSignature, services.ActorParent))
+}
+
+func TestTaskMachine(t *testing.T) {
+	m := services.TaskMachine
+
+	transition, err := m.ActionTo(models.TaskCompleted, models.TaskPending, services.ActorParent)
+	require.NoError(t, err)
+	assert.Equal(t, services.TaskReopen, transition.Action)
+
+	transition, err = m.ActionTo(models.TaskPending, models.TaskFailed, services.ActorParent)
+	require.NoError(t, err)
+	assert.Equal(t, services.TaskFail, transition.Action)
+
+	// Отправить на проверку можно только задачу в работе и только ребенку
+	_, err = m.Find(services.TaskSubmit, models.TaskSubmitted, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	_, err = m.Find(services.TaskSubmit, models.TaskPending, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+
+	// Отмененная задача остается отмененной
+	assert.Empty(t, m.Available(models.TaskCancelled, services.ActorParent))
+	assert.Empty(t, m.Available(models.TaskCancelled, services.ActorSystem))
+}
+
+func TestRewardMachine(t *testing.T) {
+	m := services.RewardMachine
+
+	transition, err := m.ActionTo(models.RewardAvailable, models.RewardClaimed, services.ActorChild)
+	require.NoError(t, err)
+	assert.Equal(t, services.RewardClaim, transition.Action)
+
+	// Выдать можно только запрошенную награду
+	_, err = m.ActionTo(models.RewardAvailable, models.RewardCompleted, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	_, err = m.ActionTo(models.RewardClaimed, models.RewardCompleted, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+
+	// Истечение срока выполняет только планировщик
+	_, err = m.Find(services.RewardExpire, models.RewardAvailable, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+}
+
+func TestClaimMachine(t *testing.T) {
+	m := services.ClaimMachine
+
+	// Родитель может выдать награду сразу или после одобрения
+	for _, from := range []string{models.ClaimPending, models.ClaimApproved} {
+		transition, err := m.Find(services.ClaimFulfil, from, services.ActorParent)
+		require.NoError(t, err)
+		assert.Equal(t, models.ClaimFulfilled, transition.To)
+	}
+
+	// Ребенок может отменить только нерассмотренный запрос
+	_, err := m.Find(services.ClaimCancel, models.ClaimPending, services.ActorChild)
+	assert.NoError(t, err)
+	_, err = m.Find(services.ClaimCancel, models.ClaimApproved, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	_, err = m.Find(services.ClaimReject, models.ClaimPending, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+
+	// Выданную или отклоненную награду изменить нельзя
+	assert.Empty(t, m.Available(models.ClaimFulfilled, services.ActorParent))
+	assert.Empty(t, m.Available(models.ClaimRejected, services.ActorParent))
+}
+
+// fireTask переводит задачу из статуса from так же, как обработчики:
+// записывает переход и меняет статус в одной транзакции
+func fireTask(db *gorm.DB, id, action, from, role string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		t, err := services.TaskMachine.Fire(tx, id, action, from, role, "", "")
+		if err != nil {
+			return err
+		}
+		return services.SetStatus(tx, &models.Task{}, id, from, map[string]interface{}{
+			"status":     t.To,
+			"updated_at": time.Now(),
+		})
+	})
+}
+
+func TestSetStatusStale(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	task := createTask(t, db, contract, 5, models.TaskPending, time.Now().AddDate(0, 0, 1))
+
+	// Родитель засчитал задачу после того, как ребенок прочитал ее статус
+	require.NoError(t, fireTask(db, task.ID, services.TaskApprove, task.Status, services.ActorParent))
+	err := fireTask(db, task.ID, services.TaskSubmit, task.Status, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+
+	// Переход устаревшего запроса не остается в истории
+	history, err := services.TransitionHistory(db, services.EntityTask, task.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, services.TaskApprove, history[0].Action)
+	assert.Equal(t, models.TaskCompleted, taskStatus(t, db, task.ID))
+}
+
+func TestSetStatusConcurrent(t *testing.T) {
+	db := testDB(t)
+	contract := newSchedulerContract(t, db)
+	task := createTask(t, db, contract, 5, models.TaskPending, time.Now().AddDate(0, 0, 1))
+
+	// Оба запроса прочитали статус pending и выполняются одновременно
+	actions := []struct {
+		action string
+		role   string
+	}{
+		{services.TaskSubmit, services.ActorChild},
+		{services.TaskFail, services.ActorSystem},
+	}
+	errs := make([]error, len(actions))
+	var wg sync.WaitGroup
+	for i, a := range actions {
+		wg.Add(1)
+		go func(i int, action, role string) {
+			defer wg.Done()
+			errs[i] = fireTask(db, task.ID, action, models.TaskPending, role)
+		}(i, a.action, a.role)
+	}
+	wg.Wait()
+
+	// Выполняется ровно один переход, второй получает ErrInvalidTransition
+	succeeded := -1
+	for i, err := range errs {
+		if err == nil {
+			require.Equal(t, -1, succeeded, "оба перехода выполнены")
+			succeeded = i
+			continue
+		}
+		assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	}
+	require.NotEqual(t, -1, succeeded)
+
+	history, err := services.TransitionHistory(db, services.EntityTask, task.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, actions[succeeded].action, history[0].Action)
+	assert.Equal(t, history[0].ToStatus, taskStatus(t, db, task.ID))
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func doneTask(id string, due, done time.Time) services.TaskSnapshot {
+	return services.TaskSnapshot{ID: id, Status: models.TaskCompleted, DueDate: due, Points: 10, DoneAt: &done}
+}
+
+func TestValidateStreakBonus(t *testing.T) {
+	bonus := models.StreakBonus{Scope: models.StreakDays, Kind: models.StreakMultiplier, Length: 7, MultiplierPercent: 150}
+	require.NoError(t, services.ValidateStreakBonus(&bonus))
+	assert.Equal(t, "UTC", bonus.Timezone)
+
+	seriesID := "series-1"
+	invalid := []models.StreakBonus{
+		// Множитель не больше x1 ничего не дает
+		{Scope: models.StreakDays, Kind: models.StreakMultiplier, Length: 7, MultiplierPercent: 100},
+		// У множителя нет баллов, у бонуса за рубеж - множителя
+		{Scope: models.StreakDays, Kind: models.StreakMultiplier, Length: 7, MultiplierPercent: 150, Points: 5},
+		{Scope: models.StreakDays, Kind: models.StreakMilestone, Length: 7, MultiplierPercent: 150, Points: 5},
+		{Scope: models.StreakDays, Kind: models.StreakMilestone, Length: 1, Points: 5},
+		// Повторяющаяся задача указывается только для серии повторений
+		{Scope: models.StreakDays, SeriesID: &seriesID, Kind: models.StreakMilestone, Length: 3, Points: 5},
+		{Scope: "week", Kind: models.StreakMilestone, Length: 3, Points: 5},
+		{Scope: models.StreakSeries, Kind: models.StreakMilestone, Length: 3, Points: 5, Timezone: "Mars/Olympus"},
+	}
+	for _, item := range invalid {
+		assert.ErrorIs(t, services.ValidateStreakBonus(&item), services.ErrInvalidStreakBonus, "%+v", item)
+	}
+
+	milestone := models.StreakBonus{Scope: models.StreakSeries, SeriesID: &seriesID, Kind: models.StreakMilestone, Length: 5, Points: 20}
+	assert.NoError(t, services.ValidateStreakBonus(&milestone))
+}
+
+func TestCountDayStreak(t *testing.T) {
+	now := at(10, 12)
+	submittedAt := at(8, 9)
+	tasks := []services.TaskSnapshot{
+		// 2 марта выполнено с опозданием - серия прерывается
+		doneTask("late", at(2, 18), at(3, 9)),
+		doneTask("a", at(4, 18), at(4, 17)),
+		doneTask("b", at(5, 18), at(5, 10)),
+		// Отмененная задача и день без задач серию не прерывают
+		{ID: "cancelled", Status: models.TaskCancelled, DueDate: at(6, 18)},
+		doneTask("c", at(7, 18), at(7, 11)),
+		doneTask("d", at(7, 20), at(7, 19)),
+		// Задача отправлена вовремя и ждет проверки
+		{ID: "submitted", Status: models.TaskSubmitted, DueDate: at(8, 18), DoneAt: &submittedAt},
+		doneTask("e", at(9, 18), at(9, 8)),
+		// Срок еще не наступил
+		{ID: "future", Status: models.TaskPending, DueDate: at(10, 20)},
+	}
+
+	streak := services.CountDayStreak(tasks, time.UTC, now, now)
+	assert.Equal(t, 4, streak.Current)
+	assert.Equal(t, 4, streak.Best)
+	require.NotNil(t, streak.LastKeptAt)
+	assert.Equal(t, time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), *streak.LastKeptAt)
+
+	// Серия на день задачи не учитывает следующие дни
+	streak = services.CountDayStreak(tasks, time.UTC, at(5, 18), now)
+	assert.Equal(t, 2, streak.Current)
+
+	// Просроченная задача в работе прерывает день, даже если остальные выполнены
+	tasks = append(tasks, services.TaskSnapshot{ID: "overdue", Status: models.TaskPending, DueDate: at(9, 20)})
+	streak = services.CountDayStreak(tasks, time.UTC, now, now)
+	assert.Equal(t, 0, streak.Current)
+	assert.Equal(t, 3, streak.Best)
+	assert.Nil(t, streak.LastKeptAt)
+}
+
+func TestCountDayStreakTimezone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	now := at(10, 12)
+
+	// В UTC задачи приходятся на 4 и 5 марта, по Москве обе на 5 марта
+	tasks := []services.TaskSnapshot{
+		doneTask("a", at(4, 22), at(4, 21)),
+		doneTask("b", at(5, 18), at(5, 10)),
+	}
+	assert.Equal(t, 2, services.CountDayStreak(tasks, time.UTC, now, now).Current)
+	assert.Equal(t, 1, services.CountDayStreak(tasks, moscow, now, now).Current)
+}
+
+func TestCountSeriesStreak(t *testing.T) {
+	now := at(10, 12)
+	seriesID, otherID := "series-1", "series-2"
+	tasks := []services.TaskSnapshot{
+		{ID: "s1", Status: models.TaskFailed, DueDate: at(3, 18), SeriesID: &seriesID},
+		doneTask("s2", at(4, 18), at(4, 17)),
+		doneTask("s3", at(5, 18), at(5, 17)),
+		doneTask("s4", at(6, 18), at(6, 17)),
+		// Другая серия на эту не влияет
+		{ID: "o1", Status: models.TaskFailed, DueDate: at(5, 18), SeriesID: &otherID},
+	}
+	for i := 1; i <= 3; i++ {
+		tasks[i].SeriesID = &seriesID
+	}
+
+	streak := services.CountSeriesStreak(tasks, seriesID, now, now)
+	assert.Equal(t, 3, streak.Current)
+	assert.Equal(t, 3, streak.Best)
+
+	streak = services.CountSeriesStreak(tasks, seriesID, at(5, 18), now)
+	assert.Equal(t, 2, streak.Current)
+
+	assert.Equal(t, 0, services.CountSeriesStreak(tasks, otherID, now, now).Current)
+}
+
+func TestStreakMultiplier(t *testing.T) {
+	seriesID, otherID := "series-1", "series-2"
+	bonuses := []models.StreakBonus{
+		{ID: "days-3", Scope: models.StreakDays, Kind: models.StreakMultiplier, Length: 3, MultiplierPercent: 120},
+		{ID: "days-7", Scope: models.StreakDays, Kind: models.StreakMultiplier, Length: 7, MultiplierPercent: 150},
+		{ID: "days-milestone", Scope: models.StreakDays, Kind: models.StreakMilestone, Length: 5, Points: 30},
+		{ID: "series", Scope: models.StreakSeries, SeriesID: &seriesID, Kind: models.StreakMultiplier, Length: 2, MultiplierPercent: 200},
+	}
+
+	assert.Nil(t, services.StreakMultiplier(bonuses, models.StreakDays, nil, 2))
+	bonus := services.StreakMultiplier(bonuses, models.StreakDays, nil, 5)
+	require.NotNil(t, bonus)
+	assert.Equal(t, "days-3", bonus.ID)
+	// Множители не складываются, действует наибольший
+	bonus = services.StreakMultiplier(bonuses, models.StreakDays, nil, 10)
+	require.NotNil(t, bonus)
+	assert.Equal(t, "days-7", bonus.ID)
+
+	bonus = services.StreakMultiplier(bonuses, models.StreakSeries, &seriesID, 2)
+	require.NotNil(t, bonus)
+	assert.Equal(t, 200, bonus.MultiplierPercent)
+	assert.Nil(t, services.StreakMultiplier(bonuses, models.StreakSeries, &otherID, 10))
+	assert.Nil(t, services.StreakMultiplier(bonuses, models.StreakSeries, nil, 10))
+}
+
+func TestStreakExtra(t *testing.T) {
+	assert.Equal(t, 5, services.StreakExtra(10, 150))
+	assert.Equal(t, 10, services.StreakExtra(10, 200))
+	// Дробная часть округляется до ближайшего, половина - вверх
+	assert.Equal(t, 2, services.StreakExtra(7, 125))
+	assert.Equal(t, 2, services.StreakExtra(3, 150))
+	assert.Equal(t, 1, services.StreakExtra(3, 120))
+	assert.Equal(t, 0, services.StreakExtra(0, 150))
+	assert.Equal(t, 0, services.StreakExtra(10, 100))
+}
+
+func TestRevokeStreakBonuses(t *testing.T) {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	contract := createContract(t, db, parent, child, models.ContractActive)
+	task := createTask(t, db, contract, 10, models.TaskCompleted, time.Now().Add(time.Hour))
+	bonus := models.StreakBonus{
+		ContractID: contract.ID,
+		Scope:      models.StreakDays,
+		Kind:       models.StreakMilestone,
+		Length:     1,
+		Points:     5,
+		Timezone:   "UTC",
+	}
+	require.NoError(t, db.Omit("Contract").Create(&bonus).Error)
+
+	approve := func() {
+		require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+			if err := services.AwardTask(tx, &task, child.ID, parent.ID); err != nil {
+				return err
+			}
+			return services.ApplyStreakBonuses(tx, &task, child.ID, parent.ID, time.Now())
+		}))
+	}
+	awards := func() int64 {
+		var count int64
+		db.Model(&models.StreakAward{}).Where("task_id = ?", task.ID).Count(&count)
+		return count
+	}
+
+	approve()
+	assert.Equal(t, 15, balanceOf(t, db, contract))
+	assert.Equal(t, int64(1), awards())
+
+	// Отмена выполнения списывает и баллы, и бонус за серию
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := services.RevokeTask(tx, &task, child.ID, parent.ID); err != nil {
+			return err
+		}
+		return services.RevokeStreakBonuses(tx, task.ID)
+	}))
+	assert.Equal(t, 0, balanceOf(t, db, contract))
+	assert.Zero(t, awards())
+
+	// При повторном подтверждении бонус начисляется снова
+	approve()
+	assert.Equal(t, 15, balanceOf(t, db, contract))
+	assert.Equal(t, int64(1), awards())
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soulfeelings/parents-children-contracts/backend/handlers"
+	"github.com/soulfeelings/parents-children-contracts/backend/middleware"
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupTaskApprovalRouter(db *gorm.DB) *gin.Engine {
+	taskHandlers := handlers.NewTaskHandlers(db)
+	router, _, authorized := newAPIRouter(db)
+	tasks := authorized.Group("/tasks")
+	{
+		tasks.PUT("/:id", taskHandlers.Update)
+		tasks.POST("/:id/submit", middleware.RoleMiddleware("child"), taskHandlers.Submit)
+		tasks.POST("/:id/approve", middleware.RoleMiddleware("parent"), taskHandlers.Approve)
+		tasks.POST("/:id/reject", middleware.RoleMiddleware("parent"), taskHandlers.Reject)
+	}
+	return router
+}
+
+type taskApprovalFixture struct {
+	db          *gorm.DB
+	router      *gin.Engine
+	contract    models.Contract
+	parentToken string
+	childToken  string
+}
+
+func newTaskApprovalFixture(t *testing.T) taskApprovalFixture {
+	db := testDB(t)
+	parent := createUser(t, db, "parent")
+	child := createUser(t, db, "child")
+	createFamily(t, db, parent, child)
+	return taskApprovalFixture{
+		db:          db,
+		router:      setupTaskApprovalRouter(db),
+		contract:    createContract(t, db, parent, child, models.ContractActive),
+		parentToken: authToken(t, db, parent),
+		childToken:  authToken(t, db, child),
+	}
+}
+
+func (f taskApprovalFixture) reload(t *testing.T, id string) models.Task {
+	t.Helper()
+	var task models.Task
+	require.NoError(t, f.db.First(&task, "id = ?", id).Error)
+	return task
+}
+
+func (f taskApprovalFixture) submission(t *testing.T, taskID string) models.TaskSubmission {
+	t.Helper()
+	var submission models.TaskSubmission
+	require.NoError(t, f.db.Where("task_id = ?", taskID).Order("created_at DESC").First(&submission).Error)
+	return submission
+}
+
+func TestTaskSubmitApprove(t *testing.T) {
+	f := newTaskApprovalFixture(t)
+	task := createTask(t, f.db, f.contract, 10, models.TaskPending, time.Now().Add(24*time.Hour))
+
+	// Родитель не может отправить задачу за ребенка
+	w := doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/submit", f.parentToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/submit", f.childToken, map[string]string{"note": "Готово"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.TaskSubmitted, f.reload(t, task.ID).Status)
+
+	// Повторная отправка недопустима
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/submit", f.childToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/approve", f.parentToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored := f.reload(t, task.ID)
+	assert.Equal(t, models.TaskCompleted, stored.Status)
+	assert.NotNil(t, stored.ReviewedAt)
+	assert.Equal(t, 10, balanceOf(t, f.db, f.contract))
+	assert.Equal(t, "approved", f.submission(t, task.ID).Status)
+
+	// Повторное подтверждение не начисляет баллы второй раз
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/approve", f.parentToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 10, balanceOf(t, f.db, f.contract))
+}
+
+func TestTaskApprovePartial(t *testing.T) {
+	f := newTaskApprovalFixture(t)
+	task := createTask(t, f.db, f.contract, 10, models.TaskPending, time.Now().Add(24*time.Hour))
+
+	now := time.Now()
+	items := []models.TaskChecklistItem{
+		{TaskID: task.ID, Title: "Убрать игрушки", Position: 0, CompletedAt: &now, CreatedAt: now, UpdatedAt: now},
+		{TaskID: task.ID, Title: "Пропылесосить", Position: 1, CompletedAt: &now, CreatedAt: now, UpdatedAt: now},
+		{TaskID: task.ID, Title: "Вынести мусор", Position: 2, CreatedAt: now, UpdatedAt: now},
+	}
+	require.NoError(t, f.db.Create(&items).Error)
+
+	w := doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/approve", f.parentToken, map[string]bool{"partial_credit": true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 2 из 3 пунктов: 10 * 2 / 3
+	assert.Equal(t, 6, balanceOf(t, f.db, f.contract))
+	var entry models.LedgerEntry
+	require.NoError(t, f.db.Where("task_id = ? AND entry_type = ?", task.ID, models.LedgerEarn).First(&entry).Error)
+	assert.Equal(t, 6, entry.Amount)
+}
+
+func TestTaskReject(t *testing.T) {
+	f := newTaskApprovalFixture(t)
+	task := createTask(t, f.db, f.contract, 10, models.TaskPending, time.Now().Add(24*time.Hour))
+
+	w := doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/submit", f.childToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Без причины отклонить нельзя
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/reject", f.parentToken, map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, models.TaskSubmitted, f.reload(t, task.ID).Status)
+
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/reject", f.parentToken, map[string]string{"reason": "Посуда не домыта"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored := f.reload(t, task.ID)
+	assert.Equal(t, models.TaskPending, stored.Status)
+	assert.Equal(t, "Посуда не домыта", stored.RejectionReason)
+	assert.Equal(t, 0, balanceOf(t, f.db, f.contract))
+
+	submission := f.submission(t, task.ID)
+	assert.Equal(t, "rejected", submission.Status)
+	assert.Equal(t, "Посуда не домыта", submission.ReviewReason)
+
+	// Задачу в работе отклонить нельзя: она не отправлена на проверку
+	w = doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/reject", f.parentToken, map[string]string{"reason": "Еще раз"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	history, err := services.TransitionHistory(f.db, services.EntityTask, task.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, services.TaskReject, history[1].Action)
+	assert.Equal(t, "Посуда не домыта", history[1].Reason)
+}
+
+func TestTaskUpdateStatusOfSubmittedTask(t *testing.T) {
+	f := newTaskApprovalFixture(t)
+	task := createTask(t, f.db, f.contract, 10, models.TaskPending, time.Now().Add(24*time.Hour))
+
+	w := doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/submit", f.childToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Решение по отправленной задаче принимается только через проверку
+	for _, status := range []string{models.TaskPending, models.TaskFailed} {
+		w = doJSON(f.router, http.MethodPut, "/api/tasks/"+task.ID, f.parentToken, map[string]string{"status": status})
+		assert.Equal(t, http.StatusBadRequest, w.Code, status)
+	}
+	assert.Equal(t, models.TaskSubmitted, f.reload(t, task.ID).Status)
+	assert.Equal(t, "submitted", f.submission(t, task.ID).Status)
+
+	// Остальные поля родитель по-прежнему может менять
+	w = doJSON(f.router, http.MethodPut, "/api/tasks/"+task.ID, f.parentToken, map[string]int{"points": 15})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 15, f.reload(t, task.ID).Points)
+}
+
+func TestTaskApproveRequiresActiveContract(t *testing.T) {
+	f := newTaskApprovalFixture(t)
+	require.NoError(t, f.db.Model(&f.contract).Update("status", models.ContractPendingSignature).Error)
+	task := createTask(t, f.db, f.contract, 10, models.TaskPending, time.Now().Add(24*time.Hour))
+
+	w := doJSON(f.router, http.MethodPost, "/api/tasks/"+task.ID+"/approve", f.parentToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, models.TaskPending, f.reload(t, task.ID).Status)
+	assert.Equal(t, 0, balanceOf(t, f.db, f.contract))
+}
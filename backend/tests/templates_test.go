@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBlueprints(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration int
+		task     services.TaskBlueprint
+		wantErr  bool
+	}{
+		{name: "Разовая задача", duration: 7, task: services.TaskBlueprint{Title: "Уборка", Points: 10, DueDay: 6, DueTime: "18:00"}},
+		{name: "Повторяющаяся задача", duration: 7, task: services.TaskBlueprint{Title: "Кровать", Points: 5, Rule: "daily"}},
+		{name: "Срок за пределами контракта", duration: 7, task: services.TaskBlueprint{Title: "Уборка", DueDay: 7}, wantErr: true},
+		{name: "Некорректное время", duration: 7, task: services.TaskBlueprint{Title: "Уборка", DueTime: "25:00"}, wantErr: true},
+		{name: "Некорректное правило", duration: 7, task: services.TaskBlueprint{Title: "Кровать", Rule: "FREQ=HOURLY"}, wantErr: true},
+		{name: "Чек-лист у повторяющейся задачи", duration: 7, task: services.TaskBlueprint{Title: "Кровать", Rule: "daily", Checklist: []string{"Подушка"}}, wantErr: true},
+		{name: "Без названия", duration: 7, task: services.TaskBlueprint{Points: 5}, wantErr: true},
+		{name: "Нулевой срок шаблона", duration: 0, task: services.TaskBlueprint{Title: "Уборка"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := services.ValidateBlueprints(tt.duration, []services.TaskBlueprint{tt.task}, nil)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, services.ErrInvalidTemplate)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSetBlueprintsCanonicalRule(t *testing.T) {
+	template := &models.ContractTemplate{DurationDays: 7}
+	tasks := []services.TaskBlueprint{{Title: "Домашнее задание", Points: 10, Rule: "weekdays"}}
+	require.NoError(t, services.SetBlueprints(template, tasks, nil))
+
+	parsedTasks, parsedRewards, err := services.TemplateBlueprints(template)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", parsedTasks[0].Rule)
+	assert.Empty(t, parsedRewards)
+	assert.Equal(t, "[]", string(template.Rewards))
+}
+
+func TestTemplateDueDate(t *testing.T) {
+	start := time.Date(2024, 9, 2, 15, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 9, 8, 18, 0, 0, 0, time.UTC),
+		services.TemplateDueDate(start, services.TaskBlueprint{DueDay: 6, DueTime: "18:00"}))
+	// Без времени задача должна быть выполнена к 20:00
+	assert.Equal(t, time.Date(2024, 9, 2, 20, 0, 0, 0, time.UTC),
+		services.TemplateDueDate(start, services.TaskBlueprint{}))
+}
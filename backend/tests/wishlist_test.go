@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/soulfeelings/parents-children-contracts/backend/models"
+	"github.com/soulfeelings/parents-children-contracts/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWishMachine(t *testing.T) {
+	m := services.WishMachine
+
+	// Родитель может пересмотреть стоимость и принять желание без нее
+	for _, from := range []string{models.WishPending, models.WishPriced} {
+		transition, err := m.Find(services.WishPrice, from, services.ActorParent)
+		if assert.NoError(t, err) {
+			assert.Equal(t, models.WishPriced, transition.To)
+		}
+		transition, err = m.Find(services.WishAccept, from, services.ActorParent)
+		if assert.NoError(t, err) {
+			assert.Equal(t, models.WishAccepted, transition.To)
+		}
+	}
+
+	// Решения принимает родитель, ребенок может только отозвать желание
+	_, err := m.Find(services.WishAccept, models.WishPending, services.ActorChild)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+	_, err = m.Find(services.WishWithdraw, models.WishPriced, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrTransitionForbidden)
+	assert.Equal(t, []string{services.WishWithdraw}, m.Available(models.WishPending, services.ActorChild))
+
+	// Решение окончательное
+	_, err = m.Find(services.WishReject, models.WishAccepted, services.ActorParent)
+	assert.ErrorIs(t, err, services.ErrInvalidTransition)
+	assert.Empty(t, m.Available(models.WishWithdrawn, services.ActorChild))
+}
+
+func TestWishCost(t *testing.T) {
+	wish := models.Wish{SuggestedPoints: 200}
+	assert.Equal(t, 200, wish.Cost())
+
+	price := 150
+	wish.Price = &price
+	assert.Equal(t, 150, wish.Cost())
+
+	// После принятия стоимость определяет награда контракта
+	wish.Reward = &models.Reward{PointsCost: 120}
+	assert.Equal(t, 120, wish.Cost())
+}
+
+func TestWishProgress(t *testing.T) {
+	progress := services.WishProgress(200, services.ContractBalance{ContractID: "contract-1", Balance: 50})
+	assert.Equal(t, "contract-1", progress.ContractID)
+	assert.Equal(t, 150, progress.Missing)
+	assert.Equal(t, 25, progress.Percent)
+
+	// Накоплено больше, чем нужно
+	progress = services.WishProgress(100, services.ContractBalance{Balance: 130})
+	assert.Equal(t, 0, progress.Missing)
+	assert.Equal(t, 100, progress.Percent)
+
+	// Отрицательный баланс после корректировки не дает отрицательного процента
+	progress = services.WishProgress(100, services.ContractBalance{Balance: -20})
+	assert.Equal(t, 120, progress.Missing)
+	assert.Equal(t, 0, progress.Percent)
+
+	// Бесплатное желание уже достижимо
+	assert.Equal(t, 100, services.WishProgress(0, services.ContractBalance{}).Percent)
+}
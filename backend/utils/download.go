@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SignDownload подписывает ссылку на скачивание файла для пользователя.
+// Подпись действительна до expires и не позволяет подменить файл или пользователя.
+func SignDownload(fileID, userID string, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("download\n" + fileID + "\n" + userID + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownload проверяет подпись ссылки на скачивание и срок ее действия
+func VerifyDownload(fileID, userID string, expires int64, signature string, now time.Time) bool {
+	if now.Unix() > expires {
+		return false
+	}
+	expected := SignDownload(fileID, userID, time.Unix(expires, 0))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -7,18 +7,52 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// ScopeChild - ограниченный доступ ребенка, вошедшего по PIN-коду
+const ScopeChild = "child"
+
+// Время жизни токенов, задается из конфигурации при старте приложения
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	Scope     string `json:"scope,omitempty"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID string, role string) (string, error) {
+// ConfigureTokenTTL задает время жизни access- и refresh-токенов
+func ConfigureTokenTTL(access, refresh time.Duration) {
+	if access > 0 {
+		accessTokenTTL = access
+	}
+	if refresh > 0 {
+		refreshTokenTTL = refresh
+	}
+}
+
+// AccessTokenTTL возвращает время жизни access-токена
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// RefreshTokenTTL возвращает время жизни refresh-токена и сессии
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
+
+// GenerateToken выпускает короткоживущий access-токен, привязанный к сессии
+func GenerateToken(userID string, role string, scope string, sessionID string) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		Scope:     scope,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -31,7 +65,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -42,4 +76,4 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	return claims, nil
-} 
\ No newline at end of file
+}
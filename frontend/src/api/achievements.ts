@@ -0,0 +1,59 @@
+import { apiClient } from "./client";
+import {
+  Achievement,
+  AchievementFilters,
+  ChildAchievements,
+  CreateAchievementRequest,
+  UpdateAchievementRequest,
+} from "./types";
+
+export const achievementsApi = {
+  // Все доступные ребенку достижения: открытые и продвижение к остальным
+  getForChild: async (childId: string): Promise<ChildAchievements> => {
+    const response = await apiClient.get<ChildAchievements>(
+      `/children/${childId}/achievements`
+    );
+    return response.data;
+  },
+
+  getAll: async (filters: AchievementFilters = {}): Promise<Achievement[]> => {
+    const response = await apiClient.get<{ achievements: Achievement[] }>(
+      "/achievements",
+      { params: filters }
+    );
+    return response.data.achievements;
+  },
+
+  getById: async (id: string): Promise<Achievement> => {
+    const response = await apiClient.get<{ achievement: Achievement }>(
+      `/achievements/${id}`
+    );
+    return response.data.achievement;
+  },
+
+  // Дети семьи, уже выполнившие условие, получают достижение сразу
+  create: async (
+    data: CreateAchievementRequest
+  ): Promise<{ achievement: Achievement; awarded?: number }> => {
+    const response = await apiClient.post<{
+      achievement: Achievement;
+      awarded?: number;
+    }>("/achievements", data);
+    return response.data;
+  },
+
+  update: async (
+    id: string,
+    data: UpdateAchievementRequest
+  ): Promise<{ achievement: Achievement; awarded?: number }> => {
+    const response = await apiClient.put<{
+      achievement: Achievement;
+      awarded?: number;
+    }>(`/achievements/${id}`, data);
+    return response.data;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/achievements/${id}`);
+  },
+};
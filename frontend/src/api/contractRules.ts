@@ -0,0 +1,72 @@
+import { apiClient } from "./client";
+import {
+  ContractRule,
+  ContractRuleFilters,
+  CreateContractRuleRequest,
+  RuleDryRun,
+  RuleFiring,
+  UpdateContractRuleRequest,
+} from "./types";
+
+export const contractRulesApi = {
+  getAll: async (filters: ContractRuleFilters = {}): Promise<ContractRule[]> => {
+    const response = await apiClient.get<{ rules: ContractRule[] }>(
+      "/contract-rules",
+      { params: filters }
+    );
+    return response.data.rules;
+  },
+
+  getById: async (id: string): Promise<ContractRule> => {
+    const response = await apiClient.get<{ rule: ContractRule }>(
+      `/contract-rules/${id}`
+    );
+    return response.data.rule;
+  },
+
+  create: async (data: CreateContractRuleRequest): Promise<ContractRule> => {
+    const response = await apiClient.post<{ rule: ContractRule }>(
+      "/contract-rules",
+      data
+    );
+    return response.data.rule;
+  },
+
+  update: async (
+    id: string,
+    data: UpdateContractRuleRequest
+  ): Promise<ContractRule> => {
+    const response = await apiClient.put<{ rule: ContractRule }>(
+      `/contract-rules/${id}`,
+      data
+    );
+    return response.data.rule;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/contract-rules/${id}`);
+  },
+
+  getFirings: async (id: string): Promise<RuleFiring[]> => {
+    const response = await apiClient.get<{ firings: RuleFiring[] }>(
+      `/contract-rules/${id}/firings`
+    );
+    return response.data.firings;
+  },
+
+  // Что сохраненное правило сделало бы за историю контракта
+  dryRun: async (id: string): Promise<RuleDryRun> => {
+    const response = await apiClient.get<RuleDryRun>(
+      `/contract-rules/${id}/dry-run`
+    );
+    return response.data;
+  },
+
+  dryRunDraft: async (data: CreateContractRuleRequest): Promise<RuleDryRun> => {
+    const response = await apiClient.post<RuleDryRun>(
+      "/contract-rules/dry-run",
+      data
+    );
+    return response.data;
+  },
+};
@@ -1,5 +1,14 @@
 import { apiClient } from "./client";
-import { Contract, CreateContractRequest } from "./types";
+import {
+  Contract,
+  ContractDiff,
+  ContractSignatures,
+  ContractVersion,
+  ContractTransitionRequest,
+  CreateContractRequest,
+  RenewContractRequest,
+  StateTransitions,
+} from "./types";
 
 export const contractsApi = {
   getAll: async (): Promise<Contract[]> => {
@@ -28,4 +37,76 @@ export const contractsApi = {
   delete: async (id: string): Promise<void> => {
     await apiClient.delete(`/contracts/${id}`);
   },
+
+  propose: async (id: string, coSignerId?: string): Promise<Contract> => {
+    const response = await apiClient.post<Contract>(`/contracts/${id}/propose`, {
+      co_signer_id: coSignerId,
+    });
+    return response.data;
+  },
+
+  sign: async (id: string, termsHash?: string): Promise<Contract> => {
+    const response = await apiClient.post<Contract>(`/contracts/${id}/sign`, {
+      terms_hash: termsHash,
+    });
+    return response.data;
+  },
+
+  withdraw: async (id: string): Promise<void> => {
+    await apiClient.post(`/contracts/${id}/withdraw`);
+  },
+
+  renew: async (id: string, data: RenewContractRequest = {}): Promise<Contract> => {
+    const response = await apiClient.post<{ contract: Contract }>(
+      `/contracts/${id}/renew`,
+      data
+    );
+    return response.data.contract;
+  },
+
+  getVersions: async (id: string): Promise<ContractVersion[]> => {
+    const response = await apiClient.get<{ versions: ContractVersion[] }>(
+      `/contracts/${id}/versions`
+    );
+    return response.data.versions;
+  },
+
+  getVersion: async (id: string, version: number): Promise<ContractVersion> => {
+    const response = await apiClient.get<{ version: ContractVersion }>(
+      `/contracts/${id}/versions/${version}`
+    );
+    return response.data.version;
+  },
+
+  diff: async (id: string, from?: number, to?: number): Promise<ContractDiff> => {
+    const response = await apiClient.get<ContractDiff>(`/contracts/${id}/diff`, {
+      params: { from, to },
+    });
+    return response.data;
+  },
+
+  getSignatures: async (id: string): Promise<ContractSignatures> => {
+    const response = await apiClient.get<ContractSignatures>(
+      `/contracts/${id}/signatures`
+    );
+    return response.data;
+  },
+
+  transition: async (
+    id: string,
+    data: ContractTransitionRequest
+  ): Promise<Contract> => {
+    const response = await apiClient.post<{ contract: Contract }>(
+      `/contracts/${id}/transitions`,
+      data
+    );
+    return response.data.contract;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/contracts/${id}/transitions`
+    );
+    return response.data;
+  },
 };
@@ -0,0 +1,83 @@
+import { apiClient } from "./client";
+import { Family, ChildInfo, Guardianship, FamilyInvitation } from "./types";
+
+export const familiesApi = {
+  getAll: async (): Promise<Family[]> => {
+    const response = await apiClient.get<{ families: Family[] }>("/families");
+    return response.data.families;
+  },
+
+  getById: async (id: string): Promise<Family> => {
+    const response = await apiClient.get<{ family: Family }>(`/families/${id}`);
+    return response.data.family;
+  },
+
+  create: async (name: string): Promise<Family> => {
+    const response = await apiClient.post<{ family: Family }>("/families", {
+      name,
+    });
+    return response.data.family;
+  },
+
+  removeMember: async (familyId: string, userId: string): Promise<void> => {
+    await apiClient.delete(`/families/${familyId}/members/${userId}`);
+  },
+
+  addGuardian: async (
+    familyId: string,
+    parentId: string,
+    childId: string
+  ): Promise<Guardianship> => {
+    const response = await apiClient.post<{ guardianship: Guardianship }>(
+      `/families/${familyId}/guardianships`,
+      { parent_id: parentId, child_id: childId }
+    );
+    return response.data.guardianship;
+  },
+
+  removeGuardian: async (
+    familyId: string,
+    guardianshipId: string
+  ): Promise<void> => {
+    await apiClient.delete(
+      `/families/${familyId}/guardianships/${guardianshipId}`
+    );
+  },
+
+  createInvitation: async (
+    familyId: string,
+    data: { role: "parent" | "child"; email?: string; ttl_hours?: number }
+  ): Promise<{ invitation: FamilyInvitation; link: string }> => {
+    const response = await apiClient.post<{
+      invitation: FamilyInvitation;
+      link: string;
+    }>(`/families/${familyId}/invitations`, data);
+    return response.data;
+  },
+
+  getInvitations: async (familyId: string): Promise<FamilyInvitation[]> => {
+    const response = await apiClient.get<{ invitations: FamilyInvitation[] }>(
+      `/families/${familyId}/invitations`
+    );
+    return response.data.invitations;
+  },
+
+  revokeInvitation: async (id: string): Promise<void> => {
+    await apiClient.delete(`/invitations/${id}`);
+  },
+
+  acceptInvitation: async (code: string): Promise<Family> => {
+    const response = await apiClient.post<{ family: Family }>(
+      "/invitations/accept",
+      { code }
+    );
+    return response.data.family;
+  },
+
+  getChildren: async (): Promise<ChildInfo[]> => {
+    const response = await apiClient.get<{ children: ChildInfo[] }>(
+      "/children"
+    );
+    return response.data.children;
+  },
+};
@@ -4,3 +4,14 @@ export * from "./auth";
 export * from "./contracts";
 export * from "./tasks";
 export * from "./rewards";
+export * from "./rewardClaims";
+export * from "./rewardCatalog";
+export * from "./wishes";
+export * from "./savingsGoals";
+export * from "./contractRules";
+export * from "./streaks";
+export * from "./achievements";
+export * from "./families";
+export * from "./proposals";
+export * from "./taskSeries";
+export * from "./templates";
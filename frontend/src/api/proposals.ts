@@ -0,0 +1,53 @@
+import { apiClient } from "./client";
+import { ContractProposal, CreateProposalRequest } from "./types";
+
+export const proposalsApi = {
+  getByContract: async (contractId: string): Promise<ContractProposal[]> => {
+    const response = await apiClient.get<{ proposals: ContractProposal[] }>(
+      `/contracts/${contractId}/proposals`
+    );
+    return response.data.proposals;
+  },
+
+  create: async (
+    contractId: string,
+    data: CreateProposalRequest
+  ): Promise<ContractProposal> => {
+    const response = await apiClient.post<{ proposal: ContractProposal }>(
+      `/contracts/${contractId}/proposals`,
+      data
+    );
+    return response.data.proposal;
+  },
+
+  accept: async (id: string, message?: string): Promise<ContractProposal> => {
+    const response = await apiClient.post<{ proposal: ContractProposal }>(
+      `/proposals/${id}/accept`,
+      { message }
+    );
+    return response.data.proposal;
+  },
+
+  reject: async (id: string, message?: string): Promise<ContractProposal> => {
+    const response = await apiClient.post<{ proposal: ContractProposal }>(
+      `/proposals/${id}/reject`,
+      { message }
+    );
+    return response.data.proposal;
+  },
+
+  counter: async (
+    id: string,
+    data: CreateProposalRequest
+  ): Promise<ContractProposal> => {
+    const response = await apiClient.post<{ proposal: ContractProposal }>(
+      `/proposals/${id}/counter`,
+      data
+    );
+    return response.data.proposal;
+  },
+
+  withdraw: async (id: string): Promise<void> => {
+    await apiClient.post(`/proposals/${id}/withdraw`);
+  },
+};
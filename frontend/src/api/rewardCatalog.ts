@@ -0,0 +1,111 @@
+import { apiClient } from "./client";
+import {
+  CatalogReward,
+  CatalogRewardFilters,
+  CreateCatalogRewardRequest,
+  CreateRewardCategoryRequest,
+  Reward,
+  RewardCategory,
+  UpdateCatalogRewardRequest,
+} from "./types";
+
+export const rewardCatalogApi = {
+  getAll: async (filters: CatalogRewardFilters = {}): Promise<CatalogReward[]> => {
+    const response = await apiClient.get<{ catalog_rewards: CatalogReward[] }>(
+      "/reward-catalog",
+      { params: filters }
+    );
+    return response.data.catalog_rewards;
+  },
+
+  getById: async (id: string): Promise<CatalogReward> => {
+    const response = await apiClient.get<{ catalog_reward: CatalogReward }>(
+      `/reward-catalog/${id}`
+    );
+    return response.data.catalog_reward;
+  },
+
+  create: async (data: CreateCatalogRewardRequest): Promise<CatalogReward> => {
+    const response = await apiClient.post<{ catalog_reward: CatalogReward }>(
+      "/reward-catalog",
+      data
+    );
+    return response.data.catalog_reward;
+  },
+
+  update: async (
+    id: string,
+    data: UpdateCatalogRewardRequest
+  ): Promise<CatalogReward> => {
+    const response = await apiClient.put<{ catalog_reward: CatalogReward }>(
+      `/reward-catalog/${id}`,
+      data
+    );
+    return response.data.catalog_reward;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/reward-catalog/${id}`);
+  },
+
+  // Добавляет награду каталога в контракты; points задает стоимость в них
+  assign: async (
+    id: string,
+    contractIds: string[],
+    points?: number
+  ): Promise<Reward[]> => {
+    const response = await apiClient.post<{ rewards: Reward[] }>(
+      `/reward-catalog/${id}/assign`,
+      { contract_ids: contractIds, points }
+    );
+    return response.data.rewards;
+  },
+
+  uploadImage: async (id: string, file: File): Promise<CatalogReward> => {
+    const form = new FormData();
+    form.append("file", file);
+    const response = await apiClient.put<{ catalog_reward: CatalogReward }>(
+      `/reward-catalog/${id}/image`,
+      form,
+      { headers: { "Content-Type": "multipart/form-data" } }
+    );
+    return response.data.catalog_reward;
+  },
+
+  deleteImage: async (id: string): Promise<void> => {
+    await apiClient.delete(`/reward-catalog/${id}/image`);
+  },
+
+  getCategories: async (familyId?: string): Promise<RewardCategory[]> => {
+    const response = await apiClient.get<{ categories: RewardCategory[] }>(
+      "/reward-categories",
+      { params: { family_id: familyId } }
+    );
+    return response.data.categories;
+  },
+
+  createCategory: async (
+    data: CreateRewardCategoryRequest
+  ): Promise<RewardCategory> => {
+    const response = await apiClient.post<{ category: RewardCategory }>(
+      "/reward-categories",
+      data
+    );
+    return response.data.category;
+  },
+
+  updateCategory: async (
+    id: string,
+    data: Partial<Omit<CreateRewardCategoryRequest, "family_id">>
+  ): Promise<RewardCategory> => {
+    const response = await apiClient.put<{ category: RewardCategory }>(
+      `/reward-categories/${id}`,
+      data
+    );
+    return response.data.category;
+  },
+
+  deleteCategory: async (id: string): Promise<void> => {
+    await apiClient.delete(`/reward-categories/${id}`);
+  },
+};
@@ -0,0 +1,56 @@
+import { apiClient } from "./client";
+import { RewardClaim, RewardClaimFilters, StateTransitions } from "./types";
+
+export const rewardClaimsApi = {
+  getAll: async (filters: RewardClaimFilters = {}): Promise<RewardClaim[]> => {
+    const response = await apiClient.get<{ claims: RewardClaim[] }>(
+      "/reward-claims",
+      { params: filters }
+    );
+    return response.data.claims;
+  },
+
+  getById: async (id: string): Promise<RewardClaim> => {
+    const response = await apiClient.get<{ claim: RewardClaim }>(
+      `/reward-claims/${id}`
+    );
+    return response.data.claim;
+  },
+
+  approve: async (id: string): Promise<RewardClaim> => {
+    const response = await apiClient.post<{ claim: RewardClaim }>(
+      `/reward-claims/${id}/approve`
+    );
+    return response.data.claim;
+  },
+
+  reject: async (id: string, reason: string): Promise<RewardClaim> => {
+    const response = await apiClient.post<{ claim: RewardClaim }>(
+      `/reward-claims/${id}/reject`,
+      { reason }
+    );
+    return response.data.claim;
+  },
+
+  fulfil: async (id: string, note?: string): Promise<RewardClaim> => {
+    const response = await apiClient.post<{ claim: RewardClaim }>(
+      `/reward-claims/${id}/fulfil`,
+      { note }
+    );
+    return response.data.claim;
+  },
+
+  cancel: async (id: string): Promise<RewardClaim> => {
+    const response = await apiClient.post<{ claim: RewardClaim }>(
+      `/reward-claims/${id}/cancel`
+    );
+    return response.data.claim;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/reward-claims/${id}/transitions`
+    );
+    return response.data;
+  },
+};
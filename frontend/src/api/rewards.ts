@@ -1,5 +1,10 @@
 import { apiClient } from "./client";
-import { Reward, CreateRewardRequest } from "./types";
+import {
+  Reward,
+  CreateRewardRequest,
+  RewardClaim,
+  StateTransitions,
+} from "./types";
 
 export const rewardsApi = {
   getAll: async (contractId?: string): Promise<Reward[]> => {
@@ -30,8 +35,18 @@ export const rewardsApi = {
     await apiClient.delete(`/rewards/${id}`);
   },
 
-  claim: async (id: string): Promise<Reward> => {
-    const response = await apiClient.post<Reward>(`/rewards/${id}/claim`);
+  claim: async (id: string, note?: string): Promise<RewardClaim> => {
+    const response = await apiClient.post<{ claim: RewardClaim }>(
+      `/rewards/${id}/claim`,
+      { note }
+    );
+    return response.data.claim;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/rewards/${id}/transitions`
+    );
     return response.data;
   },
 };
@@ -0,0 +1,90 @@
+import { apiClient } from "./client";
+import {
+  CreateSavingsGoalRequest,
+  GoalProgress,
+  SavingsGoal,
+  SavingsGoalFilters,
+  StateTransitions,
+  UpdateSavingsGoalRequest,
+} from "./types";
+
+export const savingsGoalsApi = {
+  getAll: async (filters: SavingsGoalFilters = {}): Promise<SavingsGoal[]> => {
+    const response = await apiClient.get<{ goals: SavingsGoal[] }>(
+      "/savings-goals",
+      { params: filters }
+    );
+    return response.data.goals;
+  },
+
+  getById: async (id: string): Promise<SavingsGoal> => {
+    const response = await apiClient.get<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}`
+    );
+    return response.data.goal;
+  },
+
+  getProgress: async (id: string): Promise<GoalProgress> => {
+    const response = await apiClient.get<{ progress: GoalProgress }>(
+      `/savings-goals/${id}/progress`
+    );
+    return response.data.progress;
+  },
+
+  create: async (data: CreateSavingsGoalRequest): Promise<SavingsGoal> => {
+    const response = await apiClient.post<{ goal: SavingsGoal }>(
+      "/savings-goals",
+      data
+    );
+    return response.data.goal;
+  },
+
+  update: async (
+    id: string,
+    data: UpdateSavingsGoalRequest
+  ): Promise<SavingsGoal> => {
+    const response = await apiClient.put<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}`,
+      data
+    );
+    return response.data.goal;
+  },
+
+  deposit: async (id: string, amount: number): Promise<SavingsGoal> => {
+    const response = await apiClient.post<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}/deposit`,
+      { amount }
+    );
+    return response.data.goal;
+  },
+
+  withdraw: async (id: string, amount: number): Promise<SavingsGoal> => {
+    const response = await apiClient.post<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}/withdraw`,
+      { amount }
+    );
+    return response.data.goal;
+  },
+
+  claim: async (id: string): Promise<SavingsGoal> => {
+    const response = await apiClient.post<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}/claim`
+    );
+    return response.data.goal;
+  },
+
+  cancel: async (id: string, reason?: string): Promise<SavingsGoal> => {
+    const response = await apiClient.post<{ goal: SavingsGoal }>(
+      `/savings-goals/${id}/cancel`,
+      { reason }
+    );
+    return response.data.goal;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/savings-goals/${id}/transitions`
+    );
+    return response.data;
+  },
+};
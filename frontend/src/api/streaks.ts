@@ -0,0 +1,68 @@
+import { apiClient } from "./client";
+import {
+  ContractStreaks,
+  CreateStreakBonusRequest,
+  StreakAward,
+  StreakBonus,
+  StreakBonusFilters,
+  UpdateStreakBonusRequest,
+} from "./types";
+
+export const streaksApi = {
+  // Текущие серии ребенка по действующим контрактам или по одному контракту
+  getForChild: async (
+    childId: string,
+    params: { contract_id?: string; timezone?: string } = {}
+  ): Promise<ContractStreaks[]> => {
+    const response = await apiClient.get<{ contracts: ContractStreaks[] }>(
+      `/children/${childId}/streaks`,
+      { params }
+    );
+    return response.data.contracts;
+  },
+
+  getBonuses: async (filters: StreakBonusFilters = {}): Promise<StreakBonus[]> => {
+    const response = await apiClient.get<{ bonuses: StreakBonus[] }>(
+      "/streak-bonuses",
+      { params: filters }
+    );
+    return response.data.bonuses;
+  },
+
+  getBonus: async (id: string): Promise<StreakBonus> => {
+    const response = await apiClient.get<{ bonus: StreakBonus }>(
+      `/streak-bonuses/${id}`
+    );
+    return response.data.bonus;
+  },
+
+  createBonus: async (data: CreateStreakBonusRequest): Promise<StreakBonus> => {
+    const response = await apiClient.post<{ bonus: StreakBonus }>(
+      "/streak-bonuses",
+      data
+    );
+    return response.data.bonus;
+  },
+
+  updateBonus: async (
+    id: string,
+    data: UpdateStreakBonusRequest
+  ): Promise<StreakBonus> => {
+    const response = await apiClient.put<{ bonus: StreakBonus }>(
+      `/streak-bonuses/${id}`,
+      data
+    );
+    return response.data.bonus;
+  },
+
+  deleteBonus: async (id: string): Promise<void> => {
+    await apiClient.delete(`/streak-bonuses/${id}`);
+  },
+
+  getAwards: async (id: string): Promise<StreakAward[]> => {
+    const response = await apiClient.get<{ awards: StreakAward[] }>(
+      `/streak-bonuses/${id}/awards`
+    );
+    return response.data.awards;
+  },
+};
@@ -0,0 +1,49 @@
+import { apiClient } from "./client";
+import { TaskSeries, CreateTaskSeriesRequest } from "./types";
+
+export interface TaskSeriesWithUpcoming {
+  series: TaskSeries;
+  upcoming: string[];
+}
+
+export const taskSeriesApi = {
+  getAll: async (contractId?: string): Promise<TaskSeries[]> => {
+    const response = await apiClient.get<{ series: TaskSeries[] }>(
+      "/task-series",
+      { params: { contract_id: contractId } }
+    );
+    return response.data.series;
+  },
+
+  getById: async (id: string): Promise<TaskSeriesWithUpcoming> => {
+    const response = await apiClient.get<TaskSeriesWithUpcoming>(
+      `/task-series/${id}`
+    );
+    return response.data;
+  },
+
+  create: async (
+    data: CreateTaskSeriesRequest
+  ): Promise<TaskSeriesWithUpcoming> => {
+    const response = await apiClient.post<TaskSeriesWithUpcoming>(
+      "/task-series",
+      data
+    );
+    return response.data;
+  },
+
+  update: async (
+    id: string,
+    data: Partial<Omit<CreateTaskSeriesRequest, "contract_id" | "starts_on">>
+  ): Promise<TaskSeriesWithUpcoming> => {
+    const response = await apiClient.put<TaskSeriesWithUpcoming>(
+      `/task-series/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/task-series/${id}`);
+  },
+};
@@ -1,5 +1,12 @@
 import { apiClient } from "./client";
-import { Task, CreateTaskRequest } from "./types";
+import {
+  Task,
+  CreateTaskRequest,
+  Attachment,
+  TaskSubmission,
+  TaskChecklist,
+  StateTransitions,
+} from "./types";
 
 export const tasksApi = {
   getAll: async (contractId?: string): Promise<Task[]> => {
@@ -34,4 +41,100 @@ export const tasksApi = {
     const response = await apiClient.post<Task>(`/tasks/${id}/complete`);
     return response.data;
   },
+
+  approve: async (id: string, partialCredit?: boolean): Promise<Task> => {
+    const response = await apiClient.post<{ task: Task }>(
+      `/tasks/${id}/approve`,
+      partialCredit === undefined ? {} : { partial_credit: partialCredit }
+    );
+    return response.data.task;
+  },
+
+  addChecklistItem: async (
+    id: string,
+    title: string,
+    position?: number
+  ): Promise<TaskChecklist> => {
+    const response = await apiClient.post<TaskChecklist>(
+      `/tasks/${id}/checklist`,
+      { title, position }
+    );
+    return response.data;
+  },
+
+  updateChecklistItem: async (
+    id: string,
+    itemId: string,
+    data: { title?: string; completed?: boolean }
+  ): Promise<TaskChecklist> => {
+    const response = await apiClient.put<TaskChecklist>(
+      `/tasks/${id}/checklist/${itemId}`,
+      data
+    );
+    return response.data;
+  },
+
+  deleteChecklistItem: async (
+    id: string,
+    itemId: string
+  ): Promise<TaskChecklist> => {
+    const response = await apiClient.delete<TaskChecklist>(
+      `/tasks/${id}/checklist/${itemId}`
+    );
+    return response.data;
+  },
+
+  reorderChecklist: async (
+    id: string,
+    itemIds: string[]
+  ): Promise<TaskChecklist> => {
+    const response = await apiClient.post<TaskChecklist>(
+      `/tasks/${id}/checklist/reorder`,
+      { item_ids: itemIds }
+    );
+    return response.data;
+  },
+
+  getSubmissions: async (id: string): Promise<TaskSubmission[]> => {
+    const response = await apiClient.get<{ submissions: TaskSubmission[] }>(
+      `/tasks/${id}/submissions`
+    );
+    return response.data.submissions;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/tasks/${id}/transitions`
+    );
+    return response.data;
+  },
+
+  getAttachments: async (id: string): Promise<Attachment[]> => {
+    const response = await apiClient.get<{ attachments: Attachment[] }>(
+      `/tasks/${id}/attachments`
+    );
+    return response.data.attachments;
+  },
+
+  uploadAttachment: async (
+    id: string,
+    file: File,
+    submissionId?: string
+  ): Promise<Attachment> => {
+    const form = new FormData();
+    form.append("file", file);
+    const url = submissionId
+      ? `/tasks/${id}/submissions/${submissionId}/attachments`
+      : `/tasks/${id}/attachments`;
+    const response = await apiClient.post<{ attachment: Attachment }>(
+      url,
+      form,
+      { headers: { "Content-Type": "multipart/form-data" } }
+    );
+    return response.data.attachment;
+  },
+
+  deleteAttachment: async (attachmentId: string): Promise<void> => {
+    await apiClient.delete(`/attachments/${attachmentId}`);
+  },
 };
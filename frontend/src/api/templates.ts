@@ -0,0 +1,72 @@
+import { apiClient } from "./client";
+import {
+  Contract,
+  ContractTemplate,
+  CreateTemplateRequest,
+  InstantiateTemplateRequest,
+} from "./types";
+
+export const templatesApi = {
+  getAll: async (
+    source?: "builtin" | "own"
+  ): Promise<ContractTemplate[]> => {
+    const response = await apiClient.get<{ templates: ContractTemplate[] }>(
+      "/contract-templates",
+      { params: { source } }
+    );
+    return response.data.templates;
+  },
+
+  getById: async (id: string): Promise<ContractTemplate> => {
+    const response = await apiClient.get<{ template: ContractTemplate }>(
+      `/contract-templates/${id}`
+    );
+    return response.data.template;
+  },
+
+  create: async (data: CreateTemplateRequest): Promise<ContractTemplate> => {
+    const response = await apiClient.post<{ template: ContractTemplate }>(
+      "/contract-templates",
+      data
+    );
+    return response.data.template;
+  },
+
+  saveFromContract: async (
+    contractId: string,
+    title?: string,
+    description?: string
+  ): Promise<ContractTemplate> => {
+    const response = await apiClient.post<{ template: ContractTemplate }>(
+      "/contract-templates/from-contract",
+      { contract_id: contractId, title, description }
+    );
+    return response.data.template;
+  },
+
+  update: async (
+    id: string,
+    data: Partial<CreateTemplateRequest>
+  ): Promise<ContractTemplate> => {
+    const response = await apiClient.put<{ template: ContractTemplate }>(
+      `/contract-templates/${id}`,
+      data
+    );
+    return response.data.template;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/contract-templates/${id}`);
+  },
+
+  instantiate: async (
+    id: string,
+    data: InstantiateTemplateRequest
+  ): Promise<Contract[]> => {
+    const response = await apiClient.post<{ contracts: Contract[] }>(
+      `/contract-templates/${id}/instantiate`,
+      data
+    );
+    return response.data.contracts;
+  },
+};
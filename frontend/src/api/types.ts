@@ -16,36 +16,259 @@ export interface Contract {
   description?: string;
   parent_id: string;
   child_id: string;
-  status: "active" | "completed" | "cancelled";
+  status:
+    | "draft"
+    | "pending_signature"
+    | "active"
+    | "completed"
+    | "terminated";
   start_date: string;
   end_date?: string;
+  co_signer_id?: string | null;
+  terms_hash?: string;
+  current_version?: number;
+  proposed_at?: string | null;
+  activated_at?: string | null;
+  completed_at?: string | null;
+  terminated_at?: string | null;
+  predecessor_id?: string | null;
+  successor_id?: string | null;
+  auto_renew?: boolean;
+  carry_over_points?: boolean;
+  signatures?: ContractSignature[];
   created_at: string;
   updated_at: string;
 }
 
+export interface ContractSignature {
+  id: string;
+  contract_id: string;
+  signer_id: string;
+  signer?: User;
+  signer_role: "parent" | "child" | "co_parent";
+  terms_hash: string;
+  signed_at: string;
+}
+
+export interface ContractVersion {
+  id: string;
+  contract_id: string;
+  version: number;
+  terms: Record<string, unknown> | null;
+  terms_hash: string;
+  summary?: string;
+  created_by?: string | null;
+  created_at: string;
+}
+
+export interface TermsChange {
+  field: string;
+  change: "added" | "removed" | "changed";
+  item_id?: string;
+  before?: unknown;
+  after?: unknown;
+}
+
+export interface ContractDiff {
+  from: number;
+  to: number;
+  changes: TermsChange[];
+}
+
+export interface ContractSignatures {
+  status: Contract["status"];
+  terms_hash: string;
+  signatures: ContractSignature[];
+  missing_signers: ContractSignature["signer_role"][];
+}
+
 export interface Task {
   id: string;
   contract_id: string;
   title: string;
   description?: string;
-  status: "pending" | "completed" | "failed";
+  status: "pending" | "submitted" | "completed" | "failed" | "cancelled";
   points: number;
   due_date: string;
+  series_id?: string | null;
+  occurrence_date?: string | null;
+  is_exception?: boolean;
+  failed_at?: string | null;
+  partial_credit?: boolean;
+  checklist?: TaskChecklistItem[];
+  // Есть только у задач с чек-листом
+  progress?: TaskProgress;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface TaskChecklistItem {
+  id: string;
+  task_id: string;
+  title: string;
+  position: number;
+  completed_at: string | null;
+  completed_by: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface TaskProgress {
+  done: number;
+  total: number;
+  label: string;
+}
+
+export interface TaskChecklist {
+  checklist: TaskChecklistItem[];
+  progress: TaskProgress;
+}
+
+export interface Attachment {
+  id: string;
+  task_id: string;
+  submission_id: string | null;
+  uploaded_by: string;
+  file_name: string;
+  content_type: string;
+  size: number;
+  checksum: string;
+  // Подписанная ссылка, действует 15 минут; относительно корня API-сервера
+  download_url?: string;
+  created_at: string;
+}
+
+export interface TaskSubmission {
+  id: string;
+  task_id: string;
+  child_id: string;
+  note: string;
+  status: "submitted" | "approved" | "rejected";
+  review_reason: string;
+  reviewed_by: string | null;
+  reviewed_at: string | null;
+  attachments: Attachment[];
   created_at: string;
   updated_at: string;
 }
 
+export interface TaskSeries {
+  id: string;
+  contract_id: string;
+  title: string;
+  description?: string;
+  points: number;
+  rule: string;
+  due_time: string;
+  timezone: string;
+  starts_on: string;
+  ends_on?: string | null;
+  generated_until?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateTaskSeriesRequest {
+  contract_id: string;
+  title: string;
+  description?: string;
+  points: number;
+  rule: string;
+  due_time?: string;
+  timezone?: string;
+  starts_on?: string;
+  ends_on?: string;
+}
+
 export interface Reward {
   id: string;
   contract_id: string;
   title: string;
   description?: string;
   points: number;
-  status: "available" | "claimed" | "completed";
+  status: "available" | "claimed" | "completed" | "expired";
+  expiry_date?: string | null;
+  repeatable?: boolean;
+  stock?: number | null;
+  period_limit?: number;
+  limit_period?: RewardLimitPeriod | "";
+  cooldown_minutes?: number;
+  timezone?: string;
+  // Только у повторяемых наград
+  availability?: RewardAvailability;
+  // Награда ждет срабатывания правила контракта
+  locked?: boolean;
+  // Запись семейного каталога, из которой создана награда
+  catalog_reward_id?: string | null;
+  custom_points?: boolean;
   created_at: string;
   updated_at: string;
 }
 
+export type RewardLimitPeriod = "day" | "week" | "month";
+
+export interface RewardLimits {
+  repeatable?: boolean;
+  stock?: number | null;
+  period_limit?: number;
+  limit_period?: RewardLimitPeriod;
+  cooldown_minutes?: number;
+  timezone?: string;
+}
+
+export interface RewardAvailability {
+  claimed: number;
+  remaining?: number | null;
+  period_claimed: number;
+  period_remaining?: number | null;
+  next_claim_at?: string | null;
+}
+
+export interface FamilyMember {
+  id: string;
+  family_id: string;
+  user_id: string;
+  user: User;
+  role: "parent" | "child";
+  created_at: string;
+}
+
+export interface Family {
+  id: string;
+  name: string;
+  owner_id: string;
+  members: FamilyMember[];
+  created_at: string;
+  updated_at: string;
+}
+
+export interface Guardianship {
+  id: string;
+  family_id: string;
+  parent_id: string;
+  child_id: string;
+  created_at: string;
+}
+
+export interface FamilyInvitation {
+  id: string;
+  family_id: string;
+  code: string;
+  role: "parent" | "child";
+  email?: string;
+  invited_by: string;
+  expires_at: string;
+  accepted_at?: string;
+  revoked_at?: string;
+  status: "pending" | "accepted" | "revoked" | "expired";
+  created_at: string;
+}
+
+export interface ChildInfo {
+  child: User;
+  guardians: User[];
+}
+
 // Типы запросов
 export interface LoginRequest {
   email: string;
@@ -67,6 +290,15 @@ export interface CreateContractRequest {
   child_id: string;
   start_date: string;
   end_date?: string;
+  auto_renew?: boolean;
+  carry_over_points?: boolean;
+}
+
+export interface RenewContractRequest {
+  // По умолчанию используется настройка контракта carry_over_points
+  carry_over?: boolean;
+  propose?: boolean;
+  start_date?: string;
 }
 
 export interface CreateTaskRequest {
@@ -75,6 +307,8 @@ export interface CreateTaskRequest {
   description?: string;
   points: number;
   due_date: string;
+  checklist?: string[];
+  partial_credit?: boolean;
 }
 
 export interface CreateRewardRequest {
@@ -82,6 +316,7 @@ export interface CreateRewardRequest {
   title: string;
   description?: string;
   points: number;
+  limits?: RewardLimits;
 }
 
 // Типы ответов
@@ -94,3 +329,532 @@ export interface ApiResponse<T> {
   data: T;
   message?: string;
 }
+
+export interface ProposalChange {
+  type: "task_points" | "reward_points" | "reward_add";
+  task_id?: string;
+  reward_id?: string;
+  title?: string;
+  description?: string;
+  points: number;
+}
+
+export interface ContractProposal {
+  id: string;
+  contract_id: string;
+  parent_proposal_id?: string | null;
+  author_id: string;
+  author?: User;
+  author_role: "parent" | "child";
+  status: "open" | "accepted" | "rejected" | "countered" | "withdrawn";
+  message?: string;
+  changes: ProposalChange[];
+  base_version: number;
+  applied_version?: number | null;
+  response_message?: string;
+  resolved_by?: string | null;
+  resolved_at?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateProposalRequest {
+  message?: string;
+  changes: ProposalChange[];
+}
+
+export interface TaskBlueprint {
+  title: string;
+  description?: string;
+  points: number;
+  // День от начала контракта: срок разовой задачи или начало повторений
+  due_day: number;
+  due_time?: string;
+  // Правило повторения; без него задача разовая
+  rule?: string;
+  timezone?: string;
+  checklist?: string[];
+  partial_credit?: boolean;
+}
+
+export interface RewardBlueprint {
+  title: string;
+  description?: string;
+  points: number;
+}
+
+export interface ContractTemplate {
+  id: string;
+  owner_id: string | null;
+  title: string;
+  description: string;
+  duration_days: number;
+  tasks: TaskBlueprint[];
+  rewards: RewardBlueprint[];
+  is_builtin: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateTemplateRequest {
+  title: string;
+  description?: string;
+  duration_days: number;
+  tasks: TaskBlueprint[];
+  rewards: RewardBlueprint[];
+}
+
+export interface InstantiateTemplateRequest {
+  child_ids: string[];
+  start_date?: string;
+  title?: string;
+}
+
+export type ContractAction = "propose" | "withdraw" | "complete" | "terminate";
+
+export interface ContractTransitionRequest {
+  action: ContractAction;
+  // Обязательна при расторжении
+  reason?: string;
+}
+
+export interface StateTransition {
+  id: string;
+  entity_type: "contract" | "task" | "reward" | "reward_claim";
+  entity_id: string;
+  action: string;
+  from_status: string;
+  to_status: string;
+  actor_id: string | null;
+  actor_role: "parent" | "child" | "system";
+  reason: string;
+  created_at: string;
+}
+
+export interface StateTransitions {
+  status: string;
+  // Действия, доступные текущему пользователю
+  available: string[];
+  transitions: StateTransition[];
+}
+
+export interface RewardClaim {
+  id: string;
+  reward_id: string;
+  reward?: Reward;
+  contract_id: string;
+  child_id: string;
+  status: "pending" | "approved" | "rejected" | "cancelled" | "fulfilled";
+  // Баллы, зарезервированные при запросе
+  points: number;
+  note: string;
+  reviewed_by: string | null;
+  reviewed_at: string | null;
+  rejection_reason: string;
+  fulfilled_by: string | null;
+  fulfilled_at: string | null;
+  fulfilment_note: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface RewardClaimFilters {
+  status?: RewardClaim["status"];
+  reward_id?: string;
+  contract_id?: string;
+  child_id?: string;
+}
+
+export interface RewardCategory {
+  id: string;
+  family_id: string;
+  name: string;
+  position: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CatalogReward {
+  id: string;
+  family_id: string;
+  category_id?: string | null;
+  category?: RewardCategory;
+  title: string;
+  description?: string;
+  points: number;
+  // Подписанная ссылка на изображение, действует ограниченное время
+  image_url?: string;
+  created_by: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CatalogRewardFilters {
+  family_id?: string;
+  category_id?: string;
+  q?: string;
+}
+
+export interface CreateCatalogRewardRequest {
+  family_id: string;
+  category_id?: string | null;
+  title: string;
+  description?: string;
+  points: number;
+}
+
+export interface UpdateCatalogRewardRequest {
+  // Пустая строка убирает награду из категории
+  category_id?: string;
+  title?: string;
+  description?: string;
+  points?: number;
+}
+
+export interface CreateRewardCategoryRequest {
+  family_id: string;
+  name: string;
+  position?: number;
+}
+
+export type WishStatus =
+  | "pending"
+  | "priced"
+  | "accepted"
+  | "rejected"
+  | "withdrawn";
+
+// Насколько ребенок близок к желанию по балансу одного контракта
+export interface WishProgress {
+  cost: number;
+  contract_id?: string;
+  balance: number;
+  missing: number;
+  percent: number;
+}
+
+export interface Wish {
+  id: string;
+  child_id: string;
+  title: string;
+  description?: string;
+  link?: string;
+  suggested_points: number;
+  // От 1 до 5, 5 - самое желанное
+  priority: number;
+  status: WishStatus;
+  price?: number | null;
+  price_note?: string;
+  priced_by?: string | null;
+  priced_at?: string | null;
+  reviewed_by?: string | null;
+  reviewed_at?: string | null;
+  rejection_reason?: string;
+  contract_id?: string | null;
+  reward_id?: string | null;
+  reward?: Reward;
+  progress?: WishProgress;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface WishFilters {
+  // open - ожидающие решения родителя
+  status?: WishStatus | "open";
+  child_id?: string;
+}
+
+export interface CreateWishRequest {
+  title: string;
+  description?: string;
+  link?: string;
+  suggested_points: number;
+  priority?: number;
+}
+
+export interface AcceptWishRequest {
+  contract_id: string;
+  points?: number;
+}
+
+export type SavingsGoalStatus = "active" | "reached" | "claimed" | "cancelled";
+
+// Накопленные баллы и прогноз достижения цели
+export interface GoalProgress {
+  target: number;
+  saved: number;
+  percent: number;
+  // Доступный баланс, который еще можно отложить
+  available: number;
+  daily_rate: number;
+  projected_at: string | null;
+  on_track?: boolean;
+}
+
+export interface SavingsGoal {
+  id: string;
+  child_id: string;
+  contract_id: string;
+  title: string;
+  reward_id?: string | null;
+  reward?: Reward;
+  target_points?: number | null;
+  deadline?: string | null;
+  status: SavingsGoalStatus;
+  claim_id?: string | null;
+  created_by: string;
+  reached_at?: string | null;
+  closed_at?: string | null;
+  progress?: GoalProgress;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface SavingsGoalFilters {
+  // open - активные и достигнутые
+  status?: SavingsGoalStatus | "open";
+  child_id?: string;
+  contract_id?: string;
+}
+
+export interface CreateSavingsGoalRequest {
+  // Только для родителя
+  child_id?: string;
+  contract_id: string;
+  title?: string;
+  // Цель - награда контракта или сумма баллов
+  reward_id?: string;
+  target_points?: number;
+  deadline?: string;
+}
+
+export interface UpdateSavingsGoalRequest {
+  title?: string;
+  target_points?: number;
+  deadline?: string;
+}
+
+export type RuleTrigger =
+  | "task_submitted"
+  | "task_completed"
+  | "task_failed"
+  | "task_overdue"
+  | "schedule";
+
+export type RuleScope = "task" | "day" | "week" | "month" | "contract";
+
+export type RuleAction = "award" | "deduct" | "unlock_reward";
+
+export interface RuleCondition {
+  // task.points, task.days_late, tasks.completed, tasks.completion_percent, event.weekday...
+  field: string;
+  op: "eq" | "ne" | "gt" | "gte" | "lt" | "lte";
+  value: number;
+}
+
+export interface ContractRule {
+  id: string;
+  contract_id: string;
+  name: string;
+  trigger: RuleTrigger;
+  scope: RuleScope;
+  conditions: RuleCondition[];
+  action: RuleAction;
+  points: number;
+  reward_id?: string | null;
+  timezone: string;
+  enabled: boolean;
+  created_by?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface RuleFiring {
+  id: string;
+  rule_id: string;
+  contract_id: string;
+  child_id: string;
+  task_id?: string | null;
+  key: string;
+  trigger: RuleTrigger;
+  action: RuleAction;
+  // У штрафа со знаком минус
+  points: number;
+  reward_id?: string | null;
+  fired_at: string;
+}
+
+export interface RuleOutcome {
+  at: string;
+  trigger: RuleTrigger;
+  key: string;
+  task_id?: string;
+  action: RuleAction;
+  points: number;
+  reward_id?: string;
+}
+
+export interface RuleDryRun {
+  outcomes: RuleOutcome[];
+  // Штрафы здесь не ограничиваются балансом ребенка
+  points: number;
+}
+
+export interface ContractRuleFilters {
+  contract_id?: string;
+  trigger?: RuleTrigger;
+}
+
+export interface CreateContractRuleRequest {
+  contract_id: string;
+  name: string;
+  trigger: RuleTrigger;
+  scope?: RuleScope;
+  conditions?: RuleCondition[];
+  action: RuleAction;
+  points?: number;
+  reward_id?: string;
+  timezone?: string;
+  enabled?: boolean;
+}
+
+export type UpdateContractRuleRequest = Partial<
+  Omit<CreateContractRuleRequest, "contract_id">
+>;
+
+export type StreakScope = "day" | "series";
+
+export type StreakBonusKind = "multiplier" | "milestone";
+
+export interface StreakBonus {
+  id: string;
+  contract_id: string;
+  scope: StreakScope;
+  // Только для series; пустое значение - любая повторяющаяся задача
+  series_id?: string | null;
+  kind: StreakBonusKind;
+  length: number;
+  // 150 - это x1.5
+  multiplier_percent: number;
+  points: number;
+  timezone: string;
+  created_by?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface StreakAward {
+  id: string;
+  bonus_id: string;
+  contract_id: string;
+  child_id: string;
+  task_id: string;
+  key: string;
+  length: number;
+  points: number;
+  awarded_at: string;
+}
+
+export interface Streak {
+  current: number;
+  best: number;
+  last_kept_at?: string;
+  multiplier_percent?: number;
+}
+
+export interface SeriesStreak extends Streak {
+  series_id: string;
+  title: string;
+}
+
+export interface ContractStreaks {
+  contract_id: string;
+  title: string;
+  timezone: string;
+  days: Streak;
+  series: SeriesStreak[];
+}
+
+export interface StreakBonusFilters {
+  contract_id?: string;
+  series_id?: string;
+}
+
+export interface CreateStreakBonusRequest {
+  contract_id: string;
+  scope: StreakScope;
+  series_id?: string;
+  kind: StreakBonusKind;
+  length: number;
+  multiplier_percent?: number;
+  points?: number;
+  timezone?: string;
+}
+
+export interface UpdateStreakBonusRequest {
+  length?: number;
+  multiplier_percent?: number;
+  points?: number;
+  timezone?: string;
+}
+
+export type AchievementMetric =
+  | "tasks.completed"
+  | "tasks.on_time"
+  | "streak.days"
+  | "points.earned"
+  | "rewards.received"
+  | "goals.reached"
+  | "goals.max_target"
+  | "contracts.completed";
+
+export interface Achievement {
+  id: string;
+  // Пусто у встроенных достижений
+  family_id?: string | null;
+  code?: string | null;
+  title: string;
+  description: string;
+  icon: string;
+  metric: AchievementMetric;
+  threshold: number;
+  created_by?: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AchievementProgress extends Achievement {
+  value: number;
+  percent: number;
+  unlocked: boolean;
+  awarded_at?: string;
+  backfilled?: boolean;
+}
+
+export interface ChildAchievements {
+  child_id: string;
+  achievements: AchievementProgress[];
+  unlocked: number;
+}
+
+export interface AchievementFilters {
+  family_id?: string;
+  builtin?: boolean;
+}
+
+export interface CreateAchievementRequest {
+  family_id: string;
+  title: string;
+  description?: string;
+  icon?: string;
+  metric: AchievementMetric;
+  threshold: number;
+}
+
+export interface UpdateAchievementRequest {
+  title?: string;
+  description?: string;
+  icon?: string;
+  metric?: AchievementMetric;
+  threshold?: number;
+}
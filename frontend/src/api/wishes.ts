@@ -0,0 +1,73 @@
+import { apiClient } from "./client";
+import {
+  AcceptWishRequest,
+  CreateWishRequest,
+  StateTransitions,
+  Wish,
+  WishFilters,
+} from "./types";
+
+export const wishesApi = {
+  getAll: async (filters: WishFilters = {}): Promise<Wish[]> => {
+    const response = await apiClient.get<{ wishes: Wish[] }>("/wishes", {
+      params: filters,
+    });
+    return response.data.wishes;
+  },
+
+  getById: async (id: string): Promise<Wish> => {
+    const response = await apiClient.get<{ wish: Wish }>(`/wishes/${id}`);
+    return response.data.wish;
+  },
+
+  create: async (data: CreateWishRequest): Promise<Wish> => {
+    const response = await apiClient.post<{ wish: Wish }>("/wishes", data);
+    return response.data.wish;
+  },
+
+  update: async (
+    id: string,
+    data: Partial<CreateWishRequest>
+  ): Promise<Wish> => {
+    const response = await apiClient.put<{ wish: Wish }>(`/wishes/${id}`, data);
+    return response.data.wish;
+  },
+
+  price: async (id: string, points: number, note?: string): Promise<Wish> => {
+    const response = await apiClient.post<{ wish: Wish }>(
+      `/wishes/${id}/price`,
+      { points, note }
+    );
+    return response.data.wish;
+  },
+
+  accept: async (id: string, data: AcceptWishRequest): Promise<Wish> => {
+    const response = await apiClient.post<{ wish: Wish }>(
+      `/wishes/${id}/accept`,
+      data
+    );
+    return response.data.wish;
+  },
+
+  reject: async (id: string, reason: string): Promise<Wish> => {
+    const response = await apiClient.post<{ wish: Wish }>(
+      `/wishes/${id}/reject`,
+      { reason }
+    );
+    return response.data.wish;
+  },
+
+  withdraw: async (id: string): Promise<Wish> => {
+    const response = await apiClient.post<{ wish: Wish }>(
+      `/wishes/${id}/withdraw`
+    );
+    return response.data.wish;
+  },
+
+  getTransitions: async (id: string): Promise<StateTransitions> => {
+    const response = await apiClient.get<StateTransitions>(
+      `/wishes/${id}/transitions`
+    );
+    return response.data;
+  },
+};